@@ -0,0 +1,18 @@
+// Package actor guarda en el context quien hace el request, para que los repositorios lo puedan
+// anotar en el historial de cambios sin que cada metodo tenga que recibirlo.
+package actor
+
+import "context"
+
+// tipo propio para la key, como en requestid
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, nombre string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, nombre)
+}
+
+// FromContext devuelve quien hace el request o "" si no se sabe.
+func FromContext(ctx context.Context) string {
+	nombre, _ := ctx.Value(ctxKey{}).(string)
+	return nombre
+}
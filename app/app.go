@@ -0,0 +1,464 @@
+// Package app arma la aplicacion completa: crea las dependencias (pool, repos, handlers),
+// registra las rutas y se encarga de cerrar todo al final.
+package app
+
+import (
+	"api-libros/config"
+	"api-libros/db"
+	"api-libros/handlers"
+	"api-libros/middleware"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type App struct {
+	cfg  config.Config
+	pool *pgxpool.Pool // nil cuando se inyecta un repo que no usa postgres
+
+	libros     repository.LibrosRepository
+	autores    repository.AutoresRepository
+	ejemplares repository.EjemplaresRepository
+	socios     repository.SociosRepository
+	prestamos  repository.PrestamosRepository
+	reservas   repository.ReservasRepository
+	multas     repository.MultasRepository
+
+	mux     *http.ServeMux
+	handler http.Handler // mux con los middlewares aplicados
+	closers []func()     // se ejecutan en orden inverso en Close, como los defer
+
+	inFlight     atomic.Int64 // requests que entraron y todavia no respondieron, para el resumen del shutdown
+	shuttingDown atomic.Bool  // a partir de que arranca el shutdown /readyz responde 503
+}
+
+type Option func(a *App)
+
+// WithLibrosRepository reemplaza el repo de postgres por otro (por ej. el de memoria en los tests).
+// Si se usa, New no abre ningun pool.
+func WithLibrosRepository(repo repository.LibrosRepository) Option {
+	return func(a *App) {
+		a.libros = repo
+	}
+}
+
+// WithAutoresRepository reemplaza el repo de autores. Si no se usa y se inyecto un repo de libros,
+// se usa el de memoria apoyado en ese repo de libros.
+func WithAutoresRepository(repo repository.AutoresRepository) Option {
+	return func(a *App) {
+		a.autores = repo
+	}
+}
+
+// WithEjemplaresRepository reemplaza el repo de ejemplares. Mismo criterio que WithAutoresRepository.
+func WithEjemplaresRepository(repo repository.EjemplaresRepository) Option {
+	return func(a *App) {
+		a.ejemplares = repo
+	}
+}
+
+// WithSociosRepository reemplaza el repo de socios. Mismo criterio que WithAutoresRepository.
+func WithSociosRepository(repo repository.SociosRepository) Option {
+	return func(a *App) {
+		a.socios = repo
+	}
+}
+
+// WithPrestamosRepository reemplaza el repo de prestamos. Si no se usa, el de memoria se engancha
+// a los repos de ejemplares y socios, asi que esos tambien tienen que ser los de memoria.
+func WithPrestamosRepository(repo repository.PrestamosRepository) Option {
+	return func(a *App) {
+		a.prestamos = repo
+	}
+}
+
+// WithReservasRepository reemplaza el repo de reservas. Si no se usa, el de memoria se engancha
+// al de prestamos, que tambien tiene que ser el de memoria.
+func WithReservasRepository(repo repository.ReservasRepository) Option {
+	return func(a *App) {
+		a.reservas = repo
+	}
+}
+
+// WithMultasRepository reemplaza el repo de multas. Mismo criterio que WithReservasRepository.
+func WithMultasRepository(repo repository.MultasRepository) Option {
+	return func(a *App) {
+		a.multas = repo
+	}
+}
+
+// New crea todas las dependencias. Si algo falla cierra lo que ya habia abierto.
+// App implementa http.Handler, se le puede pasar directo a un http.Server o a httptest.
+func New(ctx context.Context, cfg config.Config, opts ...Option) (*App, error) {
+	a := &App{cfg: cfg}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.libros == nil {
+		pool, err := db.New(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		a.pool = pool
+		a.closers = append(a.closers, pool.Close)
+		a.libros = repository.NewPostgresLibrosRepo(pool)
+		if a.autores == nil {
+			a.autores = repository.NewPostgresAutoresRepo(pool)
+		}
+		if a.ejemplares == nil {
+			a.ejemplares = repository.NewPostgresEjemplaresRepo(pool)
+		}
+		if a.socios == nil {
+			a.socios = repository.NewPostgresSociosRepo(pool)
+		}
+		if a.prestamos == nil {
+			a.prestamos = repository.NewPostgresPrestamosRepo(pool)
+		}
+		if a.reservas == nil {
+			a.reservas = repository.NewPostgresReservasRepo(pool)
+		}
+		if a.multas == nil {
+			a.multas = repository.NewPostgresMultasRepo(pool)
+		}
+	}
+
+	if a.autores == nil {
+		a.autores = repository.NewMemoryAutoresRepo(a.libros)
+	}
+	if a.ejemplares == nil {
+		a.ejemplares = repository.NewMemoryEjemplaresRepo(a.libros)
+	}
+	if a.socios == nil {
+		a.socios = repository.NewMemorySociosRepo()
+	}
+	if a.prestamos == nil {
+		ejemplares, okEjemplares := a.ejemplares.(*repository.MemoryEjemplaresRepo)
+		socios, okSocios := a.socios.(*repository.MemorySociosRepo)
+		if !okEjemplares || !okSocios {
+			a.Close()
+			return nil, errors.New("con ejemplares o socios que no son los de memoria hay que pasar WithPrestamosRepository")
+		}
+		a.prestamos = repository.NewMemoryPrestamosRepo(ejemplares, socios)
+	}
+	if a.reservas == nil {
+		prestamos, ok := a.prestamos.(*repository.MemoryPrestamosRepo)
+		if !ok {
+			a.Close()
+			return nil, errors.New("con un repo de prestamos que no es el de memoria hay que pasar WithReservasRepository")
+		}
+		a.reservas = repository.NewMemoryReservasRepo(prestamos)
+	}
+	if a.multas == nil {
+		prestamos, ok := a.prestamos.(*repository.MemoryPrestamosRepo)
+		if !ok {
+			a.Close()
+			return nil, errors.New("con un repo de prestamos que no es el de memoria hay que pasar WithMultasRepository")
+		}
+		a.multas = repository.NewMemoryMultasRepo(prestamos)
+	}
+
+	a.routes()
+
+	return a, nil
+}
+
+func (a *App) routes() {
+	librosOpts := []handlers.Option{
+		handlers.WithPageSize(a.cfg.DefaultPageSize, a.cfg.MaxPageSize),
+		handlers.WithFuzzyThreshold(a.cfg.FuzzyThreshold),
+		handlers.WithEjemplares(a.ejemplares),
+		handlers.WithRequireIfMatch(a.cfg.RequireIfMatch),
+		handlers.WithCacheControl(a.cfg.CacheControl),
+		handlers.WithAdminToken(a.cfg.AdminToken),
+		handlers.WithBatchMax(a.cfg.BatchMax),
+	}
+	if a.cfg.CursorSecret != "" {
+		librosOpts = append(librosOpts, handlers.WithCursorSecret([]byte(a.cfg.CursorSecret)))
+	} else {
+		slog.Warn("LIBROS_CURSOR_SECRET vacio: los cursores de paginado no van a servir despues de reiniciar ni entre instancias")
+	}
+
+	librosHandler := handlers.NewLibrosHandler(a.libros, librosOpts...)
+	autoresHandler := handlers.NewAutoresHandler(a.autores, a.cfg.DefaultPageSize, a.cfg.MaxPageSize)
+	ejemplaresHandler := handlers.NewEjemplaresHandler(a.ejemplares, a.politica(), time.Now)
+	sociosHandler := handlers.NewSociosHandler(a.socios, a.cfg.DefaultPageSize, a.cfg.MaxPageSize)
+	prestamosHandler := handlers.NewPrestamosHandler(a.prestamos, a.politica(), time.Now, a.cfg.DefaultPageSize, a.cfg.MaxPageSize)
+	reservasHandler := handlers.NewReservasHandler(a.reservas, a.politica(), time.Now, a.cfg.DefaultPageSize, a.cfg.MaxPageSize)
+	multasHandler := handlers.NewMultasHandler(a.multas, a.politica(), time.Now, a.cfg.DefaultPageSize, a.cfg.MaxPageSize)
+
+	// sin pool (repo inyectado, ej. memoria) no hay nada externo que chequear
+	var checker handlers.ReadinessChecker = handlers.ReadinessFunc(func(ctx context.Context) (any, error) {
+		return nil, nil
+	})
+	if a.pool != nil {
+		checker = db.Checker{Pool: a.pool}
+	}
+
+	healthHandler := handlers.NewHealthHandler(a.notShuttingDown(checker), a.cfg.ReadyTimeout)
+
+	a.mux = http.NewServeMux()
+	a.mux.HandleFunc("/healthz", healthHandler.Healthz)
+	a.mux.HandleFunc("/readyz", healthHandler.Readyz)
+	a.mux.HandleFunc("/libros", librosHandler.Libros)
+	a.mux.HandleFunc("/libros/suggest", librosHandler.Suggest) // mas especifico que /libros/, no llega a LibrosByID
+	a.mux.HandleFunc("/libros/papelera", librosHandler.Papelera)
+	a.mux.HandleFunc("/libros:batch", librosHandler.Batch)
+	a.mux.HandleFunc("/libros/", librosHandler.LibrosByID)
+	a.mux.HandleFunc("/libros/{id}/restaurar", librosHandler.Restaurar)
+	a.mux.HandleFunc("/libros/{id}/historial", librosHandler.Historial)
+	a.mux.HandleFunc("/libros/{id}/revertir/{rev}", librosHandler.Revertir)
+	a.mux.HandleFunc("/libros/{id}/autores", autoresHandler.LibroAutores)
+	a.mux.HandleFunc("/libros/{id}/ejemplares", ejemplaresHandler.Ejemplares)
+	a.mux.HandleFunc("/libros/{id}/ejemplares/{ejemplar}", ejemplaresHandler.EjemplarByID)
+	a.mux.HandleFunc("/libros/{id}/reservas", reservasHandler.LibroReservas)
+	a.mux.HandleFunc("/autores", autoresHandler.Autores)
+	a.mux.HandleFunc("/autores/{id}", autoresHandler.AutorByID)
+	a.mux.HandleFunc("/autores/{id}/libros", autoresHandler.AutorLibros)
+	a.mux.HandleFunc("/socios", sociosHandler.Socios)
+	a.mux.HandleFunc("/socios/{id}", sociosHandler.SocioByID)
+	a.mux.HandleFunc("/socios/{id}/multas", multasHandler.SocioMultas)
+	a.mux.HandleFunc("/socios/{id}/multas/saldo", multasHandler.Saldo)
+	a.mux.HandleFunc("/socios/{id}/multas/pagos", multasHandler.Pagos)
+	a.mux.HandleFunc("/socios/{id}/multas/condonaciones", multasHandler.Condonaciones)
+	a.mux.HandleFunc("/prestamos", prestamosHandler.Prestamos)
+	a.mux.HandleFunc("/prestamos/vencidos", prestamosHandler.Vencidos) // literal, le gana a /prestamos/{id}
+	a.mux.HandleFunc("/prestamos/{id}", prestamosHandler.PrestamoByID)
+	a.mux.HandleFunc("/prestamos/{id}/devolucion", prestamosHandler.Devolucion)
+	a.mux.HandleFunc("/prestamos/{id}/renovacion", prestamosHandler.Renovacion)
+	a.mux.HandleFunc("/reservas", reservasHandler.Reservas)
+	a.mux.HandleFunc("/reservas/{id}", reservasHandler.ReservaByID)
+	a.mux.HandleFunc("/reservas/{id}/cancelacion", reservasHandler.Cancelacion)
+
+	// el request id va primero para que el access log y el recover ya lo tengan;
+	// recover va adentro del access log para que un panic quede logueado como 500
+	logger := slog.Default()
+	a.handler = middleware.Chain(a.mux,
+		middleware.RequestID,
+		middleware.Actor,
+		middleware.AccessLog(logger),
+		middleware.Recover(logger),
+	)
+}
+
+func (a *App) politica() models.PoliticaPrestamo {
+	return models.PoliticaPrestamo{
+		Dias:            a.cfg.PrestamoDias,
+		MaxRenovaciones: a.cfg.MaxRenovaciones,
+		DiasRetiro:      a.cfg.ReservaDiasRetiro,
+		DeudaMaxima:     a.cfg.MultaDeudaMaxima,
+	}
+}
+
+func (a *App) politicaMulta() models.PoliticaMulta {
+	pm := models.PoliticaMulta{
+		PorDia:     a.cfg.MultaPorDia,
+		DiasGracia: a.cfg.MultaDiasGracia,
+		Tope:       a.cfg.MultaTope,
+	}
+
+	feriados, _ := config.ParseFeriados(a.cfg.MultaFeriados) // ya lo chequeo Validate
+	for _, f := range feriados {
+		pm.Feriados = append(pm.Feriados, models.FechaDe(f))
+	}
+
+	return pm
+}
+
+// atenderReservas corre cada cfg.ReservasIntervalo hasta que se cancela ctx: vence las reservas que no
+// se retiraron a tiempo y reparte los ejemplares libres entre las que esperan. Devolver y cancelar ya
+// reparten en el momento, esto cubre los plazos que vencen solos y los ejemplares nuevos.
+func (a *App) atenderReservas(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.ReservasIntervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		hoy := models.FechaDe(time.Now())
+		cambiadas, err := a.reservas.Atender(ctx, hoy, a.politica().RetirarHasta(hoy))
+		if err != nil && ctx.Err() == nil {
+			slog.Error("no se pudieron atender las reservas", "error", err)
+			continue
+		}
+		if len(cambiadas) > 0 {
+			slog.Info("reservas atendidas", "cambiadas", len(cambiadas))
+		}
+	}
+}
+
+// calcularMultas corre cada cfg.MultasIntervalo hasta que se cancela ctx y deja al dia los cargos de
+// los prestamos atrasados. Como el calculo depende solo del dia, correrlo de mas no cambia nada.
+func (a *App) calcularMultas(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.MultasIntervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cambiadas, err := a.multas.Calcular(ctx, models.FechaDe(time.Now()), a.politicaMulta())
+		if err != nil && ctx.Err() == nil {
+			slog.Error("no se pudieron calcular las multas", "error", err)
+			continue
+		}
+		if len(cambiadas) > 0 {
+			slog.Info("multas calculadas", "cambiadas", len(cambiadas))
+		}
+	}
+}
+
+// purgarPapelera corre cada cfg.PurgaIntervalo hasta que se cancela ctx y borra de verdad los libros
+// que llevan mas de cfg.PapeleraRetencion en la papelera (con sus ejemplares, prestamos y reservas).
+func (a *App) purgarPapelera(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.PurgaIntervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := a.libros.Purgar(ctx, time.Now().Add(-a.cfg.PapeleraRetencion))
+		if err != nil && ctx.Err() == nil {
+			slog.Error("no se pudo purgar la papelera", "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("papelera purgada", "libros", n)
+		}
+	}
+}
+
+// notShuttingDown hace fallar el chequeo en cuanto empieza el shutdown, asi el orquestador
+// deja de mandar trafico mientras drenamos los requests que quedan.
+func (a *App) notShuttingDown(next handlers.ReadinessChecker) handlers.ReadinessChecker {
+	return handlers.ReadinessFunc(func(ctx context.Context) (any, error) {
+		if a.shuttingDown.Load() {
+			return nil, errors.New("el servidor se esta apagando")
+		}
+		return next.Ready(ctx)
+	})
+}
+
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.inFlight.Add(1)
+	defer a.inFlight.Add(-1)
+
+	a.handler.ServeHTTP(w, r)
+}
+
+// Run escucha en cfg.Addr y atiende requests hasta que se cancela ctx (ver Serve).
+func (a *App) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.cfg.Addr)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Servidor REST corriendo en %s", ln.Addr())
+	return a.Serve(ctx, ln)
+}
+
+// Serve atiende requests en ln hasta que se cancela ctx. Ahi /readyz pasa a responder 503 y durante
+// cfg.ShutdownDelay se siguen atendiendo requests, para que el balanceador vea el 503 y saque la
+// instancia antes de que se rechacen conexiones. Despues deja de aceptar conexiones, espera hasta
+// cfg.ShutdownTimeout a que terminen los requests en curso y cierra todas las dependencias (el pool
+// incluido). Devuelve error si el server fallo o si hubo que cortar requests porque se vencio el plazo.
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{
+		Handler:      a,
+		ReadTimeout:  a.cfg.ReadTimeout,
+		WriteTimeout: a.cfg.WriteTimeout,
+		IdleTimeout:  a.cfg.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	// los jobs usan el pool, asi que tienen que terminar antes de a.Close
+	jobCtx, stopJobs := context.WithCancel(ctx)
+	var jobs sync.WaitGroup
+	for _, job := range []func(context.Context){a.atenderReservas, a.calcularMultas, a.purgarPapelera} {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			job(jobCtx)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		// el server se cayo solo, no por un shutdown
+		stopJobs()
+		jobs.Wait()
+		a.Close()
+		return err
+	case <-ctx.Done():
+	}
+
+	a.shuttingDown.Store(true)
+	stopJobs()
+	jobs.Wait()
+
+	if a.cfg.ShutdownDelay > 0 {
+		log.Printf("apagando: /readyz responde 503, se cierra el listener en %s", a.cfg.ShutdownDelay)
+		time.Sleep(a.cfg.ShutdownDelay)
+	}
+
+	pending := a.inFlight.Load()
+	log.Printf("apagando: no se aceptan mas conexiones, %d requests en curso", pending)
+
+	// contexto nuevo: ctx ya esta cancelado y el plazo para drenar arranca recien ahora
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := srv.Shutdown(shutdownCtx)
+
+	left := a.inFlight.Load()
+	if shutdownErr != nil {
+		srv.Close() //corta las conexiones que quedaron colgadas
+		log.Printf("shutdown: se vencio el plazo de %s, %d requests drenados, %d cortados", a.cfg.ShutdownTimeout, pending-left, left)
+	} else {
+		log.Printf("shutdown: %d requests drenados", pending)
+	}
+
+	// recien ahora que nadie usa el pool lo cierro
+	a.Close()
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return shutdownErr
+}
+
+// Close libera todas las dependencias que creo New. Se puede llamar mas de una vez.
+func (a *App) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+	a.closers = nil
+}
@@ -0,0 +1,667 @@
+package app
+
+import (
+	"api-libros/config"
+	"api-libros/models"
+	"api-libros/repository"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestApp(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	a, err := New(context.Background(), config.Default(), WithLibrosRepository(repository.NewMemoryLibrosRepo()))
+	if err != nil {
+		t.Fatalf("error creando la app: %v", err)
+	}
+	t.Cleanup(a.Close)
+
+	srv := httptest.NewServer(a)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestApp_CRUD(t *testing.T) {
+	srv := newTestApp(t)
+
+	// crear
+	body, _ := json.Marshal(models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965})
+	resp, err := http.Post(srv.URL+"/libros", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("error en POST: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST: status esperado 201, vino %d", resp.StatusCode)
+	}
+
+	var creado models.Libro
+	if err := json.NewDecoder(resp.Body).Decode(&creado); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+
+	// leer por id
+	resp, err = http.Get(srv.URL + "/libros/1")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET: status esperado 200, vino %d", resp.StatusCode)
+	}
+
+	var leido models.Libro
+	if err := json.NewDecoder(resp.Body).Decode(&leido); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+
+	if leido != creado {
+		t.Fatalf("GET devolvio %+v, esperaba %+v", leido, creado)
+	}
+
+	// listar
+	resp, err = http.Get(srv.URL + "/libros")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var libros []models.Libro
+	if err := json.NewDecoder(resp.Body).Decode(&libros); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+
+	if len(libros) != 1 {
+		t.Fatalf("esperaba 1 libro, vinieron %d", len(libros))
+	}
+}
+
+func TestApp_RutaInexistente(t *testing.T) {
+	srv := newTestApp(t)
+
+	resp, err := http.Get(srv.URL + "/editoriales")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status esperado 404, vino %d", resp.StatusCode)
+	}
+}
+
+func TestApp_LibroAutores(t *testing.T) {
+	srv := newTestApp(t)
+
+	body, _ := json.Marshal(models.LibroInput{Titulo: "Dune", Autor: "frank herbert", Ano: 1965})
+	resp, err := http.Post(srv.URL+"/libros", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("error en POST: %v", err)
+	}
+	resp.Body.Close()
+
+	// "frank herbert" ya es el autor 1, sale del texto del libro
+	body, _ = json.Marshal(models.AutorInput{Nombre: "Kevin J. Anderson"})
+	resp, err = http.Post(srv.URL+"/autores", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("error en POST: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /autores: status esperado 201, vino %d", resp.StatusCode)
+	}
+
+	// /libros/{id}/autores no tiene que caer en LibrosByID
+	body, _ = json.Marshal(models.LibroAutoresInput{Autores: []int{1, 2}})
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/libros/1/autores", bytes.NewReader(body))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error en PUT: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /libros/1/autores: status esperado 200, vino %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/autores/2/libros")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var libros []models.Libro
+	if err := json.NewDecoder(resp.Body).Decode(&libros); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+
+	if len(libros) != 1 || libros[0].Autor != "frank herbert, Kevin J. Anderson" {
+		t.Fatalf("esperaba Dune con los dos autores, vino %+v", libros)
+	}
+}
+
+func TestApp_Suggest(t *testing.T) {
+	srv := newTestApp(t)
+
+	// /libros/suggest no tiene que caer en LibrosByID (que responderia 400 por id invalido)
+	resp, err := http.Get(srv.URL + "/libros/suggest?q=dune")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status esperado 200, vino %d", resp.StatusCode)
+	}
+}
+
+func TestApp_ISBN(t *testing.T) {
+	srv := newTestApp(t)
+
+	// /libros/isbn/{isbn} tiene que llegar a la busqueda por isbn, no parsearse como id (400)
+	// ni confundirse con /libros/{id}/autores
+	resp, err := http.Get(srv.URL + "/libros/isbn/978-0-306-40615-7")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("status esperado 404, vino %d", resp.StatusCode)
+	}
+}
+
+// TestApp_Papelera: /libros/papelera no tiene que caer en LibrosByID ni /libros/{id}/restaurar en el id
+func TestApp_Papelera(t *testing.T) {
+	srv := newTestApp(t)
+
+	do := func(method, path string) int {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(`{"titulo":"Dune","autor":"Frank Herbert","ano":1965}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error en %s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	steps := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/libros", http.StatusCreated},
+		{http.MethodDelete, "/libros/1", http.StatusNoContent},
+		{http.MethodGet, "/libros/1", http.StatusNotFound},
+		{http.MethodGet, "/libros/papelera", http.StatusOK},
+		{http.MethodGet, "/libros?include_deleted=true", http.StatusForbidden}, // sin LIBROS_ADMIN_TOKEN nadie es admin
+		{http.MethodPost, "/libros/1/restaurar", http.StatusOK},
+		{http.MethodGet, "/libros/1", http.StatusOK},
+	}
+	for _, s := range steps {
+		if got := do(s.method, s.path); got != s.want {
+			t.Fatalf("%s %s: status esperado %d, vino %d", s.method, s.path, s.want, got)
+		}
+	}
+}
+
+// TestApp_Historial: el historial guarda quien hizo cada cambio (X-Actor) y en que request
+func TestApp_Historial(t *testing.T) {
+	srv := newTestApp(t)
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("X-Actor", "ana@biblioteca.org")
+		req.Header.Set("X-Request-ID", "req-"+method)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error en %s %s: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	do(http.MethodPost, "/libros", `{"titulo":"Dune","autor":"Frank Herbert","ano":1965}`)
+	do(http.MethodPatch, "/libros/1", `{"ano":1966}`)
+
+	if resp := do(http.MethodPost, "/libros/1/revertir/1", ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /libros/1/revertir/1: status esperado 200, vino %d", resp.StatusCode)
+	}
+
+	resp := do(http.MethodGet, "/libros/1/historial", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /libros/1/historial: status esperado 200, vino %d", resp.StatusCode)
+	}
+
+	var revs []models.Revision
+	if err := json.NewDecoder(resp.Body).Decode(&revs); err != nil {
+		t.Fatalf("error decodificando: %v", err)
+	}
+
+	want := []string{models.RevisionRevertir, models.RevisionModificar, models.RevisionCrear}
+	if len(revs) != len(want) {
+		t.Fatalf("esperaba %d revisiones, vinieron %+v", len(want), revs)
+	}
+	for i, op := range want {
+		if revs[i].Operacion != op || revs[i].Actor != "ana@biblioteca.org" || revs[i].RequestID == "" {
+			t.Fatalf("revision %d: esperaba %s de ana con request id, vino %+v", i, op, revs[i])
+		}
+	}
+	if revs[1].RequestID != "req-PATCH" {
+		t.Fatalf("el PATCH tiene que quedar con su request id, vino %q", revs[1].RequestID)
+	}
+}
+
+func TestApp_Ejemplares(t *testing.T) {
+	srv := newTestApp(t)
+
+	body, _ := json.Marshal(models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965})
+	resp, err := http.Post(srv.URL+"/libros", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("error en POST: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Post(srv.URL+"/libros/1/ejemplares", "application/json",
+		strings.NewReader(`{"codigo_barras":"DUNE-1","ubicacion":"A-1","estado":"bueno","fecha_adquisicion":"2020-03-01"}`))
+	if err != nil {
+		t.Fatalf("error en POST: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /libros/1/ejemplares: status esperado 201, vino %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/libros/1?disponibilidad=true")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var libro models.Libro
+	if err := json.NewDecoder(resp.Body).Decode(&libro); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+
+	if libro.Disponibilidad == nil || libro.Disponibilidad.Total != 1 {
+		t.Fatalf("esperaba 1 ejemplar en la disponibilidad, vino %+v", libro.Disponibilidad)
+	}
+}
+
+func TestApp_Prestamos(t *testing.T) {
+	srv := newTestApp(t)
+
+	post := func(path, body string) int {
+		t.Helper()
+
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("error en POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	post("/libros", `{"titulo":"Dune","autor":"Frank Herbert","ano":1965}`)
+	post("/libros/1/ejemplares", `{"codigo_barras":"DUNE-1","ubicacion":"A-1","estado":"bueno","fecha_adquisicion":"2020-03-01"}`)
+	post("/socios", `{"nombre":"Ana Pérez","email":"ana@example.com"}`)
+
+	if got := post("/prestamos", `{"ejemplar_id":1,"socio_id":1}`); got != http.StatusCreated {
+		t.Fatalf("POST /prestamos: status esperado 201, vino %d", got)
+	}
+	if got := post("/prestamos", `{"ejemplar_id":1,"socio_id":1}`); got != http.StatusConflict {
+		t.Fatalf("POST /prestamos con el ejemplar prestado: status esperado 409, vino %d", got)
+	}
+
+	// /prestamos/vencidos no tiene que caer en /prestamos/{id} (que responderia 400 por id invalido)
+	resp, err := http.Get(srv.URL + "/prestamos/vencidos")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var vencidos []models.Prestamo
+	if err := json.NewDecoder(resp.Body).Decode(&vencidos); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(vencidos) != 0 {
+		t.Fatalf("esperaba 200 sin vencidos, vino %d %+v", resp.StatusCode, vencidos)
+	}
+
+	if got := post("/prestamos/1/renovacion", ""); got != http.StatusOK {
+		t.Fatalf("POST /prestamos/1/renovacion: status esperado 200, vino %d", got)
+	}
+	if got := post("/prestamos/1/devolucion", ""); got != http.StatusOK {
+		t.Fatalf("POST /prestamos/1/devolucion: status esperado 200, vino %d", got)
+	}
+	if got := post("/prestamos", `{"ejemplar_id":1,"socio_id":1}`); got != http.StatusCreated {
+		t.Fatalf("POST /prestamos despues de devolver: status esperado 201, vino %d", got)
+	}
+}
+
+// TestApp_Reservas: un ejemplar nuevo queda apartado al crearlo para el primero de la cola
+func TestApp_Reservas(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShutdownDelay = 0
+
+	url, cancel, done := startServe(t, cfg, repository.NewMemoryLibrosRepo())
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	post := func(path, body string) int {
+		t.Helper()
+
+		resp, err := http.Post(url+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("error en POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	post("/libros", `{"titulo":"Dune","autor":"Frank Herbert","ano":1965}`)
+	post("/libros/1/ejemplares", `{"codigo_barras":"DUNE-1","ubicacion":"A-1","estado":"bueno","fecha_adquisicion":"2020-03-01"}`)
+	post("/socios", `{"nombre":"Ana Pérez","email":"ana@example.com"}`)
+	post("/socios", `{"nombre":"Bruno Díaz","email":"bruno@example.com"}`)
+
+	if got := post("/libros/1/reservas", `{"socio_id":2}`); got != http.StatusConflict {
+		t.Fatalf("reservar con ejemplares libres: status esperado 409, vino %d", got)
+	}
+	post("/prestamos", `{"ejemplar_id":1,"socio_id":1}`)
+	if got := post("/libros/1/reservas", `{"socio_id":2}`); got != http.StatusCreated {
+		t.Fatalf("POST /libros/1/reservas: status esperado 201, vino %d", got)
+	}
+
+	post("/libros/1/ejemplares", `{"codigo_barras":"DUNE-2","ubicacion":"A-1","estado":"bueno","fecha_adquisicion":"2024-03-01"}`)
+
+	resp, err := http.Get(url + "/reservas/1")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+
+	var r models.Reserva
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+	if r.Estado != models.ReservaDisponible || r.EjemplarID != 2 {
+		t.Fatalf("esperaba apartado el ejemplar 2, vino %+v", r)
+	}
+
+	if got := post("/prestamos", `{"ejemplar_id":2,"socio_id":1}`); got != http.StatusConflict {
+		t.Fatalf("prestar un ejemplar apartado para otro: status esperado 409, vino %d", got)
+	}
+
+	if got := post("/reservas/1/cancelacion", ""); got != http.StatusOK {
+		t.Fatalf("POST /reservas/1/cancelacion: status esperado 200, vino %d", got)
+	}
+}
+
+// TestApp_Multas: las rutas de la cuenta de un socio. Un socio recien creado no debe nada
+func TestApp_Multas(t *testing.T) {
+	srv := newTestApp(t)
+
+	post := func(path, body string) int {
+		t.Helper()
+
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("error en POST %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	post("/socios", `{"nombre":"Ana Pérez","email":"ana@example.com"}`)
+
+	// /socios/{id}/multas/saldo no tiene que caer en /socios/{id}/multas
+	resp, err := http.Get(srv.URL + "/socios/1/multas/saldo")
+	if err != nil {
+		t.Fatalf("error en GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var saldo models.SaldoMultas
+	if err := json.NewDecoder(resp.Body).Decode(&saldo); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || saldo != (models.SaldoMultas{SocioID: 1}) {
+		t.Fatalf("esperaba 200 con saldo 0, vino %d %+v", resp.StatusCode, saldo)
+	}
+
+	if got := post("/socios/1/multas/pagos", `{"monto":100}`); got != http.StatusConflict {
+		t.Fatalf("POST /socios/1/multas/pagos sin deuda: status esperado 409, vino %d", got)
+	}
+	if got := post("/socios/1/multas/condonaciones", `{"monto":100}`); got != http.StatusBadRequest {
+		t.Fatalf("POST /socios/1/multas/condonaciones sin nota: status esperado 400, vino %d", got)
+	}
+	if got := post("/socios/2/multas/pagos", `{"monto":100}`); got != http.StatusNotFound {
+		t.Fatalf("POST /socios/2/multas/pagos: status esperado 404, vino %d", got)
+	}
+}
+
+func TestApp_Health(t *testing.T) {
+	srv := newTestApp(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("error en GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: status esperado 200, vino %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestApp_CloseDosVeces(t *testing.T) {
+	a, err := New(context.Background(), config.Default(), WithLibrosRepository(repository.NewMemoryLibrosRepo()))
+	if err != nil {
+		t.Fatalf("error creando la app: %v", err)
+	}
+
+	a.Close()
+	a.Close()
+}
+
+// slowRepo frena GetAll hasta que se cierra release, para tener un request en curso durante el shutdown
+type slowRepo struct {
+	repository.LibrosRepository
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *slowRepo) GetAll(ctx context.Context, f models.LibroFilter) ([]models.Libro, error) {
+	close(r.started)
+	<-r.release
+	return r.LibrosRepository.GetAll(ctx, f)
+}
+
+func startServe(t *testing.T, cfg config.Config, repo repository.LibrosRepository) (string, context.CancelFunc, chan error) {
+	t.Helper()
+
+	a, err := New(context.Background(), cfg, WithLibrosRepository(repo))
+	if err != nil {
+		t.Fatalf("error creando la app: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error abriendo listener: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Serve(ctx, ln) }()
+
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestApp_Serve_DrenaRequestsEnCurso(t *testing.T) {
+	repo := &slowRepo{
+		LibrosRepository: repository.NewMemoryLibrosRepo(),
+		started:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+
+	cfg := config.Default()
+	cfg.ShutdownDelay = 0
+
+	url, cancel, done := startServe(t, cfg, repo)
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url + "/libros")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+
+	<-repo.started
+	cancel() // como si llegara SIGTERM con el request a mitad de camino
+
+	// el server tiene que seguir esperando al request, no volver todavia
+	select {
+	case err := <-done:
+		t.Fatalf("Serve volvio antes de drenar el request: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(repo.release)
+
+	if got := <-status; got != http.StatusOK {
+		t.Fatalf("el request en curso tenia que terminar bien, vino status %d", got)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("shutdown con error: %v", err)
+	}
+}
+
+func TestApp_Serve_PlazoVencido(t *testing.T) {
+	repo := &slowRepo{
+		LibrosRepository: repository.NewMemoryLibrosRepo(),
+		started:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	defer close(repo.release)
+
+	cfg := config.Default()
+	cfg.ShutdownDelay = 0
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+
+	url, cancel, done := startServe(t, cfg, repo)
+
+	go func() {
+		resp, err := http.Get(url + "/libros")
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-repo.started
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("esperaba DeadlineExceeded, vino %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve no respeto el shutdown timeout")
+	}
+}
+
+// TestApp_Serve_ReadyzAntesDeCerrar: apenas llega el shutdown /readyz responde 503, pero durante
+// ShutdownDelay el server sigue atendiendo para que el balanceador lo vea
+func TestApp_Serve_ReadyzAntesDeCerrar(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShutdownDelay = 300 * time.Millisecond
+
+	url, cancel, done := startServe(t, cfg, repository.NewMemoryLibrosRepo())
+	cancel()
+
+	deadline := time.Now().Add(cfg.ShutdownDelay / 2)
+	for {
+		resp, err := http.Get(url + "/readyz")
+		if err != nil {
+			t.Fatalf("el listener se cerro antes del shutdown delay: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("/readyz seguia respondiendo %d durante el shutdown", resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := http.Get(url + "/libros")
+	if err != nil {
+		t.Fatalf("durante el shutdown delay se tienen que seguir atendiendo requests: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("shutdown con error: %v", err)
+	}
+}
+
+// TestApp_Batch: /libros:batch llega al handler (no se lo come /libros/) y las operaciones dejan
+// su revision con el actor del request
+func TestApp_Batch(t *testing.T) {
+	srv := newTestApp(t)
+
+	body := `[{"op":"crear","libro":{"titulo":"Dune","autor":"Frank Herbert","ano":1965}},{"op":"modificar","id":1,"libro":{"ano":1966}}]`
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/libros:batch?atomic=true", strings.NewReader(body))
+	req.Header.Set("X-Actor", "ana@biblioteca.org")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error en POST /libros:batch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /libros:batch: status esperado 200, vino %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(srv.URL + "/libros/1/historial")
+	if err != nil {
+		t.Fatalf("error en GET /libros/1/historial: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var revs []models.Revision
+	if err := json.NewDecoder(resp.Body).Decode(&revs); err != nil {
+		t.Fatalf("error decodificando: %v", err)
+	}
+	if len(revs) != 2 || revs[0].Operacion != models.RevisionModificar || revs[0].Actor != "ana@biblioteca.org" {
+		t.Fatalf("esperaba la revision de modificar de ana, vino %+v", revs)
+	}
+}
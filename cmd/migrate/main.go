@@ -0,0 +1,87 @@
+// migrate aplica, deshace o lista las migraciones embebidas en el paquete db.
+//
+//	go run ./cmd/migrate up
+//	go run ./cmd/migrate down [pasos]   (por defecto 1)
+//	go run ./cmd/migrate status
+package main
+
+import (
+	"api-libros/config"
+	"api-libros/db"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "uso: migrate up | down [pasos] | status")
+		os.Exit(2)
+	}
+
+	// la misma configuracion que la API (LIBROS_DATABASE_URL, LIBROS_CONFIG, etc), sin flags
+	cfg, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configuración inválida:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	poolConfig, err := cfg.PoolConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		log.Fatalf("No se pudo conectar a PostgreSQL: %v", err)
+	}
+	defer pool.Close()
+
+	switch os.Args[1] {
+	case "up":
+		n, err := db.Migrate(ctx, pool)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("migraciones aplicadas: %d\n", n)
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps <= 0 {
+				log.Fatalf("pasos invalido: %q", os.Args[2])
+			}
+		}
+
+		n, err := db.Rollback(ctx, pool, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("migraciones deshechas: %d\n", n)
+
+	case "status":
+		status, err := db.Status(ctx, pool)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, s := range status {
+			estado := "pendiente"
+			if s.Applied {
+				estado = "aplicada " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, estado)
+		}
+
+	default:
+		fmt.Fprintf(os.Stderr, "comando desconocido %q\n", os.Args[1])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(k string) string { return values[k] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "libros.env")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("error escribiendo archivo: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if cfg != Default() {
+		t.Fatalf("esperaba los defaults, vino %+v", cfg)
+	}
+}
+
+func TestLoad_Prioridad(t *testing.T) {
+	file := writeFile(t, `
+# comentario
+LIBROS_ADDR=:7000
+LIBROS_DEFAULT_PAGE_SIZE=10
+LIBROS_LOG_LEVEL="debug"
+`)
+
+	cfg, err := Load(
+		[]string{"-config", file, "-addr", ":9000"},
+		env(map[string]string{
+			"LIBROS_ADDR":              ":8000",
+			"LIBROS_DEFAULT_PAGE_SIZE": "20",
+		}),
+	)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if cfg.Addr != ":9000" {
+		t.Fatalf("el flag tiene que pisar al entorno y al archivo: addr=%q", cfg.Addr)
+	}
+
+	if cfg.DefaultPageSize != 20 {
+		t.Fatalf("el entorno tiene que pisar al archivo: default page size=%d", cfg.DefaultPageSize)
+	}
+
+	if cfg.LogLevel != slog.LevelDebug {
+		t.Fatalf("el archivo tiene que pisar al default: log level=%v", cfg.LogLevel)
+	}
+}
+
+func TestLoad_ArchivoDesdeEntorno(t *testing.T) {
+	file := writeFile(t, "LIBROS_READ_TIMEOUT=3s\n")
+
+	cfg, err := Load(nil, env(map[string]string{"LIBROS_CONFIG": file}))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if cfg.ReadTimeout != 3*time.Second {
+		t.Fatalf("read timeout esperado 3s, vino %v", cfg.ReadTimeout)
+	}
+}
+
+func TestLoad_ReportaTodosLosErrores(t *testing.T) {
+	file := writeFile(t, "LIBROS_NO_EXISTE=1\nesto no es clave valor\n")
+
+	_, err := Load(
+		[]string{"-config", file, "-db-max-conns", "muchas"},
+		env(map[string]string{
+			"LIBROS_READ_TIMEOUT":      "rapido",
+			"LIBROS_DEFAULT_PAGE_SIZE": "100",
+			"LIBROS_MAX_PAGE_SIZE":     "10",
+			"LIBROS_LOG_LEVEL":         "verbose",
+			"LIBROS_ADDR":              "sin-puerto",
+		}),
+	)
+	if err == nil {
+		t.Fatal("esperaba error")
+	}
+
+	msg := err.Error()
+	for _, want := range []string{
+		"LIBROS_NO_EXISTE",
+		"CLAVE=valor",
+		"-db-max-conns",
+		"LIBROS_READ_TIMEOUT",
+		"LIBROS_LOG_LEVEL",
+		"max page size",
+		"addr invalida",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("el error no menciona %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"ok", func(c *Config) {}, ""},
+		{"dsn invalida", func(c *Config) { c.DatabaseURL = "postgres://:x" }, "database url"},
+		{"max conns 0", func(c *Config) { c.DBMaxConns = 0 }, "max conns"},
+		{"min mayor que max", func(c *Config) { c.DBMinConns = 20 }, "min conns"},
+		{"timeout 0", func(c *Config) { c.WriteTimeout = 0 }, "write timeout"},
+		{"shutdown delay 0", func(c *Config) { c.ShutdownDelay = 0 }, ""},
+		{"shutdown delay negativo", func(c *Config) { c.ShutdownDelay = -time.Second }, "shutdown delay"},
+		{"page size 0", func(c *Config) { c.DefaultPageSize = 0 }, "default page size"},
+		{"fuzzy threshold mayor a 1", func(c *Config) { c.FuzzyThreshold = 1.5 }, "fuzzy threshold"},
+		{"batch max 0", func(c *Config) { c.BatchMax = 0 }, "batch max"},
+		{"retencion 0", func(c *Config) { c.PapeleraRetencion = 0 }, "papelera retencion"},
+		{"intervalo de purga 0", func(c *Config) { c.PurgaIntervalo = 0 }, "purga intervalo"},
+		{"prestamo de 0 dias", func(c *Config) { c.PrestamoDias = 0 }, "prestamo dias"},
+		{"renovaciones negativas", func(c *Config) { c.MaxRenovaciones = -1 }, "max renovaciones"},
+		{"retiro de 0 dias", func(c *Config) { c.ReservaDiasRetiro = 0 }, "reserva dias retiro"},
+		{"intervalo de reservas 0", func(c *Config) { c.ReservasIntervalo = 0 }, "reservas intervalo"},
+		{"multa por dia negativa", func(c *Config) { c.MultaPorDia = -1 }, "multa por dia"},
+		{"gracia negativa", func(c *Config) { c.MultaDiasGracia = -1 }, "multa dias gracia"},
+		{"tope negativo", func(c *Config) { c.MultaTope = -1 }, "multa tope"},
+		{"deuda maxima negativa", func(c *Config) { c.MultaDeudaMaxima = -1 }, "multa deuda maxima"},
+		{"feriados", func(c *Config) { c.MultaFeriados = "2024-12-25, 2025-01-01" }, ""},
+		{"feriado invalido", func(c *Config) { c.MultaFeriados = "2024-12-25,navidad" }, "multa feriados"},
+		{"intervalo de multas 0", func(c *Config) { c.MultasIntervalo = 0 }, "multas intervalo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.mutate(&cfg)
+
+			err := cfg.Validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error inesperado: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("esperaba error con %q, vino %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseFeriados(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		want    []time.Time
+		wantErr bool
+	}{
+		{"vacio", "", nil, false},
+		{"uno", "2024-12-25", []time.Time{time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)}, false},
+		{"con espacios y coma al final", " 2024-12-25 , 2025-01-01,", []time.Time{
+			time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, false},
+		{"invalido", "25/12/2024", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFeriados(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, esperaba error: %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("esperaba %v, vino %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestPoolConfig_UmbralFuzzy: cada conexion arranca con el umbral de pg_trgm, /libros/suggest no lo setea
+func TestPoolConfig_UmbralFuzzy(t *testing.T) {
+	cfg := Default()
+	cfg.FuzzyThreshold = 0.45
+
+	pc, err := cfg.PoolConfig()
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if got := pc.ConnConfig.RuntimeParams["pg_trgm.word_similarity_threshold"]; got != "0.45" {
+		t.Fatalf("esperaba el umbral 0.45 en la conexion, vino %q", got)
+	}
+}
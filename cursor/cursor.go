@@ -0,0 +1,69 @@
+// Package cursor convierte un models.Cursor en un token opaco y firmado para mandarle al cliente.
+//
+// El token es base64url(json) + "." + base64url(hmac-sha256). El cliente no tiene que
+// interpretarlo ni armarlo; si lo modifica la firma no coincide y Decode lo rechaza.
+package cursor
+
+import (
+	"api-libros/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("cursor invalido")
+
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+func (s *Signer) Encode(c models.Cursor) string {
+	payload, _ := json.Marshal(c) //un struct con ints y bools no puede fallar al serializar
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.sign(payload))
+}
+
+func (s *Signer) Decode(token string) (models.Cursor, error) {
+	var c models.Cursor
+	enc := base64.RawURLEncoding
+
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return c, ErrInvalid
+	}
+
+	payload, err := enc.DecodeString(p)
+	if err != nil {
+		return c, ErrInvalid
+	}
+
+	gotSig, err := enc.DecodeString(sig)
+	if err != nil {
+		return c, ErrInvalid
+	}
+
+	// hmac.Equal compara en tiempo constante, asi no se puede adivinar la firma midiendo tiempos
+	if !hmac.Equal(gotSig, s.sign(payload)) {
+		return c, ErrInvalid
+	}
+
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return c, ErrInvalid
+	}
+
+	return c, nil
+}
+
+func (s *Signer) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,56 @@
+package cursor
+
+import (
+	"api-libros/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSigner_RoundTrip(t *testing.T) {
+	s := NewSigner([]byte("secreto"))
+
+	for _, c := range []models.Cursor{
+		{ID: 1},
+		{ID: 42, Backward: true},
+		{ID: 7, Keys: []string{"1965", "Dune"}, Sort: "-ano,titulo"},
+	} {
+		got, err := s.Decode(s.Encode(c))
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Fatalf("esperaba %+v, vino %+v", c, got)
+		}
+	}
+}
+
+func TestSigner_Rechaza(t *testing.T) {
+	s := NewSigner([]byte("secreto"))
+	token := s.Encode(models.Cursor{ID: 10})
+	payload, sig, _ := strings.Cut(token, ".")
+
+	otro := NewSigner([]byte("otro secreto")).Encode(models.Cursor{ID: 10})
+	adulterado := NewSigner([]byte("x")).Encode(models.Cursor{ID: 999})
+	adulteradoPayload, _, _ := strings.Cut(adulterado, ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"vacio", ""},
+		{"sin firma", payload},
+		{"firma de otro secreto", otro},
+		{"payload cambiado", adulteradoPayload + "." + sig},
+		{"base64 invalido", "%%%." + sig},
+		{"basura", "hola"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Decode(tt.token); err != ErrInvalid {
+				t.Fatalf("esperaba ErrInvalid, vino %v", err)
+			}
+		})
+	}
+}
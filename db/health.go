@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolStats es lo que nos interesa de pgxpool.Stat() para mostrar en /readyz.
+type PoolStats struct {
+	TotalConns    int32 `json:"total_conns"`
+	AcquiredConns int32 `json:"acquired_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	MaxConns      int32 `json:"max_conns"`
+	AcquireCount  int64 `json:"acquire_count"`
+	EmptyAcquire  int64 `json:"empty_acquire_count"` // veces que hubo que esperar porque no habia conexiones libres
+}
+
+type Readiness struct {
+	Pool             PoolStats `json:"pool"`
+	MigrationVersion int64     `json:"migration_version"`
+}
+
+// Checker revisa que la base este lista para atender requests.
+type Checker struct {
+	Pool *pgxpool.Pool
+}
+
+// Ready consigue una conexion del pool, verifica que libros se pueda consultar y lee la version
+// de las migraciones. Aunque falle devuelve las estadisticas del pool, que sirven para diagnosticar.
+func (c Checker) Ready(ctx context.Context) (any, error) {
+	stat := c.Pool.Stat()
+
+	result := Readiness{
+		Pool: PoolStats{
+			TotalConns:    stat.TotalConns(),
+			AcquiredConns: stat.AcquiredConns(),
+			IdleConns:     stat.IdleConns(),
+			MaxConns:      stat.MaxConns(),
+			AcquireCount:  stat.AcquireCount(),
+			EmptyAcquire:  stat.EmptyAcquireCount(),
+		},
+	}
+
+	conn, err := c.Pool.Acquire(ctx)
+	if err != nil {
+		return result, fmt.Errorf("no se pudo obtener una conexion: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT 1 FROM libros LIMIT 1"); err != nil {
+		return result, fmt.Errorf("no se puede consultar libros: %w", err)
+	}
+
+	if err := conn.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&result.MigrationVersion); err != nil {
+		return result, fmt.Errorf("no se pudo leer la version de las migraciones: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Las migraciones viven en db/migrations con el formato NNNN_nombre.up.sql / NNNN_nombre.down.sql.
+// Quedan embebidas en el binario, asi no hay que copiar archivos sql al deployar.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// numero cualquiera pero fijo: todas las instancias que arrancan a la vez piden el mismo lock
+const migrationsLockID = 727274
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrate aplica todas las migraciones pendientes, cada una en su propia transaccion.
+// Devuelve cuantas aplico.
+func Migrate(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+
+	err = withMigrationsLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx,
+					"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+					m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migracion %04d_%s: %w", m.Version, m.Name, err)
+			}
+
+			applied++
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// Rollback deshace las ultimas `steps` migraciones aplicadas (steps <= 0 deshace todas).
+// Devuelve cuantas deshizo.
+func Rollback(ctx context.Context, pool *pgxpool.Pool, steps int) (int, error) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return 0, err
+	}
+
+	byVersion := map[int64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	rolledBack := 0
+
+	err = withMigrationsLock(ctx, pool, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int64, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] }) //de la mas nueva a la mas vieja
+
+		if steps > 0 && steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, v := range versions {
+			m, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("la version %d esta aplicada pero no existe en el binario", v)
+			}
+
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, m.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rollback %04d_%s: %w", m.Version, m.Name, err)
+			}
+
+			rolledBack++
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status lista todas las migraciones conocidas y si estan aplicadas o no.
+func Status(ctx context.Context, pool *pgxpool.Pool) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	done, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := done[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &at
+		}
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// Version devuelve la ultima migracion aplicada (0 si no hay ninguna).
+func Version(ctx context.Context, pool *pgxpool.Pool) (int64, error) {
+	var v int64
+	err := pool.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&v)
+	return v, err
+}
+
+// Reset deja la base como recien creada: deshace todo y vuelve a aplicar todas las migraciones.
+// Pensado para los tests, NO usar contra una base con datos reales.
+func Reset(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := Rollback(ctx, pool, 0); err != nil {
+		return err
+	}
+	_, err := Migrate(ctx, pool)
+	return err
+}
+
+// withMigrationsLock toma un advisory lock de sesion para que dos instancias no migren a la vez.
+// Uso una sola conexion porque el lock pertenece a la sesion que lo pidio.
+func withMigrationsLock(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockID); err != nil {
+		return err
+	}
+	// context.Background: si ctx ya se cancelo igual quiero liberar el lock
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockID)
+
+	if _, err := conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	done := map[int64]time.Time{}
+
+	// Status puede llamarse antes de la primera migracion, cuando la tabla todavia no existe
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return done, nil
+	}
+
+	rows, err := conn.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v int64
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		done[v] = at
+	}
+
+	return done, rows.Err()
+}
+
+// loadMigrations lee los .sql, arma los pares up/down y los ordena por version.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, file := range files {
+		base := path.Base(file)
+
+		var direction string
+		switch {
+		case strings.HasSuffix(base, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(base, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migracion %s: el nombre tiene que terminar en .up.sql o .down.sql", base)
+		}
+
+		stem := strings.TrimSuffix(base, "."+direction+".sql")
+		num, name, ok := strings.Cut(stem, "_")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("migracion %s: el formato es NNNN_nombre.%s.sql", base, direction)
+		}
+
+		version, err := strconv.ParseInt(num, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migracion %s: version invalida %q", base, num)
+		}
+
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migracion %d: nombres distintos en up y down (%q, %q)", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migracion %04d_%s: falta el archivo up o down", m.Version, m.Name)
+		}
+		result = append(result, *m)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Embebidas(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		t.Fatalf("las migraciones embebidas no son validas: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("no se embebio ninguna migracion")
+	}
+
+	if migrations[0].Version != 1 || !strings.Contains(migrations[0].Up, "libros") {
+		t.Fatalf("la primera migracion tiene que crear libros: %+v", migrations[0])
+	}
+
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version <= migrations[i-1].Version {
+			t.Fatalf("migraciones desordenadas: %d despues de %d", migrations[i].Version, migrations[i-1].Version)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	sql := &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		wantErr  bool
+		wantVers []int64
+	}{
+		{
+			name: "ordena por version y no por nombre",
+			files: fstest.MapFS{
+				"migrations/0010_b.up.sql":   sql,
+				"migrations/0010_b.down.sql": sql,
+				"migrations/0002_a.up.sql":   sql,
+				"migrations/0002_a.down.sql": sql,
+			},
+			wantVers: []int64{2, 10},
+		},
+		{
+			name:     "sin migraciones",
+			files:    fstest.MapFS{},
+			wantVers: []int64{},
+		},
+		{
+			name: "falta el down",
+			files: fstest.MapFS{
+				"migrations/0001_a.up.sql": sql,
+			},
+			wantErr: true,
+		},
+		{
+			name: "nombres distintos",
+			files: fstest.MapFS{
+				"migrations/0001_a.up.sql":   sql,
+				"migrations/0001_b.down.sql": sql,
+			},
+			wantErr: true,
+		},
+		{
+			name: "version invalida",
+			files: fstest.MapFS{
+				"migrations/abc_a.up.sql":   sql,
+				"migrations/abc_a.down.sql": sql,
+			},
+			wantErr: true,
+		},
+		{
+			name: "sin direccion",
+			files: fstest.MapFS{
+				"migrations/0001_a.sql": sql,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("esperaba error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if len(migrations) != len(tt.wantVers) {
+				t.Fatalf("esperaba %d migraciones, vinieron %d", len(tt.wantVers), len(migrations))
+			}
+
+			for i, m := range migrations {
+				if m.Version != tt.wantVers[i] {
+					t.Fatalf("posicion %d: esperaba version %d, vino %d", i, tt.wantVers[i], m.Version)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+-- una tabla adoptada (sin el comentario del up, incluidas las de antes de que el up lo pusiera) tiene
+-- datos que esta migracion no creo: se deja como esta en vez de borrarla al volver a la version 0
+DO $$
+BEGIN
+    IF obj_description(to_regclass('libros'), 'pg_class') = 'creada por la migracion 0001' THEN
+        DROP TABLE libros;
+    END IF;
+END $$;
@@ -0,0 +1,14 @@
+-- las bases que ya estaban andando crearon la tabla a mano: esa se adopta como esta. Solo la que crea
+-- esta migracion queda marcada con el comentario, asi el down sabe si la puede borrar.
+DO $$
+BEGIN
+    IF to_regclass('libros') IS NULL THEN
+        CREATE TABLE libros (
+            id     SERIAL PRIMARY KEY,
+            titulo TEXT NOT NULL,
+            autor  TEXT NOT NULL,
+            ano    INT  NOT NULL
+        );
+        COMMENT ON TABLE libros IS 'creada por la migracion 0001';
+    END IF;
+END $$;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS libros_ano_id_idx;
+DROP INDEX IF EXISTS libros_autor_id_idx;
+DROP INDEX IF EXISTS libros_titulo_id_idx;
+
+DROP COLLATION IF EXISTS espanol;
@@ -0,0 +1,8 @@
+-- collation de ICU en español: las vocales con tilde van junto a las sin tilde y la ñ despues de la n.
+-- Con la collation por defecto (o "C") "Árbol" quedaba despues de "Zorro".
+CREATE COLLATION IF NOT EXISTS espanol (provider = icu, locale = 'es');
+
+-- un indice por columna ordenable, con id al final porque es el desempate del ORDER BY
+CREATE INDEX IF NOT EXISTS libros_titulo_id_idx ON libros (titulo COLLATE espanol, id);
+CREATE INDEX IF NOT EXISTS libros_autor_id_idx ON libros (autor COLLATE espanol, id);
+CREATE INDEX IF NOT EXISTS libros_ano_id_idx ON libros (ano, id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS libros_busqueda_idx;
+ALTER TABLE libros DROP COLUMN IF EXISTS busqueda;
+
+DROP TEXT SEARCH CONFIGURATION IF EXISTS simple_unaccent;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS espanol_unaccent;
+
+-- la extension unaccent queda: puede usarla otra cosa de la base
@@ -0,0 +1,23 @@
+-- busqueda de texto completo sobre titulo y autor (GET /libros?q=...)
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- copias de spanish y simple que ademas sacan las tildes: "dostoievski" encuentra "Dostoievski"
+-- y "rebelion" encuentra "Rebelión". Se usan tanto para indexar como para ts_headline.
+CREATE TEXT SEARCH CONFIGURATION espanol_unaccent (COPY = spanish);
+ALTER TEXT SEARCH CONFIGURATION espanol_unaccent
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+
+CREATE TEXT SEARCH CONFIGURATION simple_unaccent (COPY = simple);
+ALTER TEXT SEARCH CONFIGURATION simple_unaccent
+    ALTER MAPPING FOR hword, hword_part, word WITH unaccent, simple;
+
+-- spanish encuentra variantes ("crimenes" ~ "crimen"), simple encuentra nombres propios tal cual
+-- (el stemmer deja "orwell" como "orwel"). El titulo pesa mas que el autor en el ranking.
+ALTER TABLE libros ADD COLUMN busqueda tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('espanol_unaccent', titulo), 'A') ||
+    setweight(to_tsvector('simple_unaccent', titulo), 'A') ||
+    setweight(to_tsvector('espanol_unaccent', autor), 'B') ||
+    setweight(to_tsvector('simple_unaccent', autor), 'B')
+) STORED;
+
+CREATE INDEX libros_busqueda_idx ON libros USING GIN (busqueda);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS libros_autor_trgm_idx;
+DROP INDEX IF EXISTS libros_titulo_trgm_idx;
+
+DROP FUNCTION IF EXISTS f_unaccent(text);
+
+-- pg_trgm queda instalada: puede usarla otra cosa de la base
@@ -0,0 +1,12 @@
+-- busqueda tolerante a errores de tipeo (?q=...&fuzzy=true) y /libros/suggest
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- unaccent() es STABLE (depende del diccionario) y no se puede usar en un indice.
+-- Este wrapper fija el diccionario y se declara IMMUTABLE, que es lo que pide el indice.
+CREATE OR REPLACE FUNCTION f_unaccent(text) RETURNS text
+    LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+    AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+-- los operadores <% de pg_trgm usan estos indices; las consultas tienen que usar la misma expresion
+CREATE INDEX IF NOT EXISTS libros_titulo_trgm_idx ON libros USING GIN (f_unaccent(titulo) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS libros_autor_trgm_idx ON libros USING GIN (f_unaccent(autor) gin_trgm_ops);
@@ -0,0 +1,3 @@
+-- libros.autor nunca se dejo de escribir, asi que no se pierde nada al volver atras
+DROP TABLE IF EXISTS libro_autores;
+DROP TABLE IF EXISTS autores;
@@ -0,0 +1,39 @@
+-- autores como entidad: un libro puede tener varios y un autor varios libros.
+-- libros.autor queda como texto para mostrar ("Autor 1, Autor 2"), lo mantiene la API.
+CREATE TABLE autores (
+    id     SERIAL PRIMARY KEY,
+    nombre TEXT NOT NULL
+);
+
+-- "Fiódor Dostoievski" y "fiodor dostoievski" son el mismo autor
+CREATE UNIQUE INDEX autores_nombre_idx ON autores (lower(f_unaccent(nombre)));
+
+CREATE TABLE libro_autores (
+    libro_id INT NOT NULL REFERENCES libros (id) ON DELETE CASCADE,
+    autor_id INT NOT NULL REFERENCES autores (id) ON DELETE RESTRICT, -- no se borra un autor con libros
+    orden    INT NOT NULL,
+    PRIMARY KEY (libro_id, autor_id),
+    UNIQUE (libro_id, orden)
+);
+
+CREATE INDEX libro_autores_autor_idx ON libro_autores (autor_id);
+
+-- separo los autores que ya estaban cargados: "A, B", "A & B" y "A; B". La coma es como la API arma
+-- libros.autor. Por "y" no se corta: "José Ortega y Gasset" es un solo autor, no "José Ortega" y
+-- "Gasset", y eso no se puede arreglar despues. Un "A y B" queda como un autor y se separa a mano.
+-- Si el mismo nombre aparece escrito distinto se queda con el primero en orden alfabetico.
+INSERT INTO autores (nombre)
+SELECT DISTINCT ON (lower(f_unaccent(nombre))) nombre
+  FROM (
+        SELECT btrim(p) AS nombre
+          FROM libros, regexp_split_to_table(autor, '\s*[,;&]\s*') AS p
+       ) s
+ WHERE nombre <> ''
+ ORDER BY lower(f_unaccent(nombre)), nombre;
+
+INSERT INTO libro_autores (libro_id, autor_id, orden)
+SELECT l.id, a.id, min(p.orden)
+  FROM libros l
+ CROSS JOIN regexp_split_to_table(l.autor, '\s*[,;&]\s*') WITH ORDINALITY AS p (nombre, orden)
+  JOIN autores a ON lower(f_unaccent(a.nombre)) = lower(f_unaccent(btrim(p.nombre)))
+ GROUP BY l.id, a.id;
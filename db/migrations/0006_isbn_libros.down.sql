@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS libros_isbn_key;
+
+ALTER TABLE libros DROP COLUMN IF EXISTS isbn;
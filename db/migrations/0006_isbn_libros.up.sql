@@ -0,0 +1,6 @@
+-- ISBN-13 sin guiones (la API normaliza los ISBN-10 antes de guardar). NULL = no se cargo,
+-- y como NULL no choca con NULL los libros viejos sin ISBN no violan el indice unico.
+ALTER TABLE libros ADD COLUMN IF NOT EXISTS isbn TEXT
+    CONSTRAINT libros_isbn_formato CHECK (isbn ~ '^97[89][0-9]{10}$');
+
+CREATE UNIQUE INDEX IF NOT EXISTS libros_isbn_key ON libros (isbn);
@@ -0,0 +1,11 @@
+-- copias fisicas de cada libro. Si se borra el libro se va su inventario.
+CREATE TABLE ejemplares (
+    id                SERIAL PRIMARY KEY,
+    libro_id          INT  NOT NULL REFERENCES libros (id) ON DELETE CASCADE,
+    codigo_barras     TEXT NOT NULL CONSTRAINT ejemplares_codigo_barras_key UNIQUE,
+    ubicacion         TEXT NOT NULL,
+    estado            TEXT NOT NULL CHECK (estado IN ('nuevo', 'bueno', 'regular', 'malo')),
+    fecha_adquisicion DATE NOT NULL
+);
+
+CREATE INDEX ejemplares_libro_idx ON ejemplares (libro_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS prestamos;
+DROP TABLE IF EXISTS socios;
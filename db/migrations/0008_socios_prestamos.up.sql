@@ -0,0 +1,29 @@
+CREATE TABLE socios (
+    id     SERIAL PRIMARY KEY,
+    nombre TEXT NOT NULL,
+    email  TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX socios_email_key ON socios (lower(email));
+
+-- el historial de prestamos de un socio no se pierde: no se lo puede borrar si tiene alguno.
+-- Del lado del ejemplar si se borran en cascada (se fue el ejemplar, o el libro entero), pero la API
+-- no deja dar de baja un ejemplar que esta prestado.
+CREATE TABLE prestamos (
+    id           SERIAL PRIMARY KEY,
+    ejemplar_id  INT  NOT NULL REFERENCES ejemplares (id) ON DELETE CASCADE,
+    socio_id     INT  NOT NULL REFERENCES socios (id) ON DELETE RESTRICT,
+    desde        DATE NOT NULL,
+    vence        DATE NOT NULL,
+    devuelto     DATE,
+    renovaciones INT  NOT NULL DEFAULT 0,
+    CHECK (vence >= desde),
+    CHECK (devuelto IS NULL OR devuelto >= desde)
+);
+
+-- un ejemplar no puede estar prestado dos veces: como mucho un prestamo sin devolver por ejemplar.
+-- La API ya lo chequea con el ejemplar bloqueado (FOR UPDATE), esto es la ultima red.
+CREATE UNIQUE INDEX prestamos_activo_key ON prestamos (ejemplar_id) WHERE devuelto IS NULL;
+
+CREATE INDEX prestamos_socio_idx ON prestamos (socio_id);
+CREATE INDEX prestamos_vence_idx ON prestamos (vence) WHERE devuelto IS NULL;
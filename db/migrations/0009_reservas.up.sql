@@ -0,0 +1,22 @@
+-- la cola de reservas de cada libro. El orden de llegada es el id.
+-- Si se borra el socio o el libro se van sus reservas; la API no deja borrar un ejemplar apartado.
+CREATE TABLE reservas (
+    id            SERIAL PRIMARY KEY,
+    libro_id      INT  NOT NULL REFERENCES libros (id) ON DELETE CASCADE,
+    socio_id      INT  NOT NULL REFERENCES socios (id) ON DELETE CASCADE,
+    estado        TEXT NOT NULL DEFAULT 'esperando'
+                  CHECK (estado IN ('esperando', 'disponible', 'retirada', 'cancelada', 'vencida')),
+    creada        DATE NOT NULL,
+    ejemplar_id   INT  REFERENCES ejemplares (id) ON DELETE CASCADE,
+    retirar_hasta DATE,
+    CHECK (estado <> 'disponible' OR (ejemplar_id IS NOT NULL AND retirar_hasta IS NOT NULL))
+);
+
+-- un socio esta una sola vez en la cola de cada libro
+CREATE UNIQUE INDEX reservas_activa_key ON reservas (libro_id, socio_id) WHERE estado IN ('esperando', 'disponible');
+
+-- un ejemplar se aparta para una sola reserva
+CREATE UNIQUE INDEX reservas_ejemplar_key ON reservas (ejemplar_id) WHERE estado = 'disponible';
+
+CREATE INDEX reservas_cola_idx ON reservas (libro_id, id) WHERE estado = 'esperando';
+CREATE INDEX reservas_socio_idx ON reservas (socio_id);
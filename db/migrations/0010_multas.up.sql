@@ -0,0 +1,20 @@
+-- la cuenta de multas de cada socio: cargos por atraso, pagos y condonaciones. Los montos van en centavos.
+-- Es plata: no se borra con el socio (no se lo puede borrar si tiene movimientos) ni con el prestamo
+-- (si se borra el libro, el cargo queda sin prestamo_id pero sigue contando).
+CREATE TABLE multas (
+    id          SERIAL PRIMARY KEY,
+    socio_id    INT  NOT NULL CONSTRAINT multas_socio_id_fkey REFERENCES socios (id) ON DELETE RESTRICT,
+    prestamo_id INT  REFERENCES prestamos (id) ON DELETE SET NULL,
+    tipo        TEXT NOT NULL CHECK (tipo IN ('cargo', 'pago', 'condonacion')),
+    monto       INT  NOT NULL CHECK (monto >= 0),
+    dias        INT  NOT NULL DEFAULT 0,
+    fecha       DATE NOT NULL,
+    final       BOOLEAN NOT NULL DEFAULT false,
+    nota        TEXT NOT NULL DEFAULT '',
+    CHECK (tipo = 'cargo' OR (monto > 0 AND prestamo_id IS NULL))
+);
+
+-- un solo cargo por prestamo: el calculo lo actualiza con ON CONFLICT hasta que queda final
+CREATE UNIQUE INDEX multas_cargo_key ON multas (prestamo_id) WHERE tipo = 'cargo';
+
+CREATE INDEX multas_socio_idx ON multas (socio_id, id);
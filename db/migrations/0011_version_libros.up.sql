@@ -0,0 +1,3 @@
+-- version de cada libro para el control de concurrencia optimista (ETag / If-Match).
+-- Arranca en 1 y la sube cada UPDATE que hace la API, incluido el que reescribe libros.autor.
+ALTER TABLE libros ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
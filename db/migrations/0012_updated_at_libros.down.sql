@@ -0,0 +1 @@
+ALTER TABLE libros DROP COLUMN IF EXISTS updated_at;
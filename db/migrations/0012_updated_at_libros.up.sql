@@ -0,0 +1,3 @@
+-- cuando cambio cada libro por ultima vez, para Last-Modified / If-Modified-Since.
+-- Lo pone el repo en cada UPDATE (junto con version), los libros que ya estaban arrancan con la hora de la migracion.
+ALTER TABLE libros ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,19 @@
+-- sin deleted_at no hay papelera: los libros que estan ahi tendrian que borrarse de verdad (y en cascada
+-- sus ejemplares, prestamos y multas) o volver como vivos (y su ISBN puede chocar con otro). Ninguna de
+-- las dos la puede decidir la migracion, asi que falla y hay que vaciar la papelera antes (purgar o restaurar)
+DO $$
+DECLARE
+    n bigint;
+BEGIN
+    SELECT count(*) INTO n FROM libros WHERE deleted_at IS NOT NULL;
+    IF n > 0 THEN
+        RAISE EXCEPTION 'hay % libros en la papelera: purgarlos o restaurarlos antes de volver a la version 12', n;
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS libros_deleted_at_idx;
+
+DROP INDEX IF EXISTS libros_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS libros_isbn_key ON libros (isbn);
+
+ALTER TABLE libros DROP COLUMN IF EXISTS deleted_at;
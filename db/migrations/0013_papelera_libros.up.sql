@@ -0,0 +1,11 @@
+-- DELETE /libros/{id} ya no borra la fila: la manda a la papelera poniendo deleted_at. El job de purga
+-- la borra de verdad despues del periodo de retencion, y ahi se van en cascada ejemplares, prestamos, etc.
+ALTER TABLE libros ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- el ISBN solo tiene que ser unico entre los libros vivos: se puede volver a cargar el ISBN de uno que
+-- esta en la papelera (y entonces ese ya no se puede restaurar). Mismo nombre, lo busca isISBNDuplicado
+DROP INDEX IF EXISTS libros_isbn_key;
+CREATE UNIQUE INDEX IF NOT EXISTS libros_isbn_key ON libros (isbn) WHERE deleted_at IS NULL;
+
+-- para la purga y para listar la papelera
+CREATE INDEX IF NOT EXISTS libros_deleted_at_idx ON libros (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS libro_revisiones;
+DROP FUNCTION IF EXISTS libro_revisiones_inmutables();
@@ -0,0 +1,24 @@
+-- cada alta, cambio, borrado, restauracion o reversion de un libro deja una revision con el libro
+-- antes y despues (el mismo JSON que devuelve la API). Las escribe el repo en la misma transaccion
+-- que el cambio. rev es la version en la que quedo el libro, asi que coincide con el ETag.
+-- No tiene FK a libros a proposito: el historial tiene que sobrevivir a la purga de la papelera.
+CREATE TABLE IF NOT EXISTS libro_revisiones (
+    libro_id   INT         NOT NULL,
+    rev        INT         NOT NULL,
+    operacion  TEXT        NOT NULL,
+    antes      JSONB,
+    despues    JSONB       NOT NULL,
+    actor      TEXT        NOT NULL DEFAULT '',
+    request_id TEXT        NOT NULL DEFAULT '',
+    fecha      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (libro_id, rev)
+);
+
+-- las revisiones no se tocan: ni UPDATE ni DELETE (TRUNCATE si, para los tests)
+CREATE OR REPLACE FUNCTION libro_revisiones_inmutables() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ BEGIN RAISE EXCEPTION 'las revisiones de libros no se modifican'; END $$;
+
+DROP TRIGGER IF EXISTS libro_revisiones_inmutables ON libro_revisiones;
+CREATE TRIGGER libro_revisiones_inmutables BEFORE UPDATE OR DELETE ON libro_revisiones
+    FOR EACH ROW EXECUTE FUNCTION libro_revisiones_inmutables();
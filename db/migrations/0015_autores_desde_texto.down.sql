@@ -0,0 +1,3 @@
+-- los links quedan como estaban: libros.autor se sigue escribiendo, solo deja de rearmarlos
+DROP TRIGGER IF EXISTS libro_autores_desde_texto ON libros;
+DROP FUNCTION IF EXISTS libro_autores_desde_texto();
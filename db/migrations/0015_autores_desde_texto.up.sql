@@ -0,0 +1,37 @@
+-- libros.autor y libro_autores no se pueden separar: cualquier escritura de autor (POST, PUT, PATCH,
+-- batch, revertir) rearma los links desde el texto en la misma transaccion. Se corta como en la 0005 y
+-- cada nombre se busca sin mirar mayusculas ni tildes; si no existe se crea. Si el texto es justo el que
+-- arma la API con los links actuales (PUT /libros/{id}/autores, renombrar un autor) no se toca nada: asi
+-- un autor con coma en el nombre no se parte.
+CREATE OR REPLACE FUNCTION libro_autores_desde_texto() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    IF NEW.autor = coalesce((SELECT string_agg(a.nombre, ', ' ORDER BY la.orden)
+                               FROM libro_autores la JOIN autores a ON a.id = la.autor_id
+                              WHERE la.libro_id = NEW.id), '') THEN
+        RETURN NULL;
+    END IF;
+
+    -- el primero como viene escrito; los que ya existen no cambian de nombre
+    INSERT INTO autores (nombre)
+    SELECT btrim(p.nombre)
+      FROM regexp_split_to_table(NEW.autor, '\s*[,;&]\s*') WITH ORDINALITY AS p (nombre, orden)
+     WHERE btrim(p.nombre) <> ''
+     ORDER BY p.orden
+        ON CONFLICT DO NOTHING;
+
+    DELETE FROM libro_autores WHERE libro_id = NEW.id;
+
+    INSERT INTO libro_autores (libro_id, autor_id, orden)
+    SELECT NEW.id, a.id, min(p.orden)
+      FROM regexp_split_to_table(NEW.autor, '\s*[,;&]\s*') WITH ORDINALITY AS p (nombre, orden)
+      JOIN autores a ON lower(f_unaccent(a.nombre)) = lower(f_unaccent(btrim(p.nombre)))
+     GROUP BY a.id;
+
+    RETURN NULL;
+END $$;
+
+DROP TRIGGER IF EXISTS libro_autores_desde_texto ON libros;
+CREATE TRIGGER libro_autores_desde_texto AFTER INSERT OR UPDATE OF autor ON libros
+    FOR EACH ROW EXECUTE FUNCTION libro_autores_desde_texto();
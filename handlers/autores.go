@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type AutoresHandler struct {
+	repo repository.AutoresRepository
+
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewAutoresHandler(repo repository.AutoresRepository, defaultLimit, maxLimit int) *AutoresHandler {
+	return &AutoresHandler{repo: repo, defaultLimit: defaultLimit, maxLimit: maxLimit}
+}
+
+// Autores responde GET /autores (?nombre=, limit, offset) y POST /autores.
+func (h *AutoresHandler) Autores(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filtro, err := h.parseAutorFilter(r)
+		if err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		autores, err := h.repo.GetAll(r.Context(), filtro)
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, autores)
+
+	case http.MethodPost:
+		var input models.AutorInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Create(r.Context(), input)
+		if errors.Is(err, repository.ErrAutorDuplicado) {
+			httphelpers.RespondError(w, r, "ya existe un autor con ese nombre", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al crear el autor", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusCreated, salida)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// AutorByID responde GET, PUT y DELETE /autores/{id}.
+func (h *AutoresHandler) AutorByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		salida, err := h.repo.GetByID(r.Context(), id)
+		if errors.Is(err, repository.ErrAutorNotFound) {
+			httphelpers.RespondError(w, r, "autor no encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodPut:
+		var upd models.AutorInput
+
+		if err := httphelpers.DecodeJSON(w, r, &upd); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := upd.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Update(r.Context(), id, upd)
+		switch {
+		case errors.Is(err, repository.ErrAutorNotFound):
+			httphelpers.RespondError(w, r, "autor no encontrado", http.StatusNotFound)
+			return
+		case errors.Is(err, repository.ErrAutorDuplicado):
+			httphelpers.RespondError(w, r, "ya existe un autor con ese nombre", http.StatusConflict)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "error al actualizar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodDelete:
+		err := h.repo.Delete(r.Context(), id)
+		switch {
+		case errors.Is(err, repository.ErrAutorNotFound):
+			httphelpers.RespondError(w, r, "autor no encontrado", http.StatusNotFound)
+			return
+		case errors.Is(err, repository.ErrAutorConLibros):
+			// no borro en cascada: los libros se quedarian sin autor sin que nadie se entere
+			httphelpers.RespondError(w, r, "el autor tiene libros, primero hay que sacarlo de esos libros", http.StatusConflict)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "no se pudo eliminar", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// AutorLibros responde GET /autores/{id}/libros.
+func (h *AutoresHandler) AutorLibros(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	libros, err := h.repo.GetLibros(r.Context(), id)
+	if errors.Is(err, repository.ErrAutorNotFound) {
+		httphelpers.RespondError(w, r, "autor no encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, libros)
+}
+
+// LibroAutores responde GET y PUT /libros/{id}/autores. PUT recibe {"autores": [3, 1]} y
+// reemplaza la lista entera; el orden del array es el orden en que se muestran.
+func (h *AutoresHandler) LibroAutores(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		autores, err := h.repo.GetByLibro(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, autores)
+
+	case http.MethodPut:
+		var input models.LibroAutoresInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		autores, err := h.repo.SetLibroAutores(r.Context(), id, input.Autores)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+			return
+		case errors.Is(err, repository.ErrAutorNotFound):
+			// el recurso de la URL existe, lo que esta mal es un dato del body
+			var verr models.ValidationError
+			verr.Add("autores", models.CodeNotFound, "alguno de los autores no existe")
+			httphelpers.RespondValidationError(w, r, verr.Err())
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "error al actualizar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, autores)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *AutoresHandler) parseAutorFilter(r *http.Request) (models.AutorFilter, error) {
+	q := r.URL.Query()
+
+	var f models.AutorFilter
+	var verr models.ValidationError
+
+	if nombre := q.Get("nombre"); nombre != "" {
+		f.Nombre = &nombre
+	}
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, verr.Err()
+}
+
+// pathID lee el {id} de rutas como /autores/{id}
+func pathID(r *http.Request) (int, error) {
+	return strconv.Atoi(r.PathValue("id"))
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestAutores arma los repos en memoria con los 3 libros de newTestRepo y dos autores:
+// Frank Herbert (1, autor de Dune) y Ursula K. Le Guin (2, sin libros)
+func newTestAutores(t *testing.T) (*repository.MemoryLibrosRepo, *repository.MemoryAutoresRepo) {
+	t.Helper()
+
+	libros := newTestRepo(t)
+	autores := repository.NewMemoryAutoresRepo(libros)
+
+	for _, nombre := range []string{"Frank Herbert", "Ursula K. Le Guin"} {
+		if _, err := autores.Create(context.Background(), models.AutorInput{Nombre: nombre}); err != nil {
+			t.Fatalf("error cargando autores: %v", err)
+		}
+	}
+
+	if _, err := autores.SetLibroAutores(context.Background(), 1, []int{1}); err != nil {
+		t.Fatalf("error cargando autores: %v", err)
+	}
+
+	return libros, autores
+}
+
+func TestAutores_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{"listar", http.MethodGet, "/autores", "", http.StatusOK},
+		{"listar por nombre", http.MethodGet, "/autores?nombre=guin", "", http.StatusOK},
+		{"limit invalido", http.MethodGet, "/autores?limit=abc", "", http.StatusBadRequest},
+		{"limit muy grande", http.MethodGet, "/autores?limit=1000", "", http.StatusBadRequest},
+		{"crear", http.MethodPost, "/autores", `{"nombre": "Ray Bradbury"}`, http.StatusCreated},
+		{"crear sin nombre", http.MethodPost, "/autores", `{"nombre": "  "}`, http.StatusBadRequest},
+		{"crear duplicado", http.MethodPost, "/autores", `{"nombre": "frank herbert"}`, http.StatusConflict},
+		{"json invalido", http.MethodPost, "/autores", `{"nombre":`, http.StatusBadRequest},
+		{"metodo no permitido", http.MethodDelete, "/autores", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestAutores(t)
+			handler := NewAutoresHandler(repo, 50, 500)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Autores(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAutores_GET_FiltroNombre(t *testing.T) {
+	_, repo := newTestAutores(t)
+	handler := NewAutoresHandler(repo, 50, 500)
+
+	rr := httptest.NewRecorder()
+	handler.Autores(rr, httptest.NewRequest(http.MethodGet, "/autores?nombre=GUIN", nil))
+
+	got := decodeJSON[[]models.Autor](t, rr)
+	if len(got) != 1 || got[0].Nombre != "Ursula K. Le Guin" {
+		t.Fatalf("esperaba solo a Le Guin, vino %+v", got)
+	}
+}
+
+func TestAutorByID_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{"leer", http.MethodGet, "1", "", http.StatusOK},
+		{"leer inexistente", http.MethodGet, "99", "", http.StatusNotFound},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest},
+		{"renombrar", http.MethodPut, "2", `{"nombre": "Ursula Le Guin"}`, http.StatusOK},
+		{"renombrar inexistente", http.MethodPut, "99", `{"nombre": "x"}`, http.StatusNotFound},
+		{"renombrar a un nombre que ya existe", http.MethodPut, "2", `{"nombre": "Frank HERBERT"}`, http.StatusConflict},
+		{"renombrar sin nombre", http.MethodPut, "2", `{}`, http.StatusBadRequest},
+		{"borrar sin libros", http.MethodDelete, "2", "", http.StatusNoContent},
+		{"borrar con libros", http.MethodDelete, "1", "", http.StatusConflict},
+		{"borrar inexistente", http.MethodDelete, "99", "", http.StatusNotFound},
+		{"metodo no permitido", http.MethodPatch, "1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestAutores(t)
+			handler := NewAutoresHandler(repo, 50, 500)
+
+			req := httptest.NewRequest(tt.method, "/autores/"+tt.id, strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			handler.AutorByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAutorLibros(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"con libros", "1", http.StatusOK, []int{1}},
+		{"sin libros", "2", http.StatusOK, []int{}},
+		{"inexistente", "99", http.StatusNotFound, nil},
+		{"id invalido", "abc", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestAutores(t)
+			handler := NewAutoresHandler(repo, 50, 500)
+
+			req := httptest.NewRequest(http.MethodGet, "/autores/"+tt.id+"/libros", nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			handler.AutorLibros(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantIDs == nil {
+				return
+			}
+
+			got := decodeJSON[[]models.Libro](t, rr)
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("esperaba libros %v, vino %+v", tt.wantIDs, got)
+			}
+			for i, l := range got {
+				if l.ID != tt.wantIDs[i] {
+					t.Fatalf("esperaba libros %v, vino %+v", tt.wantIDs, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLibroAutores_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		body       string
+		wantStatus int
+		wantField  string // campo con error en el problem de validacion
+	}{
+		{"listar", http.MethodGet, "1", "", http.StatusOK, ""},
+		{"listar libro inexistente", http.MethodGet, "99", "", http.StatusNotFound, ""},
+		{"reemplazar", http.MethodPut, "1", `{"autores": [2, 1]}`, http.StatusOK, ""},
+		{"libro inexistente", http.MethodPut, "99", `{"autores": [1]}`, http.StatusNotFound, ""},
+		{"autor inexistente", http.MethodPut, "1", `{"autores": [1, 99]}`, http.StatusBadRequest, "autores"},
+		{"lista vacia", http.MethodPut, "1", `{"autores": []}`, http.StatusBadRequest, "autores"},
+		{"autor repetido", http.MethodPut, "1", `{"autores": [1, 1]}`, http.StatusBadRequest, "autores"},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest, ""},
+		{"metodo no permitido", http.MethodPost, "1", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestAutores(t)
+			handler := NewAutoresHandler(repo, 50, 500)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.id+"/autores", strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			handler.LibroAutores(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+// TestLibroAutores_PUT_ActualizaLibro: el texto libros.autor se arma con los autores en el orden del body
+func TestLibroAutores_PUT_ActualizaLibro(t *testing.T) {
+	libros, repo := newTestAutores(t)
+	handler := NewAutoresHandler(repo, 50, 500)
+
+	req := httptest.NewRequest(http.MethodPut, "/libros/1/autores", strings.NewReader(`{"autores": [2, 1]}`))
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+
+	handler.LibroAutores(rr, req)
+
+	got := decodeJSON[[]models.Autor](t, rr)
+	if len(got) != 2 || got[0].ID != 2 || got[1].ID != 1 {
+		t.Fatalf("esperaba autores [2 1], vino %+v", got)
+	}
+
+	l, err := libros.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if l.Autor != "Ursula K. Le Guin, Frank Herbert" {
+		t.Fatalf("libros.autor esperado %q, vino %q", "Ursula K. Le Guin, Frank Herbert", l.Autor)
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// resultadoOp es como termino una operacion en la respuesta de POST /libros:batch: el status que
+// hubiera respondido el endpoint de esa operacion y el libro con su version (lo que iria en el ETag),
+// o el problem del error
+type resultadoOp struct {
+	Status  int                  `json:"status"`
+	Libro   *models.Libro        `json:"libro,omitempty"`
+	Version int                  `json:"version,omitempty"`
+	Error   *httphelpers.Problem `json:"error,omitempty"`
+}
+
+// Batch responde POST /libros:batch. El body es un array de operaciones (ver models.LibroOp) que se
+// aplican en orden. Con ?atomic=true van todas o ninguna: responde 200 con un resultado por operacion,
+// o el error de la primera que falla (con su indice en detail). Sin atomic cada una va por su cuenta y
+// responde 207 con el resultado de cada una, aunque fallen todas.
+func (h *LibrosHandler) Batch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var verr models.ValidationError
+	atomic := parseBoolParam(r.URL.Query(), "atomic", &verr)
+	if err := verr.Err(); err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	var ops []models.LibroOp
+
+	if err := httphelpers.DecodeJSON(w, r, &ops); err != nil {
+		httphelpers.RespondDecodeError(w, r, err)
+		return
+	}
+
+	switch {
+	case len(ops) == 0:
+		verr.Add("batch", models.CodeRequired, "el batch no tiene operaciones")
+	case len(ops) > h.batchMax:
+		verr.Add("batch", models.CodeTooLarge, fmt.Sprintf("el batch no puede tener mas de %d operaciones", h.batchMax))
+	}
+	if err := verr.Err(); err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	// las invalidas no llegan al repo: en atomic cortan todo, si no quedan con su 400
+	resultados := make([]resultadoOp, len(ops))
+	var validas []int
+	for i := range ops {
+		err := h.validarOp(&ops[i])
+		if err == nil {
+			validas = append(validas, i)
+			continue
+		}
+
+		verr.AddAll(fmt.Sprintf("[%d].", i), err)
+		p := problemaDe(err)
+		resultados[i] = resultadoOp{Status: p.Status, Error: &p}
+	}
+
+	if atomic {
+		if err := verr.Err(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Batch(r.Context(), ops, true)
+
+		var batchErr *repository.BatchError
+		switch {
+		case errors.As(err, &batchErr):
+			p := problemaDe(batchErr.Err)
+			p.Detail = fmt.Sprintf("operacion %d: %s (no se aplico ninguna)", batchErr.Indice, p.Detail)
+			httphelpers.RespondProblem(w, r, p)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "error al aplicar el batch", http.StatusInternalServerError)
+			return
+		}
+
+		for i, res := range salida {
+			resultados[i] = resultadoDe(ops[i], res)
+		}
+		httphelpers.RespondJSON(w, http.StatusOK, resultados)
+		return
+	}
+
+	if len(validas) > 0 {
+		pedidas := make([]models.LibroOp, len(validas))
+		for j, i := range validas {
+			pedidas[j] = ops[i]
+		}
+
+		salida, err := h.repo.Batch(r.Context(), pedidas, false)
+		if err != nil {
+			httphelpers.RespondError(w, r, "error al aplicar el batch", http.StatusInternalServerError)
+			return
+		}
+
+		for j, i := range validas {
+			resultados[i] = resultadoDe(ops[i], salida[j])
+		}
+	}
+
+	httphelpers.RespondJSON(w, http.StatusMultiStatus, resultados)
+}
+
+// validarOp es op.Validate mas el If-Match obligatorio: con WithRequireIfMatch las operaciones sobre
+// un libro que ya existe tienen que traer version
+func (h *LibrosHandler) validarOp(op *models.LibroOp) error {
+	err := op.Validate()
+
+	if h.requireIfMatch && op.Op != models.RevisionCrear && op.Version == 0 {
+		var verr models.ValidationError
+		verr.AddAll("", err)
+		verr.Add("version", models.CodeRequired, "version requerida (es el ETag del libro, pedilo con GET)")
+		return verr.Err()
+	}
+
+	return err
+}
+
+func resultadoDe(op models.LibroOp, res repository.ResultadoBatch) resultadoOp {
+	if res.Err != nil {
+		p := problemaDe(res.Err)
+		return resultadoOp{Status: p.Status, Error: &p}
+	}
+
+	switch op.Op {
+	case models.RevisionCrear:
+		return resultadoOp{Status: http.StatusCreated, Libro: res.Libro, Version: res.Libro.Version}
+	case models.RevisionBorrar:
+		return resultadoOp{Status: http.StatusNoContent} // como DELETE
+	default:
+		return resultadoOp{Status: http.StatusOK, Libro: res.Libro, Version: res.Libro.Version}
+	}
+}
+
+// problemaDe es el problem que hubiera respondido el endpoint de la operacion con ese error. Va sin
+// instance: dentro de un batch no hay un request por operacion.
+func problemaDe(err error) httphelpers.Problem {
+	var verr *models.ValidationError
+	var dup *repository.ISBNDuplicadoError
+	var conflict *repository.VersionConflictError
+
+	switch {
+	case errors.As(err, &verr):
+		return httphelpers.Problem{
+			Type:   httphelpers.ProblemTypeValidation,
+			Title:  "Datos inválidos",
+			Status: http.StatusBadRequest,
+			Detail: "uno o más campos no son válidos",
+			Errors: verr.Errors,
+		}
+	case errors.As(err, &dup):
+		return httphelpers.Problem{
+			Type:    httphelpers.ProblemTypeISBN,
+			Title:   "ISBN duplicado",
+			Status:  http.StatusConflict,
+			Detail:  fmt.Sprintf("ya existe un libro con el isbn %s", dup.ISBN),
+			LibroID: dup.ID,
+		}
+	case errors.As(err, &conflict):
+		return problemaSimple(http.StatusPreconditionFailed, fmt.Sprintf("el libro cambio, ahora va por la version %d", conflict.Actual.Version))
+	case errors.Is(err, repository.ErrNotFound):
+		return problemaSimple(http.StatusNotFound, "libro no encontrado")
+	case errors.Is(err, repository.ErrEjemplarPrestado), errors.Is(err, repository.ErrEjemplarReservado):
+		return problemaSimple(http.StatusConflict, detalleOcupado(err))
+	default:
+		return problemaSimple(http.StatusInternalServerError, "error al aplicar la operacion")
+	}
+}
+
+// detalleOcupado es el detail del 409 de borrar un libro con ejemplares prestados o apartados
+func detalleOcupado(err error) string {
+	if errors.Is(err, repository.ErrEjemplarPrestado) {
+		return "el libro tiene ejemplares prestados, primero hay que devolverlos"
+	}
+	return "el libro tiene ejemplares apartados para reservas"
+}
+
+func problemaSimple(status int, detail string) httphelpers.Problem {
+	return httphelpers.Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLibros_Batch_TableDriven(t *testing.T) {
+	const aleph = `{"titulo":"El Aleph","autor":"Jorge Luis Borges","ano":1949,"isbn":"978-0-306-40615-7"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		body       string
+		opts       []Option
+		wantStatus int
+		wantItems  []int  // status de cada operacion, en 200 y 207
+		wantField  string // campo con error en el problem de validacion
+		wantLibros int    // cuantos libros quedan (newTestRepo carga 3)
+	}{
+		{
+			name:       "sin atomic cada una por su cuenta",
+			method:     http.MethodPost,
+			body:       `[{"op":"crear","libro":` + aleph + `},{"op":"crear","libro":` + aleph + `},{"op":"modificar","id":1,"libro":{"ano":1966}},{"op":"borrar","id":2,"version":7},{"op":"borrar","id":3},{"op":"reemplazar","id":99,"libro":` + aleph + `},{"op":"volar"}]`,
+			wantStatus: http.StatusMultiStatus,
+			wantItems:  []int{201, 409, 200, 412, 204, 404, 400},
+			wantLibros: 3,
+		},
+		{
+			name:       "atomic",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"crear","libro":` + aleph + `},{"op":"borrar","id":3,"version":1}]`,
+			wantStatus: http.StatusOK,
+			wantItems:  []int{201, 204},
+			wantLibros: 3,
+		},
+		{
+			name:       "atomic con una que falla no aplica ninguna",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"crear","libro":` + aleph + `},{"op":"borrar","id":99}]`,
+			wantStatus: http.StatusNotFound,
+			wantLibros: 3,
+		},
+		{
+			name:       "atomic con ISBN repetido adentro del batch",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"crear","libro":` + aleph + `},{"op":"crear","libro":` + aleph + `}]`,
+			wantStatus: http.StatusConflict,
+			wantLibros: 3,
+		},
+		{
+			name:       "atomic con una invalida",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"crear","libro":` + aleph + `},{"op":"modificar","id":1,"libro":{"ano":-1}}]`,
+			wantStatus: http.StatusBadRequest,
+			wantField:  "[1].libro.ano",
+			wantLibros: 3,
+		},
+		{
+			name:       "crear sin libro",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"crear"}]`,
+			wantStatus: http.StatusBadRequest,
+			wantField:  "[0].libro",
+			wantLibros: 3,
+		},
+		{
+			name:       "If-Match obligatorio pide version",
+			method:     http.MethodPost,
+			query:      "?atomic=true",
+			body:       `[{"op":"borrar","id":1}]`,
+			opts:       []Option{WithRequireIfMatch(true)},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "[0].version",
+			wantLibros: 3,
+		},
+		{
+			name:       "vacio",
+			method:     http.MethodPost,
+			body:       `[]`,
+			wantStatus: http.StatusBadRequest,
+			wantField:  "batch",
+			wantLibros: 3,
+		},
+		{
+			name:       "mas operaciones que el maximo",
+			method:     http.MethodPost,
+			body:       `[{"op":"borrar","id":1},{"op":"borrar","id":2}]`,
+			opts:       []Option{WithBatchMax(1)},
+			wantStatus: http.StatusBadRequest,
+			wantField:  "batch",
+			wantLibros: 3,
+		},
+		{"atomic invalido", http.MethodPost, "?atomic=quizas", `[]`, nil, http.StatusBadRequest, nil, "atomic", 3},
+		{"campo desconocido", http.MethodPost, "", `[{"op":"borrar","id":1,"hack":1}]`, nil, http.StatusBadRequest, nil, "hack", 3},
+		{"no es un array", http.MethodPost, "", `{"op":"borrar","id":1}`, nil, http.StatusBadRequest, nil, "", 3},
+		{"metodo no permitido", http.MethodGet, "", "", nil, http.StatusMethodNotAllowed, nil, "", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo, tt.opts...)
+
+			req := httptest.NewRequest(tt.method, "/libros:batch"+tt.query, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Batch(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantItems != nil {
+				items := decodeJSON[[]resultadoOp](t, rr)
+				if len(items) != len(tt.wantItems) {
+					t.Fatalf("esperaba %d resultados, vinieron %+v", len(tt.wantItems), items)
+				}
+				for i, item := range items {
+					if item.Status != tt.wantItems[i] {
+						t.Fatalf("operacion %d: status esperado %d, vino %+v", i, tt.wantItems[i], item)
+					}
+					if (item.Error != nil) != (item.Status >= 400) {
+						t.Fatalf("operacion %d: el error tiene que venir solo si fallo, vino %+v", i, item)
+					}
+					if (item.Version != 0) != (item.Libro != nil) {
+						t.Fatalf("operacion %d: la version tiene que venir con el libro, vino %+v", i, item)
+					}
+				}
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+
+			if got := countLibros(t, repo); got != tt.wantLibros {
+				t.Fatalf("esperaba %d libros, hay %d", tt.wantLibros, got)
+			}
+		})
+	}
+}
+
+// TestLibros_Batch_ISBNDuplicado: el error de una operacion dice que libro ya tiene el ISBN, como en POST
+func TestLibros_Batch_ISBNDuplicado(t *testing.T) {
+	handler := NewLibrosHandler(newTestRepo(t))
+
+	body := `[{"op":"modificar","id":1,"libro":{"isbn":"9780306406157"}},{"op":"modificar","id":2,"libro":{"isbn":"9780306406157"}}]`
+	rr := httptest.NewRecorder()
+	handler.Batch(rr, httptest.NewRequest(http.MethodPost, "/libros:batch", strings.NewReader(body)))
+
+	if rr.Code != http.StatusMultiStatus {
+		t.Fatalf("status esperado 207, vino %d: %s", rr.Code, rr.Body.String())
+	}
+
+	items := decodeJSON[[]resultadoOp](t, rr)
+	if len(items) != 2 || items[1].Error == nil || items[1].Error.Type != httphelpers.ProblemTypeISBN || items[1].Error.LibroID != 1 {
+		t.Fatalf("esperaba un ISBN duplicado con libro_id 1 en la segunda, vino %+v", items)
+	}
+}
+
+// TestLibros_Batch_Version: la version de cada resultado sirve para la proxima operacion sin pedir el libro
+func TestLibros_Batch_Version(t *testing.T) {
+	handler := NewLibrosHandler(newTestRepo(t))
+
+	batch := func(body string) []resultadoOp {
+		t.Helper()
+
+		rr := httptest.NewRecorder()
+		handler.Batch(rr, httptest.NewRequest(http.MethodPost, "/libros:batch", strings.NewReader(body)))
+		if rr.Code != http.StatusMultiStatus {
+			t.Fatalf("status esperado 207, vino %d: %s", rr.Code, rr.Body.String())
+		}
+		return decodeJSON[[]resultadoOp](t, rr)
+	}
+
+	items := batch(`[{"op":"modificar","id":1,"libro":{"ano":1966}}]`)
+	if items[0].Status != http.StatusOK || items[0].Version == 0 {
+		t.Fatalf("esperaba la version nueva, vino %+v", items[0])
+	}
+	version := items[0].Version
+
+	items = batch(fmt.Sprintf(`[{"op":"modificar","id":1,"version":%d,"libro":{"ano":1967}},{"op":"modificar","id":1,"version":%d,"libro":{"ano":1968}}]`, version, version))
+	if items[0].Status != http.StatusOK || items[0].Version != version+1 {
+		t.Fatalf("con la version del batch anterior tendria que aplicarse, vino %+v", items[0])
+	}
+	if items[1].Status != http.StatusPreconditionFailed {
+		t.Fatalf("con una version vieja tendria que fallar con 412, vino %+v", items[1])
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type EjemplaresHandler struct {
+	repo     repository.EjemplaresRepository
+	politica models.PoliticaPrestamo
+	now      func() time.Time
+}
+
+// politica y now fijan hasta cuando se retira un ejemplar nuevo que queda apartado para la cola de reservas.
+func NewEjemplaresHandler(repo repository.EjemplaresRepository, politica models.PoliticaPrestamo, now func() time.Time) *EjemplaresHandler {
+	return &EjemplaresHandler{repo: repo, politica: politica, now: now}
+}
+
+// Ejemplares responde GET y POST /libros/{id}/ejemplares.
+func (h *EjemplaresHandler) Ejemplares(w http.ResponseWriter, r *http.Request) {
+	libroID, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		ejemplares, err := h.repo.GetByLibro(r.Context(), libroID)
+		if errors.Is(err, repository.ErrNotFound) {
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, ejemplares)
+
+	case http.MethodPost:
+		var input models.EjemplarInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Create(r.Context(), libroID, input, h.politica.RetirarHasta(models.FechaDe(h.now())))
+		if h.respondRepoError(w, r, err, "Error al crear el ejemplar") {
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusCreated, salida)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// EjemplarByID responde GET, PUT y DELETE /libros/{id}/ejemplares/{ejemplar}.
+func (h *EjemplaresHandler) EjemplarByID(w http.ResponseWriter, r *http.Request) {
+	libroID, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("ejemplar"))
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID de ejemplar inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		salida, err := h.repo.GetByID(r.Context(), libroID, id)
+		if h.respondRepoError(w, r, err, "Error al consultar") {
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodPut:
+		var upd models.EjemplarInput
+
+		if err := httphelpers.DecodeJSON(w, r, &upd); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := upd.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Update(r.Context(), libroID, id, upd)
+		if h.respondRepoError(w, r, err, "error al actualizar") {
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodDelete:
+		err := h.repo.Delete(r.Context(), libroID, id)
+		if h.respondRepoError(w, r, err, "no se pudo eliminar") {
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// respondRepoError traduce los errores del repo a la respuesta. Devuelve true si respondio.
+func (h *EjemplaresHandler) respondRepoError(w http.ResponseWriter, r *http.Request, err error, msg500 string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrNotFound):
+		httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+	case errors.Is(err, repository.ErrEjemplarNotFound):
+		httphelpers.RespondError(w, r, "ejemplar no encontrado", http.StatusNotFound)
+	case errors.Is(err, repository.ErrCodigoBarrasDuplicado):
+		httphelpers.RespondError(w, r, "ya existe un ejemplar con ese codigo de barras", http.StatusConflict)
+	case errors.Is(err, repository.ErrEjemplarPrestado):
+		httphelpers.RespondError(w, r, "el ejemplar esta prestado, primero hay que devolverlo", http.StatusConflict)
+	case errors.Is(err, repository.ErrEjemplarReservado):
+		httphelpers.RespondError(w, r, "el ejemplar esta apartado para una reserva", http.StatusConflict)
+	default:
+		httphelpers.RespondError(w, r, msg500, http.StatusInternalServerError)
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestEjemplares arma los 3 libros de newTestRepo con dos ejemplares de Dune (ids 1 y 2)
+// y uno de 1984 (id 3)
+func newTestEjemplares(t *testing.T) (*repository.MemoryLibrosRepo, *repository.MemoryEjemplaresRepo) {
+	t.Helper()
+
+	libros := newTestRepo(t)
+	ejemplares := repository.NewMemoryEjemplaresRepo(libros)
+
+	cargar := []struct {
+		libroID int
+		codigo  string
+	}{{1, "DUNE-1"}, {1, "DUNE-2"}, {2, "1984-1"}}
+
+	for _, c := range cargar {
+		in := models.EjemplarInput{CodigoBarras: c.codigo, Ubicacion: "A-1", Estado: models.EstadoBueno, FechaAdquisicion: models.NewFecha(2020, time.March, 1)}
+		if _, err := ejemplares.Create(context.Background(), c.libroID, in, models.Fecha{}); err != nil {
+			t.Fatalf("error cargando ejemplares: %v", err)
+		}
+	}
+
+	return libros, ejemplares
+}
+
+func TestEjemplares_TableDriven(t *testing.T) {
+	const valido = `{"codigo_barras":"DUNE-3","ubicacion":"B-2","estado":"nuevo","fecha_adquisicion":"2024-05-10"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		libro      string
+		body       string
+		wantStatus int
+		wantCount  int // solo en GET 200
+	}{
+		{"listar", http.MethodGet, "1", "", http.StatusOK, 2},
+		{"listar libro sin ejemplares", http.MethodGet, "3", "", http.StatusOK, 0},
+		{"listar libro inexistente", http.MethodGet, "99", "", http.StatusNotFound, 0},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest, 0},
+		{"crear", http.MethodPost, "1", valido, http.StatusCreated, 0},
+		{"crear en libro inexistente", http.MethodPost, "99", valido, http.StatusNotFound, 0},
+		{"codigo duplicado", http.MethodPost, "3", `{"codigo_barras":"DUNE-1","ubicacion":"B-2","estado":"nuevo","fecha_adquisicion":"2024-05-10"}`, http.StatusConflict, 0},
+		{"estado invalido", http.MethodPost, "1", `{"codigo_barras":"X-1","ubicacion":"B-2","estado":"roto","fecha_adquisicion":"2024-05-10"}`, http.StatusBadRequest, 0},
+		{"fecha invalida", http.MethodPost, "1", `{"codigo_barras":"X-1","ubicacion":"B-2","estado":"nuevo","fecha_adquisicion":"10/05/2024"}`, http.StatusBadRequest, 0},
+		{"faltan campos", http.MethodPost, "1", `{}`, http.StatusBadRequest, 0},
+		{"metodo no permitido", http.MethodDelete, "1", "", http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestEjemplares(t)
+			handler := NewEjemplaresHandler(repo, models.PoliticaPrestamo{DiasRetiro: 3}, time.Now)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.libro+"/ejemplares", strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.libro)
+			rr := httptest.NewRecorder()
+
+			handler.Ejemplares(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			switch {
+			case tt.method == http.MethodGet && rr.Code == http.StatusOK:
+				if got := decodeJSON[[]models.Ejemplar](t, rr); len(got) != tt.wantCount {
+					t.Fatalf("esperaba %d ejemplares, vinieron %d", tt.wantCount, len(got))
+				}
+
+			case rr.Code == http.StatusCreated:
+				got := decodeJSON[models.Ejemplar](t, rr)
+				if got.LibroID != 1 || got.FechaAdquisicion != models.NewFecha(2024, time.May, 10) {
+					t.Fatalf("ejemplar creado incorrecto: %+v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestEjemplarByID_TableDriven(t *testing.T) {
+	const valido = `{"codigo_barras":"DUNE-1","ubicacion":"C-7","estado":"regular","fecha_adquisicion":"2020-03-01"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		libro      string
+		ejemplar   string
+		body       string
+		wantStatus int
+	}{
+		{"leer", http.MethodGet, "1", "1", "", http.StatusOK},
+		{"leer ejemplar de otro libro", http.MethodGet, "2", "1", "", http.StatusNotFound},
+		{"leer inexistente", http.MethodGet, "1", "99", "", http.StatusNotFound},
+		{"libro inexistente", http.MethodGet, "99", "1", "", http.StatusNotFound},
+		{"ejemplar invalido", http.MethodGet, "1", "abc", "", http.StatusBadRequest},
+		{"reemplazar", http.MethodPut, "1", "1", valido, http.StatusOK},
+		{"reemplazar con codigo de otro", http.MethodPut, "1", "2", valido, http.StatusConflict},
+		{"reemplazar invalido", http.MethodPut, "1", "1", `{"estado":"nuevo"}`, http.StatusBadRequest},
+		{"borrar", http.MethodDelete, "1", "1", "", http.StatusNoContent},
+		{"borrar de otro libro", http.MethodDelete, "2", "1", "", http.StatusNotFound},
+		{"metodo no permitido", http.MethodPost, "1", "1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, repo := newTestEjemplares(t)
+			handler := NewEjemplaresHandler(repo, models.PoliticaPrestamo{DiasRetiro: 3}, time.Now)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.libro+"/ejemplares/"+tt.ejemplar, strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.libro)
+			req.SetPathValue("ejemplar", tt.ejemplar)
+			rr := httptest.NewRecorder()
+
+			handler.EjemplarByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestLibros_GET_ByID_Disponibilidad(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		ejemplares bool // si el handler tiene el repo de ejemplares
+		wantStatus int
+		want       *models.Disponibilidad
+	}{
+		{"sin pedirla no viene", "", true, http.StatusOK, nil},
+		{"con disponibilidad", "?disponibilidad=true", true, http.StatusOK, &models.Disponibilidad{Total: 2, Disponibles: 2}},
+		{"valor invalido", "?disponibilidad=quizas", true, http.StatusBadRequest, nil},
+		{"sin inventario", "?disponibilidad=true", false, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			libros, ejemplares := newTestEjemplares(t)
+
+			var opts []Option
+			if tt.ejemplares {
+				opts = append(opts, WithEjemplares(ejemplares))
+			}
+			handler := NewLibrosHandler(libros, opts...)
+
+			rr := httptest.NewRecorder()
+			handler.LibrosByID(rr, httptest.NewRequest(http.MethodGet, "/libros/1"+tt.query, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			got := decodeJSON[models.Libro](t, rr)
+			switch {
+			case tt.want == nil && got.Disponibilidad != nil:
+				t.Fatalf("no esperaba disponibilidad, vino %+v", got.Disponibilidad)
+			case tt.want != nil && (got.Disponibilidad == nil || *got.Disponibilidad != *tt.want):
+				t.Fatalf("disponibilidad esperada %+v, vino %+v", tt.want, got.Disponibilidad)
+			}
+		})
+	}
+}
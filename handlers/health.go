@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"context"
+	"net/http"
+	"time"
+)
+
+// ReadinessChecker revisa una dependencia (la base, por ejemplo). Devuelve un detalle para
+// mostrar en la respuesta aunque falle, asi el que mira /readyz ve por que no esta lista.
+type ReadinessChecker interface {
+	Ready(ctx context.Context) (any, error)
+}
+
+// ReadinessFunc permite usar una funcion comun como ReadinessChecker, igual que http.HandlerFunc.
+type ReadinessFunc func(ctx context.Context) (any, error)
+
+func (f ReadinessFunc) Ready(ctx context.Context) (any, error) {
+	return f(ctx)
+}
+
+type HealthHandler struct {
+	checker ReadinessChecker
+	timeout time.Duration
+}
+
+func NewHealthHandler(checker ReadinessChecker, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+		timeout: timeout,
+	}
+}
+
+type healthResponse struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Database any    `json:"database,omitempty"`
+}
+
+// Healthz (liveness) solo dice que el proceso esta vivo y atiende. No toca la base:
+// si la base se cae no queremos que el orquestador reinicie la API, solo que no le mande trafico.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz (readiness) dice si la API puede atender trafico: la base tiene que responder dentro del timeout.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	detail, err := h.checker.Ready(ctx)
+
+	if err != nil {
+		httphelpers.RespondJSON(w, http.StatusServiceUnavailable, healthResponse{
+			Status:   "unavailable",
+			Error:    err.Error(),
+			Database: detail,
+		})
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, healthResponse{
+		Status:   "ok",
+		Database: detail,
+	})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	// aunque la base este caida, liveness responde 200
+	handler := NewHealthHandler(ReadinessFunc(func(ctx context.Context) (any, error) {
+		return nil, errors.New("base caida")
+	}), time.Second)
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Healthz(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status esperado 200, vino %d", rr.Code)
+	}
+}
+
+func TestReadyz_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		checker    ReadinessFunc
+		wantStatus int
+		wantState  string
+	}{
+		{
+			name:   "lista",
+			method: http.MethodGet,
+			checker: func(ctx context.Context) (any, error) {
+				return map[string]int{"migration_version": 1}, nil
+			},
+			wantStatus: http.StatusOK,
+			wantState:  "ok",
+		},
+		{
+			name:   "base caida",
+			method: http.MethodGet,
+			checker: func(ctx context.Context) (any, error) {
+				return map[string]int{"total_conns": 0}, errors.New("connection refused")
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantState:  "unavailable",
+		},
+		{
+			name:   "base lenta",
+			method: http.MethodGet,
+			checker: func(ctx context.Context) (any, error) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(time.Second):
+					return nil, nil
+				}
+			},
+			wantStatus: http.StatusServiceUnavailable,
+			wantState:  "unavailable",
+		},
+		{
+			name:       "metodo no permitido",
+			method:     http.MethodPost,
+			checker:    func(ctx context.Context) (any, error) { return nil, nil },
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(tt.checker, 20*time.Millisecond)
+
+			req := httptest.NewRequest(tt.method, "/readyz", nil)
+			rr := httptest.NewRecorder()
+
+			handler.Readyz(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantState != "" {
+				resp := decodeJSON[healthResponse](t, rr)
+
+				if resp.Status != tt.wantState {
+					t.Fatalf("status esperado %q, vino %q", tt.wantState, resp.Status)
+				}
+
+				if tt.wantStatus == http.StatusServiceUnavailable && resp.Error == "" {
+					t.Fatal("esperaba el motivo del error en la respuesta")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Historial responde GET /libros/{id}/historial (limit, offset): los cambios del libro, el mas nuevo
+// primero. El de un libro que esta en la papelera o ya se purgo solo se ve con ?include_deleted=true,
+// como el libro mismo.
+func (h *LibrosHandler) Historial(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	filtro, conBorrados, err := h.parseRevisionFilter(r)
+	if err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	if conBorrados && !h.esAdmin(r) {
+		respondSoloAdmin(w, r)
+		return
+	}
+
+	if !conBorrados {
+		if _, err := h.repo.GetByID(r.Context(), id); err != nil {
+			respondLibroError(w, r, err, "Error al consultar")
+			return
+		}
+	}
+
+	revs, err := h.repo.Historial(r.Context(), id, filtro)
+	if err != nil {
+		respondLibroError(w, r, err, "Error al consultar")
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, revs)
+}
+
+// Revertir responde POST /libros/{id}/revertir/{rev}: deja titulo, autor, ano e isbn como quedaron en
+// la revision rev. Es un cambio mas (sube la version y deja su propia revision) y respeta If-Match
+// igual que PUT.
+func (h *LibrosHandler) Revertir(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || rev <= 0 {
+		httphelpers.RespondError(w, r, "revision inválida", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := h.ifMatch(w, r, id)
+	if !ok {
+		return
+	}
+
+	salida, err := h.repo.Revertir(r.Context(), id, rev, version)
+
+	var dup *repository.ISBNDuplicadoError
+	var conflict *repository.VersionConflictError
+	switch {
+	case errors.As(err, &dup):
+		respondISBNDuplicado(w, r, dup)
+		return
+	case errors.As(err, &conflict):
+		respondVersionConflict(w, conflict)
+		return
+	case errors.Is(err, repository.ErrRevisionNotFound):
+		httphelpers.RespondError(w, r, "revision no encontrada", http.StatusNotFound)
+		return
+	case err != nil:
+		respondLibroError(w, r, err, "error al revertir")
+		return
+	}
+
+	w.Header().Set("ETag", libroETag(salida))
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// libroEnFecha responde GET /libros/{id}?as_of=...: el libro como estaba en ese momento segun el
+// historial. Sin include_deleted el libro tiene que existir ahora y no haber estado en la papelera en
+// ese momento. Va sin ETag ni Last-Modified: esa version no sirve para un If-Match.
+func (h *LibrosHandler) libroEnFecha(w http.ResponseWriter, r *http.Request, id int, asOf time.Time, conBorrados bool) {
+	if !conBorrados {
+		if _, err := h.repo.GetByID(r.Context(), id); err != nil {
+			respondLibroError(w, r, err, "Error al consultar")
+			return
+		}
+	}
+
+	salida, err := h.repo.EnFecha(r.Context(), id, asOf)
+	if err == nil && salida.Borrado != nil && !conBorrados {
+		err = repository.ErrNotFound
+	}
+	if err != nil {
+		respondLibroError(w, r, err, "Error al consultar")
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// respondLibroError responde 404 si el libro no existe y 500 con msg500 para cualquier otro error
+func respondLibroError(w http.ResponseWriter, r *http.Request, err error, msg500 string) {
+	if errors.Is(err, repository.ErrNotFound) {
+		httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+		return
+	}
+	httphelpers.RespondError(w, r, msg500, http.StatusInternalServerError)
+}
+
+func (h *LibrosHandler) parseRevisionFilter(r *http.Request) (f models.RevisionFilter, conBorrados bool, err error) {
+	q := r.URL.Query()
+	var verr models.ValidationError
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	conBorrados = parseBoolParam(q, "include_deleted", &verr)
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, conBorrados, verr.Err()
+}
+
+// parseTimeParam devuelve nil si el param no vino; si vino y no es RFC 3339 agrega el error a verr
+func parseTimeParam(q url.Values, name string, verr *models.ValidationError) *time.Time {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		verr.Add(name, models.CodeInvalidTime, name+" tiene que ser una fecha y hora RFC 3339, por ej. 2024-03-05T12:00:00Z")
+		return nil
+	}
+
+	return &t
+}
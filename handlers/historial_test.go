@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newTestHistorial es newTestPapelera con el titulo de Dune (libro 1) cambiado. Tambien devuelve un
+// momento entre el alta y el cambio, para pedir ?as_of.
+func newTestHistorial(t *testing.T) (*LibrosHandler, time.Time) {
+	t.Helper()
+
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	time.Sleep(2 * time.Millisecond)
+	antesDelCambio := time.Now()
+	time.Sleep(2 * time.Millisecond)
+
+	if _, err := repo.Patch(ctx, 1, models.LibroPatch{Titulo: ptr("Dune (ed. 1965)")}); err != nil {
+		t.Fatalf("error modificando: %v", err)
+	}
+	if err := repo.Delete(ctx, 2); err != nil {
+		t.Fatalf("error borrando: %v", err)
+	}
+
+	return NewLibrosHandler(repo, WithAdminToken("secreto")), antesDelCambio
+}
+
+func TestLibros_Historial_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		query      string
+		admin      bool
+		wantStatus int
+		wantRevs   []int // solo en 200
+	}{
+		{"historial", http.MethodGet, "1", "", false, http.StatusOK, []int{2, 1}},
+		{"paginado", http.MethodGet, "1", "?limit=1&offset=1", false, http.StatusOK, []int{1}},
+		{"libro en la papelera", http.MethodGet, "2", "", false, http.StatusNotFound, nil},
+		{"en la papelera para el admin", http.MethodGet, "2", "?include_deleted=true", true, http.StatusOK, []int{2, 1}},
+		{"include_deleted sin ser admin", http.MethodGet, "2", "?include_deleted=true", false, http.StatusForbidden, nil},
+		{"no existe", http.MethodGet, "999", "", false, http.StatusNotFound, nil},
+		{"limit muy grande", http.MethodGet, "1", "?limit=1000", false, http.StatusBadRequest, nil},
+		{"id invalido", http.MethodGet, "abc", "", false, http.StatusBadRequest, nil},
+		{"metodo no permitido", http.MethodPost, "1", "", false, http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestHistorial(t)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.id+"/historial"+tt.query, nil)
+			req.SetPathValue("id", tt.id)
+			if tt.admin {
+				req.Header.Set("Authorization", "Bearer secreto")
+			}
+			rr := httptest.NewRecorder()
+
+			handler.Historial(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				got := decodeJSON[[]models.Revision](t, rr)
+				if len(got) != len(tt.wantRevs) {
+					t.Fatalf("esperaba las revisiones %v, vino %+v", tt.wantRevs, got)
+				}
+				for i, rev := range tt.wantRevs {
+					if got[i].Rev != rev {
+						t.Fatalf("esperaba las revisiones %v, vino %+v", tt.wantRevs, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLibros_AsOf_TableDriven(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		asOf        func(antesDelCambio time.Time) string
+		extra       string
+		admin       bool
+		wantStatus  int
+		wantTitulo  string // solo en 200
+		wantBorrado bool
+		wantField   string // campo con error en el problem de validacion
+	}{
+		{"antes del cambio", "1", func(t time.Time) string { return t.Format(time.RFC3339Nano) }, "", false, http.StatusOK, "Dune", false, ""},
+		{"despues del cambio", "1", func(time.Time) string { return "2100-01-01T00:00:00Z" }, "", false, http.StatusOK, "Dune (ed. 1965)", false, ""},
+		{"antes de que existiera", "1", func(time.Time) string { return "2000-01-01T00:00:00Z" }, "", false, http.StatusNotFound, "", false, ""},
+		{"en la papelera", "2", func(time.Time) string { return "2100-01-01T00:00:00Z" }, "", false, http.StatusNotFound, "", false, ""},
+		{"en la papelera para el admin", "2", func(time.Time) string { return "2100-01-01T00:00:00Z" }, "&include_deleted=true", true, http.StatusOK, "1984", true, ""},
+		{"antes del borrado", "2", func(t time.Time) string { return t.Format(time.RFC3339Nano) }, "", false, http.StatusNotFound, "", false, ""},
+		{"fecha invalida", "1", func(time.Time) string { return "ayer" }, "", false, http.StatusBadRequest, "", false, "as_of"},
+		{"con disponibilidad", "1", func(time.Time) string { return "2100-01-01T00:00:00Z" }, "&disponibilidad=true", false, http.StatusBadRequest, "", false, "disponibilidad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, antesDelCambio := newTestHistorial(t)
+
+			q := "?as_of=" + url.QueryEscape(tt.asOf(antesDelCambio)) + tt.extra
+			req := httptest.NewRequest(http.MethodGet, "/libros/"+tt.id+q, nil)
+			if tt.admin {
+				req.Header.Set("Authorization", "Bearer secreto")
+			}
+			rr := httptest.NewRecorder()
+
+			handler.LibrosByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				if etag := rr.Header().Get("ETag"); etag != "" {
+					t.Fatalf("una version vieja no lleva ETag, vino %q", etag)
+				}
+
+				got := decodeJSON[models.Libro](t, rr)
+				if got.Titulo != tt.wantTitulo || (got.Borrado != nil) != tt.wantBorrado {
+					t.Fatalf("esperaba %q (borrado %v), vino %+v", tt.wantTitulo, tt.wantBorrado, got)
+				}
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestLibros_Revertir_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		rev        string
+		ifMatch    string
+		wantStatus int
+		wantETag   string
+	}{
+		{"ok", http.MethodPost, "1", "1", "", http.StatusOK, `"3"`},
+		{"con If-Match", http.MethodPost, "1", "1", `"2"`, http.StatusOK, `"3"`},
+		{"If-Match viejo", http.MethodPost, "1", "1", `"1"`, http.StatusPreconditionFailed, `"2"`},
+		{"revision que no existe", http.MethodPost, "1", "99", "", http.StatusNotFound, ""},
+		{"libro en la papelera", http.MethodPost, "2", "1", "", http.StatusNotFound, ""},
+		{"libro que no existe", http.MethodPost, "999", "1", "", http.StatusNotFound, ""},
+		{"revision invalida", http.MethodPost, "1", "abc", "", http.StatusBadRequest, ""},
+		{"revision 0", http.MethodPost, "1", "0", "", http.StatusBadRequest, ""},
+		{"metodo no permitido", http.MethodGet, "1", "1", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestHistorial(t)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.id+"/revertir/"+tt.rev, nil)
+			req.SetPathValue("id", tt.id)
+			req.SetPathValue("rev", tt.rev)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.Revertir(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if got := rr.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("ETag esperado %q, vino %q", tt.wantETag, got)
+			}
+
+			if rr.Code == http.StatusOK {
+				if got := decodeJSON[models.Libro](t, rr); got.Titulo != "Dune" {
+					t.Fatalf("esperaba el titulo de la revision 1, vino %+v", got)
+				}
+			}
+		})
+	}
+}
@@ -2,86 +2,191 @@ package handlers
 
 import (
 	//"context" cancelación, timeout, valores. El "mensajero" lleva solo lo necesario: "para ya", "tenés 5 segundos", "este es el user 12345"
+	"api-libros/cursor"
 	"api-libros/httphelpers"
 	"api-libros/models"
 	"api-libros/repository"
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"log"
+	"hash/fnv"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 type LibrosHandler struct {
 	repo repository.LibrosRepository
+
+	defaultLimit int // limit cuando el request no manda uno
+	maxLimit     int // limit maximo que aceptamos
+
+	cursors *cursor.Signer // firma los cursores del paginado keyset
+
+	fuzzyThreshold float64 // similitud minima de ?fuzzy=true y /libros/suggest
+
+	ejemplares repository.EjemplaresRepository // para GET /libros/{id}?disponibilidad=true, puede ser nil
+
+	requireIfMatch bool // PUT, PATCH, DELETE y revertir sin If-Match responden 428
+
+	cacheControl string // Cache-Control de los GET de libros, vacio no se manda
+
+	adminToken string // habilita ?include_deleted=true, vacio = nadie es admin
+
+	batchMax int // operaciones maximas de POST /libros:batch
+}
+
+type Option func(h *LibrosHandler)
+
+// WithPageSize cambia el limit por defecto y el maximo de GET /libros (por defecto 50 y 500)
+func WithPageSize(defaultLimit, maxLimit int) Option {
+	return func(h *LibrosHandler) {
+		h.defaultLimit = defaultLimit
+		h.maxLimit = maxLimit
+	}
 }
 
-func NewLibrosHandler(repo repository.LibrosRepository) *LibrosHandler {
-    return &LibrosHandler{
-        repo: repo,
-    }
+// WithCursorSecret fija la clave con la que se firman los cursores. Todas las instancias de la API
+// tienen que usar la misma, si no un cursor generado por una no sirve en otra.
+func WithCursorSecret(secret []byte) Option {
+	return func(h *LibrosHandler) {
+		h.cursors = cursor.NewSigner(secret)
+	}
+}
+
+// WithFuzzyThreshold cambia la similitud minima (0 a 1) de la busqueda fuzzy y de /libros/suggest (por defecto 0.3)
+func WithFuzzyThreshold(threshold float64) Option {
+	return func(h *LibrosHandler) {
+		h.fuzzyThreshold = threshold
+	}
+}
+
+// WithEjemplares habilita GET /libros/{id}?disponibilidad=true, que cuenta los ejemplares del libro
+func WithEjemplares(repo repository.EjemplaresRepository) Option {
+	return func(h *LibrosHandler) {
+		h.ejemplares = repo
+	}
+}
+
+// WithRequireIfMatch hace obligatorio el If-Match en PUT, PATCH y DELETE de /libros/{id} (y en
+// revertir). Sin esto, el que no lo manda pisa lo que haya.
+func WithRequireIfMatch(require bool) Option {
+	return func(h *LibrosHandler) {
+		h.requireIfMatch = require
+	}
+}
+
+// WithCacheControl fija el Cache-Control de GET /libros y /libros/{id} (por ejemplo "no-cache", que deja
+// guardar la respuesta pero obliga a revalidarla con If-None-Match)
+func WithCacheControl(value string) Option {
+	return func(h *LibrosHandler) {
+		h.cacheControl = value
+	}
+}
+
+// WithAdminToken fija el token de administrador. Los requests que lo mandan en
+// "Authorization: Bearer <token>" pueden pedir los libros borrados con ?include_deleted=true.
+func WithAdminToken(token string) Option {
+	return func(h *LibrosHandler) {
+		h.adminToken = token
+	}
+}
+
+// WithBatchMax cambia cuantas operaciones acepta POST /libros:batch (por defecto 1000)
+func WithBatchMax(max int) Option {
+	return func(h *LibrosHandler) {
+		h.batchMax = max
+	}
+}
+
+func NewLibrosHandler(repo repository.LibrosRepository, opts ...Option) *LibrosHandler {
+	// sin WithCursorSecret uso una clave al azar: anda, pero los cursores mueren al reiniciar
+	secret := make([]byte, 32)
+	rand.Read(secret)
+
+	h := &LibrosHandler{
+		repo:         repo,
+		defaultLimit: 50,
+		maxLimit:     500,
+		cursors:      cursor.NewSigner(secret),
+
+		fuzzyThreshold: 0.3,
+		batchMax:       1000,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
 
 func (h *LibrosHandler) Libros(w http.ResponseWriter, r *http.Request) {
 	// w.Header().Set("Content-Type", "application/json")
 
-	log.Printf("%s %s", r.Method, r.URL.Path)
-
 	switch r.Method {
 	case http.MethodGet:
-		filtro, err := parseLibroFilter(r)
+		filtro, envelope, err := h.parseLibroFilter(r)
 
 		if err != nil{
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			httphelpers.RespondValidationError(w, r, err)
 			return
 		}
 
+		if filtro.Papelera == models.ConBorrados && !h.esAdmin(r) {
+			respondSoloAdmin(w, r)
+			return
+		}
 
-		result, err := h.repo.GetAll(r.Context(), filtro)
-
-		if err != nil {
-			httphelpers.RespondError(w, "Error al consultar la base", http.StatusInternalServerError)
+		// con ?cursor (aunque venga vacio, para pedir la primera pagina) respondo en modo keyset
+		if r.URL.Query().Has("cursor") {
+			h.listCursor(w, r, filtro)
 			return
 		}
 
-		// Si no hay error, devolvemos el JSON
-		httphelpers.RespondJSON(w, http.StatusOK, result)
+		h.listOffset(w, r, filtro, envelope)
 
 	case http.MethodPost:
 		var input models.LibroInput
 
 		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
-			httphelpers.RespondError(w, "json invalido", http.StatusBadRequest)
+			httphelpers.RespondDecodeError(w, r, err)
 			return
 		}
 
 		if err := input.Validate(); err != nil {
-			httphelpers.RespondError(w, err.Error(), http.StatusBadRequest)
+			httphelpers.RespondValidationError(w, r, err)
 			return
 		}
 
 		salida, err := h.repo.Create(r.Context(), input)
+		var dup *repository.ISBNDuplicadoError
+		if errors.As(err, &dup) {
+			respondISBNDuplicado(w, r, dup)
+			return
+		}
 		if err != nil {
-			httphelpers.RespondError(w, "Error al crear nuevo libro", http.StatusInternalServerError)
+			httphelpers.RespondError(w, r, "Error al crear nuevo libro", http.StatusInternalServerError)
 			return
 		}
 
 		// Respondemos con 201 Created + el libro completo (incluyendo ID generado)
 		// w.WriteHeader(http.StatusCreated)
 		// json.NewEncoder(w).Encode(nuevo)
+		w.Header().Set("ETag", libroETag(salida))
 		httphelpers.RespondJSON(w, http.StatusCreated, salida)
 
 	default:
 		w.Header().Set("Allow", "GET, POST") // XQ PROTOCOLO HTTP dice que servidor debería indicar qué métodos sí están permitidos para ese recurso
-		httphelpers.RespondError(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
 	}
 }
 
 func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 
-	log.Printf("%s %s", r.Method, r.URL.Path)
-
 	// Siempre seteamos el Content-Type para que el cliente sepa que devolvemos JSON
 	// w.Header().Set("Content-type", "application/json")
 
@@ -90,12 +195,18 @@ func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 	// TrimPrefix quita el prefijo "/tareas/" → queda "123"
 	idStr := strings.TrimPrefix(r.URL.Path, "/libros/")
 
+	// /libros/isbn/{isbn} no se puede registrar aparte en el mux: choca con /libros/{id}/autores
+	if isbn, ok := strings.CutPrefix(idStr, "isbn/"); ok {
+		h.libroByISBN(w, r, isbn)
+		return
+	}
+
 	id, err := strconv.Atoi(idStr)
 
 	if err != nil {
 		// 400 Bad Request + JSON de error
 		// http.Error escribe status, header y body automáticamente
-		httphelpers.RespondError(w, "ID inválido", http.StatusBadRequest)
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
 		return
 	}
 
@@ -103,17 +214,66 @@ func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
 
-		salida, err := h.repo.GetByID(r.Context(), id)
+		var verr models.ValidationError
+		disponibilidad := parseBoolParam(r.URL.Query(), "disponibilidad", &verr)
+		if disponibilidad && h.ejemplares == nil {
+			verr.Add("disponibilidad", models.CodeNotAllowed, "esta instancia no tiene inventario de ejemplares")
+		}
+		conBorrados := parseBoolParam(r.URL.Query(), "include_deleted", &verr)
+		asOf := parseTimeParam(r.URL.Query(), "as_of", &verr)
+		if asOf != nil && disponibilidad {
+			verr.Add("as_of", models.CodeNotAllowed, "as_of no se puede combinar con disponibilidad")
+		}
+		if err := verr.Err(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		if conBorrados && !h.esAdmin(r) {
+			respondSoloAdmin(w, r)
+			return
+		}
+
+		if asOf != nil {
+			h.libroEnFecha(w, r, id, *asOf, conBorrados)
+			return
+		}
+
+		var salida *models.Libro
+		if conBorrados {
+			salida, err = h.repo.GetByIDConBorrados(r.Context(), id)
+		} else {
+			salida, err = h.repo.GetByID(r.Context(), id)
+		}
 
 		if err == repository.ErrNotFound {
-			httphelpers.RespondError(w, "libro no encontrado", http.StatusNotFound)
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+			return
 		}
 
 		if err != nil {
-			httphelpers.RespondError(w, "Error al consultar", http.StatusInternalServerError)
+			httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
 			return
 		}
 
+		// la disponibilidad cambia sin que cambie el libro, esa respuesta va sin validadores
+		if !disponibilidad && httphelpers.NotModified(w, r, h.validators(salida)) {
+			return
+		}
+
+		if disponibilidad {
+			d, err := h.ejemplares.Disponibilidad(r.Context(), id)
+			if err == repository.ErrNotFound { // lo borraron entre las dos consultas (o esta en la papelera)
+				httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+				return
+			}
+			salida.Disponibilidad = &d
+		}
+
 		httphelpers.RespondJSON(w, http.StatusOK, salida)
 
 	case http.MethodPut:
@@ -121,35 +281,58 @@ func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 		var upd models.LibroInput
 
 		if err := httphelpers.DecodeJSON(w, r, &upd); err != nil {
-			httphelpers.RespondError(w, "json invalido", http.StatusBadRequest)
+			httphelpers.RespondDecodeError(w, r, err)
 			return
 		}
 
 		if err := upd.Validate(); err != nil {
-			httphelpers.RespondError(w, err.Error(), http.StatusBadRequest)
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		version, ok := h.ifMatch(w, r, id)
+		if !ok {
 			return
 		}
 
 		//DB.EXEC para INSERT/UPDATE/DELETE
-		salida, err := h.repo.Update(r.Context(), id, upd)
+		var salida *models.Libro
+		if version == 0 {
+			salida, err = h.repo.Update(r.Context(), id, upd)
+		} else {
+			salida, err = h.repo.UpdateIf(r.Context(), id, version, upd)
+		}
+
+		var dup *repository.ISBNDuplicadoError
+		if errors.As(err, &dup) {
+			respondISBNDuplicado(w, r, dup)
+			return
+		}
+
+		var conflict *repository.VersionConflictError
+		if errors.As(err, &conflict) {
+			respondVersionConflict(w, conflict)
+			return
+		}
 
 		if err == repository.ErrNotFound {
-			httphelpers.RespondError(w, "libro no encontrado", http.StatusNotFound)
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
 			return
 		}
 
 		if err != nil {
-			httphelpers.RespondError(w, "error al actualizar", http.StatusInternalServerError)
+			httphelpers.RespondError(w, r, "error al actualizar", http.StatusInternalServerError)
 			return
 		}
 
+		w.Header().Set("ETag", libroETag(salida))
 		httphelpers.RespondJSON(w, http.StatusOK, salida)
 
 	case http.MethodPatch:
 		var patch models.LibroPatch
 
 		if err := httphelpers.DecodeJSON(w, r, &patch); err != nil {
-			httphelpers.RespondError(w, "json invalido", http.StatusBadRequest)
+			httphelpers.RespondDecodeError(w, r, err)
 			return
 		}
 		// vuelco la info en el LibroPatch
@@ -160,35 +343,78 @@ func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 
 		// chequeo que los datos que llegaron sean validos
 		if err := patch.Validate(); err != nil {
-			httphelpers.RespondError(w, err.Error(), http.StatusBadRequest)
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		version, ok := h.ifMatch(w, r, id)
+		if !ok {
 			return
 		}
 
-		salida, err := h.repo.Patch(r.Context(), id, patch)
+		var salida *models.Libro
+		if version == 0 {
+			salida, err = h.repo.Patch(r.Context(), id, patch)
+		} else {
+			salida, err = h.repo.PatchIf(r.Context(), id, version, patch)
+		}
+
+		var dup *repository.ISBNDuplicadoError
+		if errors.As(err, &dup) {
+			respondISBNDuplicado(w, r, dup)
+			return
+		}
+
+		var conflict *repository.VersionConflictError
+		if errors.As(err, &conflict) {
+			respondVersionConflict(w, conflict)
+			return
+		}
 
 		if err == repository.ErrNotFound {
-			httphelpers.RespondError(w, "libro no encontrado", http.StatusNotFound)
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
 			return
 		}
 
 		if err != nil {
-			httphelpers.RespondError(w, "error al actualizar", http.StatusInternalServerError)
+			httphelpers.RespondError(w, r, "error al actualizar", http.StatusInternalServerError)
 			return
 		}
 
 		//quizas aca podriamos usar el omitempty para retornar solo los campos actualizados
+		w.Header().Set("ETag", libroETag(salida))
 		httphelpers.RespondJSON(w, http.StatusOK, salida)
 	case http.MethodDelete:
 
-		err := h.repo.Delete(r.Context(), id)
+		version, ok := h.ifMatch(w, r, id)
+		if !ok {
+			return
+		}
+
+		if version == 0 {
+			err = h.repo.Delete(r.Context(), id)
+		} else {
+			err = h.repo.DeleteIf(r.Context(), id, version)
+		}
+
+		var conflict *repository.VersionConflictError
+		if errors.As(err, &conflict) {
+			respondVersionConflict(w, conflict)
+			return
+		}
 
 		if err == repository.ErrNotFound { //si 0 → 404
-			httphelpers.RespondError(w, "libro no encontrado", http.StatusNotFound)
+			httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, repository.ErrEjemplarPrestado) || errors.Is(err, repository.ErrEjemplarReservado) {
+			httphelpers.RespondError(w, r, detalleOcupado(err), http.StatusConflict)
 			return
 		}
 
 		if err != nil {
-			httphelpers.RespondError(w, "no se puedo eliminar", http.StatusInternalServerError)
+			httphelpers.RespondError(w, r, "no se puedo eliminar", http.StatusInternalServerError)
 			return
 		}
 
@@ -202,58 +428,274 @@ func (h *LibrosHandler) LibrosByID(w http.ResponseWriter, r *http.Request) {
 
 	default:
 		w.Header().Set("Allow", "GET, PUT, PATCH, DELETE")
-		httphelpers.RespondError(w, "metodo no permitido", http.StatusMethodNotAllowed)
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+
+	}
+
+}
+
+// libroByISBN responde GET /libros/isbn/{isbn}. Acepta el ISBN-10 o el 13, con o sin guiones.
+func (h *LibrosHandler) libroByISBN(w http.ResponseWriter, r *http.Request, raw string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isbn, err := models.NormalizeISBN(raw)
+	if err != nil {
+		var verr models.ValidationError
+		verr.Add("isbn", models.CodeInvalidISBN, "isbn invalido")
+		httphelpers.RespondValidationError(w, r, verr.Err())
+		return
+	}
+
+	salida, err := h.repo.GetByISBN(r.Context(), isbn)
+
+	if err == repository.ErrNotFound {
+		httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+		return
+	}
+
+	if httphelpers.NotModified(w, r, h.validators(salida)) {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// validators son los headers de cache de un libro: ETag, Last-Modified y Cache-Control
+func (h *LibrosHandler) validators(l *models.Libro) httphelpers.Validators {
+	return httphelpers.Validators{ETag: libroETag(l), LastModified: l.Modificado, CacheControl: h.cacheControl}
+}
+
+// coleccionETag es un ETag debil para una pagina de GET /libros: cambia si cambia algun libro de la
+// pagina (su version), si entra o sale alguno, o si cambia el total. No hace falta codificar el JSON.
+func coleccionETag(items []models.Libro, total int) string {
+	hash := fnv.New64a()
+	for _, l := range items {
+		fmt.Fprintf(hash, "%d:%d,", l.ID, l.Version)
+	}
+	fmt.Fprintf(hash, "total:%d", total)
+
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// libroETag es el ETag (fuerte) de un libro: su version entre comillas
+func libroETag(l *models.Libro) string {
+	return fmt.Sprintf(`"%d"`, l.Version)
+}
+
+// ifMatch lee el If-Match de PUT, PATCH, DELETE y revertir y devuelve la version que pide el cliente,
+// o 0 si no pone condicion (no vino el header, o vino *). Un ETag que no es uno de los nuestros (debil,
+// cualquier otra cosa) no coincide con ninguna version: -1. Si el header es obligatorio y no vino
+// responde 428 y devuelve ok false.
+//
+// Con una lista ("3", "4") alcanza con que coincida uno (RFC 9110, 13.1.1): se busca la version actual
+// del libro id y, si esta en la lista, se pide esa. Si cambia entre medio el repo igual da el conflicto.
+func (h *LibrosHandler) ifMatch(w http.ResponseWriter, r *http.Request, id int) (version int, ok bool) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if raw == "" {
+		if h.requireIfMatch {
+			httphelpers.RespondError(w, r, "falta el header If-Match con el ETag del libro (pedilo con GET)", http.StatusPreconditionRequired)
+			return 0, false
+		}
+		return 0, true
+	}
+
+	if raw == "*" {
+		return 0, true
+	}
+
+	var versiones []int
+	for _, tag := range strings.Split(raw, ",") {
+		if v := versionDeETag(strings.TrimSpace(tag)); v > 0 {
+			versiones = append(versiones, v)
+		}
+	}
+
+	switch {
+	case len(versiones) == 0:
+		return -1, true
+	case len(versiones) == 1:
+		return versiones[0], true
+	}
 
+	// si no existe o falla, -1: el repo responde lo mismo que sin lista
+	actual, err := h.repo.GetByID(r.Context(), id)
+	if err != nil || !slices.Contains(versiones, actual.Version) {
+		return -1, true
 	}
 
+	return actual.Version, true
 }
 
-func parseLibroFilter(r *http.Request) (filter models.LibroFilter, err error){
+// versionDeETag es la version de un ETag fuerte nuestro ("3"), o -1. Los debiles no sirven para If-Match.
+func versionDeETag(tag string) int {
+	unquoted, found := strings.CutPrefix(tag, `"`)
+	unquoted, closed := strings.CutSuffix(unquoted, `"`)
+	v, err := strconv.Atoi(unquoted)
+	if !found || !closed || err != nil || v <= 0 {
+		return -1
+	}
+
+	return v
+}
+
+// respondVersionConflict responde 412 con el libro como esta ahora y su ETag, para que el cliente
+// pueda ver que cambio y reintentar con la version nueva
+func respondVersionConflict(w http.ResponseWriter, conflict *repository.VersionConflictError) {
+	w.Header().Set("ETag", libroETag(&conflict.Actual))
+	httphelpers.RespondJSON(w, http.StatusPreconditionFailed, conflict.Actual)
+}
+
+// respondISBNDuplicado responde 409 con el id del libro que ya tiene el ISBN en libro_id
+func respondISBNDuplicado(w http.ResponseWriter, r *http.Request, dup *repository.ISBNDuplicadoError) {
+	httphelpers.RespondProblem(w, r, httphelpers.Problem{
+		Type:    httphelpers.ProblemTypeISBN,
+		Title:   "ISBN duplicado",
+		Status:  http.StatusConflict,
+		Detail:  fmt.Sprintf("ya existe un libro con el isbn %s", dup.ISBN),
+		LibroID: dup.ID,
+	})
+}
+
+// parseLibroFilter lee los query params de GET /libros. Junta todos los errores
+// (de formato y de Validate) en un *models.ValidationError en vez de cortar en el primero.
+// envelope es ?envelope=true: responder {items, total, ...} en vez del array pelado.
+func (h *LibrosHandler) parseLibroFilter(r *http.Request) (filter models.LibroFilter, envelope bool, err error){
 	q := r.URL.Query()
+	defaultLimit, maxLimit := h.defaultLimit, h.maxLimit
 
 	var f models.LibroFilter
+	var verr models.ValidationError
+
+	if q.Has("q") {
+		search := q.Get("q")
+		f.Q = &search // si vino vacio lo rechaza Validate
+	}
 
 	if autor := q.Get("autor"); autor != "" {
 		f.Autor = &autor
 	}
 
+	f.From = parseIntParam(q, "from", &verr)
+	f.To = parseIntParam(q, "to", &verr)
 
-	if from := q.Get("from"); from != "" {
-		v,err := strconv.Atoi(from)
-		if err != nil{
-			return f, fmt.Errorf("from invalido")
+	f.Limit = defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", maxLimit))
 		}
+		f.Limit = *v
+	}
 
-		f.From = &v
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
 	}
 
-	if to := q.Get("to"); to != "" {
-		v, err := strconv.Atoi(to)
+	f.Sort = parseSortParam(q, &verr)
+
+	envelope = parseBoolParam(q, "envelope", &verr)
+
+	f.Fuzzy = parseBoolParam(q, "fuzzy", &verr)
+	f.FuzzyThreshold = h.fuzzyThreshold
+
+	// que el que lo pide sea admin lo chequea el handler, aca solo se lee
+	if parseBoolParam(q, "include_deleted", &verr) {
+		f.Papelera = models.ConBorrados
+	}
+
+	if token := q.Get("cursor"); token != "" {
+		c, err := h.cursors.Decode(token)
 		if err != nil {
-			return f, fmt.Errorf("to invalido")
+			verr.Add("cursor", models.CodeInvalidCursor, "cursor invalido")
+		} else {
+			f.Cursor = &c
 		}
-		f.To = &v
 	}
 
-	if limit := q.Get("limit"); limit != ""{
-		v, err := strconv.Atoi(limit)
-		if err != nil{
-			return f, fmt.Errorf("limit invalido")
-		}
-		f.Limit = v
-	}else{
-		f.Limit = 50 // default
+	if q.Has("cursor") && f.Limit <= 0 {
+		verr.Add("limit", models.CodeMustBePositive, "limit tiene que ser mayor a 0 con cursor")
 	}
 
-	if offset := q.Get("offset"); offset != ""{
-		v, err := strconv.Atoi(offset)
-		if err != nil {
-			return f, fmt.Errorf("offset invalido")
+	if q.Has("cursor") && f.Q != nil && len(f.Sort) == 0 && f.Cursor == nil {
+		verr.Add("cursor", models.CodeNotAllowed, "para usar cursor con q hace falta un sort explicito")
+	}
+
+	if q.Has("cursor") && q.Has("offset") && f.Cursor == nil {
+		// la primera pagina con cursor no trae Cursor, Validate no se daria cuenta
+		verr.Add("offset", models.CodeNotAllowed, "offset no se puede usar junto con cursor")
+	}
+
+	// Validate nunca se llamaba: un limit negativo llegaba hasta postgres y volvia como 500
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, envelope, verr.Err()
+}
+
+// parseSortParam lee ?sort=-ano,titulo: columnas separadas por coma, con "-" adelante para descendente.
+// Que las columnas existan y no se repitan lo chequea LibroFilter.Validate.
+func parseSortParam(q url.Values, verr *models.ValidationError) []models.SortField {
+	raw := q.Get("sort")
+	if raw == "" {
+		return nil
+	}
+
+	var sort []models.SortField
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		campo, desc := strings.CutPrefix(part, "-")
+
+		if campo == "" {
+			verr.Add("sort", models.CodeBlank, "sort tiene una columna vacia")
+			continue
 		}
-		f.Offset = v
+
+		sort = append(sort, models.SortField{Campo: campo, Desc: desc})
+	}
+
+	return sort
+}
+
+// parseBoolParam devuelve false si el param no vino; si vino y no es true/false/1/0 agrega el error a verr
+func parseBoolParam(q url.Values, name string, verr *models.ValidationError) bool {
+	raw := q.Get(name)
+	if raw == "" {
+		return false
+	}
+
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		verr.Add(name, models.CodeNotBoolean, name+" tiene que ser true o false")
+	}
+
+	return v
+}
+
+// parseIntParam devuelve nil si el param no vino; si vino y no es un entero agrega el error a verr
+func parseIntParam(q url.Values, name string, verr *models.ValidationError) *int {
+	raw := q.Get(name)
+	if raw == "" {
+		return nil
+	}
+
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		verr.Add(name, models.CodeNotInteger, name+" invalido")
+		return nil
 	}
 
-	return f, nil
+	return &v
 }
 
 //TODO: estructurar bien el proyecto, los DTOS de response y de respuesta, packages etc
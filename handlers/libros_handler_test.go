@@ -1,133 +1,200 @@
 package handlers
 
 import (
+	"api-libros/httphelpers"
 	"api-libros/models"
 	"api-libros/repository"
 	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 
-type FakeLibrosRepo struct {
-	libros map[int]models.Libro
-}
+// newTestRepo arma el repo en memoria con los mismos 3 libros que usan todos los tests (ids 1, 2 y 3)
+func newTestRepo(t *testing.T) *repository.MemoryLibrosRepo {
+	t.Helper()
 
-func NewFakeLibrosRepo() *FakeLibrosRepo {
-	return &FakeLibrosRepo{
-		libros: map[int]models.Libro{
-			1: {ID: 1, Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965},
-			2: {ID: 2, Titulo: "1984", Autor: "George Orwell", Ano: 1949},
-			3: {ID: 3, Titulo: "Fahrenheit 451", Autor: "Ray Bradbury", Ano: 1953},
-		},
+	repo := repository.NewMemoryLibrosRepo()
+
+	libros := []models.LibroInput{
+		{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965},
+		{Titulo: "1984", Autor: "George Orwell", Ano: 1949},
+		{Titulo: "Fahrenheit 451", Autor: "Ray Bradbury", Ano: 1953},
 	}
-}
 
-func (f *FakeLibrosRepo) GetAll(ctx context.Context) ([]models.Libro, error) {
-	res := []models.Libro{}
-	for _, l := range f.libros {
-		res = append(res, l)
+	for _, in := range libros {
+		if _, err := repo.Create(context.Background(), in); err != nil {
+			t.Fatalf("error cargando libros: %v", err)
+		}
 	}
-	return res, nil
+
+	return repo
 }
 
-func (f *FakeLibrosRepo) GetByID(ctx context.Context, id int) (*models.Libro, error) {
-	l, ok := f.libros[id]
-	if !ok {
-		return nil, repository.ErrNotFound
+// --------------------- METODOS DE PRUEBA ---------------------
+
+func TestLibros_GET_All(t *testing.T) {
+	repo := newTestRepo(t)
+	handler := NewLibrosHandler(repo)
+
+	req := httptest.NewRequest(http.MethodGet, "/libros", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Libros(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status esperado 200, vino %d", rr.Code)
 	}
 
-	return &l, nil
-}
+	var resp []models.Libro
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("json invalido: %v", err)
+	}
 
-func (f *FakeLibrosRepo) Create(ctx context.Context, in models.LibroInput) (*models.Libro, error) {
-	id := len(f.libros) + 1
-	l := models.Libro{
-		ID:     id,
-		Titulo: in.Titulo,
-		Autor:  in.Autor,
-		Ano:    in.Ano,
+	if len(resp) != 3 {
+		t.Fatalf("esperaba 3 libros, vinieron %d", len(resp))
 	}
-	f.libros[id] = l
-	return &l, nil
 }
 
-func (f *FakeLibrosRepo) Update(ctx context.Context, id int, in models.LibroInput) (*models.Libro, error) {
-	if _, ok := f.libros[id]; !ok {
-		return nil, repository.ErrNotFound
+func TestLibros_GET_Limit_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"limit por defecto", "", http.StatusOK, 2},
+		{"limit explicito", "?limit=3", http.StatusOK, 3},
+		{"limit igual al maximo", "?limit=5", http.StatusOK, 3},
+		{"limit mayor al maximo", "?limit=6", http.StatusBadRequest, 0},
 	}
 
-	l := models.Libro{
-		ID:     id,
-		Titulo: in.Titulo,
-		Autor:  in.Autor,
-		Ano:    in.Ano,
-	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo, WithPageSize(2, 5))
 
-	f.libros[id] = l
-	return &l, nil
+			req := httptest.NewRequest(http.MethodGet, "/libros"+tt.query, nil)
+			rr := httptest.NewRecorder()
 
-}
+			handler.Libros(rr, req)
 
-func (f *FakeLibrosRepo) Patch(ctx context.Context, id int, in models.LibroPatch) (*models.Libro, error) {
-	existing, ok := f.libros[id]
-	if !ok {
-		return nil, repository.ErrNotFound
-	}
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
+			}
 
-	if in.Titulo != nil {
-		existing.Titulo = *in.Titulo
-	}
-	if in.Autor != nil {
-		existing.Autor = *in.Autor
-	}
-	if in.Ano != nil {
-		existing.Ano = *in.Ano
-	}
+			if tt.wantStatus == http.StatusOK {
+				resp := decodeJSON[[]models.Libro](t, rr)
 
-	f.libros[id] = existing
-	return &existing, nil
+				if len(resp) != tt.wantCount {
+					t.Fatalf("esperaba %d libros, vinieron %d", tt.wantCount, len(resp))
+				}
+			}
+		})
+	}
 }
 
-func (f *FakeLibrosRepo) Delete(ctx context.Context, id int) error {
-
-	// if borrado == nil { //el id NUNCA VA A SER 0 a menos que no se encuentre un libro con ese id
-	// 	return repository.ErrNotFound
-	// }
-	_, ok := f.libros[id]
-	if !ok {
-		return repository.ErrNotFound
+func TestLibros_GET_Sort_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"sin sort", "", http.StatusOK, []int{1, 2, 3}},
+		{"descendente", "?sort=-ano", http.StatusOK, []int{1, 3, 2}},
+		{"por titulo", "?sort=titulo", http.StatusOK, []int{2, 1, 3}},
+		{"varias columnas", "?sort=autor,-ano", http.StatusOK, []int{1, 2, 3}},
+		{"columna no permitida", "?sort=precio", http.StatusBadRequest, nil},
+		{"columna repetida", "?sort=ano,-ano", http.StatusBadRequest, nil},
+		{"columna vacia", "?sort=ano,,titulo", http.StatusBadRequest, nil},
+		{"sql en sort", "?sort=" + url.QueryEscape("id;DROP TABLE libros"), http.StatusBadRequest, nil},
 	}
 
-	delete(f.libros, id)
-	return nil
-}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo)
 
-// --------------------- METODOS DE PRUEBA ---------------------
+			req := httptest.NewRequest(http.MethodGet, "/libros"+tt.query, nil)
+			rr := httptest.NewRecorder()
 
-func TestLibros_GET_All(t *testing.T) {
-	repo := NewFakeLibrosRepo()
-	handler := NewLibrosHandler(repo)
+			handler.Libros(rr, req)
 
-	req := httptest.NewRequest(http.MethodGet, "/libros", nil)
-	rr := httptest.NewRecorder()
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
 
-	handler.Libros(rr, req)
+			if tt.wantStatus != http.StatusOK {
+				problem := decodeJSON[httphelpers.Problem](t, rr)
+				if len(problem.Errors) == 0 || problem.Errors[0].Field != "sort" {
+					t.Fatalf("esperaba un error en el campo sort, vino %+v", problem.Errors)
+				}
+				return
+			}
 
-	if rr.Code != http.StatusOK {
-		t.Fatalf("status esperado 200, vino %d", rr.Code)
+			if got := ids(decodeJSON[[]models.Libro](t, rr)); !equalIDs(got, tt.wantIDs) {
+				t.Fatalf("orden esperado %v, vino %v", tt.wantIDs, got)
+			}
+		})
 	}
+}
 
-	var resp []models.Libro
-	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
-		t.Fatalf("json invalido: %v", err)
+func TestLibros_GET_Busqueda_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{"por autor", "?q=orwell", http.StatusOK, []int{2}},
+		{"por titulo sin tildes", "?q=FAHRENHEIT", http.StatusOK, []int{3}},
+		{"sin resultados", "?q=tolkien", http.StatusOK, []int{}},
+		{"q vacio", "?q=", http.StatusBadRequest, nil},
+		{"q con espacios", "?q=%20%20", http.StatusBadRequest, nil},
+		{"cursor sin sort", "?q=orwell&cursor=", http.StatusBadRequest, nil},
+		{"cursor con sort", "?q=orwell&cursor=&sort=id", http.StatusOK, nil},
+		{"fuzzy", "?q=orwel&fuzzy=true", http.StatusOK, []int{2}},
+		{"fuzzy sin q", "?fuzzy=true", http.StatusBadRequest, nil},
+		{"fuzzy invalido", "?q=orwel&fuzzy=si", http.StatusBadRequest, nil},
 	}
 
-	if len(resp) != 3 {
-		t.Fatalf("esperaba 3 libros, vinieron %d", len(resp))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLibrosHandler(newTestRepo(t))
+
+			req := httptest.NewRequest(http.MethodGet, "/libros"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.Libros(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantIDs == nil {
+				return
+			}
+
+			libros := decodeJSON[[]models.Libro](t, rr)
+			if got := ids(libros); !equalIDs(got, tt.wantIDs) {
+				t.Fatalf("ids esperados %v, vinieron %v", tt.wantIDs, got)
+			}
+
+			for _, l := range libros {
+				if strings.Contains(tt.query, "fuzzy") {
+					break // en fuzzy no hay resaltado
+				}
+				if l.Resaltado == nil || !strings.Contains(l.Resaltado.Titulo+l.Resaltado.Autor, "<mark>") {
+					t.Fatalf("falta el resaltado en %+v", l)
+				}
+			}
+		})
 	}
 }
 
@@ -158,7 +225,7 @@ func TestLibros_GET_ByID_TableDriven(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := NewFakeLibrosRepo()
+			repo := newTestRepo(t)
 			handler := NewLibrosHandler(repo)
 
 			req := httptest.NewRequest(
@@ -212,7 +279,7 @@ func TestLibros_POST_TableDriven(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := NewFakeLibrosRepo()
+			repo := newTestRepo(t)
 			handler := NewLibrosHandler(repo)
 
 			var req *http.Request
@@ -231,14 +298,129 @@ func TestLibros_POST_TableDriven(t *testing.T) {
 				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
 			}
 
-			if len(repo.libros) != tt.wantCount {
-				t.Fatalf("esperaba %d libros, hay %d", tt.wantCount, len(repo.libros))
+			if n := countLibros(t, repo); n != tt.wantCount {
+				t.Fatalf("esperaba %d libros, hay %d", tt.wantCount, n)
 			}
 		})
 	}
 }
 
 
+func TestLibros_Errores_ProblemJSON_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantCodes  map[string]string // field -> code
+	}{
+		{
+			name:       "POST con todos los campos invalidos",
+			method:     http.MethodPost,
+			url:        "/libros",
+			body:       `{"titulo": "", "autor": "  ", "ano": 0}`,
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"titulo": "required", "autor": "required", "ano": "must_be_positive"},
+		},
+		{
+			name:       "PATCH con campos vacios",
+			method:     http.MethodPatch,
+			url:        "/libros/1",
+			body:       `{"titulo": " ", "ano": -3}`,
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"titulo": "blank", "ano": "must_be_positive"},
+		},
+		{
+			name:       "tipo equivocado",
+			method:     http.MethodPost,
+			url:        "/libros",
+			body:       `{"titulo": "X", "autor": "Y", "ano": "dos mil"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"ano": "invalid_type"},
+		},
+		{
+			name:       "campo desconocido",
+			method:     http.MethodPost,
+			url:        "/libros",
+			body:       `{"titulo": "X", "autor": "Y", "ano": 2000, "hack": 1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"hack": "unknown_field"},
+		},
+		{
+			name:       "filtros invalidos",
+			method:     http.MethodGet,
+			url:        "/libros?from=abc&limit=-1&offset=x",
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"from": "not_integer", "offset": "not_integer", "limit": "negative"},
+		},
+		{
+			name:       "rango invertido",
+			method:     http.MethodGet,
+			url:        "/libros?from=2000&to=1990",
+			wantStatus: http.StatusBadRequest,
+			wantCodes:  map[string]string{"from": "invalid_range"},
+		},
+		{
+			name:       "no encontrado",
+			method:     http.MethodGet,
+			url:        "/libros/999",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLibrosHandler(newTestRepo(t))
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			if strings.HasPrefix(tt.url, "/libros/") {
+				handler.LibrosByID(rr, req)
+			} else {
+				handler.Libros(rr, req)
+			}
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != httphelpers.ProblemContentType {
+				t.Fatalf("Content-Type esperado %q, vino %q", httphelpers.ProblemContentType, ct)
+			}
+
+			resp := decodeJSON[httphelpers.Problem](t, rr)
+
+			if resp.Status != tt.wantStatus || resp.Title == "" || resp.Type == "" {
+				t.Fatalf("problem incompleto: %+v", resp)
+			}
+
+			if resp.Instance != tt.url {
+				t.Fatalf("instance esperado %q, vino %q", tt.url, resp.Instance)
+			}
+
+			got := map[string]string{}
+			for _, fe := range resp.Errors {
+				got[fe.Field] = fe.Code
+				if fe.Message == "" {
+					t.Fatalf("error sin mensaje: %+v", fe)
+				}
+			}
+
+			if len(got) != len(tt.wantCodes) {
+				t.Fatalf("errores esperados %v, vinieron %v", tt.wantCodes, got)
+			}
+
+			for field, code := range tt.wantCodes {
+				if got[field] != code {
+					t.Fatalf("campo %s: codigo esperado %q, vino %q", field, code, got[field])
+				}
+			}
+		})
+	}
+}
+
 func TestLibros_PUT_TableDriven(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -270,7 +452,7 @@ func TestLibros_PUT_TableDriven(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := NewFakeLibrosRepo()
+			repo := newTestRepo(t)
 			handler := NewLibrosHandler(repo)
 
 			req := newJSONRequest(http.MethodPut, "/libros/"+tt.id, tt.input)
@@ -312,7 +494,7 @@ func TestLibros_PATCH_TableDriven(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := NewFakeLibrosRepo()
+			repo := newTestRepo(t)
 			handler := NewLibrosHandler(repo)
 
 			req := newJSONRequest(http.MethodPatch, "/libros/"+tt.id, tt.patch)
@@ -358,7 +540,7 @@ func TestLibros_DELETE_TableDriven(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := NewFakeLibrosRepo()
+			repo := newTestRepo(t)
 			handler := NewLibrosHandler(repo)
 
 			req := httptest.NewRequest(http.MethodDelete, "/libros/"+tt.id, nil)
@@ -370,7 +552,8 @@ func TestLibros_DELETE_TableDriven(t *testing.T) {
 				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
 			}
 
-			_, exists := repo.libros[1]
+			_, err := repo.GetByID(context.Background(), 1)
+			exists := err == nil
 			if exists != tt.wantExists && tt.id == "1" {
 				t.Fatalf("estado del repo incorrecto")
 			}
@@ -378,6 +561,188 @@ func TestLibros_DELETE_TableDriven(t *testing.T) {
 	}
 }
 
+// TestLibros_IfMatch_TableDriven: el libro 1 esta en la version 2 (se edito una vez), "1" es un ETag viejo
+func TestLibros_IfMatch_TableDriven(t *testing.T) {
+	put := `{"titulo":"Dune Messiah","autor":"Frank Herbert","ano":1969}`
+	patch := `{"ano":1966}`
+
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		body       string
+		ifMatch    string
+		require    bool
+		wantStatus int
+		wantETag   string
+	}{
+		{"GET", http.MethodGet, "1", "", "", false, http.StatusOK, `"2"`},
+		{"PUT sin If-Match", http.MethodPut, "1", put, "", false, http.StatusOK, `"3"`},
+		{"PUT con la version actual", http.MethodPut, "1", put, `"2"`, false, http.StatusOK, `"3"`},
+		{"PUT con una version vieja", http.MethodPut, "1", put, `"1"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"PUT con *", http.MethodPut, "1", put, "*", false, http.StatusOK, `"3"`},
+		{"PUT con ETag debil", http.MethodPut, "1", put, `W/"2"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"PUT con basura", http.MethodPut, "1", put, "dos", false, http.StatusPreconditionFailed, `"2"`},
+		{"PUT con una lista que tiene la actual", http.MethodPut, "1", put, `"1", "2"`, false, http.StatusOK, `"3"`},
+		{"PUT con una lista de versiones viejas", http.MethodPut, "1", put, `"1", "7"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"PUT con la actual debil en la lista", http.MethodPut, "1", put, `"1", W/"2"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"DELETE con una lista que tiene la actual", http.MethodDelete, "1", "", `"2","5"`, false, http.StatusNoContent, ""},
+		{"PATCH con la version actual", http.MethodPatch, "1", patch, `"2"`, false, http.StatusOK, `"3"`},
+		{"PATCH con una version vieja", http.MethodPatch, "1", patch, `"1"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"DELETE con la version actual", http.MethodDelete, "1", "", `"2"`, false, http.StatusNoContent, ""},
+		{"DELETE con una version vieja", http.MethodDelete, "1", "", `"1"`, false, http.StatusPreconditionFailed, `"2"`},
+		{"libro inexistente", http.MethodPut, "999", put, `"1"`, false, http.StatusNotFound, ""},
+		{"PUT obligatorio sin If-Match", http.MethodPut, "1", put, "", true, http.StatusPreconditionRequired, ""},
+		{"PATCH obligatorio sin If-Match", http.MethodPatch, "1", patch, "", true, http.StatusPreconditionRequired, ""},
+		{"DELETE obligatorio sin If-Match", http.MethodDelete, "1", "", "", true, http.StatusPreconditionRequired, ""},
+		{"obligatorio con If-Match", http.MethodPatch, "1", patch, `"2"`, true, http.StatusOK, `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo, WithRequireIfMatch(tt.require))
+
+			if _, err := repo.Patch(context.Background(), 1, models.LibroPatch{Titulo: ptr("Dune")}); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.id, strings.NewReader(tt.body))
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.LibrosByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("ETag esperado %q, vino %q", tt.wantETag, got)
+			}
+
+			// el 412 trae el libro como esta, sin los cambios del request
+			if rr.Code == http.StatusPreconditionFailed {
+				if got := decodeJSON[models.Libro](t, rr); got.Titulo != "Dune" || got.Ano != 1965 {
+					t.Fatalf("esperaba el libro actual, vino %+v", got)
+				}
+			}
+			if rr.Code == http.StatusPreconditionFailed || rr.Code == http.StatusPreconditionRequired {
+				if l, err := repo.GetByID(context.Background(), 1); err != nil || l.Version != 2 {
+					t.Fatalf("el libro no tenia que cambiar: %+v, %v", l, err)
+				}
+			}
+		})
+	}
+}
+func TestLibros_GET_Condicional_TableDriven(t *testing.T) {
+	antes := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	despues := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name       string
+		url        string
+		headers    map[string]string
+		wantStatus int
+		wantETag   string
+	}{
+		{"sin condiciones", "/libros/1", nil, http.StatusOK, `"1"`},
+		{"If-None-Match igual", "/libros/1", map[string]string{"If-None-Match": `"1"`}, http.StatusNotModified, `"1"`},
+		{"If-None-Match debil", "/libros/1", map[string]string{"If-None-Match": `W/"1"`}, http.StatusNotModified, `"1"`},
+		{"If-None-Match en una lista", "/libros/1", map[string]string{"If-None-Match": `"7", "1"`}, http.StatusNotModified, `"1"`},
+		{"If-None-Match *", "/libros/1", map[string]string{"If-None-Match": "*"}, http.StatusNotModified, `"1"`},
+		{"If-None-Match distinto", "/libros/1", map[string]string{"If-None-Match": `"2"`}, http.StatusOK, `"1"`},
+		{"If-Modified-Since despues", "/libros/1", map[string]string{"If-Modified-Since": despues}, http.StatusNotModified, `"1"`},
+		{"If-Modified-Since antes", "/libros/1", map[string]string{"If-Modified-Since": antes}, http.StatusOK, `"1"`},
+		{"If-Modified-Since invalido", "/libros/1", map[string]string{"If-Modified-Since": "ayer"}, http.StatusOK, `"1"`},
+		{"If-None-Match le gana a If-Modified-Since", "/libros/1", map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": despues}, http.StatusOK, `"1"`},
+		{"por ISBN", "/libros/isbn/9780441013593", map[string]string{"If-None-Match": `"2"`}, http.StatusNotModified, `"2"`},
+		{"con disponibilidad no hay validadores", "/libros/1?disponibilidad=true", map[string]string{"If-None-Match": `"1"`}, http.StatusOK, ""},
+		{"libro inexistente", "/libros/999", map[string]string{"If-None-Match": "*"}, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo, WithCacheControl("no-cache"), WithEjemplares(repository.NewMemoryEjemplaresRepo(repo)))
+
+			if _, err := repo.Patch(context.Background(), 2, models.LibroPatch{ISBN: ptr("9780441013593")}); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.LibrosByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("ETag"); got != tt.wantETag {
+				t.Fatalf("ETag esperado %q, vino %q", tt.wantETag, got)
+			}
+
+			if tt.wantETag != "" {
+				if rr.Header().Get("Last-Modified") == "" || rr.Header().Get("Cache-Control") != "no-cache" {
+					t.Fatalf("faltan Last-Modified o Cache-Control: %v", rr.Header())
+				}
+			}
+			if rr.Code == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Fatalf("un 304 no lleva body, vino %q", rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestLibros_GET_Coleccion_Condicional: la misma pagina revalida con su ETag debil hasta que cambia un libro
+func TestLibros_GET_Coleccion_Condicional(t *testing.T) {
+	for _, url := range []string{"/libros?limit=2", "/libros?limit=2&envelope=true", "/libros?limit=2&cursor="} {
+		t.Run(url, func(t *testing.T) {
+			repo := newTestRepo(t)
+			handler := NewLibrosHandler(repo)
+
+			get := func(etag string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, url, nil)
+				if etag != "" {
+					req.Header.Set("If-None-Match", etag)
+				}
+				rr := httptest.NewRecorder()
+				handler.Libros(rr, req)
+				return rr
+			}
+
+			rr := get("")
+			etag := rr.Header().Get("ETag")
+			if rr.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+				t.Fatalf("esperaba 200 con un ETag debil, vino %d %q", rr.Code, etag)
+			}
+
+			if rr := get(etag); rr.Code != http.StatusNotModified {
+				t.Fatalf("con el mismo ETag: status esperado 304, vino %d", rr.Code)
+			}
+
+			// el tercero no esta en la primera pagina pero cambia el total (offset) o si hay siguiente (cursor)
+			if err := repo.Delete(context.Background(), 3); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if rr := get(etag); rr.Code != http.StatusOK {
+				t.Fatalf("despues de borrar: status esperado 200, vino %d", rr.Code)
+			}
+
+			etag = get("").Header().Get("ETag")
+			if _, err := repo.Patch(context.Background(), 1, models.LibroPatch{Ano: ptr(1966)}); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if rr := get(etag); rr.Code != http.StatusOK {
+				t.Fatalf("despues de editar: status esperado 200, vino %d", rr.Code)
+			}
+		})
+	}
+}
 
 // ---------- HELPERS ----------
 
@@ -396,6 +761,83 @@ func newJSONRequest(method, url string, body any) *http.Request {
 	return req
 }
 
+func TestLibros_ISBN_TableDriven(t *testing.T) {
+	const dune = "9780441013593" // se lo pongo al libro 1 antes de cada caso
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		body        string
+		wantStatus  int
+		wantISBN    string // isbn del libro que vuelve (solo en 200/201)
+		wantLibroID int    // libro_id del problem (solo en 409) o id del libro encontrado
+	}{
+		{"crear con isbn-10", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000,"isbn":"0-306-40615-2"}`, http.StatusCreated, "9780306406157", 0},
+		{"crear con isbn-13 con guiones", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000,"isbn":"978-0-306-40615-7"}`, http.StatusCreated, "9780306406157", 0},
+		{"crear con isbn-10 terminado en X", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000,"isbn":"080442957x"}`, http.StatusCreated, "9780804429573", 0},
+		{"crear sin isbn", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000}`, http.StatusCreated, "", 0},
+		{"digito verificador incorrecto", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000,"isbn":"0-306-40615-3"}`, http.StatusBadRequest, "", 0},
+		{"largo incorrecto", http.MethodPost, "/libros", `{"titulo":"X","autor":"Y","ano":2000,"isbn":"12345"}`, http.StatusBadRequest, "", 0},
+		{"crear duplicado con el isbn-10", http.MethodPost, "/libros", `{"titulo":"Dune","autor":"Frank Herbert","ano":1965,"isbn":"0441013597"}`, http.StatusConflict, "", 1},
+		{"PUT duplicado", http.MethodPut, "/libros/2", `{"titulo":"1984","autor":"George Orwell","ano":1949,"isbn":"` + dune + `"}`, http.StatusConflict, "", 1},
+		{"PATCH duplicado", http.MethodPatch, "/libros/3", `{"isbn":"` + dune + `"}`, http.StatusConflict, "", 1},
+		{"PATCH invalido", http.MethodPatch, "/libros/3", `{"isbn":"abc"}`, http.StatusBadRequest, "", 0},
+		{"PATCH vacio lo saca", http.MethodPatch, "/libros/1", `{"isbn":""}`, http.StatusOK, "", 0},
+		{"buscar por isbn-13", http.MethodGet, "/libros/isbn/978-0-441-01359-3", "", http.StatusOK, dune, 1},
+		{"buscar por isbn-10", http.MethodGet, "/libros/isbn/0441013597", "", http.StatusOK, dune, 1},
+		{"buscar inexistente", http.MethodGet, "/libros/isbn/9780306406157", "", http.StatusNotFound, "", 0},
+		{"buscar invalido", http.MethodGet, "/libros/isbn/abc", "", http.StatusBadRequest, "", 0},
+		{"metodo no permitido", http.MethodDelete, "/libros/isbn/" + dune, "", http.StatusMethodNotAllowed, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepo(t)
+			if _, err := repo.Patch(context.Background(), 1, models.LibroPatch{ISBN: ptr(dune)}); err != nil {
+				t.Fatalf("error cargando isbn: %v", err)
+			}
+			handler := NewLibrosHandler(repo)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			if tt.url == "/libros" {
+				handler.Libros(rr, req)
+			} else {
+				handler.LibrosByID(rr, req)
+			}
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			switch rr.Code {
+			case http.StatusOK, http.StatusCreated:
+				l := decodeJSON[models.Libro](t, rr)
+				if l.ISBN != tt.wantISBN {
+					t.Fatalf("isbn esperado %q, vino %q", tt.wantISBN, l.ISBN)
+				}
+				if tt.wantLibroID != 0 && l.ID != tt.wantLibroID {
+					t.Fatalf("esperaba el libro %d, vino %d", tt.wantLibroID, l.ID)
+				}
+
+			case http.StatusConflict:
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if p.LibroID != tt.wantLibroID || p.Type != httphelpers.ProblemTypeISBN {
+					t.Fatalf("esperaba un problem de isbn duplicado con libro_id %d, vino %+v", tt.wantLibroID, p)
+				}
+
+			case http.StatusBadRequest:
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != "isbn" || p.Errors[0].Code != models.CodeInvalidISBN {
+					t.Fatalf("esperaba un error invalid_isbn en isbn, vino %+v", p.Errors)
+				}
+			}
+		})
+	}
+}
+
 func decodeJSON[T any](t *testing.T, rr *httptest.ResponseRecorder) T {
 	t.Helper()
 
@@ -406,6 +848,16 @@ func decodeJSON[T any](t *testing.T, rr *httptest.ResponseRecorder) T {
 	return out
 }
 
+func countLibros(t *testing.T, repo repository.LibrosRepository) int {
+	t.Helper()
+
+	libros, err := repo.GetAll(context.Background(), models.LibroFilter{Limit: 1000})
+	if err != nil {
+		t.Fatalf("error contando libros: %v", err)
+	}
+	return len(libros)
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type MultasHandler struct {
+	repo     repository.MultasRepository
+	politica models.PoliticaPrestamo // para saber si el saldo bloquea al socio
+	now      func() time.Time
+
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewMultasHandler(repo repository.MultasRepository, politica models.PoliticaPrestamo, now func() time.Time, defaultLimit, maxLimit int) *MultasHandler {
+	return &MultasHandler{repo: repo, politica: politica, now: now, defaultLimit: defaultLimit, maxLimit: maxLimit}
+}
+
+// SocioMultas responde GET /socios/{id}/multas (?tipo, limit, offset): los movimientos de la cuenta en orden.
+func (h *MultasHandler) SocioMultas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	filtro, err := h.parseMultaFilter(r)
+	if err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	multas, err := h.repo.GetBySocio(r.Context(), id, filtro)
+	if h.respondRepoError(w, r, err, "Error al consultar") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, multas)
+}
+
+// Saldo responde GET /socios/{id}/multas/saldo: cuanto debe el socio y si eso le impide pedir prestado.
+func (h *MultasHandler) Saldo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	s, err := h.repo.Saldo(r.Context(), id)
+	if h.respondRepoError(w, r, err, "Error al consultar") {
+		return
+	}
+	s.Bloqueado = h.politica.Bloqueado(s.Saldo)
+
+	httphelpers.RespondJSON(w, http.StatusOK, s)
+}
+
+// Pagos responde POST /socios/{id}/multas/pagos
+func (h *MultasHandler) Pagos(w http.ResponseWriter, r *http.Request) {
+	h.registrar(w, r, models.MultaPago)
+}
+
+// Condonaciones responde POST /socios/{id}/multas/condonaciones. Tiene que venir con una nota.
+func (h *MultasHandler) Condonaciones(w http.ResponseWriter, r *http.Request) {
+	h.registrar(w, r, models.MultaCondonacion)
+}
+
+func (h *MultasHandler) registrar(w http.ResponseWriter, r *http.Request, tipo string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var input models.MovimientoInput
+
+	if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+		httphelpers.RespondDecodeError(w, r, err)
+		return
+	}
+
+	if err := input.Validate(tipo); err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	salida, err := h.repo.Registrar(r.Context(), models.NuevoMovimiento{
+		SocioID: id,
+		Tipo:    tipo,
+		Monto:   input.Monto,
+		Fecha:   models.FechaDe(h.now()),
+		Nota:    input.Nota,
+	})
+	if h.respondRepoError(w, r, err, "Error al registrar el movimiento") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusCreated, salida)
+}
+
+// respondRepoError traduce los errores del repo a la respuesta. Devuelve true si respondio.
+func (h *MultasHandler) respondRepoError(w http.ResponseWriter, r *http.Request, err error, msg500 string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrSocioNotFound):
+		httphelpers.RespondError(w, r, "socio no encontrado", http.StatusNotFound)
+	case errors.Is(err, repository.ErrMontoExcedeSaldo):
+		httphelpers.RespondError(w, r, "el monto es mayor que lo que debe el socio", http.StatusConflict)
+	default:
+		httphelpers.RespondError(w, r, msg500, http.StatusInternalServerError)
+	}
+	return true
+}
+
+func (h *MultasHandler) parseMultaFilter(r *http.Request) (models.MultaFilter, error) {
+	q := r.URL.Query()
+
+	var f models.MultaFilter
+	var verr models.ValidationError
+
+	if q.Has("tipo") {
+		tipo := q.Get("tipo")
+		f.Tipo = &tipo
+	}
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, verr.Err()
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMultas arma los ejemplares de newTestEjemplares y los socios de newTestSocios, con "hoy" fijo
+// en el 2024-03-20. Ana devolvio hoy el ejemplar 3, que vencia el 15: debe $5 (500 centavos) y con una
+// deuda maxima de $3 no puede pedir prestado. Bruno no debe nada.
+func newTestMultas(t *testing.T) (*PrestamosHandler, *MultasHandler) {
+	t.Helper()
+
+	_, ejemplares := newTestEjemplares(t)
+	socios := newTestSocios(t)
+	prestamos := repository.NewMemoryPrestamosRepo(ejemplares, socios)
+	multas := repository.NewMemoryMultasRepo(prestamos)
+
+	ctx := context.Background()
+	politica := models.PoliticaPrestamo{Dias: 14, MaxRenovaciones: 1, DiasRetiro: 3, DeudaMaxima: 300}
+	hoy := models.NewFecha(2024, time.March, 20)
+
+	p, err := prestamos.Prestar(ctx, politica.NuevoPrestamo(models.PrestamoInput{EjemplarID: 3, SocioID: 1}, models.NewFecha(2024, time.March, 1)))
+	if err != nil {
+		t.Fatalf("error cargando prestamos: %v", err)
+	}
+	if _, err := prestamos.Devolver(ctx, p.ID, politica.Devolucion(hoy)); err != nil {
+		t.Fatalf("error devolviendo: %v", err)
+	}
+	if _, err := multas.Calcular(ctx, hoy, models.PoliticaMulta{PorDia: 100}); err != nil {
+		t.Fatalf("error calculando multas: %v", err)
+	}
+
+	now := func() time.Time { return time.Date(2024, time.March, 20, 15, 30, 0, 0, time.UTC) }
+	return NewPrestamosHandler(prestamos, politica, now, 50, 500), NewMultasHandler(multas, politica, now, 50, 500)
+}
+
+func TestSocioMultas_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		socio      string
+		query      string
+		wantStatus int
+		wantCount  int // solo en 200
+	}{
+		{"movimientos", http.MethodGet, "1", "", http.StatusOK, 1},
+		{"solo pagos", http.MethodGet, "1", "?tipo=pago", http.StatusOK, 0},
+		{"socio sin multas", http.MethodGet, "2", "", http.StatusOK, 0},
+		{"tipo invalido", http.MethodGet, "1", "?tipo=regalo", http.StatusBadRequest, 0},
+		{"limit muy grande", http.MethodGet, "1", "?limit=1000", http.StatusBadRequest, 0},
+		{"socio inexistente", http.MethodGet, "99", "", http.StatusNotFound, 0},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest, 0},
+		{"metodo no permitido", http.MethodPost, "1", "", http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestMultas(t)
+
+			req := httptest.NewRequest(tt.method, "/socios/"+tt.socio+"/multas"+tt.query, nil)
+			req.SetPathValue("id", tt.socio)
+			rr := httptest.NewRecorder()
+
+			handler.SocioMultas(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				if got := decodeJSON[[]models.Multa](t, rr); len(got) != tt.wantCount {
+					t.Fatalf("esperaba %d movimientos, vinieron %+v", tt.wantCount, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMultasSaldo_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		socio      string
+		wantStatus int
+		want       models.SaldoMultas // solo en 200
+	}{
+		{"debe mas del maximo", "1", http.StatusOK, models.SaldoMultas{SocioID: 1, Cargos: 500, Saldo: 500, Bloqueado: true}},
+		{"no debe nada", "2", http.StatusOK, models.SaldoMultas{SocioID: 2}},
+		{"socio inexistente", "99", http.StatusNotFound, models.SaldoMultas{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestMultas(t)
+
+			req := httptest.NewRequest(http.MethodGet, "/socios/"+tt.socio+"/multas/saldo", nil)
+			req.SetPathValue("id", tt.socio)
+			rr := httptest.NewRecorder()
+
+			handler.Saldo(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				if got := decodeJSON[models.SaldoMultas](t, rr); got != tt.want {
+					t.Fatalf("esperaba %+v, vino %+v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMultasMovimientos_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		accion     func(h *MultasHandler, w http.ResponseWriter, r *http.Request)
+		socio      string
+		body       string
+		wantStatus int
+		wantField  string // campo con error en el problem de validacion
+	}{
+		{"pago", (*MultasHandler).Pagos, "1", `{"monto":200}`, http.StatusCreated, ""},
+		{"pago de todo", (*MultasHandler).Pagos, "1", `{"monto":500}`, http.StatusCreated, ""},
+		{"pago mayor que la deuda", (*MultasHandler).Pagos, "1", `{"monto":501}`, http.StatusConflict, ""},
+		{"pago sin deuda", (*MultasHandler).Pagos, "2", `{"monto":1}`, http.StatusConflict, ""},
+		{"monto 0", (*MultasHandler).Pagos, "1", `{"monto":0}`, http.StatusBadRequest, "monto"},
+		{"socio inexistente", (*MultasHandler).Pagos, "99", `{"monto":1}`, http.StatusNotFound, ""},
+		{"condonacion", (*MultasHandler).Condonaciones, "1", `{"monto":500,"nota":"primera vez"}`, http.StatusCreated, ""},
+		{"condonacion sin nota", (*MultasHandler).Condonaciones, "1", `{"monto":500}`, http.StatusBadRequest, "nota"},
+		{"json invalido", (*MultasHandler).Pagos, "1", `{"monto":`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestMultas(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/socios/"+tt.socio+"/multas", strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.socio)
+			rr := httptest.NewRecorder()
+
+			tt.accion(handler, rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+// TestMultas_Bloqueo: Ana no puede pedir prestado hasta que paga lo suficiente
+func TestMultas_Bloqueo(t *testing.T) {
+	prestamos, multas := newTestMultas(t)
+
+	prestar := func() int {
+		rr := httptest.NewRecorder()
+		prestamos.Prestamos(rr, httptest.NewRequest(http.MethodPost, "/prestamos", strings.NewReader(`{"ejemplar_id":1,"socio_id":1}`)))
+		return rr.Code
+	}
+
+	if code := prestar(); code != http.StatusConflict {
+		t.Fatalf("con deuda: status esperado 409, vino %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/socios/1/multas/pagos", strings.NewReader(`{"monto":200}`))
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+	multas.Pagos(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("pago: status esperado 201, vino %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// debe 300, justo el maximo
+	if code := prestar(); code != http.StatusCreated {
+		t.Fatalf("despues de pagar: status esperado 201, vino %d", code)
+	}
+}
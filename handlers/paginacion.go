@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"net/http"
+	"strconv"
+)
+
+// listOffset responde GET /libros paginando con limit/offset. Siempre manda los links first/prev/next/last
+// en el header Link; con envelope ademas envuelve los items en {items, total, limit, offset, next, prev}.
+func (h *LibrosHandler) listOffset(w http.ResponseWriter, r *http.Request, f models.LibroFilter, envelope bool) {
+	items, err := h.repo.GetAll(r.Context(), f)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	// Count usa los mismos filtros que GetAll, asi el total coincide con lo que se puede recorrer
+	total, err := h.repo.Count(r.Context(), f)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	links := offsetLinks(r, f, total)
+	httphelpers.SetLinkHeader(w, links)
+
+	if httphelpers.NotModified(w, r, httphelpers.Validators{ETag: coleccionETag(items, total), CacheControl: h.cacheControl}) {
+		return
+	}
+
+	if !envelope {
+		httphelpers.RespondJSON(w, http.StatusOK, items)
+		return
+	}
+
+	page := models.LibrosEnvelope{Items: items, Total: total, Limit: f.Limit, Offset: f.Offset}
+	for _, l := range links {
+		switch l.Rel {
+		case "next":
+			page.Next = l.URL
+		case "prev":
+			page.Prev = l.URL
+		}
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, page)
+}
+
+// offsetLinks arma first/prev/next/last. Con limit 0 no hay paginas, solo first.
+func offsetLinks(r *http.Request, f models.LibroFilter, total int) []httphelpers.Link {
+	at := func(offset int) string {
+		if offset <= 0 {
+			return httphelpers.URLWith(r, map[string]string{"limit": strconv.Itoa(f.Limit)}, "offset")
+		}
+		return httphelpers.URLWith(r, map[string]string{"limit": strconv.Itoa(f.Limit), "offset": strconv.Itoa(offset)})
+	}
+
+	links := []httphelpers.Link{{Rel: "first", URL: at(0)}}
+
+	if f.Limit <= 0 {
+		return links
+	}
+
+	if f.Offset > 0 {
+		links = append(links, httphelpers.Link{Rel: "prev", URL: at(max(f.Offset-f.Limit, 0))})
+	}
+
+	if f.Offset+f.Limit < total {
+		links = append(links, httphelpers.Link{Rel: "next", URL: at(f.Offset + f.Limit)})
+	}
+
+	last := 0
+	if total > 0 {
+		last = (total - 1) / f.Limit * f.Limit
+	}
+	links = append(links, httphelpers.Link{Rel: "last", URL: at(last)})
+
+	return links
+}
+
+// listCursor responde GET /libros en modo keyset: {items, next, prev}.
+// next y prev son cursores opacos; el cliente los manda tal cual en ?cursor= para moverse.
+func (h *LibrosHandler) listCursor(w http.ResponseWriter, r *http.Request, f models.LibroFilter) {
+	limit := f.Limit
+	backward := f.Cursor != nil && f.Cursor.Backward
+
+	// pido uno de mas para saber si hay otra pagina en esa direccion sin hacer un COUNT
+	f.Limit = limit + 1
+
+	items, err := h.repo.GetAll(r.Context(), f)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	// el ETag sale de lo que vino, con el de mas incluido: si desaparece, cambia si hay otra pagina
+	etag := coleccionETag(items, -1)
+
+	hasMore := len(items) > limit
+	if hasMore {
+		if backward {
+			items = items[1:] // hacia atras el de mas es el primero (el repo ya los devuelve ascendentes)
+		} else {
+			items = items[:limit]
+		}
+	}
+
+	page := models.LibrosPage{Items: items}
+
+	if len(items) > 0 {
+		first, last := items[0], items[len(items)-1]
+
+		// hacia adelante: hay anterior si vinimos de algun lado, hay siguiente si sobro uno.
+		// hacia atras es al reves: siempre hay siguiente (de ahi venimos) y anterior solo si sobro.
+		hasNext := hasMore || backward
+		hasPrev := f.Cursor != nil && (!backward || hasMore)
+
+		if hasNext {
+			page.Next = h.cursors.Encode(models.NewCursor(last, f.Sort, false))
+		}
+		if hasPrev {
+			page.Prev = h.cursors.Encode(models.NewCursor(first, f.Sort, true))
+		}
+	}
+
+	// en modo cursor no hay "last": para saber donde termina habria que recorrer todo
+	links := []httphelpers.Link{{Rel: "first", URL: httphelpers.URLWith(r, map[string]string{"cursor": ""})}}
+	if page.Prev != "" {
+		links = append(links, httphelpers.Link{Rel: "prev", URL: httphelpers.URLWith(r, map[string]string{"cursor": page.Prev})})
+	}
+	if page.Next != "" {
+		links = append(links, httphelpers.Link{Rel: "next", URL: httphelpers.URLWith(r, map[string]string{"cursor": page.Next})})
+	}
+	httphelpers.SetLinkHeader(w, links)
+
+	if httphelpers.NotModified(w, r, httphelpers.Validators{ETag: etag, CacheControl: h.cacheControl}) {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, page)
+}
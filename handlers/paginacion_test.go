@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"api-libros/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func getPage(t *testing.T, handler *LibrosHandler, query string) (int, models.LibrosPage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/libros?"+query, nil)
+	rr := httptest.NewRecorder()
+
+	handler.Libros(rr, req)
+
+	if rr.Code != http.StatusOK {
+		return rr.Code, models.LibrosPage{}
+	}
+	return rr.Code, decodeJSON[models.LibrosPage](t, rr)
+}
+
+func ids(libros []models.Libro) []int {
+	out := []int{}
+	for _, l := range libros {
+		out = append(out, l.ID)
+	}
+	return out
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLibros_GET_Cursor_IdaYVuelta(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.Create(context.Background(), models.LibroInput{Titulo: "Neuromancer", Autor: "William Gibson", Ano: 1984})
+	repo.Create(context.Background(), models.LibroInput{Titulo: "Solaris", Autor: "Stanislaw Lem", Ano: 1961})
+
+	handler := NewLibrosHandler(repo, WithCursorSecret([]byte("test")))
+
+	// primera pagina
+	status, p1 := getPage(t, handler, "cursor=&limit=2")
+	if status != http.StatusOK {
+		t.Fatalf("status esperado 200, vino %d", status)
+	}
+	if !equalIDs(ids(p1.Items), []int{1, 2}) || p1.Prev != "" || p1.Next == "" {
+		t.Fatalf("primera pagina incorrecta: %+v", p1)
+	}
+
+	// segunda
+	_, p2 := getPage(t, handler, "limit=2&cursor="+url.QueryEscape(p1.Next))
+	if !equalIDs(ids(p2.Items), []int{3, 4}) || p2.Prev == "" || p2.Next == "" {
+		t.Fatalf("segunda pagina incorrecta: %+v", p2)
+	}
+
+	// tercera y ultima
+	_, p3 := getPage(t, handler, "limit=2&cursor="+url.QueryEscape(p2.Next))
+	if !equalIDs(ids(p3.Items), []int{5}) || p3.Next != "" || p3.Prev == "" {
+		t.Fatalf("ultima pagina incorrecta: %+v", p3)
+	}
+
+	// volver para atras desde la ultima
+	_, back := getPage(t, handler, "limit=2&cursor="+url.QueryEscape(p3.Prev))
+	if !equalIDs(ids(back.Items), []int{3, 4}) || back.Next == "" || back.Prev == "" {
+		t.Fatalf("pagina anterior incorrecta: %+v", back)
+	}
+
+	_, first := getPage(t, handler, "limit=2&cursor="+url.QueryEscape(back.Prev))
+	if !equalIDs(ids(first.Items), []int{1, 2}) || first.Prev != "" || first.Next == "" {
+		t.Fatalf("vuelta a la primera pagina incorrecta: %+v", first)
+	}
+}
+
+func TestLibros_GET_Cursor_Ordenado(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.Create(context.Background(), models.LibroInput{Titulo: "Neuromancer", Autor: "William Gibson", Ano: 1965})
+	handler := NewLibrosHandler(repo, WithCursorSecret([]byte("test")))
+
+	// Dune y Neuromancer son de 1965: el empate lo desarma el titulo
+	want := [][]int{{4, 1}, {3, 2}}
+
+	status, p1 := getPage(t, handler, "cursor=&limit=2&sort=-ano,-titulo")
+	if status != http.StatusOK || !equalIDs(ids(p1.Items), want[0]) {
+		t.Fatalf("primera pagina incorrecta (status %d): %+v", status, p1)
+	}
+
+	_, p2 := getPage(t, handler, "limit=2&sort=-ano,-titulo&cursor="+url.QueryEscape(p1.Next))
+	if !equalIDs(ids(p2.Items), want[1]) || p2.Next != "" {
+		t.Fatalf("segunda pagina incorrecta: %+v", p2)
+	}
+
+	_, back := getPage(t, handler, "limit=2&sort=-ano,-titulo&cursor="+url.QueryEscape(p2.Prev))
+	if !equalIDs(ids(back.Items), want[0]) || back.Prev != "" {
+		t.Fatalf("vuelta a la primera pagina incorrecta: %+v", back)
+	}
+}
+
+func TestLibros_GET_Cursor_Invalido_TableDriven(t *testing.T) {
+	otro := NewLibrosHandler(newTestRepo(t), WithCursorSecret([]byte("otra clave")))
+	_, p := getPage(t, otro, "cursor=&limit=1")
+
+	mismo := NewLibrosHandler(newTestRepo(t), WithCursorSecret([]byte("test")))
+	_, ordenado := getPage(t, mismo, "cursor=&limit=1&sort=-ano")
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"cursor firmado con otra clave", "cursor=" + url.QueryEscape(p.Next)},
+		{"cursor basura", "cursor=abc"},
+		{"cursor de otro orden", "sort=titulo&cursor=" + url.QueryEscape(ordenado.Next)},
+		{"cursor con offset", "cursor=&offset=2"},
+		{"cursor con limit 0", "cursor=&limit=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLibrosHandler(newTestRepo(t), WithCursorSecret([]byte("test")))
+
+			status, _ := getPage(t, handler, tt.query)
+			if status != http.StatusBadRequest {
+				t.Fatalf("status esperado 400, vino %d", status)
+			}
+		})
+	}
+}
+
+func TestLibros_GET_Envelope_TableDriven(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantIDs   []int
+		wantTotal int
+		wantNext  string
+		wantPrev  string
+		wantLink  string
+	}{
+		{
+			name:      "primera pagina",
+			query:     "envelope=true&limit=2",
+			wantIDs:   []int{1, 2},
+			wantTotal: 3,
+			wantNext:  "/libros?envelope=true&limit=2&offset=2",
+			wantLink:  `</libros?envelope=true&limit=2>; rel="first", </libros?envelope=true&limit=2&offset=2>; rel="next", </libros?envelope=true&limit=2&offset=2>; rel="last"`,
+		},
+		{
+			name:      "ultima pagina",
+			query:     "envelope=true&limit=2&offset=2",
+			wantIDs:   []int{3},
+			wantTotal: 3,
+			wantPrev:  "/libros?envelope=true&limit=2",
+			wantLink:  `</libros?envelope=true&limit=2>; rel="first", </libros?envelope=true&limit=2>; rel="prev", </libros?envelope=true&limit=2&offset=2>; rel="last"`,
+		},
+		{
+			name:      "el total respeta los filtros",
+			query:     "envelope=true&limit=1&autor=orwell",
+			wantIDs:   []int{2},
+			wantTotal: 1,
+			wantLink:  `</libros?autor=orwell&envelope=true&limit=1>; rel="first", </libros?autor=orwell&envelope=true&limit=1>; rel="last"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLibrosHandler(newTestRepo(t))
+
+			req := httptest.NewRequest(http.MethodGet, "/libros?"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.Libros(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("status esperado 200, vino %d: %s", rr.Code, rr.Body.String())
+			}
+
+			if got := rr.Header().Get("Link"); got != tt.wantLink {
+				t.Fatalf("Link esperado\n%s\nvino\n%s", tt.wantLink, got)
+			}
+
+			page := decodeJSON[models.LibrosEnvelope](t, rr)
+
+			if !equalIDs(ids(page.Items), tt.wantIDs) {
+				t.Fatalf("items esperados %v, vinieron %v", tt.wantIDs, ids(page.Items))
+			}
+			if page.Total != tt.wantTotal || page.Next != tt.wantNext || page.Prev != tt.wantPrev {
+				t.Fatalf("sobre incorrecto: %+v", page)
+			}
+		})
+	}
+}
+
+func TestLibros_GET_SinEnvelope_MandaLink(t *testing.T) {
+	handler := NewLibrosHandler(newTestRepo(t))
+
+	req := httptest.NewRequest(http.MethodGet, "/libros?limit=1&offset=1", nil)
+	rr := httptest.NewRecorder()
+
+	handler.Libros(rr, req)
+
+	// sin envelope la respuesta sigue siendo un array, pero los links van igual en el header
+	if got := len(decodeJSON[[]models.Libro](t, rr)); got != 1 {
+		t.Fatalf("esperaba 1 libro, vinieron %d", got)
+	}
+
+	link := rr.Header().Get("Link")
+	for _, want := range []string{`rel="first"`, `</libros?limit=1>; rel="prev"`, `</libros?limit=1&offset=2>; rel="next"`, `</libros?limit=1&offset=2>; rel="last"`} {
+		if !strings.Contains(link, want) {
+			t.Fatalf("al header Link le falta %s: %s", want, link)
+		}
+	}
+}
+
+func TestLibros_GET_Envelope_Invalido(t *testing.T) {
+	handler := NewLibrosHandler(newTestRepo(t))
+
+	status, _ := getPage(t, handler, "envelope=talvez")
+	if status != http.StatusBadRequest {
+		t.Fatalf("status esperado 400, vino %d", status)
+	}
+}
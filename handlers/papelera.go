@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Papelera responde GET /libros/papelera: los libros borrados que todavia no se purgaron, con los
+// mismos filtros y el mismo paginado que GET /libros.
+func (h *LibrosHandler) Papelera(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filtro, envelope, err := h.parseLibroFilter(r)
+	if err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+	filtro.Papelera = models.SoloBorrados
+
+	if r.URL.Query().Has("cursor") {
+		h.listCursor(w, r, filtro)
+		return
+	}
+
+	h.listOffset(w, r, filtro, envelope)
+}
+
+// Restaurar responde POST /libros/{id}/restaurar: saca el libro de la papelera y lo devuelve con su
+// ETag nuevo (restaurar tambien sube la version).
+func (h *LibrosHandler) Restaurar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.Restaurar(r.Context(), id)
+
+	var dup *repository.ISBNDuplicadoError
+	switch {
+	case errors.As(err, &dup):
+		respondISBNDuplicado(w, r, dup)
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrNoBorrado):
+		httphelpers.RespondError(w, r, "el libro no esta en la papelera", http.StatusConflict)
+		return
+	case err != nil:
+		httphelpers.RespondError(w, r, "error al restaurar", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", libroETag(salida))
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// esAdmin dice si el request trae el token de administrador en "Authorization: Bearer <token>".
+// Sin WithAdminToken nadie es admin.
+func (h *LibrosHandler) esAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) == 1
+}
+
+func respondSoloAdmin(w http.ResponseWriter, r *http.Request) {
+	httphelpers.RespondError(w, r, "include_deleted es solo para administradores", http.StatusForbidden)
+}
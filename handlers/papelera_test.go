@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"api-libros/models"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestPapelera es newTestRepo con el libro 2 (1984) en la papelera
+func newTestPapelera(t *testing.T) *LibrosHandler {
+	t.Helper()
+
+	repo := newTestRepo(t)
+	if err := repo.Delete(context.Background(), 2); err != nil {
+		t.Fatalf("error borrando: %v", err)
+	}
+
+	return NewLibrosHandler(repo, WithAdminToken("secreto"))
+}
+
+func TestLibros_Papelera_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		wantStatus int
+		wantIDs    []int // solo en 200
+	}{
+		{"lista los borrados", http.MethodGet, "", http.StatusOK, []int{2}},
+		{"con filtros", http.MethodGet, "?autor=bradbury", http.StatusOK, []int{}},
+		{"limit invalido", http.MethodGet, "?limit=abc", http.StatusBadRequest, nil},
+		{"metodo no permitido", http.MethodPost, "", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestPapelera(t)
+
+			req := httptest.NewRequest(tt.method, "/libros/papelera"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.Papelera(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				assertLibroIDs(t, decodeJSON[[]models.Libro](t, rr), tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestLibros_Restaurar_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		wantStatus int
+		wantETag   string
+	}{
+		{"ok", http.MethodPost, "2", http.StatusOK, `"3"`}, // borrar y restaurar suben la version
+		{"no esta en la papelera", http.MethodPost, "1", http.StatusConflict, ""},
+		{"no existe", http.MethodPost, "999", http.StatusNotFound, ""},
+		{"id invalido", http.MethodPost, "abc", http.StatusBadRequest, ""},
+		{"metodo no permitido", http.MethodGet, "2", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestPapelera(t)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.id+"/restaurar", nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			handler.Restaurar(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantETag != "" {
+				if got := rr.Header().Get("ETag"); got != tt.wantETag {
+					t.Fatalf("ETag esperado %s, vino %q", tt.wantETag, got)
+				}
+				if l := decodeJSON[models.Libro](t, rr); l.Borrado != nil {
+					t.Fatalf("el libro restaurado no tiene que venir con borrado: %+v", l)
+				}
+			}
+		})
+	}
+}
+
+func TestLibros_IncludeDeleted_TableDriven(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		auth        string
+		wantStatus  int
+		wantIDs     []int // solo en 200 de la lista
+		wantBorrado bool  // solo en 200 de un libro
+	}{
+		{"lista sin borrados", "/libros", "", http.StatusOK, []int{1, 3}, false},
+		{"lista con borrados", "/libros?include_deleted=true", "Bearer secreto", http.StatusOK, []int{1, 2, 3}, false},
+		{"lista sin token", "/libros?include_deleted=true", "", http.StatusForbidden, nil, false},
+		{"lista con otro token", "/libros?include_deleted=true", "Bearer otro", http.StatusForbidden, nil, false},
+		{"include_deleted=false no pide nada", "/libros?include_deleted=false", "", http.StatusOK, []int{1, 3}, false},
+		{"include_deleted invalido", "/libros?include_deleted=quizas", "Bearer secreto", http.StatusBadRequest, nil, false},
+		{"libro borrado", "/libros/2", "", http.StatusNotFound, nil, false},
+		{"libro borrado con borrados", "/libros/2?include_deleted=true", "Bearer secreto", http.StatusOK, nil, true},
+		{"libro vivo con borrados", "/libros/1?include_deleted=true", "Bearer secreto", http.StatusOK, nil, false},
+		{"libro sin token", "/libros/2?include_deleted=true", "secreto", http.StatusForbidden, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestPapelera(t)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rr := httptest.NewRecorder()
+
+			if req.URL.Path == "/libros" {
+				handler.Libros(rr, req)
+			} else {
+				handler.LibrosByID(rr, req)
+			}
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code != http.StatusOK {
+				return
+			}
+
+			if tt.wantIDs != nil {
+				assertLibroIDs(t, decodeJSON[[]models.Libro](t, rr), tt.wantIDs)
+				return
+			}
+
+			if l := decodeJSON[models.Libro](t, rr); (l.Borrado != nil) != tt.wantBorrado {
+				t.Fatalf("borrado esperado %v, vino %+v", tt.wantBorrado, l)
+			}
+		})
+	}
+}
+
+func assertLibroIDs(t *testing.T, libros []models.Libro, want []int) {
+	t.Helper()
+
+	if len(libros) != len(want) {
+		t.Fatalf("esperaba libros %v, vinieron %+v", want, libros)
+	}
+	for i, l := range libros {
+		if l.ID != want[i] {
+			t.Fatalf("esperaba libros %v, vinieron %+v", want, libros)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type PrestamosHandler struct {
+	repo     repository.PrestamosRepository
+	politica models.PoliticaPrestamo
+	now      func() time.Time // en los tests se fija el dia
+
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewPrestamosHandler(repo repository.PrestamosRepository, politica models.PoliticaPrestamo, now func() time.Time, defaultLimit, maxLimit int) *PrestamosHandler {
+	return &PrestamosHandler{repo: repo, politica: politica, now: now, defaultLimit: defaultLimit, maxLimit: maxLimit}
+}
+
+func (h *PrestamosHandler) hoy() models.Fecha {
+	return models.FechaDe(h.now())
+}
+
+// Prestamos responde GET /prestamos (?socio_id, ejemplar_id, libro_id, activos, limit, offset)
+// y POST /prestamos, que presta un ejemplar a un socio desde hoy.
+func (h *PrestamosHandler) Prestamos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filtro, err := h.parsePrestamoFilter(r)
+		if err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		h.listar(w, r, filtro)
+
+	case http.MethodPost:
+		var input models.PrestamoInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Prestar(r.Context(), h.politica.NuevoPrestamo(input, h.hoy()))
+		switch {
+		case errors.Is(err, repository.ErrEjemplarNotFound):
+			var verr models.ValidationError
+			verr.Add("ejemplar_id", models.CodeNotFound, "el ejemplar no existe")
+			httphelpers.RespondValidationError(w, r, verr.Err())
+			return
+		case errors.Is(err, repository.ErrSocioNotFound):
+			var verr models.ValidationError
+			verr.Add("socio_id", models.CodeNotFound, "el socio no existe")
+			httphelpers.RespondValidationError(w, r, verr.Err())
+			return
+		case errors.Is(err, repository.ErrEjemplarPrestado):
+			httphelpers.RespondError(w, r, "el ejemplar ya esta prestado", http.StatusConflict)
+			return
+		case errors.Is(err, repository.ErrEjemplarReservado):
+			httphelpers.RespondError(w, r, "el ejemplar esta apartado para la reserva de otro socio", http.StatusConflict)
+			return
+		case errors.Is(err, repository.ErrSocioBloqueado):
+			httphelpers.RespondError(w, r, "el socio debe multas por encima del maximo, no puede pedir prestado", http.StatusConflict)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "Error al registrar el prestamo", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusCreated, salida)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// Vencidos responde GET /prestamos/vencidos: los prestamos sin devolver que vencieron antes de hoy,
+// los mas atrasados primero. Acepta los mismos filtros que GET /prestamos.
+func (h *PrestamosHandler) Vencidos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filtro, err := h.parsePrestamoFilter(r)
+	if err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	hoy := h.hoy()
+	filtro.VencidosAl = &hoy
+
+	h.listar(w, r, filtro)
+}
+
+func (h *PrestamosHandler) listar(w http.ResponseWriter, r *http.Request, filtro models.PrestamoFilter) {
+	prestamos, err := h.repo.GetAll(r.Context(), filtro)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, prestamos)
+}
+
+// PrestamoByID responde GET /prestamos/{id}.
+func (h *PrestamosHandler) PrestamoByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.GetByID(r.Context(), id)
+	if h.respondRepoError(w, r, err, "Error al consultar") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// Devolucion responde POST /prestamos/{id}/devolucion: cierra el prestamo con fecha de hoy.
+func (h *PrestamosHandler) Devolucion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.Devolver(r.Context(), id, h.politica.Devolucion(h.hoy()))
+	if h.respondRepoError(w, r, err, "Error al registrar la devolucion") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// Renovacion responde POST /prestamos/{id}/renovacion: el prestamo pasa a vencer dentro de
+// politica.Dias dias contando desde hoy.
+func (h *PrestamosHandler) Renovacion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.Renovar(r.Context(), id, h.politica.Renovacion(h.hoy()))
+	if h.respondRepoError(w, r, err, "Error al renovar el prestamo") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// respondRepoError traduce los errores del repo a la respuesta. Devuelve true si respondio.
+func (h *PrestamosHandler) respondRepoError(w http.ResponseWriter, r *http.Request, err error, msg500 string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrPrestamoNotFound):
+		httphelpers.RespondError(w, r, "prestamo no encontrado", http.StatusNotFound)
+	case errors.Is(err, repository.ErrPrestamoDevuelto):
+		httphelpers.RespondError(w, r, "el prestamo ya fue devuelto", http.StatusConflict)
+	case errors.Is(err, repository.ErrPrestamoVencido):
+		httphelpers.RespondError(w, r, "el prestamo esta vencido, no se puede renovar", http.StatusConflict)
+	case errors.Is(err, repository.ErrSinRenovaciones):
+		httphelpers.RespondError(w, r, fmt.Sprintf("el prestamo ya se renovo %d veces", h.politica.MaxRenovaciones), http.StatusConflict)
+	default:
+		httphelpers.RespondError(w, r, msg500, http.StatusInternalServerError)
+	}
+	return true
+}
+
+func (h *PrestamosHandler) parsePrestamoFilter(r *http.Request) (models.PrestamoFilter, error) {
+	q := r.URL.Query()
+
+	var f models.PrestamoFilter
+	var verr models.ValidationError
+
+	f.SocioID = parseIntParam(q, "socio_id", &verr)
+	f.EjemplarID = parseIntParam(q, "ejemplar_id", &verr)
+	f.LibroID = parseIntParam(q, "libro_id", &verr)
+	f.Activos = parseBoolParam(q, "activos", &verr)
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, verr.Err()
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPrestamos arma los ejemplares de newTestEjemplares, los socios de newTestSocios y dos prestamos,
+// con "hoy" fijo en el 2024-03-20:
+//   - 1: ejemplar 1 a Ana desde el 2024-03-01, vencido el 15
+//   - 2: ejemplar 3 a Bruno desde el 2024-03-10, vence el 24
+func newTestPrestamos(t *testing.T) (*repository.MemoryEjemplaresRepo, *PrestamosHandler) {
+	t.Helper()
+
+	_, ejemplares := newTestEjemplares(t)
+	socios := newTestSocios(t)
+	prestamos := repository.NewMemoryPrestamosRepo(ejemplares, socios)
+
+	politica := models.PoliticaPrestamo{Dias: 14, MaxRenovaciones: 1}
+
+	for _, c := range []struct {
+		in    models.PrestamoInput
+		desde models.Fecha
+	}{
+		{models.PrestamoInput{EjemplarID: 1, SocioID: 1}, models.NewFecha(2024, time.March, 1)},
+		{models.PrestamoInput{EjemplarID: 3, SocioID: 2}, models.NewFecha(2024, time.March, 10)},
+	} {
+		if _, err := prestamos.Prestar(context.Background(), politica.NuevoPrestamo(c.in, c.desde)); err != nil {
+			t.Fatalf("error cargando prestamos: %v", err)
+		}
+	}
+
+	now := func() time.Time { return time.Date(2024, time.March, 20, 15, 30, 0, 0, time.UTC) }
+	return ejemplares, NewPrestamosHandler(prestamos, politica, now, 50, 500)
+}
+
+func TestPrestamos_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantField  string // campo con error en el problem de validacion
+	}{
+		{"listar", http.MethodGet, "/prestamos", "", http.StatusOK, ""},
+		{"listar por socio", http.MethodGet, "/prestamos?socio_id=1&activos=true", "", http.StatusOK, ""},
+		{"socio_id invalido", http.MethodGet, "/prestamos?socio_id=abc", "", http.StatusBadRequest, "socio_id"},
+		{"activos invalido", http.MethodGet, "/prestamos?activos=quizas", "", http.StatusBadRequest, "activos"},
+		{"prestar", http.MethodPost, "/prestamos", `{"ejemplar_id":2,"socio_id":1}`, http.StatusCreated, ""},
+		{"ya prestado", http.MethodPost, "/prestamos", `{"ejemplar_id":1,"socio_id":2}`, http.StatusConflict, ""},
+		{"ejemplar inexistente", http.MethodPost, "/prestamos", `{"ejemplar_id":99,"socio_id":1}`, http.StatusBadRequest, "ejemplar_id"},
+		{"socio inexistente", http.MethodPost, "/prestamos", `{"ejemplar_id":2,"socio_id":99}`, http.StatusBadRequest, "socio_id"},
+		{"faltan campos", http.MethodPost, "/prestamos", `{}`, http.StatusBadRequest, "ejemplar_id"},
+		{"metodo no permitido", http.MethodDelete, "/prestamos", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestPrestamos(t)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Prestamos(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+// TestPrestamos_POST_Fechas: el prestamo arranca hoy y vence a los Dias de la politica
+func TestPrestamos_POST_Fechas(t *testing.T) {
+	_, handler := newTestPrestamos(t)
+
+	rr := httptest.NewRecorder()
+	handler.Prestamos(rr, httptest.NewRequest(http.MethodPost, "/prestamos", strings.NewReader(`{"ejemplar_id":2,"socio_id":1}`)))
+
+	got := decodeJSON[models.Prestamo](t, rr)
+	want := models.Prestamo{
+		ID:         got.ID,
+		EjemplarID: 2,
+		LibroID:    1,
+		SocioID:    1,
+		Desde:      models.NewFecha(2024, time.March, 20),
+		Vence:      models.NewFecha(2024, time.April, 3),
+	}
+	if got != want {
+		t.Fatalf("esperaba %+v, vino %+v", want, got)
+	}
+}
+
+func TestPrestamos_Vencidos(t *testing.T) {
+	_, handler := newTestPrestamos(t)
+
+	rr := httptest.NewRecorder()
+	handler.Vencidos(rr, httptest.NewRequest(http.MethodGet, "/prestamos/vencidos", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status esperado 200, vino %d: %s", rr.Code, rr.Body.String())
+	}
+
+	got := decodeJSON[[]models.Prestamo](t, rr)
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Fatalf("esperaba solo el prestamo 1, vino %+v", got)
+	}
+}
+
+func TestPrestamoAcciones_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		accion     func(h *PrestamosHandler, w http.ResponseWriter, r *http.Request)
+		method     string
+		id         string
+		wantStatus int
+	}{
+		{"leer", (*PrestamosHandler).PrestamoByID, http.MethodGet, "1", http.StatusOK},
+		{"leer inexistente", (*PrestamosHandler).PrestamoByID, http.MethodGet, "99", http.StatusNotFound},
+		{"leer id invalido", (*PrestamosHandler).PrestamoByID, http.MethodGet, "abc", http.StatusBadRequest},
+		{"devolver", (*PrestamosHandler).Devolucion, http.MethodPost, "1", http.StatusOK},
+		{"devolver inexistente", (*PrestamosHandler).Devolucion, http.MethodPost, "99", http.StatusNotFound},
+		{"devolver con GET", (*PrestamosHandler).Devolucion, http.MethodGet, "1", http.StatusMethodNotAllowed},
+		{"renovar", (*PrestamosHandler).Renovacion, http.MethodPost, "2", http.StatusOK},
+		{"renovar vencido", (*PrestamosHandler).Renovacion, http.MethodPost, "1", http.StatusConflict},
+		{"renovar inexistente", (*PrestamosHandler).Renovacion, http.MethodPost, "99", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestPrestamos(t)
+
+			req := httptest.NewRequest(tt.method, "/prestamos/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			tt.accion(handler, rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestPrestamos_Circuito: devolver dos veces y renovar de mas dan 409, y el ejemplar prestado no se puede borrar
+func TestPrestamos_Circuito(t *testing.T) {
+	ejemplares, handler := newTestPrestamos(t)
+
+	post := func(accion http.HandlerFunc, id string) int {
+		req := httptest.NewRequest(http.MethodPost, "/prestamos/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		accion(rr, req)
+		return rr.Code
+	}
+
+	if got := post(handler.Renovacion, "2"); got != http.StatusOK {
+		t.Fatalf("primera renovacion: status esperado 200, vino %d", got)
+	}
+	if got := post(handler.Renovacion, "2"); got != http.StatusConflict {
+		t.Fatalf("renovacion de mas: status esperado 409, vino %d", got)
+	}
+
+	eh := NewEjemplaresHandler(ejemplares, models.PoliticaPrestamo{DiasRetiro: 3}, time.Now)
+	req := httptest.NewRequest(http.MethodDelete, "/libros/2/ejemplares/3", nil)
+	req.SetPathValue("id", "2")
+	req.SetPathValue("ejemplar", "3")
+	rr := httptest.NewRecorder()
+	eh.EjemplarByID(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("borrar ejemplar prestado: status esperado 409, vino %d", rr.Code)
+	}
+
+	if got := post(handler.Devolucion, "2"); got != http.StatusOK {
+		t.Fatalf("devolver: status esperado 200, vino %d", got)
+	}
+	if got := post(handler.Devolucion, "2"); got != http.StatusConflict {
+		t.Fatalf("devolver dos veces: status esperado 409, vino %d", got)
+	}
+}
+
+// TestPrestamos_BorrarLibroPrestado: como con el ejemplar, el libro no va a la papelera con un ejemplar
+// prestado; en un batch la operacion falla con el mismo 409
+func TestPrestamos_BorrarLibroPrestado(t *testing.T) {
+	libros, ejemplares := newTestEjemplares(t)
+	prestamos := repository.NewMemoryPrestamosRepo(ejemplares, newTestSocios(t))
+
+	np := models.PoliticaPrestamo{Dias: 14}.NuevoPrestamo(models.PrestamoInput{EjemplarID: 3, SocioID: 1}, models.NewFecha(2024, time.March, 1))
+	if _, err := prestamos.Prestar(context.Background(), np); err != nil {
+		t.Fatalf("error prestando: %v", err)
+	}
+
+	handler := NewLibrosHandler(libros)
+
+	rr := httptest.NewRecorder()
+	handler.LibrosByID(rr, httptest.NewRequest(http.MethodDelete, "/libros/2", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("DELETE: status esperado 409, vino %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Batch(rr, httptest.NewRequest(http.MethodPost, "/libros:batch", strings.NewReader(`[{"op":"borrar","id":2}]`)))
+	items := decodeJSON[[]resultadoOp](t, rr)
+	if len(items) != 1 || items[0].Status != http.StatusConflict {
+		t.Fatalf("batch: esperaba un 409, vino %+v", items)
+	}
+
+	if _, err := libros.GetByID(context.Background(), 2); err != nil {
+		t.Fatalf("el libro no tenia que ir a la papelera: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type ReservasHandler struct {
+	repo     repository.ReservasRepository
+	politica models.PoliticaPrestamo // para el plazo de retiro
+	now      func() time.Time
+
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewReservasHandler(repo repository.ReservasRepository, politica models.PoliticaPrestamo, now func() time.Time, defaultLimit, maxLimit int) *ReservasHandler {
+	return &ReservasHandler{repo: repo, politica: politica, now: now, defaultLimit: defaultLimit, maxLimit: maxLimit}
+}
+
+func (h *ReservasHandler) hoy() models.Fecha {
+	return models.FechaDe(h.now())
+}
+
+// LibroReservas responde GET /libros/{id}/reservas (la cola, en orden) y POST /libros/{id}/reservas,
+// que pone a un socio al final de la cola. Solo se puede reservar si no hay ejemplares libres.
+func (h *ReservasHandler) LibroReservas(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		cola, err := h.repo.GetByLibro(r.Context(), id)
+		if h.respondRepoError(w, r, err, "Error al consultar") {
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, cola)
+
+	case http.MethodPost:
+		var input models.ReservaInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		hoy := h.hoy()
+		salida, err := h.repo.Reservar(r.Context(), models.NuevaReserva{
+			LibroID:      id,
+			SocioID:      input.SocioID,
+			Creada:       hoy,
+			RetirarHasta: h.politica.RetirarHasta(hoy),
+		})
+		if errors.Is(err, repository.ErrSocioNotFound) {
+			var verr models.ValidationError
+			verr.Add("socio_id", models.CodeNotFound, "el socio no existe")
+			httphelpers.RespondValidationError(w, r, verr.Err())
+			return
+		}
+		if h.respondRepoError(w, r, err, "Error al registrar la reserva") {
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusCreated, salida)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// Reservas responde GET /reservas (?socio_id, libro_id, activas, limit, offset).
+func (h *ReservasHandler) Reservas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filtro, err := h.parseReservaFilter(r)
+	if err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	reservas, err := h.repo.GetAll(r.Context(), filtro)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, reservas)
+}
+
+// ReservaByID responde GET /reservas/{id}.
+func (h *ReservasHandler) ReservaByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.GetByID(r.Context(), id)
+	if h.respondRepoError(w, r, err, "Error al consultar") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// Cancelacion responde POST /reservas/{id}/cancelacion: saca la reserva de la cola. Si tenia un
+// ejemplar apartado, pasa al siguiente de la cola.
+func (h *ReservasHandler) Cancelacion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	salida, err := h.repo.Cancelar(r.Context(), id, h.politica.RetirarHasta(h.hoy()))
+	if h.respondRepoError(w, r, err, "Error al cancelar la reserva") {
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, salida)
+}
+
+// respondRepoError traduce los errores del repo a la respuesta. Devuelve true si respondio.
+func (h *ReservasHandler) respondRepoError(w http.ResponseWriter, r *http.Request, err error, msg500 string) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, repository.ErrNotFound):
+		httphelpers.RespondError(w, r, "libro no encontrado", http.StatusNotFound)
+	case errors.Is(err, repository.ErrReservaNotFound):
+		httphelpers.RespondError(w, r, "reserva no encontrada", http.StatusNotFound)
+	case errors.Is(err, repository.ErrReservaDuplicada):
+		httphelpers.RespondError(w, r, "el socio ya esta en la cola de ese libro", http.StatusConflict)
+	case errors.Is(err, repository.ErrHayDisponibles):
+		httphelpers.RespondError(w, r, "el libro tiene ejemplares disponibles, no hace falta reservarlo", http.StatusConflict)
+	case errors.Is(err, repository.ErrReservaCerrada):
+		httphelpers.RespondError(w, r, "la reserva ya no esta en la cola", http.StatusConflict)
+	default:
+		httphelpers.RespondError(w, r, msg500, http.StatusInternalServerError)
+	}
+	return true
+}
+
+func (h *ReservasHandler) parseReservaFilter(r *http.Request) (models.ReservaFilter, error) {
+	q := r.URL.Query()
+
+	var f models.ReservaFilter
+	var verr models.ValidationError
+
+	f.SocioID = parseIntParam(q, "socio_id", &verr)
+	f.LibroID = parseIntParam(q, "libro_id", &verr)
+	f.Activas = parseBoolParam(q, "activas", &verr)
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, verr.Err()
+}
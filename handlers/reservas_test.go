@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestReservas arma los ejemplares de newTestEjemplares y los socios de newTestSocios, con "hoy" fijo
+// en el 2024-03-20. El unico ejemplar de 1984 (el 3) esta prestado a Bruno (prestamo 1) y Ana esta
+// primera en la cola de 1984 (reserva 1). Dune tiene sus dos ejemplares libres.
+func newTestReservas(t *testing.T) (*PrestamosHandler, *ReservasHandler) {
+	t.Helper()
+
+	_, ejemplares := newTestEjemplares(t)
+	socios := newTestSocios(t)
+	prestamos := repository.NewMemoryPrestamosRepo(ejemplares, socios)
+	reservas := repository.NewMemoryReservasRepo(prestamos)
+
+	politica := models.PoliticaPrestamo{Dias: 14, MaxRenovaciones: 1, DiasRetiro: 3}
+	desde := models.NewFecha(2024, time.March, 10)
+
+	if _, err := prestamos.Prestar(context.Background(), politica.NuevoPrestamo(models.PrestamoInput{EjemplarID: 3, SocioID: 2}, desde)); err != nil {
+		t.Fatalf("error cargando prestamos: %v", err)
+	}
+	if _, err := reservas.Reservar(context.Background(), models.NuevaReserva{LibroID: 2, SocioID: 1, Creada: desde}); err != nil {
+		t.Fatalf("error cargando reservas: %v", err)
+	}
+
+	now := func() time.Time { return time.Date(2024, time.March, 20, 15, 30, 0, 0, time.UTC) }
+	return NewPrestamosHandler(prestamos, politica, now, 50, 500), NewReservasHandler(reservas, politica, now, 50, 500)
+}
+
+func TestLibroReservas_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		libro      string
+		body       string
+		wantStatus int
+		wantField  string // campo con error en el problem de validacion
+	}{
+		{"cola", http.MethodGet, "2", "", http.StatusOK, ""},
+		{"cola de libro inexistente", http.MethodGet, "99", "", http.StatusNotFound, ""},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest, ""},
+		{"reservar", http.MethodPost, "2", `{"socio_id":2}`, http.StatusCreated, ""},
+		{"ya esta en la cola", http.MethodPost, "2", `{"socio_id":1}`, http.StatusConflict, ""},
+		{"hay ejemplares libres", http.MethodPost, "1", `{"socio_id":1}`, http.StatusConflict, ""},
+		{"libro inexistente", http.MethodPost, "99", `{"socio_id":1}`, http.StatusNotFound, ""},
+		{"socio inexistente", http.MethodPost, "2", `{"socio_id":99}`, http.StatusBadRequest, "socio_id"},
+		{"falta socio", http.MethodPost, "2", `{}`, http.StatusBadRequest, "socio_id"},
+		{"metodo no permitido", http.MethodDelete, "2", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestReservas(t)
+
+			req := httptest.NewRequest(tt.method, "/libros/"+tt.libro+"/reservas", strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.libro)
+			rr := httptest.NewRecorder()
+
+			handler.LibroReservas(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestReservas_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantCount  int // solo en 200
+	}{
+		{"listar", http.MethodGet, "/reservas", http.StatusOK, 1},
+		{"por socio", http.MethodGet, "/reservas?socio_id=2", http.StatusOK, 0},
+		{"activas de un libro", http.MethodGet, "/reservas?libro_id=2&activas=true", http.StatusOK, 1},
+		{"socio_id invalido", http.MethodGet, "/reservas?socio_id=abc", http.StatusBadRequest, 0},
+		{"activas invalido", http.MethodGet, "/reservas?activas=quizas", http.StatusBadRequest, 0},
+		{"limit muy grande", http.MethodGet, "/reservas?limit=1000", http.StatusBadRequest, 0},
+		{"metodo no permitido", http.MethodPost, "/reservas", http.StatusMethodNotAllowed, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestReservas(t)
+
+			rr := httptest.NewRecorder()
+			handler.Reservas(rr, httptest.NewRequest(tt.method, tt.url, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusOK {
+				if got := decodeJSON[[]models.Reserva](t, rr); len(got) != tt.wantCount {
+					t.Fatalf("esperaba %d reservas, vinieron %+v", tt.wantCount, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReservaAcciones_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		accion     func(h *ReservasHandler, w http.ResponseWriter, r *http.Request)
+		method     string
+		id         string
+		wantStatus int
+	}{
+		{"leer", (*ReservasHandler).ReservaByID, http.MethodGet, "1", http.StatusOK},
+		{"leer inexistente", (*ReservasHandler).ReservaByID, http.MethodGet, "99", http.StatusNotFound},
+		{"leer id invalido", (*ReservasHandler).ReservaByID, http.MethodGet, "abc", http.StatusBadRequest},
+		{"cancelar", (*ReservasHandler).Cancelacion, http.MethodPost, "1", http.StatusOK},
+		{"cancelar inexistente", (*ReservasHandler).Cancelacion, http.MethodPost, "99", http.StatusNotFound},
+		{"cancelar con GET", (*ReservasHandler).Cancelacion, http.MethodGet, "1", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, handler := newTestReservas(t)
+
+			req := httptest.NewRequest(tt.method, "/reservas/"+tt.id, nil)
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			tt.accion(handler, rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestReservas_Circuito: al devolver, el ejemplar queda apartado para Ana con plazo de retiro;
+// Bruno no se lo puede llevar y Ana si
+func TestReservas_Circuito(t *testing.T) {
+	prestamos, reservas := newTestReservas(t)
+
+	post := func(accion http.HandlerFunc, id, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/x/"+id, strings.NewReader(body))
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		accion(rr, req)
+		return rr
+	}
+
+	if rr := post(prestamos.Devolucion, "1", ""); rr.Code != http.StatusOK {
+		t.Fatalf("devolver: status esperado 200, vino %d", rr.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/reservas/1", nil)
+	req.SetPathValue("id", "1")
+	rr := httptest.NewRecorder()
+	reservas.ReservaByID(rr, req)
+
+	got := decodeJSON[models.Reserva](t, rr)
+	if got.Estado != models.ReservaDisponible || got.EjemplarID != 3 || got.RetirarHasta != models.NewFecha(2024, time.March, 23) {
+		t.Fatalf("esperaba el ejemplar 3 apartado hasta el 23, vino %+v", got)
+	}
+
+	if rr := post(prestamos.Prestamos, "", `{"ejemplar_id":3,"socio_id":2}`); rr.Code != http.StatusConflict {
+		t.Fatalf("prestar el ejemplar apartado a otro: status esperado 409, vino %d", rr.Code)
+	}
+	if rr := post(prestamos.Prestamos, "", `{"ejemplar_id":3,"socio_id":1}`); rr.Code != http.StatusCreated {
+		t.Fatalf("retirar la reserva: status esperado 201, vino %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := post(reservas.Cancelacion, "1", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("cancelar una reserva retirada: status esperado 409, vino %d", rr.Code)
+	}
+}
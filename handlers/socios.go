@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type SociosHandler struct {
+	repo repository.SociosRepository
+
+	defaultLimit int
+	maxLimit     int
+}
+
+func NewSociosHandler(repo repository.SociosRepository, defaultLimit, maxLimit int) *SociosHandler {
+	return &SociosHandler{repo: repo, defaultLimit: defaultLimit, maxLimit: maxLimit}
+}
+
+// Socios responde GET /socios (?nombre=, limit, offset) y POST /socios.
+func (h *SociosHandler) Socios(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filtro, err := h.parseSocioFilter(r)
+		if err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		socios, err := h.repo.GetAll(r.Context(), filtro)
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, socios)
+
+	case http.MethodPost:
+		var input models.SocioInput
+
+		if err := httphelpers.DecodeJSON(w, r, &input); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := input.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Create(r.Context(), input)
+		if errors.Is(err, repository.ErrSocioDuplicado) {
+			httphelpers.RespondError(w, r, "ya existe un socio con ese email", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al crear el socio", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusCreated, salida)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+// SocioByID responde GET, PUT y DELETE /socios/{id}.
+func (h *SociosHandler) SocioByID(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		httphelpers.RespondError(w, r, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		salida, err := h.repo.GetByID(r.Context(), id)
+		if errors.Is(err, repository.ErrSocioNotFound) {
+			httphelpers.RespondError(w, r, "socio no encontrado", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			httphelpers.RespondError(w, r, "Error al consultar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodPut:
+		var upd models.SocioInput
+
+		if err := httphelpers.DecodeJSON(w, r, &upd); err != nil {
+			httphelpers.RespondDecodeError(w, r, err)
+			return
+		}
+
+		if err := upd.Validate(); err != nil {
+			httphelpers.RespondValidationError(w, r, err)
+			return
+		}
+
+		salida, err := h.repo.Update(r.Context(), id, upd)
+		switch {
+		case errors.Is(err, repository.ErrSocioNotFound):
+			httphelpers.RespondError(w, r, "socio no encontrado", http.StatusNotFound)
+			return
+		case errors.Is(err, repository.ErrSocioDuplicado):
+			httphelpers.RespondError(w, r, "ya existe un socio con ese email", http.StatusConflict)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "error al actualizar", http.StatusInternalServerError)
+			return
+		}
+
+		httphelpers.RespondJSON(w, http.StatusOK, salida)
+
+	case http.MethodDelete:
+		err := h.repo.Delete(r.Context(), id)
+		switch {
+		case errors.Is(err, repository.ErrSocioNotFound):
+			httphelpers.RespondError(w, r, "socio no encontrado", http.StatusNotFound)
+			return
+		case errors.Is(err, repository.ErrSocioConPrestamos):
+			// el historial de prestamos no se borra, asi que el socio tampoco
+			httphelpers.RespondError(w, r, "el socio tiene prestamos, no se puede eliminar", http.StatusConflict)
+			return
+		case errors.Is(err, repository.ErrSocioConMultas):
+			// lo mismo con la cuenta de multas
+			httphelpers.RespondError(w, r, "el socio tiene movimientos de multas, no se puede eliminar", http.StatusConflict)
+			return
+		case err != nil:
+			httphelpers.RespondError(w, r, "no se pudo eliminar", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *SociosHandler) parseSocioFilter(r *http.Request) (models.SocioFilter, error) {
+	q := r.URL.Query()
+
+	var f models.SocioFilter
+	var verr models.ValidationError
+
+	if nombre := q.Get("nombre"); nombre != "" {
+		f.Nombre = &nombre
+	}
+
+	f.Limit = h.defaultLimit
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > h.maxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", h.maxLimit))
+		}
+		f.Limit = *v
+	}
+
+	if v := parseIntParam(q, "offset", &verr); v != nil {
+		f.Offset = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	return f, verr.Err()
+}
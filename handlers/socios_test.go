@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestSocios arma dos socios: Ana (1) y Bruno (2)
+func newTestSocios(t *testing.T) *repository.MemorySociosRepo {
+	t.Helper()
+
+	socios := repository.NewMemorySociosRepo()
+
+	for _, in := range []models.SocioInput{
+		{Nombre: "Ana Pérez", Email: "ana@example.com"},
+		{Nombre: "Bruno Díaz", Email: "bruno@example.com"},
+	} {
+		if _, err := socios.Create(context.Background(), in); err != nil {
+			t.Fatalf("error cargando socios: %v", err)
+		}
+	}
+
+	return socios
+}
+
+func TestSocios_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantField  string // campo con error en el problem de validacion
+	}{
+		{"listar", http.MethodGet, "/socios", "", http.StatusOK, ""},
+		{"listar por nombre", http.MethodGet, "/socios?nombre=ana", "", http.StatusOK, ""},
+		{"limit muy grande", http.MethodGet, "/socios?limit=1000", "", http.StatusBadRequest, "limit"},
+		{"crear", http.MethodPost, "/socios", `{"nombre":"Carla","email":"carla@example.com"}`, http.StatusCreated, ""},
+		{"email duplicado", http.MethodPost, "/socios", `{"nombre":"Otra Ana","email":"ANA@example.com"}`, http.StatusConflict, ""},
+		{"email invalido", http.MethodPost, "/socios", `{"nombre":"Carla","email":"carla.example.com"}`, http.StatusBadRequest, "email"},
+		{"sin nombre", http.MethodPost, "/socios", `{"email":"carla@example.com"}`, http.StatusBadRequest, "nombre"},
+		{"metodo no permitido", http.MethodDelete, "/socios", "", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSociosHandler(newTestSocios(t), 50, 500)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.Socios(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantField != "" {
+				p := decodeJSON[httphelpers.Problem](t, rr)
+				if len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField {
+					t.Fatalf("esperaba un error en %q, vino %+v", tt.wantField, p.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestSocioByID_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		id         string
+		body       string
+		wantStatus int
+	}{
+		{"leer", http.MethodGet, "1", "", http.StatusOK},
+		{"leer inexistente", http.MethodGet, "99", "", http.StatusNotFound},
+		{"id invalido", http.MethodGet, "abc", "", http.StatusBadRequest},
+		{"editar", http.MethodPut, "1", `{"nombre":"Ana María Pérez","email":"ana@example.com"}`, http.StatusOK},
+		{"editar con email de otro", http.MethodPut, "1", `{"nombre":"Ana","email":"bruno@example.com"}`, http.StatusConflict},
+		{"editar inexistente", http.MethodPut, "99", `{"nombre":"x","email":"x@example.com"}`, http.StatusNotFound},
+		{"borrar", http.MethodDelete, "2", "", http.StatusNoContent},
+		{"borrar inexistente", http.MethodDelete, "99", "", http.StatusNotFound},
+		{"metodo no permitido", http.MethodPatch, "1", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSociosHandler(newTestSocios(t), 50, 500)
+
+			req := httptest.NewRequest(tt.method, "/socios/"+tt.id, strings.NewReader(tt.body))
+			req.SetPathValue("id", tt.id)
+			rr := httptest.NewRecorder()
+
+			handler.SocioByID(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
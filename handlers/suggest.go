@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"api-libros/httphelpers"
+	"api-libros/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	suggestDefaultLimit = 10
+	suggestMaxLimit     = 25
+)
+
+// Suggest responde GET /libros/suggest?q=dost&limit=5 con titulos y autores para autocompletar:
+// [{"texto": "Fiódor Dostoievski", "campo": "autor"}, ...]. Tolera errores de tipeo igual que ?fuzzy=true.
+func (h *LibrosHandler) Suggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		httphelpers.RespondError(w, r, "metodo no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var verr models.ValidationError
+
+	f := models.SuggestFilter{
+		Q:         strings.TrimSpace(q.Get("q")),
+		Limit:     suggestDefaultLimit,
+		Threshold: h.fuzzyThreshold,
+	}
+
+	if v := parseIntParam(q, "limit", &verr); v != nil {
+		if *v > suggestMaxLimit {
+			verr.Add("limit", models.CodeTooLarge, fmt.Sprintf("limit no puede ser mayor que %d", suggestMaxLimit))
+		}
+		f.Limit = *v
+	}
+
+	var ferr *models.ValidationError
+	if errors.As(f.Validate(), &ferr) {
+		verr.Errors = append(verr.Errors, ferr.Errors...)
+	}
+
+	if err := verr.Err(); err != nil {
+		httphelpers.RespondValidationError(w, r, err)
+		return
+	}
+
+	sugerencias, err := h.repo.Suggest(r.Context(), f)
+	if err != nil {
+		httphelpers.RespondError(w, r, "Error al consultar la base", http.StatusInternalServerError)
+		return
+	}
+
+	httphelpers.RespondJSON(w, http.StatusOK, sugerencias)
+}
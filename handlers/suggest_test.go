@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"api-libros/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLibros_Suggest_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		query      string
+		wantStatus int
+		want       []models.Sugerencia
+	}{
+		{"autor mal escrito", http.MethodGet, "?q=orwel", http.StatusOK, []models.Sugerencia{{Texto: "George Orwell", Campo: "autor"}}},
+		{"prefijo de titulo", http.MethodGet, "?q=fahren", http.StatusOK, []models.Sugerencia{{Texto: "Fahrenheit 451", Campo: "titulo"}}},
+		{"sin coincidencias", http.MethodGet, "?q=zzzz", http.StatusOK, []models.Sugerencia{}},
+		{"sin q", http.MethodGet, "", http.StatusBadRequest, nil},
+		{"q vacio", http.MethodGet, "?q=%20", http.StatusBadRequest, nil},
+		{"limit muy grande", http.MethodGet, "?q=orwel&limit=1000", http.StatusBadRequest, nil},
+		{"limit 0", http.MethodGet, "?q=orwel&limit=0", http.StatusBadRequest, nil},
+		{"metodo no permitido", http.MethodPost, "?q=orwel", http.StatusMethodNotAllowed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewLibrosHandler(newTestRepo(t))
+
+			req := httptest.NewRequest(tt.method, "/libros/suggest"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler.Suggest(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			got := decodeJSON[[]models.Sugerencia](t, rr)
+			if len(got) != len(tt.want) {
+				t.Fatalf("esperaba %v, vino %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("esperaba %v, vino %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
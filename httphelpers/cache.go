@@ -0,0 +1,66 @@
+package httphelpers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Validators son los datos de cache de una respuesta GET. ETag puede ser fuerte ("3") o debil (W/"..."),
+// LastModified en cero no se manda, y CacheControl vacio tampoco.
+type Validators struct {
+	ETag         string
+	LastModified time.Time
+	CacheControl string
+}
+
+// NotModified pone los headers de v y, si el request es condicional y el cliente ya tiene esta version,
+// responde 304 sin body y devuelve true. Se llama antes de armar la respuesta, asi un 304 no paga el
+// encoding. Como dice RFC 9110 (13.2.2), si vino If-None-Match se ignora If-Modified-Since.
+func NotModified(w http.ResponseWriter, r *http.Request, v Validators) bool {
+	h := w.Header()
+	if v.ETag != "" {
+		h.Set("ETag", v.ETag)
+	}
+	if !v.LastModified.IsZero() {
+		h.Set("Last-Modified", v.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if v.CacheControl != "" {
+		h.Set("Cache-Control", v.CacheControl)
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if v.ETag == "" || !etagMatch(inm, v.ETag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		// Last-Modified tiene resolucion de segundos
+		if err != nil || v.LastModified.IsZero() || v.LastModified.Truncate(time.Second).After(ims) {
+			return false
+		}
+	}
+
+	// el 304 no lleva body, y sin Content-Type ni Content-Length
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatch es la comparacion debil de If-None-Match: una lista de ETags separados por coma (o *),
+// y W/"x" coincide con "x".
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
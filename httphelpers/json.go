@@ -6,14 +6,21 @@ import (
 	"net/http"
 )
 
-func RespondError(w http.ResponseWriter, msg string, status int) {
-	RespondJSON(w, status, map[string]string{
-		"error": msg,
+// RespondError responde un error simple en formato problem+json (ver Problem).
+// msg va en "detail" y "instance" es el path del request.
+func RespondError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	RespondProblem(w, r, Problem{
+		Status: status,
+		Detail: msg,
 	})
 }
 
 func RespondJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/json")
+	respond(w, status, "application/json", data)
+}
+
+func respond(w http.ResponseWriter, status int, contentType string, data any) {
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(data); err != nil {
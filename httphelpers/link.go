@@ -0,0 +1,45 @@
+package httphelpers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Link es un link de navegacion de RFC 8288 (rel = first, prev, next, last).
+type Link struct {
+	Rel string
+	URL string
+}
+
+// SetLinkHeader escribe los links en un solo header: Link: </libros?offset=2>; rel="next", </libros?offset=8>; rel="last"
+func SetLinkHeader(w http.ResponseWriter, links []Link) {
+	if len(links) == 0 {
+		return
+	}
+
+	parts := make([]string, 0, len(links))
+	for _, l := range links {
+		parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, l.URL, l.Rel))
+	}
+
+	w.Header().Set("Link", strings.Join(parts, ", "))
+}
+
+// URLWith devuelve el path y la query de r con los params de set reemplazados y los de del borrados.
+// Sirve para armar los links a otras paginas conservando los filtros que mando el cliente.
+func URLWith(r *http.Request, set map[string]string, del ...string) string {
+	q := r.URL.Query()
+
+	for k, v := range set {
+		q.Set(k, v)
+	}
+	for _, k := range del {
+		q.Del(k)
+	}
+
+	if len(q) == 0 {
+		return r.URL.Path
+	}
+	return r.URL.Path + "?" + q.Encode()
+}
@@ -0,0 +1,97 @@
+package httphelpers
+
+import (
+	"api-libros/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Tipos de problema propios. Para los errores que no tienen nada especial se usa "about:blank"
+// (RFC 9457, seccion 4.2) y el title es el texto del status.
+const (
+	ProblemTypeValidation  = "/problemas/validacion"
+	ProblemTypeInvalidJSON = "/problemas/json-invalido"
+	ProblemTypeISBN        = "/problemas/isbn-duplicado"
+)
+
+// Problem es el cuerpo de error de la API, segun RFC 9457 (application/problem+json).
+type Problem struct {
+	Type     string              `json:"type"`
+	Title    string              `json:"title"`
+	Status   int                 `json:"status"`
+	Detail   string              `json:"detail,omitempty"`
+	Instance string              `json:"instance,omitempty"`
+	Errors   []models.FieldError `json:"errors,omitempty"`
+
+	// extension (RFC 9457, seccion 3.2): en un 409 por ISBN duplicado, el libro que ya lo tiene
+	LibroID int `json:"libro_id,omitempty"`
+}
+
+// RespondProblem completa los campos que falten (type, title, instance) y responde.
+func RespondProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.RequestURI()
+	}
+
+	respond(w, p.Status, ProblemContentType, p)
+}
+
+// RespondValidationError responde 400 con la lista de campos invalidos.
+// Si err no es un *models.ValidationError responde un 400 comun con el mensaje.
+func RespondValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		RespondError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	RespondProblem(w, r, Problem{
+		Type:   ProblemTypeValidation,
+		Title:  "Datos inválidos",
+		Status: http.StatusBadRequest,
+		Detail: "uno o más campos no son válidos",
+		Errors: verr.Errors,
+	})
+}
+
+// RespondDecodeError responde 400 para un body que no se pudo decodificar con DecodeJSON.
+// Cuando el problema es de un campo puntual (tipo equivocado, campo desconocido) lo informa en errors.
+func RespondDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := Problem{
+		Type:   ProblemTypeInvalidJSON,
+		Title:  "JSON inválido",
+		Status: http.StatusBadRequest,
+		Detail: "json invalido",
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		p.Errors = []models.FieldError{{
+			Field:   typeErr.Field,
+			Code:    models.CodeInvalidType,
+			Message: "se esperaba un valor de tipo " + typeErr.Type.String(),
+		}}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json no tiene un tipo de error para esto, solo el mensaje
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p.Errors = []models.FieldError{{
+			Field:   field,
+			Code:    models.CodeUnknownField,
+			Message: "campo desconocido",
+		}}
+	}
+
+	RespondProblem(w, r, p)
+}
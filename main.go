@@ -1,26 +1,46 @@
 package main
 
 import (
+	"api-libros/app"
+	"api-libros/config"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
-	"api-libros/db"
-	"api-libros/handlers"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
-
 func main() {
-	database := db.New()
-	defer database.Close()
-
-	librosHandler := &handlers.LibrosHandler{ //uso puntero porque el handler es un servicio y puede llevar metricas globales que no me serviria copiar
-		DB : database,
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configuración inválida:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	// logs estructurados en JSON; lo que todavia usa el paquete log tambien sale por aca
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: cfg.LogLevel})))
 
-	http.HandleFunc("/libros", librosHandler.Libros)
-	http.HandleFunc("/libros/", librosHandler.LibrosByID)
+	// ctx se cancela con Ctrl+C o cuando el orquestador manda SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	fmt.Println("Servidor REST corriendo en http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// app es el dueño del pool y de todo lo demas, main solo lo arranca y espera a que termine
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Run se encarga de drenar los requests en curso y de cerrar el pool antes de volver
+	if err := application.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
 
+	log.Println("Servidor detenido")
 }
@@ -0,0 +1,182 @@
+// Package middleware tiene los wrappers que se aplican a todos los requests de la API.
+package middleware
+
+import (
+	"api-libros/actor"
+	"api-libros/httphelpers"
+	"api-libros/requestid"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Middleware func(http.Handler) http.Handler
+
+// Chain aplica los middlewares en el orden en que se pasan: el primero es el de mas afuera.
+//
+//	Chain(mux, A, B) == A(B(mux))
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID usa el X-Request-ID que manda el cliente (o un proxy) y si no viene genera uno.
+// Lo devuelve en la respuesta y lo deja en el context (ver paquete requestid).
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
+
+const ActorHeader = "X-Actor"
+
+// Actor deja en el context quien hace el request (ver paquete actor), para el historial de los libros.
+// La API no autentica a nadie: el X-Actor lo tiene que poner el proxy que si lo hace. Si viene algo
+// raro se ignora y el cambio queda sin actor.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if nombre := r.Header.Get(ActorHeader); validActor(nombre) {
+			r = r.WithContext(actor.NewContext(r.Context(), nombre))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AccessLog escribe una linea de log por request, cuando ya se respondio.
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("request_id", requestid.FromContext(r.Context())),
+			)
+		})
+	}
+}
+
+// Recover convierte un panic en un 500 JSON en vez de cortar la conexion sin explicacion.
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := &statusRecorder{ResponseWriter: w}
+
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				// ErrAbortHandler es la forma "oficial" de cortar un request, net/http lo maneja solo
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				logger.ErrorContext(r.Context(), "panic en handler",
+					slog.Any("panic", p),
+					slog.String("request_id", requestid.FromContext(r.Context())),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				// si el handler ya empezo a responder no hay forma de cambiar el status
+				if !rec.wroteHeader {
+					httphelpers.RespondError(rec, r, "error interno", http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// statusRecorder se queda con el status y los bytes escritos para el access log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK) // igual que net/http: el primer Write sin WriteHeader es un 200
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Status() int {
+	if !s.wroteHeader {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Unwrap deja que http.ResponseController llegue al ResponseWriter original (Flush, deadlines, etc).
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b) //crypto/rand.Read no devuelve error desde go 1.24
+	return hex.EncodeToString(b)
+}
+
+// validRequestID evita que un cliente meta cualquier cosa en nuestros logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' { // solo ASCII visible, sin espacios
+			return false
+		}
+	}
+	return true
+}
+
+// validActor acepta nombres y emails con acentos, pero nada de caracteres de control
+func validActor(nombre string) bool {
+	if nombre == "" || len(nombre) > 128 || !utf8.ValidString(nombre) {
+		return false
+	}
+	for _, c := range nombre {
+		if unicode.IsControl(c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"api-libros/actor"
+	"api-libros/requestid"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain_Orden(t *testing.T) {
+	var orden []string
+
+	mw := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				orden = append(orden, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orden = append(orden, "handler")
+	}), mw("a"), mw("b"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(orden, ","); got != "a,b,handler" {
+		t.Fatalf("orden esperado a,b,handler, vino %s", got)
+	}
+}
+
+func TestRequestID_TableDriven(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{"propaga el del cliente", "abc-123", true},
+		{"genera si no viene", "", false},
+		{"genera si viene con espacios", "hola mundo", false},
+		{"genera si es demasiado largo", strings.Repeat("x", 200), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enContext string
+
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				enContext = requestid.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/libros", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			h.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if got == "" {
+				t.Fatal("la respuesta no tiene X-Request-ID")
+			}
+
+			if got != enContext {
+				t.Fatalf("el id del header (%q) y el del context (%q) no coinciden", got, enContext)
+			}
+
+			if tt.wantSame && got != tt.header {
+				t.Fatalf("esperaba que se propague %q, vino %q", tt.header, got)
+			}
+
+			if !tt.wantSame && got == tt.header {
+				t.Fatalf("esperaba un id generado, se uso el del cliente")
+			}
+		})
+	}
+}
+
+func TestActor_TableDriven(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"email", "ana@biblioteca.org", "ana@biblioteca.org"},
+		{"con acentos y espacios", "José Pérez", "José Pérez"},
+		{"sin header", "", ""},
+		{"con salto de linea", "ana\nadmin", ""},
+		{"demasiado largo", strings.Repeat("x", 200), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := "sin llamar"
+
+			h := Actor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = actor.FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/libros", nil)
+			if tt.header != "" {
+				req.Header.Set(ActorHeader, tt.header)
+			}
+
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Fatalf("actor esperado %q, vino %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hola"))
+	}), RequestID, AccessLog(logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/libros", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("se esperaba una linea JSON: %v\n%s", err, buf.String())
+	}
+
+	want := map[string]any{
+		"method":     "POST",
+		"path":       "/libros",
+		"status":     float64(201),
+		"bytes":      float64(4),
+		"request_id": "req-1",
+	}
+
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s esperado %v, vino %v", k, v, line[k])
+		}
+	}
+
+	if _, ok := line["duration"]; !ok {
+		t.Error("falta duration en el access log")
+	}
+}
+
+func TestRecover_TableDriven(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantJSON   bool
+	}{
+		{
+			name: "panic antes de responder",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantJSON:   true,
+		},
+		{
+			name: "panic despues de responder",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				panic("boom")
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "sin panic",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			h := Chain(tt.handler, AccessLog(logger), Recover(logger))
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/libros", nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status esperado %d, vino %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantJSON {
+				if ct := rr.Header().Get("Content-Type"); !strings.Contains(ct, "json") {
+					t.Fatalf("esperaba una respuesta JSON, vino Content-Type %q", ct)
+				}
+
+				if !strings.Contains(buf.String(), "panic en handler") {
+					t.Fatalf("el panic no quedo logueado:\n%s", buf.String())
+				}
+
+				if !strings.Contains(buf.String(), `"status":500`) {
+					t.Fatalf("el access log no registro el 500:\n%s", buf.String())
+				}
+			}
+		})
+	}
+}
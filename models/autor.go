@@ -0,0 +1,78 @@
+package models
+
+import "strings"
+
+type Autor struct {
+	ID     int    `json:"id"`
+	Nombre string `json:"nombre"`
+}
+
+type AutorInput struct {
+	Nombre string `json:"nombre"`
+}
+
+func (a AutorInput) Validate() error {
+	var verr ValidationError
+
+	if strings.TrimSpace(a.Nombre) == "" {
+		verr.Add("nombre", CodeRequired, "nombre requerido")
+	}
+
+	return verr.Err()
+}
+
+type AutorFilter struct {
+	Nombre *string // coincidencia parcial, sin distinguir mayusculas
+	Limit  int
+	Offset int
+}
+
+func (f *AutorFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
+
+// LibroAutoresInput es el body de PUT /libros/{id}/autores: los ids de los autores en el orden
+// en que se muestran ("Autor 1, Autor 2"). Reemplaza la lista completa.
+type LibroAutoresInput struct {
+	Autores []int `json:"autores"`
+}
+
+func (in LibroAutoresInput) Validate() error {
+	var verr ValidationError
+
+	if len(in.Autores) == 0 {
+		verr.Add("autores", CodeRequired, "el libro tiene que tener al menos un autor")
+	}
+
+	seen := map[int]bool{}
+	for _, id := range in.Autores {
+		if id <= 0 {
+			verr.Add("autores", CodeMustBePositive, "id de autor invalido")
+		} else if seen[id] {
+			verr.Add("autores", CodeNotAllowed, "autor repetido")
+		}
+		seen[id] = true
+	}
+
+	return verr.Err()
+}
+
+// AutoresDisplay arma el texto de libros.autor a partir de los autores en orden: "Autor 1, Autor 2".
+// Se sigue guardando en libros.autor para mostrar, filtrar por ?autor= y buscar sin hacer joins.
+func AutoresDisplay(autores []Autor) string {
+	nombres := make([]string, 0, len(autores))
+	for _, a := range autores {
+		nombres = append(nombres, a.Nombre)
+	}
+	return strings.Join(nombres, ", ")
+}
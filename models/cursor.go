@@ -0,0 +1,11 @@
+package models
+
+// Cursor marca donde arranca una pagina en modo keyset: "los libros que vienen despues
+// (o antes, si Backward) de este libro" segun el orden pedido. Como guarda valores y no una
+// posicion, la pagina siguiente no se corre aunque se inserten o borren libros en el medio.
+type Cursor struct {
+	ID       int      `json:"id"`
+	Keys     []string `json:"k,omitempty"` // valor de cada columna de Sort para el ultimo libro visto
+	Sort     string   `json:"s,omitempty"` // orden con el que se genero (SortString)
+	Backward bool     `json:"back,omitempty"`
+}
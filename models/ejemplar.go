@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// estados fisicos de un ejemplar. No dicen si esta prestado: eso sale de los prestamos.
+const (
+	EstadoNuevo   = "nuevo"
+	EstadoBueno   = "bueno"
+	EstadoRegular = "regular"
+	EstadoMalo    = "malo"
+)
+
+var estadosEjemplar = []string{EstadoNuevo, EstadoBueno, EstadoRegular, EstadoMalo}
+
+const maxCodigoBarras = 64
+
+// Ejemplar es una copia fisica de un libro. Libro es la obra; la biblioteca puede tener varios
+// ejemplares de la misma, cada uno con su codigo de barras.
+type Ejemplar struct {
+	ID               int    `json:"id"`
+	LibroID          int    `json:"libro_id"`
+	CodigoBarras     string `json:"codigo_barras"`
+	Ubicacion        string `json:"ubicacion"` // estante, ej: "B-12"
+	Estado           string `json:"estado"`
+	FechaAdquisicion Fecha  `json:"fecha_adquisicion"`
+}
+
+type EjemplarInput struct {
+	CodigoBarras     string `json:"codigo_barras"`
+	Ubicacion        string `json:"ubicacion"`
+	Estado           string `json:"estado"`
+	FechaAdquisicion Fecha  `json:"fecha_adquisicion"`
+}
+
+func (in EjemplarInput) Validate() error {
+	var verr ValidationError
+
+	switch codigo := strings.TrimSpace(in.CodigoBarras); {
+	case codigo == "":
+		verr.Add("codigo_barras", CodeRequired, "codigo de barras requerido")
+	case codigo != in.CodigoBarras || strings.ContainsAny(codigo, " \t"):
+		verr.Add("codigo_barras", CodeNotAllowed, "el codigo de barras no puede tener espacios")
+	case len(codigo) > maxCodigoBarras:
+		verr.Add("codigo_barras", CodeTooLarge, fmt.Sprintf("el codigo de barras no puede tener mas de %d caracteres", maxCodigoBarras))
+	}
+
+	if strings.TrimSpace(in.Ubicacion) == "" {
+		verr.Add("ubicacion", CodeRequired, "ubicacion requerida")
+	}
+
+	if !slices.Contains(estadosEjemplar, in.Estado) {
+		verr.Add("estado", CodeInvalidOption, "estado tiene que ser uno de: "+strings.Join(estadosEjemplar, ", "))
+	}
+
+	if in.FechaAdquisicion.IsZero() {
+		verr.Add("fecha_adquisicion", CodeRequired, "fecha de adquisicion requerida")
+	}
+
+	return verr.Err()
+}
+
+// Disponibilidad resume los ejemplares de un libro. Sale en GET /libros/{id}?disponibilidad=true
+type Disponibilidad struct {
+	Total       int `json:"total"`
+	Disponibles int `json:"disponibles"`
+	Prestados   int `json:"prestados"`
+	Apartados   int `json:"apartados"`          // esperando que los retire el socio que los reservo
+	EnEspera    int `json:"reservas_en_espera"` // socios en la cola sin ejemplar todavia
+}
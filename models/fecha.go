@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const formatoFecha = "2006-01-02"
+
+// Fecha es un dia sin hora ni zona: en JSON viaja como "2006-01-02" y en postgres es un DATE.
+// Siempre queda a las 00:00 UTC, asi dos Fecha del mismo dia son iguales con ==.
+type Fecha struct {
+	time.Time
+}
+
+func NewFecha(year int, month time.Month, day int) Fecha {
+	return Fecha{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+// FechaDe se queda con el dia de t (en la zona de t)
+func FechaDe(t time.Time) Fecha {
+	return NewFecha(t.Date())
+}
+
+func ParseFecha(s string) (Fecha, error) {
+	t, err := time.Parse(formatoFecha, s)
+	if err != nil {
+		return Fecha{}, fmt.Errorf("fecha invalida %q, se espera AAAA-MM-DD", s)
+	}
+	return Fecha{t}, nil
+}
+
+func (f Fecha) String() string {
+	return f.Format(formatoFecha)
+}
+
+func (f Fecha) MarshalJSON() ([]byte, error) {
+	if f.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.String())
+}
+
+func (f *Fecha) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*f = Fecha{}
+		return nil
+	}
+
+	parsed, err := ParseFecha(s)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// AddDays devuelve la fecha n dias despues (antes si n es negativo)
+func (f Fecha) AddDays(n int) Fecha {
+	return Fecha{f.AddDate(0, 0, n)}
+}
+
+// Scan y Value son para pgx: un DATE se lee y se escribe como time.Time y la Fecha cero es NULL
+func (f *Fecha) Scan(src any) error {
+	if src == nil {
+		*f = Fecha{}
+		return nil
+	}
+
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("no se puede leer %T como Fecha", src)
+	}
+	*f = FechaDe(t)
+	return nil
+}
+
+func (f Fecha) Value() (driver.Value, error) {
+	if f.IsZero() {
+		return nil, nil
+	}
+	return f.Time, nil
+}
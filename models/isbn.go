@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrISBNInvalido = errors.New("isbn invalido")
+
+// NormalizeISBN acepta un ISBN-10 o ISBN-13 con o sin guiones/espacios ("0-306-40615-2",
+// "978 0 306 40615 7") y lo devuelve como ISBN-13 sin separadores, que es como se guarda.
+// Si el digito verificador no cierra devuelve ErrISBNInvalido.
+func NormalizeISBN(s string) (string, error) {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '-' || r == ' ':
+			continue
+		case r >= '0' && r <= '9', r == 'x', r == 'X':
+			b.WriteRune(r)
+		default:
+			return "", ErrISBNInvalido
+		}
+	}
+	digits := strings.ToUpper(b.String())
+
+	switch len(digits) {
+	case 10:
+		if !isbn10Valido(digits) {
+			return "", ErrISBNInvalido
+		}
+		// el ISBN-13 equivalente es 978 + los primeros 9 digitos + un verificador nuevo
+		base := "978" + digits[:9]
+		return base + isbn13Verificador(base), nil
+
+	case 13:
+		if strings.Contains(digits, "X") || isbn13Verificador(digits[:12]) != digits[12:] {
+			return "", ErrISBNInvalido
+		}
+		return digits, nil
+	}
+
+	return "", ErrISBNInvalido
+}
+
+// isbn10Valido: la suma de cada digito por su peso (10 a 1) tiene que ser multiplo de 11.
+// La X solo puede ir al final y vale 10.
+func isbn10Valido(digits string) bool {
+	sum := 0
+	for i, r := range digits {
+		v := int(r - '0')
+		if r == 'X' {
+			if i != 9 {
+				return false
+			}
+			v = 10
+		}
+		sum += v * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// isbn13Verificador calcula el ultimo digito a partir de los 12 primeros (pesos 1 y 3 alternados)
+func isbn13Verificador(base string) string {
+	sum := 0
+	for i, r := range base {
+		v := int(r - '0')
+		if i%2 == 1 {
+			v *= 3
+		}
+		sum += v
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+// validarISBN normaliza *isbn en el lugar. Si no es valido agrega el error a verr y lo deja como vino.
+func validarISBN(isbn *string, verr *ValidationError) {
+	n, err := NormalizeISBN(*isbn)
+	if err != nil {
+		verr.Add("isbn", CodeInvalidISBN, "isbn invalido (se espera un ISBN-10 o ISBN-13 con su digito verificador)")
+		return
+	}
+	*isbn = n
+}
@@ -1,8 +1,33 @@
 package models
 
+import "time"
+
 type Libro struct {
 	ID     int    `json:"id"`
 	Titulo string `json:"titulo"`
 	Autor  string `json:"autor"`
 	Ano    int    `json:"ano"`
+	ISBN   string `json:"isbn,omitempty"` // siempre ISBN-13 sin guiones; vacio si no se cargo
+
+	// Version sube con cada cambio y Modificado es cuando fue. No van en el JSON, viajan en los headers
+	// ETag y Last-Modified de GET /libros/{id}
+	Version    int       `json:"-"`
+	Modificado time.Time `json:"-"`
+
+	// Borrado es cuando se mando a la papelera, nil si el libro esta vivo. Solo lo ve quien pide los
+	// borrados (GET /libros/papelera o ?include_deleted=true)
+	Borrado *time.Time `json:"borrado,omitempty"`
+
+	// solo viene en GET /libros/{id}?disponibilidad=true
+	Disponibilidad *Disponibilidad `json:"disponibilidad,omitempty"`
+
+	// solo viene en GET /libros?q=...
+	Resaltado *Resaltado `json:"resaltado,omitempty"`
+}
+
+// Resaltado es el titulo y el autor, escapados como HTML, con las palabras que coincidieron con la busqueda
+// marcadas entre <mark> y </mark>, para que el frontend las muestre resaltadas.
+type Resaltado struct {
+	Titulo string `json:"titulo"`
+	Autor  string `json:"autor"`
 }
@@ -0,0 +1,81 @@
+package models
+
+import "slices"
+
+// LibroOp es una operacion de POST /libros:batch. Op es una de las operaciones del historial: crear,
+// reemplazar (PUT), modificar (PATCH) o borrar.
+type LibroOp struct {
+	Op      string      `json:"op"`
+	ID      int         `json:"id,omitempty"`      // todas menos crear
+	Version int         `json:"version,omitempty"` // opcional, como el If-Match: si el libro cambio, la operacion falla
+	Libro   *LibroPatch `json:"libro,omitempty"`   // crear y reemplazar piden todo como LibroInput, modificar solo lo que cambia
+}
+
+// Validate chequea la operacion con las mismas reglas de LibroInput o LibroPatch segun Op (los errores
+// de libro van como "libro.titulo", etc.). Deja el ISBN normalizado, por eso recibe un puntero.
+func (o *LibroOp) Validate() error {
+	var verr ValidationError
+
+	ops := []string{RevisionCrear, RevisionReemplazar, RevisionModificar, RevisionBorrar}
+	if !slices.Contains(ops, o.Op) {
+		verr.Add("op", CodeInvalidOption, "op tiene que ser crear, reemplazar, modificar o borrar")
+		return verr.Err()
+	}
+
+	if o.Op == RevisionCrear {
+		if o.ID != 0 {
+			verr.Add("id", CodeNotAllowed, "crear no lleva id")
+		}
+		if o.Version != 0 {
+			verr.Add("version", CodeNotAllowed, "crear no lleva version")
+		}
+	} else if o.ID <= 0 {
+		verr.Add("id", CodeMustBePositive, "id invalido")
+	}
+
+	if o.Version < 0 {
+		verr.Add("version", CodeNegative, "version no puede ser negativa")
+	}
+
+	switch {
+	case o.Op == RevisionBorrar:
+		if o.Libro != nil {
+			verr.Add("libro", CodeNotAllowed, "borrar no lleva libro")
+		}
+	case o.Libro == nil:
+		verr.Add("libro", CodeRequired, "libro requerido")
+	case o.Op == RevisionModificar:
+		verr.AddAll("libro.", o.Libro.Validate())
+	default:
+		in := o.Input()
+		verr.AddAll("libro.", in.Validate())
+		if in.ISBN != "" {
+			o.Libro.ISBN = &in.ISBN
+		}
+	}
+
+	return verr.Err()
+}
+
+// Input es Libro como LibroInput, para crear y reemplazar: lo que no vino queda vacio
+func (o *LibroOp) Input() LibroInput {
+	var in LibroInput
+	if o.Libro == nil {
+		return in
+	}
+
+	if o.Libro.Titulo != nil {
+		in.Titulo = *o.Libro.Titulo
+	}
+	if o.Libro.Autor != nil {
+		in.Autor = *o.Libro.Autor
+	}
+	if o.Libro.Ano != nil {
+		in.Ano = *o.Libro.Ano
+	}
+	if o.Libro.ISBN != nil {
+		in.ISBN = *o.Libro.ISBN
+	}
+
+	return in
+}
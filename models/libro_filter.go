@@ -1,31 +1,93 @@
 package models
 
-import (
-	"errors"
-)
+import "strings"
+
 type LibroFilter struct {
+	// Q es la busqueda de texto completo sobre titulo y autor. Sin Sort, los resultados
+	// vienen ordenados por relevancia.
+	Q *string
+
+	// Fuzzy cambia como se busca Q: en vez de palabras completas compara por trigramas, asi
+	// "dostoyevski" encuentra "Dostoievski". FuzzyThreshold es la similitud minima (0 a 1).
+	Fuzzy          bool
+	FuzzyThreshold float64
+
 	Autor  *string
 	From   *int
 	To     *int
 	Limit  int
 	Offset int
+
+	// Sort vacio => por id. Los repos siempre agregan id al final como desempate (WithTiebreaker).
+	Sort []SortField
+
+	// Cursor != nil => paginado keyset (se ignora Offset). Los repos devuelven los libros
+	// siempre en el orden de Sort, tambien cuando Cursor.Backward es true.
+	Cursor *Cursor
+
+	// Papelera dice que pasa con los libros borrados. El cero (SinBorrados) los deja afuera.
+	Papelera Papelera
 }
 
+type Papelera int
+
+const (
+	SinBorrados  Papelera = iota // solo los libros vivos
+	ConBorrados                  // vivos y borrados (?include_deleted=true)
+	SoloBorrados                 // solo los que estan en la papelera (GET /libros/papelera)
+)
+
 //uso punteros para poder distinguir "no vino el filtro" vs "vino vacio"
 
 func (f *LibroFilter) Validate() error {
+	var verr ValidationError
 
 	if f.Limit < 0 {
-		return errors.New("limit no puede ser negativo")
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
 	}
 
 	if f.Offset < 0 {
-		return errors.New("offset no puede ser negativo")
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	if f.Cursor != nil && f.Offset != 0 {
+		verr.Add("offset", CodeNotAllowed, "offset no se puede usar junto con cursor")
+	}
+
+	seen := map[string]bool{}
+	for _, s := range f.Sort {
+		if !IsSortable(s.Campo) {
+			verr.Add("sort", CodeNotSortable, "no se puede ordenar por "+s.Campo)
+		} else if seen[s.Campo] {
+			verr.Add("sort", CodeNotAllowed, s.Campo+" esta repetido en sort")
+		}
+		seen[s.Campo] = true
+	}
+
+	if f.Q != nil && strings.TrimSpace(*f.Q) == "" {
+		verr.Add("q", CodeBlank, "q no puede estar vacio")
+	}
+
+	if f.Fuzzy && f.Q == nil {
+		verr.Add("q", CodeRequired, "fuzzy necesita q")
+	}
+
+	// la relevancia no sirve como clave de un cursor (es un float que depende de la busqueda)
+	if f.Q != nil && f.Cursor != nil && len(f.Sort) == 0 {
+		verr.Add("cursor", CodeNotAllowed, "para usar cursor con q hace falta un sort explicito")
+	}
+
+	if f.Cursor != nil {
+		if f.Cursor.Sort != SortString(f.Sort) {
+			verr.Add("cursor", CodeInvalidCursor, "el cursor es de una consulta con otro orden")
+		} else if _, err := f.Cursor.Libro(f.Sort); err != nil {
+			verr.Add("cursor", CodeInvalidCursor, err.Error())
+		}
 	}
 
 	if f.From != nil && f.To != nil && *f.From > *f.To {
-		return errors.New("from no puede ser mayor que to")
+		verr.Add("from", CodeInvalidRange, "from no puede ser mayor que to")
 	}
 
-	return nil
+	return verr.Err()
 }
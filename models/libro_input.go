@@ -1,7 +1,6 @@
 package models
 
 import (
-	"errors"
 	"strings"
 )
 
@@ -9,18 +8,26 @@ type LibroInput struct {
 	Titulo string `json:"titulo"`
 	Autor  string `json:"autor"`
 	Ano    int    `json:"ano"`
+	ISBN   string `json:"isbn,omitempty"` // opcional, ISBN-10 o ISBN-13
 }
 
-// aca no chequeo si es nil porque no uso punteros
-func (l LibroInput) Validate() error {
+// aca no chequeo si es nil porque no uso punteros.
+// Ademas deja el ISBN normalizado (ISBN-13 sin guiones), por eso recibe un puntero.
+func (l *LibroInput) Validate() error {
+	var verr ValidationError
+
 	if strings.TrimSpace(l.Titulo) == "" {
-		return errors.New("titulo requerido")
+		verr.Add("titulo", CodeRequired, "titulo requerido")
 	}
 	if strings.TrimSpace(l.Autor) == "" {
-		return errors.New("autor requerido")
+		verr.Add("autor", CodeRequired, "autor requerido")
 	}
 	if l.Ano <= 0 {
-		return errors.New("año inválido")
+		verr.Add("ano", CodeMustBePositive, "año inválido")
+	}
+	if l.ISBN != "" {
+		validarISBN(&l.ISBN, &verr)
 	}
-	return nil
+
+	return verr.Err()
 }
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"errors"
 	"strings"
 )
 
@@ -9,20 +8,27 @@ type LibroPatch struct {
 	Titulo *string `json:"titulo"`
 	Autor  *string `json:"autor"`
 	Ano    *int    `json:"ano"`
+	ISBN   *string `json:"isbn"` // "" le saca el ISBN al libro
 }
 
 func (u *LibroPatch) Validate() error {
+	var verr ValidationError
+
 	if u.Titulo != nil && strings.TrimSpace(*u.Titulo) == "" {
-		return errors.New("titulo vacio")
+		verr.Add("titulo", CodeBlank, "titulo vacio")
 	}
 
 	if u.Autor != nil && strings.TrimSpace(*u.Autor) == "" {
-		return errors.New("Autor vacio")
+		verr.Add("autor", CodeBlank, "Autor vacio")
 	}
 
 	if u.Ano != nil && *u.Ano <= 0 {
-		return errors.New("año invalido")
+		verr.Add("ano", CodeMustBePositive, "año invalido")
+	}
+
+	if u.ISBN != nil && *u.ISBN != "" {
+		validarISBN(u.ISBN, &verr)
 	}
 
-	return nil
+	return verr.Err()
 }
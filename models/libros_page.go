@@ -0,0 +1,18 @@
+package models
+
+// LibrosPage es la respuesta de GET /libros cuando se pagina con cursor.
+type LibrosPage struct {
+	Items []Libro `json:"items"`
+	Next  string  `json:"next,omitempty"` // cursor para la pagina siguiente, vacio si no hay
+	Prev  string  `json:"prev,omitempty"` // cursor para la pagina anterior, vacio si no hay
+}
+
+// LibrosEnvelope es la respuesta de GET /libros?envelope=true cuando se pagina con offset.
+type LibrosEnvelope struct {
+	Items  []Libro `json:"items"`
+	Total  int     `json:"total"` // cuantos libros cumplen los filtros, sin contar limit ni offset
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+	Next   string  `json:"next,omitempty"` // URL de la pagina siguiente, vacia si no hay
+	Prev   string  `json:"prev,omitempty"` // URL de la pagina anterior, vacia si no hay
+}
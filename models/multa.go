@@ -0,0 +1,132 @@
+package models
+
+import (
+	"slices"
+	"strings"
+)
+
+// Tipos de movimiento en la cuenta de multas de un socio. Los montos van siempre en centavos y
+// positivos: el saldo es cargos - pagos - condonaciones.
+const (
+	MultaCargo       = "cargo"       // lo que se le cobra por devolver tarde un prestamo
+	MultaPago        = "pago"        // el socio pago (todo o parte)
+	MultaCondonacion = "condonacion" // la biblioteca le perdona (todo o parte)
+)
+
+// Multa es un movimiento de la cuenta de un socio. Hay un solo cargo por prestamo: mientras el prestamo
+// sigue afuera el calculo lo va actualizando todos los dias, y queda Final cuando se devuelve.
+type Multa struct {
+	ID         int    `json:"id"`
+	SocioID    int    `json:"socio_id"`
+	PrestamoID int    `json:"prestamo_id,omitempty"` // solo cargos; 0 si el prestamo ya no existe
+	Tipo       string `json:"tipo"`
+	Monto      int    `json:"monto"`          // en centavos
+	Dias       int    `json:"dias,omitempty"` // dias cobrados (solo cargos)
+	Fecha      Fecha  `json:"fecha"`          // de un cargo: el ultimo dia que se calculo
+	Final      bool   `json:"final,omitempty"`
+	Nota       string `json:"nota,omitempty"`
+}
+
+// SaldoMultas es el resumen de la cuenta de un socio
+type SaldoMultas struct {
+	SocioID       int  `json:"socio_id"`
+	Cargos        int  `json:"cargos"`
+	Pagos         int  `json:"pagos"`
+	Condonaciones int  `json:"condonaciones"`
+	Saldo         int  `json:"saldo"`
+	Bloqueado     bool `json:"bloqueado"` // debe mas que PoliticaPrestamo.DeudaMaxima: no puede pedir prestado
+}
+
+// MovimientoInput es el body de POST /socios/{id}/multas/pagos y /condonaciones.
+// Una condonacion tiene que decir por que.
+type MovimientoInput struct {
+	Monto int    `json:"monto"`
+	Nota  string `json:"nota"`
+}
+
+func (in MovimientoInput) Validate(tipo string) error {
+	var verr ValidationError
+
+	if in.Monto <= 0 {
+		verr.Add("monto", CodeMustBePositive, "monto tiene que ser mayor a 0 (en centavos)")
+	}
+
+	if tipo == MultaCondonacion && strings.TrimSpace(in.Nota) == "" {
+		verr.Add("nota", CodeRequired, "una condonacion tiene que tener una nota con el motivo")
+	}
+
+	return verr.Err()
+}
+
+// NuevoMovimiento es un pago o condonacion listo para guardar
+type NuevoMovimiento struct {
+	SocioID int
+	Tipo    string
+	Monto   int
+	Fecha   Fecha
+	Nota    string
+}
+
+type MultaFilter struct {
+	Tipo   *string
+	Limit  int
+	Offset int
+}
+
+func (f *MultaFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Tipo != nil && !slices.Contains([]string{MultaCargo, MultaPago, MultaCondonacion}, *f.Tipo) {
+		verr.Add("tipo", CodeInvalidOption, "tipo tiene que ser cargo, pago o condonacion")
+	}
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
+
+// PoliticaMulta son las reglas para cobrar los atrasos. Los montos van en centavos.
+type PoliticaMulta struct {
+	PorDia     int     // lo que se cobra por cada dia de atraso
+	DiasGracia int     // los primeros dias de atraso no se cobran
+	Tope       int     // maximo por prestamo, 0 es sin tope
+	Feriados   []Fecha // no cuentan como dias de atraso
+}
+
+// Cargo es lo que corresponde cobrar por un prestamo
+type Cargo struct {
+	Dias  int // dias cobrados, sin feriados ni dias de gracia
+	Monto int
+	Final bool // el prestamo ya se devolvio, no va a cambiar mas
+}
+
+// Calcular es el cargo del prestamo al dia de hoy. Los dias de atraso son los que van del dia siguiente
+// al vencimiento hasta la devolucion (o hasta hoy si sigue afuera), sin contar los feriados.
+// No depende de nada mas que de sus argumentos: el mismo prestamo el mismo dia da siempre lo mismo.
+func (pm PoliticaMulta) Calcular(p Prestamo, hoy Fecha) Cargo {
+	hasta := hoy
+	if !p.Activo() {
+		hasta = p.Devuelto
+	}
+
+	atraso := 0
+	for d := p.Vence.AddDays(1); !d.After(hasta.Time); d = d.AddDays(1) {
+		if !slices.Contains(pm.Feriados, d) {
+			atraso++
+		}
+	}
+
+	c := Cargo{Dias: max(atraso-pm.DiasGracia, 0), Final: !p.Activo()}
+	c.Monto = c.Dias * pm.PorDia
+	if pm.Tope > 0 {
+		c.Monto = min(c.Monto, pm.Tope)
+	}
+
+	return c
+}
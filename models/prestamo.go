@@ -0,0 +1,130 @@
+package models
+
+// Prestamo es un ejemplar en manos de un socio. Mientras Devuelto es la fecha cero (null en el JSON)
+// el prestamo esta activo, y un ejemplar no puede tener dos prestamos activos.
+type Prestamo struct {
+	ID           int   `json:"id"`
+	EjemplarID   int   `json:"ejemplar_id"`
+	LibroID      int   `json:"libro_id"` // el libro del ejemplar, para no tener que ir a buscarlo
+	SocioID      int   `json:"socio_id"`
+	Desde        Fecha `json:"desde"`
+	Vence        Fecha `json:"vence"`
+	Devuelto     Fecha `json:"devuelto"`
+	Renovaciones int   `json:"renovaciones"`
+}
+
+func (p Prestamo) Activo() bool {
+	return p.Devuelto.IsZero()
+}
+
+// Vencido: sigue afuera y hoy ya paso la fecha de vencimiento
+func (p Prestamo) Vencido(hoy Fecha) bool {
+	return p.Activo() && p.Vence.Before(hoy.Time)
+}
+
+// PrestamoInput es el body de POST /prestamos. Las fechas las pone la API segun la politica.
+type PrestamoInput struct {
+	EjemplarID int `json:"ejemplar_id"`
+	SocioID    int `json:"socio_id"`
+}
+
+func (in PrestamoInput) Validate() error {
+	var verr ValidationError
+
+	if in.EjemplarID <= 0 {
+		verr.Add("ejemplar_id", CodeMustBePositive, "ejemplar_id invalido")
+	}
+	if in.SocioID <= 0 {
+		verr.Add("socio_id", CodeMustBePositive, "socio_id invalido")
+	}
+
+	return verr.Err()
+}
+
+// PoliticaPrestamo son las reglas de la biblioteca: cuantos dias dura un prestamo (y cada renovacion),
+// cuantas veces se puede renovar, cuantos dias tiene un socio para retirar un ejemplar que reservo
+// y cuanto puede deber en multas (en centavos) sin que se le corte el prestamo.
+type PoliticaPrestamo struct {
+	Dias            int
+	MaxRenovaciones int
+	DiasRetiro      int
+	DeudaMaxima     int
+}
+
+// Bloqueado: con ese saldo de multas el socio no puede pedir prestado
+func (p PoliticaPrestamo) Bloqueado(saldo int) bool {
+	return saldo > p.DeudaMaxima
+}
+
+// RetirarHasta es el ultimo dia para retirar un ejemplar que se le asigna hoy a una reserva
+func (p PoliticaPrestamo) RetirarHasta(hoy Fecha) Fecha {
+	return hoy.AddDays(p.DiasRetiro)
+}
+
+// Devolucion es lo que necesita el repo para cerrar un prestamo: la fecha, y hasta cuando puede
+// retirar el ejemplar el primero de la cola de reservas del libro, si hay alguien esperando.
+type Devolucion struct {
+	Fecha        Fecha
+	RetirarHasta Fecha
+}
+
+func (p PoliticaPrestamo) Devolucion(hoy Fecha) Devolucion {
+	return Devolucion{Fecha: hoy, RetirarHasta: p.RetirarHasta(hoy)}
+}
+
+// NuevoPrestamo es lo que se guarda al prestar: el input mas las fechas ya calculadas. El repo no presta
+// si el socio debe mas de DeudaMaxima en multas. Antes de prestar los ejemplares libres del libro pasan a
+// la cola de reservas, como al devolver: RetirarHasta es hasta cuando los puede retirar cada reserva.
+type NuevoPrestamo struct {
+	EjemplarID   int
+	SocioID      int
+	Desde        Fecha
+	Vence        Fecha
+	DeudaMaxima  int
+	RetirarHasta Fecha
+}
+
+// NuevoPrestamo arma el prestamo que empieza hoy y vence a los Dias dias
+func (p PoliticaPrestamo) NuevoPrestamo(in PrestamoInput, hoy Fecha) NuevoPrestamo {
+	return NuevoPrestamo{
+		EjemplarID: in.EjemplarID, SocioID: in.SocioID, Desde: hoy, Vence: hoy.AddDays(p.Dias),
+		DeudaMaxima: p.DeudaMaxima, RetirarHasta: p.RetirarHasta(hoy),
+	}
+}
+
+// Renovacion le dice al repo hasta cuando extender y con que reglas. El chequeo se hace adentro de la
+// misma transaccion que la renovacion, asi dos renovaciones simultaneas no pasan el limite.
+type Renovacion struct {
+	Hoy             Fecha
+	Hasta           Fecha
+	MaxRenovaciones int
+}
+
+// Renovacion extiende desde hoy, no desde el vencimiento anterior
+func (p PoliticaPrestamo) Renovacion(hoy Fecha) Renovacion {
+	return Renovacion{Hoy: hoy, Hasta: hoy.AddDays(p.Dias), MaxRenovaciones: p.MaxRenovaciones}
+}
+
+type PrestamoFilter struct {
+	SocioID    *int
+	EjemplarID *int
+	LibroID    *int
+	Activos    bool   // solo los que no se devolvieron
+	VencidosAl *Fecha // solo los activos que vencieron antes de esta fecha (implica Activos)
+	Limit      int
+	Offset     int
+}
+
+func (f *PrestamoFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,73 @@
+package models
+
+// Estados de una reserva. Esperando y disponible son las activas: las que estan en la cola del libro.
+const (
+	ReservaEsperando  = "esperando"  // en la cola, no hay ejemplar para ella todavia
+	ReservaDisponible = "disponible" // tiene un ejemplar apartado hasta RetirarHasta
+	ReservaRetirada   = "retirada"   // el socio se llevo el ejemplar apartado
+	ReservaCancelada  = "cancelada"
+	ReservaVencida    = "vencida" // no lo retiro a tiempo, el ejemplar paso al siguiente
+)
+
+// Reserva es el lugar de un socio en la cola de un libro sin ejemplares libres. La cola es por
+// orden de llegada (por id): cuando se devuelve un ejemplar se le aparta al primero que espera.
+type Reserva struct {
+	ID           int    `json:"id"`
+	LibroID      int    `json:"libro_id"`
+	SocioID      int    `json:"socio_id"`
+	Estado       string `json:"estado"`
+	Creada       Fecha  `json:"creada"`
+	Posicion     int    `json:"posicion,omitempty"`    // lugar en la cola (desde 1), solo mientras espera
+	EjemplarID   int    `json:"ejemplar_id,omitempty"` // el ejemplar apartado
+	RetirarHasta Fecha  `json:"retirar_hasta"`
+}
+
+func (r Reserva) Activa() bool {
+	return r.Estado == ReservaEsperando || r.Estado == ReservaDisponible
+}
+
+// ReservaInput es el body de POST /libros/{id}/reservas
+type ReservaInput struct {
+	SocioID int `json:"socio_id"`
+}
+
+func (in ReservaInput) Validate() error {
+	var verr ValidationError
+
+	if in.SocioID <= 0 {
+		verr.Add("socio_id", CodeMustBePositive, "socio_id invalido")
+	}
+
+	return verr.Err()
+}
+
+// NuevaReserva es lo que se guarda al reservar. RetirarHasta es por si al reservar aparecen ejemplares
+// libres que le tocan a los que ya estaban en la cola.
+type NuevaReserva struct {
+	LibroID      int
+	SocioID      int
+	Creada       Fecha
+	RetirarHasta Fecha
+}
+
+type ReservaFilter struct {
+	SocioID *int
+	LibroID *int
+	Activas bool // solo las que estan en la cola
+	Limit   int
+	Offset  int
+}
+
+func (f *ReservaFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
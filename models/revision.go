@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Operaciones de una Revision
+const (
+	RevisionCrear      = "crear"
+	RevisionReemplazar = "reemplazar" // PUT
+	RevisionModificar  = "modificar"  // PATCH, y cuando cambia el nombre de un autor del libro
+	RevisionBorrar     = "borrar"     // a la papelera
+	RevisionRestaurar  = "restaurar"
+	RevisionRevertir   = "revertir"
+)
+
+// Revision es un cambio de un libro, con como estaba antes y como quedo. Se guarda en la misma
+// transaccion que el cambio y no se modifica nunca, ni cuando se purga el libro.
+type Revision struct {
+	LibroID   int    `json:"libro_id"`
+	Rev       int    `json:"rev"` // la version en la que quedo el libro, la misma del ETag
+	Operacion string `json:"operacion"`
+	Antes     *Libro `json:"antes"` // nil en el alta
+	Despues   Libro  `json:"despues"`
+
+	Actor     string    `json:"actor,omitempty"`      // el X-Actor del request, vacio si no vino
+	RequestID string    `json:"request_id,omitempty"` // para cruzarlo con los logs
+	Fecha     time.Time `json:"fecha"`
+}
+
+type RevisionFilter struct {
+	Limit  int
+	Offset int
+}
+
+func (f *RevisionFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
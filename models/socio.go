@@ -0,0 +1,53 @@
+package models
+
+import "strings"
+
+// Socio es alguien que puede llevarse libros prestados.
+type Socio struct {
+	ID     int    `json:"id"`
+	Nombre string `json:"nombre"`
+	Email  string `json:"email"`
+}
+
+type SocioInput struct {
+	Nombre string `json:"nombre"`
+	Email  string `json:"email"`
+}
+
+func (in SocioInput) Validate() error {
+	var verr ValidationError
+
+	if strings.TrimSpace(in.Nombre) == "" {
+		verr.Add("nombre", CodeRequired, "nombre requerido")
+	}
+
+	// no intento validar el RFC entero, solo que tenga pinta de email
+	switch local, dominio, ok := strings.Cut(strings.TrimSpace(in.Email), "@"); {
+	case strings.TrimSpace(in.Email) == "":
+		verr.Add("email", CodeRequired, "email requerido")
+	case !ok || local == "" || !strings.Contains(dominio, ".") || strings.ContainsAny(in.Email, " ,;"):
+		verr.Add("email", CodeInvalidEmail, "email invalido")
+	}
+
+	return verr.Err()
+}
+
+type SocioFilter struct {
+	Nombre *string // coincidencia parcial, sin distinguir mayusculas
+	Limit  int
+	Offset int
+}
+
+func (f *SocioFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Limit < 0 {
+		verr.Add("limit", CodeNegative, "limit no puede ser negativo")
+	}
+
+	if f.Offset < 0 {
+		verr.Add("offset", CodeNegative, "offset no puede ser negativo")
+	}
+
+	return verr.Err()
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SortField es una columna de ?sort=. Con "-" adelante es descendente: ?sort=-ano,titulo
+type SortField struct {
+	Campo string
+	Desc  bool
+}
+
+// columnas por las que se puede ordenar. Cualquier otra cosa se rechaza antes de llegar al repo.
+var sortables = map[string]bool{
+	"titulo": true,
+	"autor":  true,
+	"ano":    true,
+	"id":     true,
+}
+
+func IsSortable(campo string) bool {
+	return sortables[campo]
+}
+
+// SortString arma la forma canonica del orden ("-ano,titulo"). Se guarda en el cursor
+// para detectar que un cursor se use con un orden distinto al que lo genero.
+func SortString(sort []SortField) string {
+	parts := make([]string, 0, len(sort))
+	for _, s := range sort {
+		if s.Desc {
+			parts = append(parts, "-"+s.Campo)
+		} else {
+			parts = append(parts, s.Campo)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// WithTiebreaker agrega id ascendente al final (si no estaba) para que el orden sea total:
+// sin eso dos libros del mismo año pueden cambiar de lugar entre paginas.
+func WithTiebreaker(sort []SortField) []SortField {
+	for _, s := range sort {
+		if s.Campo == "id" {
+			return sort
+		}
+	}
+
+	out := make([]SortField, 0, len(sort)+1)
+	out = append(out, sort...)
+	return append(out, SortField{Campo: "id"})
+}
+
+// SortValue devuelve el valor de la columna de orden campo para el libro l.
+func SortValue(l Libro, campo string) any {
+	switch campo {
+	case "titulo":
+		return l.Titulo
+	case "autor":
+		return l.Autor
+	case "ano":
+		return l.Ano
+	default:
+		return l.ID
+	}
+}
+
+// NewCursor arma el cursor que apunta a l dentro de una lista ordenada por sort.
+func NewCursor(l Libro, sort []SortField, backward bool) Cursor {
+	c := Cursor{ID: l.ID, Sort: SortString(sort), Backward: backward}
+
+	for _, s := range sort {
+		c.Keys = append(c.Keys, fmt.Sprint(SortValue(l, s.Campo)))
+	}
+
+	return c
+}
+
+// Libro reconstruye, a partir de las claves del cursor, un libro "de mentira" con los valores
+// de las columnas de orden. Los repos comparan contra el para saber que va antes o despues.
+func (c Cursor) Libro(sort []SortField) (Libro, error) {
+	l := Libro{ID: c.ID}
+
+	if len(c.Keys) != len(sort) {
+		return l, fmt.Errorf("el cursor tiene %d claves y el orden %d columnas", len(c.Keys), len(sort))
+	}
+
+	for i, s := range sort {
+		key := c.Keys[i]
+
+		switch s.Campo {
+		case "titulo":
+			l.Titulo = key
+		case "autor":
+			l.Autor = key
+		case "ano", "id":
+			v, err := strconv.Atoi(key)
+			if err != nil {
+				return l, fmt.Errorf("clave %s invalida en el cursor: %q", s.Campo, key)
+			}
+			if s.Campo == "ano" {
+				l.Ano = v
+			} else {
+				l.ID = v
+			}
+		default:
+			return l, fmt.Errorf("no se puede ordenar por %q", s.Campo)
+		}
+	}
+
+	return l, nil
+}
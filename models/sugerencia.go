@@ -0,0 +1,30 @@
+package models
+
+// Sugerencia es una de las opciones que devuelve GET /libros/suggest para autocompletar.
+type Sugerencia struct {
+	Texto string `json:"texto"`
+	Campo string `json:"campo"` // "titulo" o "autor"
+}
+
+// SuggestFilter es lo que se busca en GET /libros/suggest?q=...&limit=...
+type SuggestFilter struct {
+	Q     string
+	Limit int
+
+	// Threshold es la similitud minima (0 a 1) para que un titulo o autor aparezca. Sale de la config.
+	Threshold float64
+}
+
+func (f *SuggestFilter) Validate() error {
+	var verr ValidationError
+
+	if f.Q == "" {
+		verr.Add("q", CodeRequired, "q es obligatorio")
+	}
+
+	if f.Limit <= 0 {
+		verr.Add("limit", CodeMustBePositive, "limit tiene que ser mayor a 0")
+	}
+
+	return verr.Err()
+}
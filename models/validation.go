@@ -0,0 +1,70 @@
+package models
+
+import "strings"
+
+// Codigos estables de error de validacion. El frontend se guia por el codigo (no por el mensaje)
+// para traducir, asi que una vez publicados NO se cambian.
+const (
+	CodeRequired       = "required"         // falta el campo o vino vacio
+	CodeBlank          = "blank"            // el campo vino pero solo con espacios
+	CodeMustBePositive = "must_be_positive" // numero <= 0
+	CodeNegative       = "negative"         // numero < 0
+	CodeNotInteger     = "not_integer"      // se esperaba un numero entero
+	CodeTooLarge       = "too_large"        // supera el maximo permitido
+	CodeInvalidRange   = "invalid_range"    // desde > hasta
+	CodeInvalidType    = "invalid_type"     // tipo JSON equivocado (ej: "ano": "dos mil")
+	CodeUnknownField   = "unknown_field"    // campo que no existe en el modelo
+	CodeNotAllowed     = "not_allowed"      // el campo no se puede combinar con otro que si vino
+	CodeInvalidCursor  = "invalid_cursor"   // cursor adulterado, vencido o de otra consulta
+	CodeNotSortable    = "not_sortable"     // columna que no esta en la lista de ordenables
+	CodeNotBoolean     = "not_boolean"      // se esperaba true o false
+	CodeNotFound       = "not_found"        // el id hace referencia a algo que no existe
+	CodeInvalidISBN    = "invalid_isbn"     // formato o digito verificador de ISBN incorrecto
+	CodeInvalidOption  = "invalid_option"   // el valor no esta en la lista de valores permitidos
+	CodeInvalidEmail   = "invalid_email"    // no tiene forma de email
+	CodeInvalidTime    = "invalid_time"     // no es una fecha y hora RFC 3339 (ej: 2024-03-05T12:00:00Z)
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError junta TODOS los problemas de un input, no solo el primero.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Add(field, code, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// AddAll agrega los errores de err (si es un *ValidationError) con prefix delante de cada campo,
+// para validar algo que va adentro de otra cosa (ej: "libro." o "[3].")
+func (e *ValidationError) AddAll(prefix string, err error) {
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		return
+	}
+	for _, fe := range verr.Errors {
+		e.Add(prefix+fe.Field, fe.Code, fe.Message)
+	}
+}
+
+// Err devuelve nil si no se agrego ningun error. Hay que usarlo al final de los Validate:
+// devolver un *ValidationError nil como error da un error != nil (la famosa interface con puntero nil).
+func (e *ValidationError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
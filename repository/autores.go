@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrAutorNotFound  = errors.New("autor not found")
+	ErrAutorDuplicado = errors.New("ya existe un autor con ese nombre")
+	ErrAutorConLibros = errors.New("el autor tiene libros")
+)
+
+// AutoresRepository maneja los autores y su relacion con los libros. Cada vez que cambia la lista
+// de autores de un libro (o el nombre de un autor) tambien actualiza libros.autor, que queda como
+// el texto para mostrar. Al reves tambien: escribir libros.autor desde LibrosRepository rearma la
+// lista (el trigger de la migracion 0015; en memoria, el hook de MemoryLibrosRepo).
+type AutoresRepository interface {
+	GetAll(ctx context.Context, filter models.AutorFilter) ([]models.Autor, error)
+	GetByID(ctx context.Context, id int) (*models.Autor, error)
+	Create(ctx context.Context, in models.AutorInput) (*models.Autor, error)
+	Update(ctx context.Context, id int, upd models.AutorInput) (*models.Autor, error)
+	// Delete devuelve ErrAutorConLibros si todavia tiene libros asociados
+	Delete(ctx context.Context, id int) error
+
+	// GetLibros lista los libros de un autor (ErrAutorNotFound si no existe)
+	GetLibros(ctx context.Context, autorID int) ([]models.Libro, error)
+	// GetByLibro lista los autores de un libro en orden (ErrNotFound si no existe el libro)
+	GetByLibro(ctx context.Context, libroID int) ([]models.Autor, error)
+	// SetLibroAutores reemplaza los autores de un libro. ErrNotFound si no existe el libro,
+	// ErrAutorNotFound si alguno de los autores no existe.
+	SetLibroAutores(ctx context.Context, libroID int, autorIDs []int) ([]models.Autor, error)
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAutoresRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresAutoresRepo(db *pgxpool.Pool) *PostgresAutoresRepo {
+	return &PostgresAutoresRepo{DB: db}
+}
+
+// refreshAutorDisplay recalcula libros.autor ("Autor 1, Autor 2") de los libros de un autor, despues de
+// renombrarlo, y deja la revision de cada uno. Los de la papelera quedan como estaban, igual que en
+// memoria (no se pueden modificar)
+func refreshAutorDisplay(ctx context.Context, tx pgx.Tx, autorID int) error {
+	rows, err := tx.Query(ctx, `
+		SELECT `+libroCols+` FROM libros
+		 WHERE id IN (SELECT libro_id FROM libro_autores WHERE autor_id = $1) AND deleted_at IS NULL
+		 ORDER BY id
+		   FOR UPDATE`, autorID)
+	if err != nil {
+		return err
+	}
+
+	libros, err := pgx.CollectRows(rows, scanLibro)
+	if err != nil {
+		return err
+	}
+
+	for _, antes := range libros {
+		var despues models.Libro
+		err := tx.QueryRow(ctx, `
+			UPDATE libros l
+			   SET autor = (SELECT string_agg(a.nombre, ', ' ORDER BY la.orden)
+			                  FROM libro_autores la JOIN autores a ON a.id = la.autor_id
+			                 WHERE la.libro_id = l.id),
+			       version = version + 1,
+			       updated_at = now()
+			 WHERE l.id = $1
+			RETURNING `+libroCols, antes.ID).
+			Scan(libroDest(&despues)...)
+		if err != nil {
+			return err
+		}
+
+		if err := registrarRevision(ctx, tx, models.RevisionModificar, &antes, &despues); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (repo *PostgresAutoresRepo) GetAll(ctx context.Context, f models.AutorFilter) ([]models.Autor, error) {
+	query := `SELECT id, nombre FROM autores WHERE 1=1`
+	args := []any{}
+	i := 1
+
+	if f.Nombre != nil {
+		query += fmt.Sprintf(" AND nombre ILIKE $%d", i)
+		args = append(args, "%"+*f.Nombre+"%")
+		i++
+	}
+
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := repo.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, autoresError(ctx, "GetAll", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanAutor)
+	if err != nil {
+		return nil, autoresError(ctx, "GetAll", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresAutoresRepo) GetByID(ctx context.Context, id int) (*models.Autor, error) {
+	var a models.Autor
+
+	err := repo.DB.QueryRow(ctx, "SELECT id, nombre FROM autores WHERE id = $1", id).Scan(&a.ID, &a.Nombre)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAutorNotFound
+	}
+	if err != nil {
+		return nil, autoresError(ctx, "GetByID", err)
+	}
+
+	return &a, nil
+}
+
+func (repo *PostgresAutoresRepo) Create(ctx context.Context, in models.AutorInput) (*models.Autor, error) {
+	var a models.Autor
+
+	err := repo.DB.QueryRow(ctx,
+		"INSERT INTO autores (nombre) VALUES ($1) RETURNING id, nombre",
+		in.Nombre).
+		Scan(&a.ID, &a.Nombre)
+
+	if isUniqueViolation(err) {
+		return nil, ErrAutorDuplicado
+	}
+	if err != nil {
+		return nil, autoresError(ctx, "Create", err)
+	}
+
+	return &a, nil
+}
+
+func (repo *PostgresAutoresRepo) Update(ctx context.Context, id int, upd models.AutorInput) (*models.Autor, error) {
+	var a models.Autor
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			"UPDATE autores SET nombre = $1 WHERE id = $2 RETURNING id, nombre",
+			upd.Nombre, id).
+			Scan(&a.ID, &a.Nombre)
+		if err != nil {
+			return err
+		}
+
+		return refreshAutorDisplay(ctx, tx, id)
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAutorNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrAutorDuplicado
+	}
+	if err != nil {
+		return nil, autoresError(ctx, "Update", err)
+	}
+
+	return &a, nil
+}
+
+func (repo *PostgresAutoresRepo) Delete(ctx context.Context, id int) error {
+	result, err := repo.DB.Exec(ctx, "DELETE FROM autores WHERE id = $1", id)
+
+	if isForeignKeyViolation(err) {
+		return ErrAutorConLibros // ON DELETE RESTRICT de libro_autores
+	}
+	if err != nil {
+		return autoresError(ctx, "Delete", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrAutorNotFound
+	}
+	return nil
+}
+
+func (repo *PostgresAutoresRepo) GetLibros(ctx context.Context, autorID int) ([]models.Libro, error) {
+	var existe bool
+	if err := repo.DB.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM autores WHERE id = $1)", autorID).Scan(&existe); err != nil {
+		return nil, autoresError(ctx, "GetLibros", err)
+	}
+	if !existe {
+		return nil, ErrAutorNotFound
+	}
+
+	rows, err := repo.DB.Query(ctx, `
+		SELECT `+libroCols+`
+		  FROM libro_autores la JOIN libros l ON l.id = la.libro_id
+		 WHERE la.autor_id = $1 AND l.deleted_at IS NULL
+		 ORDER BY l.id`, autorID)
+	if err != nil {
+		return nil, autoresError(ctx, "GetLibros", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanLibro)
+	if err != nil {
+		return nil, autoresError(ctx, "GetLibros", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresAutoresRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Autor, error) {
+	var existe bool
+	if err := repo.DB.QueryRow(ctx, "SELECT "+libroVivo("$1"), libroID).Scan(&existe); err != nil {
+		return nil, autoresError(ctx, "GetByLibro", err)
+	}
+	if !existe {
+		return nil, ErrNotFound
+	}
+
+	rows, err := repo.DB.Query(ctx, `
+		SELECT a.id, a.nombre
+		  FROM libro_autores la JOIN autores a ON a.id = la.autor_id
+		 WHERE la.libro_id = $1
+		 ORDER BY la.orden`, libroID)
+	if err != nil {
+		return nil, autoresError(ctx, "GetByLibro", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanAutor)
+	if err != nil {
+		return nil, autoresError(ctx, "GetByLibro", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresAutoresRepo) SetLibroAutores(ctx context.Context, libroID int, autorIDs []int) ([]models.Autor, error) {
+	var result []models.Autor
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		// bloqueo el libro para que dos PUT al mismo tiempo no mezclen las listas
+		antes, err := libroParaEscribir(ctx, tx, libroID, 0)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(ctx, `
+			SELECT a.id, a.nombre
+			  FROM unnest($1::int[]) WITH ORDINALITY AS t (autor_id, orden)
+			  JOIN autores a ON a.id = t.autor_id
+			 ORDER BY t.orden`, autorIDs)
+		if err != nil {
+			return err
+		}
+		if result, err = pgx.CollectRows(rows, scanAutor); err != nil {
+			return err
+		}
+		if len(result) != len(autorIDs) {
+			return ErrAutorNotFound
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM libro_autores WHERE libro_id = $1", libroID); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO libro_autores (libro_id, autor_id, orden)
+			SELECT $1, autor_id, orden FROM unnest($2::int[]) WITH ORDINALITY AS t (autor_id, orden)`,
+			libroID, autorIDs)
+		if isForeignKeyViolation(err) {
+			return ErrAutorNotFound // lo borraron entre el SELECT y el INSERT
+		}
+		if err != nil {
+			return err
+		}
+
+		var despues models.Libro
+		err = tx.QueryRow(ctx,
+			"UPDATE libros SET autor = $1, version = version + 1, updated_at = now() WHERE id = $2 RETURNING "+libroCols,
+			models.AutoresDisplay(result), libroID).
+			Scan(libroDest(&despues)...)
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, models.RevisionModificar, antes, &despues)
+	})
+
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrAutorNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, autoresError(ctx, "SetLibroAutores", err)
+	}
+
+	return result, nil
+}
+
+func scanAutor(row pgx.CollectableRow) (models.Autor, error) {
+	var a models.Autor
+	err := row.Scan(&a.ID, &a.Nombre)
+	return a, err
+}
+
+func autoresError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "autores", op, err)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
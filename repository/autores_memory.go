@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryAutoresRepo es la version en memoria de PostgresAutoresRepo. Los libros los lee y los
+// actualiza (libros.autor) a traves de un LibrosRepository, que normalmente es un MemoryLibrosRepo.
+// Con un MemoryLibrosRepo tambien hace de trigger libro_autores_desde_texto: cada vez que se escribe
+// libros.autor se rearman los links. Los libros que ya estaban antes de crear el repo no se tocan.
+// Orden de los locks: el nuestro antes que el de libros.
+type MemoryAutoresRepo struct {
+	mu      sync.RWMutex
+	autores map[int]models.Autor
+	nextID  int
+	links   map[int][]int // libro id => ids de sus autores en orden, como libro_autores
+
+	libros LibrosRepository
+	// mostrar escribe libros.autor; con un MemoryLibrosRepo lo hace sin volver a pedir nuestro lock
+	mostrar func(ctx context.Context, libroID int, autor string) error
+}
+
+func NewMemoryAutoresRepo(libros LibrosRepository) *MemoryAutoresRepo {
+	repo := &MemoryAutoresRepo{
+		autores: map[int]models.Autor{},
+		nextID:  1,
+		links:   map[int][]int{},
+		libros:  libros,
+	}
+
+	repo.mostrar = func(ctx context.Context, libroID int, autor string) error {
+		_, err := libros.Patch(ctx, libroID, models.LibroPatch{Autor: &autor})
+		return err
+	}
+	if l, ok := libros.(*MemoryLibrosRepo); ok {
+		l.autores = repo.conVincular
+		repo.mostrar = l.mostrarAutores
+	}
+
+	return repo
+}
+
+// conVincular es el hook de MemoryLibrosRepo: corre fn (que toma el lock de libros) con el nuestro tomado
+func (repo *MemoryAutoresRepo) conVincular(fn func(vincular func(libroID int, autor string)) error) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	return fn(repo.vincular)
+}
+
+// separadorAutores corta libros.autor igual que la migracion 0005 y el trigger libro_autores_desde_texto
+var separadorAutores = regexp.MustCompile(`\s*[,;&]\s*`)
+
+// vincular es el trigger libro_autores_desde_texto: rearma los autores del libro desde el texto, buscando
+// cada nombre sin mirar mayusculas ni tildes y creandolo si no existe. Si el texto es justo el que sale
+// de los links (SetLibroAutores, renombrar) no toca nada, asi no se parte un nombre con coma.
+// Hay que tener el lock tomado.
+func (repo *MemoryAutoresRepo) vincular(libroID int, autor string) {
+	if autor == repo.display(libroID) {
+		return
+	}
+
+	ids := []int{}
+	for _, nombre := range separadorAutores.Split(autor, -1) {
+		nombre = strings.TrimSpace(nombre)
+		if nombre == "" {
+			continue
+		}
+
+		id := repo.porNombre(nombre)
+		if id == 0 {
+			id = repo.nextID
+			repo.nextID++
+			repo.autores[id] = models.Autor{ID: id, Nombre: nombre}
+		}
+
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	repo.links[libroID] = ids
+}
+
+func (repo *MemoryAutoresRepo) GetAll(ctx context.Context, f models.AutorFilter) ([]models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var nombre *regexp.Regexp
+	if f.Nombre != nil {
+		nombre = ilikeContains(*f.Nombre)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Autor{}
+	for _, a := range repo.autores {
+		if nombre == nil || nombre.MatchString(a.Nombre) {
+			result = append(result, a)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Autor) int { return a.ID - b.ID })
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func (repo *MemoryAutoresRepo) GetByID(ctx context.Context, id int) (*models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	a, ok := repo.autores[id]
+	if !ok {
+		return nil, ErrAutorNotFound
+	}
+
+	return &a, nil
+}
+
+func (repo *MemoryAutoresRepo) Create(ctx context.Context, in models.AutorInput) (*models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.nombreUsado(in.Nombre, 0) {
+		return nil, ErrAutorDuplicado
+	}
+
+	a := models.Autor{ID: repo.nextID, Nombre: in.Nombre}
+	repo.nextID++
+
+	repo.autores[a.ID] = a
+	return &a, nil
+}
+
+func (repo *MemoryAutoresRepo) Update(ctx context.Context, id int, upd models.AutorInput) (*models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.autores[id]; !ok {
+		return nil, ErrAutorNotFound
+	}
+
+	if repo.nombreUsado(upd.Nombre, id) {
+		return nil, ErrAutorDuplicado
+	}
+
+	a := models.Autor{ID: id, Nombre: upd.Nombre}
+	repo.autores[id] = a
+
+	// igual que refreshAutorDisplay: los libros de este autor muestran el nombre nuevo
+	for libroID, ids := range repo.links {
+		if slices.Contains(ids, id) {
+			if err := repo.refreshDisplay(ctx, libroID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &a, nil
+}
+
+func (repo *MemoryAutoresRepo) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.autores[id]; !ok {
+		return ErrAutorNotFound
+	}
+
+	libros, err := repo.librosDe(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(libros) > 0 {
+		return ErrAutorConLibros
+	}
+
+	delete(repo.autores, id)
+	return nil
+}
+
+func (repo *MemoryAutoresRepo) GetLibros(ctx context.Context, autorID int) ([]models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	if _, ok := repo.autores[autorID]; !ok {
+		return nil, ErrAutorNotFound
+	}
+
+	libros, err := repo.librosDe(ctx, autorID)
+	if err != nil {
+		return nil, err
+	}
+
+	// los de la papelera no se muestran, pero para Delete siguen contando (en postgres sigue el link)
+	return slices.DeleteFunc(libros, func(l models.Libro) bool { return l.Borrado != nil }), nil
+}
+
+func (repo *MemoryAutoresRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Autor{}
+	for _, id := range repo.links[libroID] {
+		result = append(result, repo.autores[id])
+	}
+
+	return result, nil
+}
+
+func (repo *MemoryAutoresRepo) SetLibroAutores(ctx context.Context, libroID int, autorIDs []int) ([]models.Autor, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Autor, 0, len(autorIDs))
+	for _, id := range autorIDs {
+		a, ok := repo.autores[id]
+		if !ok {
+			return nil, ErrAutorNotFound
+		}
+		result = append(result, a)
+	}
+
+	repo.links[libroID] = slices.Clone(autorIDs)
+
+	if err := repo.refreshDisplay(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// librosDe devuelve los libros de un autor ordenados por id, incluidos los de la papelera. Los links
+// de libros purgados se saltean aca (en postgres los borra el ON DELETE CASCADE). Hay que tener el lock tomado.
+func (repo *MemoryAutoresRepo) librosDe(ctx context.Context, autorID int) ([]models.Libro, error) {
+	result := []models.Libro{}
+
+	for libroID, ids := range repo.links {
+		if !slices.Contains(ids, autorID) {
+			continue
+		}
+
+		l, err := repo.libros.GetByIDConBorrados(ctx, libroID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *l)
+	}
+
+	slices.SortFunc(result, func(a, b models.Libro) int { return a.ID - b.ID })
+	return result, nil
+}
+
+// display es libros.autor como lo arman los links. Hay que tener el lock tomado.
+func (repo *MemoryAutoresRepo) display(libroID int) string {
+	autores := make([]models.Autor, 0, len(repo.links[libroID]))
+	for _, id := range repo.links[libroID] {
+		autores = append(autores, repo.autores[id])
+	}
+
+	return models.AutoresDisplay(autores)
+}
+
+// refreshDisplay reescribe libros.autor con los nombres actuales. Hay que tener el lock tomado.
+func (repo *MemoryAutoresRepo) refreshDisplay(ctx context.Context, libroID int) error {
+	err := repo.mostrar(ctx, libroID, repo.display(libroID))
+	if errors.Is(err, ErrNotFound) {
+		return nil // el libro se borro, no hay nada que actualizar
+	}
+	return err
+}
+
+// nombreUsado imita el indice unico sobre lower(f_unaccent(nombre)). except es el id que se esta editando.
+func (repo *MemoryAutoresRepo) nombreUsado(nombre string, except int) bool {
+	id := repo.porNombre(nombre)
+	return id != 0 && id != except
+}
+
+// porNombre es el id del autor con ese nombre segun el indice unico, o 0 si no hay
+func (repo *MemoryAutoresRepo) porNombre(nombre string) int {
+	for _, a := range repo.autores {
+		if normalizar(a.Nombre) == normalizar(nombre) {
+			return a.ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Batch manda todas las operaciones en un solo pgx.Batch (un viaje a la base, no uno por operacion), y
+// despues las revisiones en otro, todo en una transaccion. Cada operacion es una sola sentencia y los
+// errores de negocio (no existe, otra version, ISBN usado) vuelven como resultado sin abortar nada.
+//
+// Cada sentencia va entre un SAVEPOINT y su RELEASE: si una tira un error de la base (el indice de isbn
+// porque otro request cargo el mismo ISBN, un check, lo que sea) se vuelve a antes de esa operacion,
+// sin perder las anteriores, y se manda el resto. Ese error queda en su resultado; solo un error de la
+// conexion hace fallar todo el batch.
+func (repo *PostgresLibrosRepo) Batch(ctx context.Context, ops []models.LibroOp, atomic bool) ([]ResultadoBatch, error) {
+	resultados := make([]ResultadoBatch, len(ops))
+	antes := make([]*models.Libro, len(ops))
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		for desde := 0; desde < len(ops); {
+			pos, err := correrBatch(ctx, tx, ops[desde:], resultados[desde:], antes[desde:])
+
+			if err == nil {
+				break
+			}
+			var pgErr *pgconn.PgError
+			if !errors.As(err, &pgErr) {
+				return err // la conexion, no una operacion
+			}
+
+			i := desde + pos
+			if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT batch_op"); err != nil {
+				return err
+			}
+			resultados[i] = ResultadoBatch{Err: errorDeOp(ctx, tx, ops[i], err)}
+			if atomic {
+				return &BatchError{Indice: i, Err: resultados[i].Err}
+			}
+			desde = i + 1
+		}
+
+		// con el batch cerrado la conexion se puede volver a usar: el libro que tiene cada ISBN se busca aca
+		for i := range resultados {
+			var dup *ISBNDuplicadoError
+			if errors.As(resultados[i].Err, &dup) && dup.ID == 0 {
+				resultados[i].Err = isbnDuplicadoEn(ctx, tx, dup.ISBN)
+			}
+		}
+
+		if atomic {
+			for i, r := range resultados {
+				if r.Err != nil {
+					return &BatchError{Indice: i, Err: r.Err}
+				}
+			}
+		}
+
+		return registrarRevisiones(ctx, tx, ops, resultados, antes)
+	})
+
+	var batchErr *BatchError
+	switch {
+	case errors.As(err, &batchErr):
+		return nil, err
+	case err != nil:
+		return nil, dbError(ctx, "Batch", err)
+	}
+
+	return resultados, nil
+}
+
+// correrBatch manda ops en un pgx.Batch y completa sus resultados y el libro de antes de cada cambio.
+// Si una sentencia tira error la transaccion queda abortada hasta el ROLLBACK TO SAVEPOINT y la base
+// descarta todo lo que venia despues: devuelve la posicion de esa operacion y el error.
+func correrBatch(ctx context.Context, tx pgx.Tx, ops []models.LibroOp, resultados []ResultadoBatch, antes []*models.Libro) (int, error) {
+	b := &pgx.Batch{}
+	for _, op := range ops {
+		sql, args := sentenciaBatch(op)
+		b.Queue("SAVEPOINT batch_op")
+		b.Queue(sql, args...)
+		b.Queue("RELEASE SAVEPOINT batch_op")
+	}
+
+	br := tx.SendBatch(ctx, b)
+	for pos, op := range ops {
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return pos, err
+		}
+
+		rows, err := br.Query()
+		if err != nil {
+			br.Close()
+			return pos, err
+		}
+		filas, err := pgx.CollectRows(rows, scanFilaBatch)
+		if err != nil {
+			br.Close()
+			return pos, err
+		}
+		resultados[pos], antes[pos] = resultadoBatch(op, filas)
+
+		if _, err := br.Exec(); err != nil {
+			br.Close()
+			return pos, err
+		}
+	}
+
+	return -1, br.Close()
+}
+
+// errorDeOp es el error que queda en el resultado de una operacion cuya sentencia fallo
+func errorDeOp(ctx context.Context, tx pgx.Tx, op models.LibroOp, err error) error {
+	if isISBNDuplicado(err) {
+		return isbnDuplicadoEn(ctx, tx, op.Input().ISBN)
+	}
+	return dbError(ctx, "Batch", err)
+}
+
+// registrarRevisiones guarda en un solo viaje la revision de cada operacion que cambio algo, igual que
+// registrarRevision
+func registrarRevisiones(ctx context.Context, tx pgx.Tx, ops []models.LibroOp, resultados []ResultadoBatch, antes []*models.Libro) error {
+	b := &pgx.Batch{}
+	for i, r := range resultados {
+		// modificar sin campos no cambia nada: no tiene antes ni revision
+		if r.Err != nil || (antes[i] == nil && ops[i].Op != models.RevisionCrear) {
+			continue
+		}
+		b.Queue(insertRevision, revisionArgs(ctx, ops[i].Op, antes[i], r.Libro)...)
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+	return tx.SendBatch(ctx, b).Close()
+}
+
+// filaBatch es lo que devuelven las sentencias de sentenciaBatch
+type filaBatch struct {
+	estado string // ok, antes, version, prestado, reservado o isbn
+	libro  models.Libro
+}
+
+func scanFilaBatch(row pgx.CollectableRow) (filaBatch, error) {
+	var f filaBatch
+	err := row.Scan(append([]any{&f.estado}, libroDest(&f.libro)...)...)
+	return f, err
+}
+
+// resultadoBatch arma el resultado de op con las filas de su sentencia, y el libro de antes del cambio
+func resultadoBatch(op models.LibroOp, filas []filaBatch) (ResultadoBatch, *models.Libro) {
+	var res ResultadoBatch
+	var antes *models.Libro
+
+	for _, f := range filas {
+		switch f.estado {
+		case "ok":
+			res.Libro = &f.libro
+		case "antes":
+			antes = &f.libro
+		case "version":
+			res.Err = &VersionConflictError{Actual: f.libro}
+		case "prestado":
+			res.Err = ErrEjemplarPrestado
+		case "reservado":
+			res.Err = ErrEjemplarReservado
+		case "isbn":
+			res.Err = &ISBNDuplicadoError{ISBN: op.Input().ISBN}
+		}
+	}
+
+	switch {
+	case res.Libro != nil || res.Err != nil:
+	case op.Op == models.RevisionCrear:
+		res.Err = &ISBNDuplicadoError{ISBN: op.Input().ISBN}
+	default:
+		res.Err = ErrNotFound
+	}
+
+	return res, antes
+}
+
+// crearBatch es Create en una sentencia: si el ISBN ya lo tiene otro libro no inserta nada (no
+// devuelve filas)
+const crearBatch = `
+	INSERT INTO libros (titulo, autor, ano, isbn) VALUES ($1, $2, $3, NULLIF($4, ''))
+	ON CONFLICT (isbn) WHERE deleted_at IS NULL DO NOTHING
+	RETURNING 'ok', ` + libroCols
+
+// cambioBatch arma UpdateIf, PatchIf o DeleteIf en una sentencia: bloquea el libro (antes) y le aplica
+// set si esta en version, el ISBN nuevo no choca con otro libro y, si es borrar, no tiene ejemplares
+// prestados ni apartados (despues). Devuelve 'antes' y 'ok' con el libro de antes y el de despues,
+// 'version', 'prestado', 'reservado' o 'isbn' con el libro como esta, o nada si no existe.
+// $1 es el id, $2 la version (0 es cualquiera) y set usa de $3 en adelante. isbn es la expresion del
+// ISBN nuevo, vacia si no cambia.
+func cambioBatch(set, isbn string, borrar bool) string {
+	choca := "false"
+	if isbn != "" {
+		choca = "EXISTS (SELECT 1 FROM libros o WHERE o.isbn = " + isbn + " AND o.id <> $1 AND o.deleted_at IS NULL)"
+	}
+	prestado, reservado := "false", "false"
+	if borrar {
+		prestado, reservado = libroPrestado("$1"), libroReservado("$1")
+	}
+
+	return `
+		WITH antes AS (
+			SELECT *, ($2 <> 0 AND version <> $2) AS otra_version, ` + choca + ` AS choca,
+			       ` + prestado + ` AS prestado, ` + reservado + ` AS reservado
+			  FROM libros
+			 WHERE id = $1 AND deleted_at IS NULL
+			   FOR UPDATE
+		), despues AS (
+			UPDATE libros l SET ` + set + `, version = l.version + 1, updated_at = now()
+			  FROM antes a
+			 WHERE l.id = a.id AND NOT a.otra_version AND NOT a.choca AND NOT a.prestado AND NOT a.reservado
+			RETURNING l.*
+		)
+		SELECT CASE WHEN EXISTS (SELECT 1 FROM despues) THEN 'antes' WHEN otra_version THEN 'version'
+		            WHEN prestado THEN 'prestado' WHEN reservado THEN 'reservado' ELSE 'isbn' END, ` + libroCols + `
+		  FROM antes
+		UNION ALL
+		SELECT 'ok', ` + libroCols + ` FROM despues`
+}
+
+// sinCambiosBatch es PatchIf sin campos: no cambia nada ni deja revision, solo mira la version
+const sinCambiosBatch = `
+	SELECT CASE WHEN $2 <> 0 AND version <> $2 THEN 'version' ELSE 'ok' END, ` + libroCols + `
+	  FROM libros
+	 WHERE id = $1 AND deleted_at IS NULL`
+
+// sentenciaBatch devuelve la sentencia de op con sus argumentos
+func sentenciaBatch(op models.LibroOp) (string, []any) {
+	args := []any{op.ID, op.Version}
+
+	switch op.Op {
+	case models.RevisionCrear:
+		in := op.Input()
+		return crearBatch, []any{in.Titulo, in.Autor, in.Ano, in.ISBN}
+
+	case models.RevisionReemplazar:
+		in := op.Input()
+		set := "titulo = $3, autor = $4, ano = $5, isbn = NULLIF($6, '')"
+		return cambioBatch(set, "NULLIF($6, '')", false), append(args, in.Titulo, in.Autor, in.Ano, in.ISBN)
+
+	case models.RevisionModificar:
+		p := *op.Libro
+		if p == (models.LibroPatch{}) {
+			return sinCambiosBatch, args
+		}
+
+		var set []string
+		var isbn string
+		campo := func(col string, v any) {
+			args = append(args, v)
+			set = append(set, fmt.Sprintf("%s = $%d", col, len(args)))
+		}
+		if p.Titulo != nil {
+			campo("titulo", *p.Titulo)
+		}
+		if p.Autor != nil {
+			campo("autor", *p.Autor)
+		}
+		if p.Ano != nil {
+			campo("ano", *p.Ano)
+		}
+		if p.ISBN != nil {
+			args = append(args, *p.ISBN)
+			isbn = fmt.Sprintf("NULLIF($%d, '')", len(args)) // "" le saca el ISBN
+			set = append(set, "isbn = "+isbn)
+		}
+		return cambioBatch(strings.Join(set, ", "), isbn, false), args
+
+	default:
+		return cambioBatch("deleted_at = now()", "", true), args
+	}
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"api-libros/models"
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Busqueda de texto completo (?q=). En postgres la hace la columna busqueda de la migracion 0003;
+// aca esta ese SQL y la aproximacion en Go que usa el repo en memoria, juntos como en orden.go.
+
+// tsQuery es la busqueda del usuario como tsquery. Se arma con las dos configuraciones de la columna:
+// con espanol_unaccent "crimenes" encuentra "crimen", con simple_unaccent los nombres propios
+// coinciden tal cual. websearch_to_tsquery no falla nunca, acepta cualquier cosa que escriba el usuario.
+func tsQuery(n int) string {
+	return fmt.Sprintf("(websearch_to_tsquery('espanol_unaccent', $%d) || websearch_to_tsquery('simple_unaccent', $%d))", n, n)
+}
+
+// opciones de ts_headline: HighlightAll devuelve el texto entero y no un fragmento, los titulos son cortos
+const headlineOpts = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+// headlines son las dos columnas extra del SELECT cuando hay busqueda. ts_headline no escapa nada y el
+// frontend muestra el resaltado como HTML: se le pasa el texto ya escapado, si no un titulo con un
+// <script> se ejecuta. El parser de postgres toma "&lt;" como una entidad, las palabras no cambian.
+func headlines(n int) string {
+	q := tsQuery(n)
+	return fmt.Sprintf(", ts_headline('espanol_unaccent', %s, %s, '%s'), ts_headline('espanol_unaccent', %s, %s, '%s')",
+		escaparHTML("titulo"), q, headlineOpts, escaparHTML("autor"), q, headlineOpts)
+}
+
+// escaparHTML es html.EscapeString en SQL: los mismos cinco caracteres con las mismas entidades
+func escaparHTML(col string) string {
+	return fmt.Sprintf(`replace(replace(replace(replace(replace(%s, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&#34;'), '''', '&#39;')`, col)
+}
+
+// busqueda es la version en memoria. No tiene el stemmer de postgres, asi que es una aproximacion:
+// mismas palabras, sin tildes ni mayusculas, con un plural/singular basico y las mismas stopwords.
+type busqueda struct {
+	terminos []string
+}
+
+func newBusqueda(q string) busqueda {
+	var b busqueda
+	for _, p := range palabras(q) {
+		b.terminos = append(b.terminos, normalizar(q[p[0]:p[1]]))
+	}
+	return b
+}
+
+// match imita `busqueda @@ (espanol || simple)`: alcanza con que coincidan todas las palabras
+// que no son stopwords (como en espanol) o todas las palabras tal cual (como en simple).
+func (b busqueda) match(l models.Libro) bool {
+	doc := append(palabrasNormalizadas(l.Titulo), palabrasNormalizadas(l.Autor)...)
+
+	simple := len(b.terminos) > 0
+	espanol, hayTerminos := true, false
+
+	for _, t := range b.terminos {
+		if !contiene(doc, t, false) {
+			simple = false
+		}
+		if stopwords[t] {
+			continue // espanol_unaccent las saca de la consulta
+		}
+		hayTerminos = true
+		if !contiene(doc, t, true) {
+			espanol = false
+		}
+	}
+
+	return (hayTerminos && espanol) || simple
+}
+
+// rank imita ts_rank con los pesos por defecto: una coincidencia en el titulo (A) vale 1 y en el autor (B) 0.4
+func (b busqueda) rank(l models.Libro) float64 {
+	titulo, autor := palabrasNormalizadas(l.Titulo), palabrasNormalizadas(l.Autor)
+
+	var r float64
+	for _, t := range b.terminos {
+		switch {
+		case contiene(titulo, t, true):
+			r += 1
+		case contiene(autor, t, true):
+			r += 0.4
+		}
+	}
+	return r
+}
+
+// resaltar marca con <mark> las palabras de s que coinciden con algun termino (menos las stopwords,
+// que ts_headline tampoco marca). El resto del texto va escapado, como en headlines.
+func (b busqueda) resaltar(s string) string {
+	var sb strings.Builder
+	last := 0
+
+	for _, p := range palabras(s) {
+		w := normalizar(s[p[0]:p[1]])
+		if stopwords[w] || !b.coincide(w) {
+			continue
+		}
+		sb.WriteString(html.EscapeString(s[last:p[0]]))
+		sb.WriteString("<mark>" + html.EscapeString(s[p[0]:p[1]]) + "</mark>")
+		last = p[1]
+	}
+
+	sb.WriteString(html.EscapeString(s[last:]))
+	return sb.String()
+}
+
+func (b busqueda) coincide(w string) bool {
+	return contiene(b.terminos, w, true)
+}
+
+// contiene: conRaiz compara como el stemmer de espanol (aproximado), si no, la palabra exacta como simple
+func contiene(doc []string, t string, conRaiz bool) bool {
+	for _, w := range doc {
+		if w == t || (conRaiz && raiz(w) == raiz(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// raiz es un stemmer de juguete: saca el plural y la vocal final ("novelas" ~ "novela" ~ "novel")
+func raiz(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "es"):
+		w = w[:len(w)-2]
+	case len(w) > 3 && strings.HasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+	if len(w) > 3 && strings.ContainsAny(w[len(w)-1:], "aeo") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+var sinTildes = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// normalizar hace lo mismo que unaccent + lower: "Fiódor" => "fiodor", "Ñandú" => "nandu"
+func normalizar(s string) string {
+	out, _, err := transform.String(sinTildes, s)
+	if err != nil {
+		out = s
+	}
+	return strings.ToLower(out)
+}
+
+func palabrasNormalizadas(s string) []string {
+	var out []string
+	for _, p := range palabras(s) {
+		out = append(out, normalizar(s[p[0]:p[1]]))
+	}
+	return out
+}
+
+// palabras devuelve las posiciones [inicio, fin) de cada tira de letras y numeros de s,
+// igual que el parser de postgres corta "Fahrenheit 451" en "fahrenheit" y "451".
+func palabras(s string) [][2]int {
+	var out [][2]int
+	start := -1
+
+	for i, r := range s {
+		esPalabra := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+		switch {
+		case esPalabra && start < 0:
+			start = i
+		case !esPalabra && start >= 0:
+			out = append(out, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, [2]int{start, len(s)})
+	}
+
+	return out
+}
+
+// las stopwords mas comunes del diccionario spanish de postgres (ya sin tildes)
+var stopwords = map[string]bool{
+	"a": true, "al": true, "con": true, "como": true, "de": true, "del": true, "e": true,
+	"el": true, "en": true, "es": true, "la": true, "las": true, "le": true, "lo": true,
+	"los": true, "mas": true, "mi": true, "no": true, "o": true, "para": true, "pero": true,
+	"por": true, "que": true, "se": true, "si": true, "sin": true, "su": true, "sus": true,
+	"un": true, "una": true, "uno": true, "unos": true, "y": true, "ya": true,
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrEjemplarNotFound      = errors.New("ejemplar not found")
+	ErrCodigoBarrasDuplicado = errors.New("ya existe un ejemplar con ese codigo de barras")
+)
+
+// EjemplaresRepository maneja el inventario: las copias fisicas de cada libro.
+// Todos los metodos reciben el libro: si no existe devuelven ErrNotFound, y un ejemplar que
+// es de otro libro da ErrEjemplarNotFound.
+type EjemplaresRepository interface {
+	// GetByLibro lista los ejemplares de un libro por id (ErrNotFound si no existe el libro)
+	GetByLibro(ctx context.Context, libroID int) ([]models.Ejemplar, error)
+	GetByID(ctx context.Context, libroID, id int) (*models.Ejemplar, error)
+	// Create, Update: ErrNotFound si no existe el libro, ErrCodigoBarrasDuplicado si el codigo ya esta usado.
+	// Si hay reservas esperando, Create le aparta el ejemplar nuevo a la primera hasta retirarHasta.
+	Create(ctx context.Context, libroID int, in models.EjemplarInput, retirarHasta models.Fecha) (*models.Ejemplar, error)
+	Update(ctx context.Context, libroID, id int, upd models.EjemplarInput) (*models.Ejemplar, error)
+	// Delete devuelve ErrEjemplarPrestado si el ejemplar esta prestado y ErrEjemplarReservado si esta
+	// apartado para una reserva
+	Delete(ctx context.Context, libroID, id int) error
+
+	// Disponibilidad cuenta los ejemplares del libro y cuantos estan prestados (ErrNotFound si no existe el libro)
+	Disponibilidad(ctx context.Context, libroID int) (models.Disponibilidad, error)
+}
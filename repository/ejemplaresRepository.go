@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresEjemplaresRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresEjemplaresRepo(db *pgxpool.Pool) *PostgresEjemplaresRepo {
+	return &PostgresEjemplaresRepo{DB: db}
+}
+
+const ejemplarCols = `id, libro_id, codigo_barras, ubicacion, estado, fecha_adquisicion`
+
+func (repo *PostgresEjemplaresRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Ejemplar, error) {
+	if err := repo.libroExiste(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.Query(ctx, "SELECT "+ejemplarCols+" FROM ejemplares WHERE libro_id = $1 ORDER BY id", libroID)
+	if err != nil {
+		return nil, ejemplaresError(ctx, "GetByLibro", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanEjemplar)
+	if err != nil {
+		return nil, ejemplaresError(ctx, "GetByLibro", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresEjemplaresRepo) GetByID(ctx context.Context, libroID, id int) (*models.Ejemplar, error) {
+	rows, _ := repo.DB.Query(ctx, "SELECT "+ejemplarCols+" FROM ejemplares WHERE id = $1 AND libro_id = $2 AND "+libroVivo("$2"), id, libroID)
+
+	e, err := pgx.CollectExactlyOneRow(rows, scanEjemplar)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repo.noEncontrado(ctx, libroID)
+	}
+	if err != nil {
+		return nil, ejemplaresError(ctx, "GetByID", err)
+	}
+
+	return &e, nil
+}
+
+func (repo *PostgresEjemplaresRepo) Create(ctx context.Context, libroID int, in models.EjemplarInput, retirarHasta models.Fecha) (*models.Ejemplar, error) {
+	var e models.Ejemplar
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		// el lock de las reservas: si hay cola, el ejemplar nuevo se aparta antes de que lo pueda pedir otro
+		if err := bloquearLibro(ctx, tx, libroID); err != nil {
+			return err
+		}
+
+		// el FK no alcanza: un libro en la papelera sigue estando en la tabla
+		var existe bool
+		if err := tx.QueryRow(ctx, "SELECT "+libroVivo("$1"), libroID).Scan(&existe); err != nil {
+			return err
+		}
+		if !existe {
+			return ErrNotFound
+		}
+
+		rows, _ := tx.Query(ctx, `
+			INSERT INTO ejemplares (libro_id, codigo_barras, ubicacion, estado, fecha_adquisicion)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING `+ejemplarCols,
+			libroID, in.CodigoBarras, in.Ubicacion, in.Estado, in.FechaAdquisicion)
+
+		var err error
+		if e, err = pgx.CollectExactlyOneRow(rows, scanEjemplar); err != nil {
+			return err
+		}
+
+		_, err = atenderLibro(ctx, tx, libroID, nil, retirarHasta)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, err
+	case isUniqueViolation(err):
+		return nil, ErrCodigoBarrasDuplicado
+	case err != nil:
+		return nil, ejemplaresError(ctx, "Create", err)
+	}
+
+	return &e, nil
+}
+
+func (repo *PostgresEjemplaresRepo) Update(ctx context.Context, libroID, id int, upd models.EjemplarInput) (*models.Ejemplar, error) {
+	rows, _ := repo.DB.Query(ctx, `
+		UPDATE ejemplares
+		   SET codigo_barras = $1, ubicacion = $2, estado = $3, fecha_adquisicion = $4
+		 WHERE id = $5 AND libro_id = $6 AND `+libroVivo("$6")+`
+		RETURNING `+ejemplarCols,
+		upd.CodigoBarras, upd.Ubicacion, upd.Estado, upd.FechaAdquisicion, id, libroID)
+
+	e, err := pgx.CollectExactlyOneRow(rows, scanEjemplar)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repo.noEncontrado(ctx, libroID)
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrCodigoBarrasDuplicado
+	}
+	if err != nil {
+		return nil, ejemplaresError(ctx, "Update", err)
+	}
+
+	return &e, nil
+}
+
+func (repo *PostgresEjemplaresRepo) Delete(ctx context.Context, libroID, id int) error {
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		// mismo lock que toman Prestar y las reservas: o el prestamo (o la reserva) entra antes y no
+		// borro, o borro y ya no encuentran el ejemplar
+		var prestado, reservado bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM prestamos WHERE ejemplar_id = e.id AND devuelto IS NULL),
+			       EXISTS (SELECT 1 FROM reservas WHERE ejemplar_id = e.id AND estado = 'disponible')
+			  FROM ejemplares e
+			 WHERE e.id = $1 AND e.libro_id = $2 AND `+libroVivo("$2")+`
+			   FOR UPDATE OF e`, id, libroID).Scan(&prestado, &reservado)
+		if err != nil {
+			return err
+		}
+		if prestado {
+			return ErrEjemplarPrestado
+		}
+		if reservado {
+			return ErrEjemplarReservado
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM ejemplares WHERE id = $1", id)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return repo.noEncontrado(ctx, libroID)
+	case errors.Is(err, ErrEjemplarPrestado), errors.Is(err, ErrEjemplarReservado):
+		return err
+	case err != nil:
+		return ejemplaresError(ctx, "Delete", err)
+	}
+
+	return nil
+}
+
+func (repo *PostgresEjemplaresRepo) Disponibilidad(ctx context.Context, libroID int) (models.Disponibilidad, error) {
+	if err := repo.libroExiste(ctx, libroID); err != nil {
+		return models.Disponibilidad{}, err
+	}
+
+	var d models.Disponibilidad
+	err := repo.DB.QueryRow(ctx, `
+		SELECT count(*), count(p.id), count(r.id),
+		       (SELECT count(*) FROM reservas WHERE libro_id = $1 AND estado = 'esperando')
+		  FROM ejemplares e
+		  LEFT JOIN prestamos p ON p.ejemplar_id = e.id AND p.devuelto IS NULL
+		  LEFT JOIN reservas r ON r.ejemplar_id = e.id AND r.estado = 'disponible'
+		 WHERE e.libro_id = $1`, libroID).Scan(&d.Total, &d.Prestados, &d.Apartados, &d.EnEspera)
+	if err != nil {
+		return d, ejemplaresError(ctx, "Disponibilidad", err)
+	}
+	d.Disponibles = d.Total - d.Prestados - d.Apartados
+
+	return d, nil
+}
+
+func (repo *PostgresEjemplaresRepo) libroExiste(ctx context.Context, libroID int) error {
+	var existe bool
+	if err := repo.DB.QueryRow(ctx, "SELECT "+libroVivo("$1"), libroID).Scan(&existe); err != nil {
+		return ejemplaresError(ctx, "libroExiste", err)
+	}
+	if !existe {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// noEncontrado distingue, cuando un UPDATE o DELETE no toco ninguna fila, si lo que falta es el libro o el ejemplar
+func (repo *PostgresEjemplaresRepo) noEncontrado(ctx context.Context, libroID int) error {
+	if err := repo.libroExiste(ctx, libroID); err != nil {
+		return err
+	}
+	return ErrEjemplarNotFound
+}
+
+// libroVivo es la condicion "el libro param existe y no esta en la papelera". Los ejemplares de un libro
+// borrado siguen en la tabla hasta la purga (y sus prestamos siguen andando), pero por /libros/{id} no se ven.
+func libroVivo(param string) string {
+	return "EXISTS (SELECT 1 FROM libros WHERE id = " + param + " AND deleted_at IS NULL)"
+}
+
+func scanEjemplar(row pgx.CollectableRow) (models.Ejemplar, error) {
+	var e models.Ejemplar
+	err := row.Scan(&e.ID, &e.LibroID, &e.CodigoBarras, &e.Ubicacion, &e.Estado, &e.FechaAdquisicion)
+	return e, err
+}
+
+func ejemplaresError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "ejemplares", op, err)
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// MemoryEjemplaresRepo es la version en memoria de PostgresEjemplaresRepo. Que el libro exista
+// lo pregunta a un LibrosRepository, normalmente un MemoryLibrosRepo.
+type MemoryEjemplaresRepo struct {
+	mu         sync.RWMutex
+	ejemplares map[int]models.Ejemplar
+	nextID     int
+
+	libros LibrosRepository
+
+	// los hooks los ponen NewMemoryPrestamosRepo y NewMemoryReservasRepo; sin ellos ningun ejemplar
+	// esta prestado ni apartado. Se llaman con repo.mu tomado (el orden de los locks esta en MemoryPrestamosRepo)
+	prestado func(ejemplarID int) bool
+	apartado func(ctx context.Context, ejemplarID int) (bool, error)
+	enEspera func(ctx context.Context, libroID int) (int, error)
+	atender  func(ctx context.Context, libroID int, retirarHasta models.Fecha) error
+}
+
+func NewMemoryEjemplaresRepo(libros LibrosRepository) *MemoryEjemplaresRepo {
+	return &MemoryEjemplaresRepo{
+		ejemplares: map[int]models.Ejemplar{},
+		nextID:     1,
+		libros:     libros,
+	}
+}
+
+func (repo *MemoryEjemplaresRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Ejemplar, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Ejemplar{}
+	for _, e := range repo.ejemplares {
+		if e.LibroID == libroID {
+			result = append(result, e)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Ejemplar) int { return a.ID - b.ID })
+	return result, nil
+}
+
+func (repo *MemoryEjemplaresRepo) GetByID(ctx context.Context, libroID, id int) (*models.Ejemplar, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	e, ok := repo.ejemplares[id]
+	if !ok || e.LibroID != libroID {
+		return nil, ErrEjemplarNotFound
+	}
+
+	return &e, nil
+}
+
+func (repo *MemoryEjemplaresRepo) Create(ctx context.Context, libroID int, in models.EjemplarInput, retirarHasta models.Fecha) (*models.Ejemplar, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	if err := repo.codigoUsado(ctx, in.CodigoBarras, 0); err != nil {
+		return nil, err
+	}
+
+	e := ejemplarDe(repo.nextID, libroID, in)
+	repo.nextID++
+
+	repo.ejemplares[e.ID] = e
+
+	if repo.atender != nil {
+		if err := repo.atender(ctx, libroID, retirarHasta); err != nil {
+			return nil, err
+		}
+	}
+
+	return &e, nil
+}
+
+func (repo *MemoryEjemplaresRepo) Update(ctx context.Context, libroID, id int, upd models.EjemplarInput) (*models.Ejemplar, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	if e, ok := repo.ejemplares[id]; !ok || e.LibroID != libroID {
+		return nil, ErrEjemplarNotFound
+	}
+
+	if err := repo.codigoUsado(ctx, upd.CodigoBarras, id); err != nil {
+		return nil, err
+	}
+
+	e := ejemplarDe(id, libroID, upd)
+	repo.ejemplares[id] = e
+	return &e, nil
+}
+
+func (repo *MemoryEjemplaresRepo) Delete(ctx context.Context, libroID, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return err
+	}
+
+	if e, ok := repo.ejemplares[id]; !ok || e.LibroID != libroID {
+		return ErrEjemplarNotFound
+	}
+
+	if repo.prestado != nil && repo.prestado(id) {
+		return ErrEjemplarPrestado
+	}
+
+	if repo.apartado != nil {
+		apartado, err := repo.apartado(ctx, id)
+		if err != nil {
+			return err
+		}
+		if apartado {
+			return ErrEjemplarReservado
+		}
+	}
+
+	delete(repo.ejemplares, id)
+	return nil
+}
+
+func (repo *MemoryEjemplaresRepo) Disponibilidad(ctx context.Context, libroID int) (models.Disponibilidad, error) {
+	if err := ctx.Err(); err != nil {
+		return models.Disponibilidad{}, err
+	}
+
+	if _, err := repo.libros.GetByID(ctx, libroID); err != nil {
+		return models.Disponibilidad{}, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	var d models.Disponibilidad
+	for _, e := range repo.ejemplares {
+		if e.LibroID != libroID {
+			continue
+		}
+		d.Total++
+
+		if repo.prestado != nil && repo.prestado(e.ID) {
+			d.Prestados++
+			continue
+		}
+
+		if repo.apartado != nil {
+			apartado, err := repo.apartado(ctx, e.ID)
+			if err != nil {
+				return models.Disponibilidad{}, err
+			}
+			if apartado {
+				d.Apartados++
+			}
+		}
+	}
+	d.Disponibles = d.Total - d.Prestados - d.Apartados
+
+	if repo.enEspera != nil {
+		var err error
+		if d.EnEspera, err = repo.enEspera(ctx, libroID); err != nil {
+			return models.Disponibilidad{}, err
+		}
+	}
+
+	return d, nil
+}
+
+// vivo busca un ejemplar por id sin saber el libro. Uno cuyo libro se purgo cuenta como que no existe,
+// igual que despues del ON DELETE CASCADE; si el libro solo esta en la papelera el ejemplar sigue.
+// Hay que tener el lock tomado.
+func (repo *MemoryEjemplaresRepo) vivo(ctx context.Context, id int) (models.Ejemplar, bool, error) {
+	e, ok := repo.ejemplares[id]
+	if !ok {
+		return e, false, nil
+	}
+
+	_, err := repo.libros.GetByIDConBorrados(ctx, e.LibroID)
+	if errors.Is(err, ErrNotFound) {
+		return e, false, nil
+	}
+	if err != nil {
+		return e, false, err
+	}
+
+	return e, true, nil
+}
+
+// codigoUsado es la UNIQUE de codigo_barras. Los ejemplares de libros purgados no cuentan: en postgres
+// se fueron con el ON DELETE CASCADE. Los de la papelera si. Hay que tener el lock tomado.
+func (repo *MemoryEjemplaresRepo) codigoUsado(ctx context.Context, codigo string, id int) error {
+	for _, e := range repo.ejemplares {
+		if e.CodigoBarras != codigo || e.ID == id {
+			continue
+		}
+
+		_, err := repo.libros.GetByIDConBorrados(ctx, e.LibroID)
+		if errors.Is(err, ErrNotFound) {
+			delete(repo.ejemplares, e.ID)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		return ErrCodigoBarrasDuplicado
+	}
+
+	return nil
+}
+
+func ejemplarDe(id, libroID int, in models.EjemplarInput) models.Ejemplar {
+	return models.Ejemplar{
+		ID:               id,
+		LibroID:          libroID,
+		CodigoBarras:     in.CodigoBarras,
+		Ubicacion:        in.Ubicacion,
+		Estado:           in.Estado,
+		FechaAdquisicion: in.FechaAdquisicion,
+	}
+}
@@ -3,13 +3,106 @@ package repository
 import (
 	"api-libros/models"
 	"context"
+	"errors"
+	"fmt"
+	"time"
 )
 
+var ErrISBNDuplicado = errors.New("ya existe un libro con ese isbn")
+
+// ISBNDuplicadoError dice con que libro choca el ISBN, para que el cliente pueda ir a buscarlo.
+// errors.Is(err, ErrISBNDuplicado) da true.
+type ISBNDuplicadoError struct {
+	ISBN string
+	ID   int // el libro que ya tiene ese ISBN
+}
+
+func (e *ISBNDuplicadoError) Error() string {
+	return fmt.Sprintf("el isbn %s ya lo tiene el libro %d", e.ISBN, e.ID)
+}
+
+func (e *ISBNDuplicadoError) Unwrap() error { return ErrISBNDuplicado }
+
+// VersionConflictError es el libro tal como esta ahora, cuando no coincide con la version que se pidio.
+// errors.Is(err, ErrVersionConflict) da true.
+type VersionConflictError struct {
+	Actual models.Libro
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("el libro %d va por la version %d", e.Actual.ID, e.Actual.Version)
+}
+
+func (e *VersionConflictError) Unwrap() error { return ErrVersionConflict }
+
+// ResultadoBatch es como termino una operacion de Batch: el libro como quedo (en borrar, ya en la
+// papelera) o el mismo error que hubiera devuelto Create, UpdateIf, PatchIf o DeleteIf.
+type ResultadoBatch struct {
+	Libro *models.Libro
+	Err   error
+}
+
+// BatchError es la operacion que hizo fallar un Batch atomico; no se aplico ninguna.
+// errors.Is y errors.As llegan al error de la operacion.
+type BatchError struct {
+	Indice int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("operacion %d: %v", e.Indice, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// Los libros borrados van a una papelera: para GetByID, GetByISBN, Update, Patch y Delete no existen
+// (ErrNotFound), y GetAll, Count y Suggest no los muestran salvo que filter.Papelera diga otra cosa.
+// Se pueden restaurar hasta que Purgar los borra de verdad.
 type LibrosRepository interface {
 	GetAll(ctx context.Context, filter models.LibroFilter) ([]models.Libro, error)
+	// Count cuenta los libros que cumplen los filtros de filter. Ignora Limit, Offset, Cursor y Sort.
+	Count(ctx context.Context, filter models.LibroFilter) (int, error)
+	// Suggest devuelve titulos y autores parecidos a f.Q, los mas parecidos primero, para autocompletar.
+	Suggest(ctx context.Context, f models.SuggestFilter) ([]models.Sugerencia, error)
 	GetByID(ctx context.Context, id int) (*models.Libro, error)
+	// GetByIDConBorrados es GetByID que tambien encuentra los libros de la papelera (con Borrado)
+	GetByIDConBorrados(ctx context.Context, id int) (*models.Libro, error)
+	// GetByISBN busca por ISBN-13 normalizado (ErrNotFound si no hay ninguno)
+	GetByISBN(ctx context.Context, isbn string) (*models.Libro, error)
+	// Create, Update y Patch devuelven un *ISBNDuplicadoError si el ISBN ya lo tiene otro libro
 	Create(ctx context.Context, in models.LibroInput) (*models.Libro, error)
 	Update(ctx context.Context, id int, upd models.LibroInput) (*models.Libro, error)
 	Patch(ctx context.Context, id int, p models.LibroPatch) (*models.Libro, error)
 	Delete(ctx context.Context, id int) error
+
+	// UpdateIf, PatchIf y DeleteIf son Update, Patch y Delete solo si el libro sigue en version.
+	// Si cambio devuelven un *VersionConflictError con el libro actual (ErrNotFound si ya no existe).
+	UpdateIf(ctx context.Context, id, version int, upd models.LibroInput) (*models.Libro, error)
+	PatchIf(ctx context.Context, id, version int, p models.LibroPatch) (*models.Libro, error)
+	DeleteIf(ctx context.Context, id, version int) error
+
+	// Restaurar saca un libro de la papelera. ErrNoBorrado si no estaba en la papelera, y un
+	// *ISBNDuplicadoError si mientras tanto se cargo otro libro con su ISBN.
+	Restaurar(ctx context.Context, id int) (*models.Libro, error)
+	// Purgar borra de verdad los libros que estan en la papelera desde antes de antes, con sus
+	// ejemplares, prestamos y reservas. Devuelve cuantos borro.
+	Purgar(ctx context.Context, antes time.Time) (int, error)
+
+	// Cada escritura de arriba que cambia el libro deja una models.Revision en la misma transaccion,
+	// con el actor y el request id del context.
+
+	// Historial devuelve las revisiones del libro, la mas nueva primero. Tambien las de los libros que
+	// estan en la papelera o ya se purgaron. ErrNotFound si el libro no existe ni tiene historial.
+	Historial(ctx context.Context, id int, f models.RevisionFilter) ([]models.Revision, error)
+	// EnFecha devuelve el libro como estaba en t (con Borrado si estaba en la papelera). ErrNotFound si
+	// no hay ninguna revision hasta t: el libro no existia o es de antes del historial.
+	EnFecha(ctx context.Context, id int, t time.Time) (*models.Libro, error)
+	// Revertir vuelve titulo, autor, ano e isbn a como quedaron en la revision rev, como un UpdateIf
+	// (version 0 es cualquiera). ErrRevisionNotFound si el libro no tiene esa revision.
+	Revertir(ctx context.Context, id, rev, version int) (*models.Libro, error)
+
+	// Batch aplica ops (ya validadas) en orden, cada una como Create, UpdateIf, PatchIf o DeleteIf, con
+	// su revision. Con atomic van todas en una transaccion: si una falla no queda ninguna y devuelve un
+	// *BatchError. Sin atomic cada una se aplica o falla por su cuenta y su error va en el resultado.
+	Batch(ctx context.Context, ops []models.LibroOp, atomic bool) ([]ResultadoBatch, error)
 }
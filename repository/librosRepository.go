@@ -2,16 +2,26 @@ package repository
 
 import (
 	"api-libros/models"
+	"api-libros/requestid"
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var ErrNotFound = errors.New("libro not found")
+var (
+	ErrNotFound         = errors.New("libro not found")
+	ErrVersionConflict  = errors.New("el libro cambio desde la version que se leyo")
+	ErrNoBorrado        = errors.New("el libro no esta en la papelera")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
 
 type PostgresLibrosRepo struct {
 	DB *pgxpool.Pool
@@ -23,54 +33,203 @@ func NewPostgresLibrosRepo(db *pgxpool.Pool) *PostgresLibrosRepo {
 	}
 }
 
-func (repo *PostgresLibrosRepo) GetAll(ctx context.Context, f models.LibroFilter) ([]models.Libro, error) {
+// libroCols son las columnas de un models.Libro, en el orden de libroDest.
+// isbn es NULL en los libros que no lo tienen y se lee como "".
+const libroCols = `id, titulo, autor, ano, coalesce(isbn, ''), version, updated_at, deleted_at`
+
+func libroDest(l *models.Libro) []any {
+	return []any{&l.ID, &l.Titulo, &l.Autor, &l.Ano, &l.ISBN, &l.Version, &l.Modificado, &l.Borrado}
+}
+
+// scanLibro es libroDest para pgx.CollectRows
+func scanLibro(row pgx.CollectableRow) (models.Libro, error) {
+	var l models.Libro
+	err := row.Scan(libroDest(&l)...)
+	return l, err
+}
 
-	query := `SELECT id, titulo, autor, ano FROM libros WHERE 1=1`
+// libroWhere arma el WHERE con los filtros de f (papelera, autor, from, to). Lo comparten GetAll y Count
+// asi el total y los items no pueden contar cosas distintas.
+func libroWhere(f models.LibroFilter) (string, []any) {
+	var where string
+	switch f.Papelera {
+	case models.ConBorrados:
+		where = ` WHERE 1=1`
+	case models.SoloBorrados:
+		where = ` WHERE deleted_at IS NOT NULL`
+	default:
+		where = ` WHERE deleted_at IS NULL`
+	}
 	args := []any{}
 	i := 1
 
+	if f.Q != nil && f.Fuzzy {
+		where += " AND " + trgmWhere(i)
+		args = append(args, *f.Q)
+		i++
+	} else if f.Q != nil {
+		where += " AND busqueda @@ " + tsQuery(i)
+		args = append(args, *f.Q)
+		i++
+	}
+
 	if f.Autor != nil {
-		query += fmt.Sprintf(" AND autor ILIKE $%d", i)
+		where += fmt.Sprintf(" AND autor ILIKE $%d", i)
 		args = append(args, "%"+*f.Autor+"%")
 		i++
 	}
 
 	if f.From != nil {
-		query += fmt.Sprintf(" AND ano >= $%d", i)
+		where += fmt.Sprintf(" AND ano >= $%d", i)
 		args = append(args, *f.From)
 		i++
 	}
 
 	if f.To != nil {
-		query += fmt.Sprintf(" AND ano <= $%id", i)
+		where += fmt.Sprintf(" AND ano <= $%d", i)
 		args = append(args, *f.To)
+	}
+
+	return where, args
+}
+
+func (repo *PostgresLibrosRepo) GetAll(ctx context.Context, f models.LibroFilter) ([]models.Libro, error) {
+
+	where, args := libroWhere(f)
+	i := len(args) + 1
+
+	cols := libroCols
+	qArg := i
+	resaltar := f.Q != nil && !f.Fuzzy // ts_headline marca palabras completas, en fuzzy no tiene sentido
+	if f.Q != nil {
+		// el texto de la busqueda va otra vez como parametro aparte para los headlines y el ranking
+		args = append(args, *f.Q)
 		i++
 	}
+	if resaltar {
+		cols += headlines(qArg)
+	}
 
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", i, i+1)
-	args = append(args, f.Limit, f.Offset)
+	query := `SELECT ` + cols + ` FROM libros` + where
+
+	// keyset: en vez de saltear filas con OFFSET arranco desde los valores del ultimo libro visto.
+	// Usa los indices de orden y no se corre si se insertan o borran filas en paginas anteriores.
+	sort := models.WithTiebreaker(f.Sort)
+	backward := f.Cursor != nil && f.Cursor.Backward
 
-	rows, err := repo.DB.Query(ctx, query, args...)
+	if f.Cursor != nil {
+		after, err := f.Cursor.Libro(f.Sort)
+		if err != nil {
+			return nil, err
+		}
 
+		cond, condArgs := keysetCondition(sort, after, backward, i)
+		query += " AND " + cond
+		args = append(args, condArgs...)
+		i += len(condArgs)
+	}
+
+	// sin ORDER BY postgres devuelve las filas en cualquier orden y el paginado no es estable
+	order, err := orderBy(sort, backward)
 	if err != nil {
 		return nil, err
 	}
 
-	defer rows.Close()
+	if f.Q != nil && len(f.Sort) == 0 {
+		// relevancia primero; con cursor no se llega aca porque Validate exige un sort explicito
+		rank := fmt.Sprintf("ts_rank(busqueda, %s)", tsQuery(qArg))
+		if f.Fuzzy {
+			rank = trgmScore(qArg)
+		}
+		order = rank + " DESC, " + order
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", order, i, i+1)
+	args = append(args, f.Limit, f.Offset)
 
-	var result []models.Libro
+	result := []models.Libro{} //si no hay filas quiero [] y no null en el JSON
 
-	for rows.Next() {
-		var l models.Libro
-		if err := rows.Scan(&l.ID, &l.Titulo, &l.Autor, &l.Ano); err != nil {
-			return nil, err
+	err = repo.conFiltro(ctx, f, func(q querier) error {
+		rows, err := q.Query(ctx, query, args...)
+		if err != nil {
+			return err
 		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var l models.Libro
+			dest := libroDest(&l)
+
+			if resaltar {
+				l.Resaltado = &models.Resaltado{}
+				dest = append(dest, &l.Resaltado.Titulo, &l.Resaltado.Autor)
+			}
+
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+
+			result = append(result, l)
+		}
+
+		return rows.Err()
+	})
 
-		result = append(result, l)
+	if err != nil {
+		return nil, dbError(ctx, "GetAll", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, err
+	if backward {
+		slices.Reverse(result)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresLibrosRepo) Count(ctx context.Context, f models.LibroFilter) (int, error) {
+	where, args := libroWhere(f)
+
+	var total int
+	err := repo.conFiltro(ctx, f, func(q querier) error {
+		return q.QueryRow(ctx, `SELECT count(*) FROM libros`+where, args...).Scan(&total)
+	})
+	if err != nil {
+		return 0, dbError(ctx, "Count", err)
+	}
+
+	return total, nil
+}
+
+// Suggest busca titulos y autores parecidos a lo que se viene escribiendo. Con pocas letras
+// word_similarity ya da alto ("dos" contra "Dostoievski"), asi que sirve para autocompletar.
+//
+// Se llama en cada tecla, asi que va directo al pool en un solo viaje. <% usa el indice con el
+// pg_trgm.word_similarity_threshold de la conexion (config.PoolConfig lo pone en FuzzyThreshold) y
+// el word_similarity >= $3 aplica el umbral del filtro; el de la conexion no puede ser mayor.
+func (repo *PostgresLibrosRepo) Suggest(ctx context.Context, f models.SuggestFilter) ([]models.Sugerencia, error) {
+	rows, err := repo.DB.Query(ctx, `
+		SELECT campo, texto FROM (
+			SELECT 'titulo' AS campo, titulo AS texto, word_similarity(f_unaccent($1), f_unaccent(titulo)) AS sml
+			  FROM libros WHERE deleted_at IS NULL AND f_unaccent($1) <% f_unaccent(titulo)
+			UNION ALL
+			SELECT 'autor', autor, word_similarity(f_unaccent($1), f_unaccent(autor))
+			  FROM libros WHERE deleted_at IS NULL AND f_unaccent($1) <% f_unaccent(autor)
+		) s
+		WHERE sml >= $3
+		GROUP BY campo, texto
+		ORDER BY max(sml) DESC, length(texto), texto COLLATE espanol, campo
+		LIMIT $2`, f.Q, f.Limit, f.Threshold)
+	if err != nil {
+		return nil, dbError(ctx, "Suggest", err)
+	}
+
+	result, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Sugerencia, error) {
+		var s models.Sugerencia
+		err := row.Scan(&s.Campo, &s.Texto)
+		return s, err
+	})
+	if err != nil {
+		return nil, dbError(ctx, "Suggest", err)
 	}
 
 	return result, nil
@@ -80,16 +239,50 @@ func (repo *PostgresLibrosRepo) GetByID(ctx context.Context, id int) (*models.Li
 	var result models.Libro
 
 	err := repo.DB.QueryRow(ctx,
-		"SELECT id, titulo, autor, ano FROM libros WHERE id = $1",
+		"SELECT "+libroCols+" FROM libros WHERE id = $1 AND deleted_at IS NULL",
 		id).
-		Scan(&result.ID, &result.Titulo, &result.Autor, &result.Ano)
+		Scan(libroDest(&result)...)
 
 	if err == pgx.ErrNoRows {
 		return nil, ErrNotFound
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, dbError(ctx, "GetByID", err)
+	}
+
+	return &result, nil
+}
+
+func (repo *PostgresLibrosRepo) GetByIDConBorrados(ctx context.Context, id int) (*models.Libro, error) {
+	var result models.Libro
+
+	err := repo.DB.QueryRow(ctx, "SELECT "+libroCols+" FROM libros WHERE id = $1", id).
+		Scan(libroDest(&result)...)
+
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, dbError(ctx, "GetByIDConBorrados", err)
+	}
+
+	return &result, nil
+}
+
+func (repo *PostgresLibrosRepo) GetByISBN(ctx context.Context, isbn string) (*models.Libro, error) {
+	var result models.Libro
+
+	err := repo.DB.QueryRow(ctx, "SELECT "+libroCols+" FROM libros WHERE isbn = $1 AND deleted_at IS NULL", isbn).
+		Scan(libroDest(&result)...)
+
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, dbError(ctx, "GetByISBN", err)
 	}
 
 	return &result, nil
@@ -98,46 +291,73 @@ func (repo *PostgresLibrosRepo) GetByID(ctx context.Context, id int) (*models.Li
 func (repo *PostgresLibrosRepo) Create(ctx context.Context, in models.LibroInput) (*models.Libro, error) {
 	var salida models.Libro
 
-	err := repo.DB.QueryRow(ctx,
-		"INSERT INTO libros (titulo, autor, ano) VALUES ($1, $2, $3) RETURNING id, titulo, autor, ano",
-		in.Titulo, in.Autor, in.Ano).
-		Scan(&salida.ID,
-			&salida.Titulo,
-			&salida.Autor,
-			&salida.Ano) //scan no deja de ser una funcion, si no paso puntero, recibe una copia de nuevo.ID
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			"INSERT INTO libros (titulo, autor, ano, isbn) VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING "+libroCols,
+			in.Titulo, in.Autor, in.Ano, in.ISBN).
+			Scan(libroDest(&salida)...) //scan no deja de ser una funcion, si no paso puntero, recibe una copia de nuevo.ID
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, models.RevisionCrear, nil, &salida)
+	})
+
+	if isISBNDuplicado(err) {
+		return nil, repo.isbnDuplicado(ctx, in.ISBN)
+	}
 
 	if err != nil {
-		return nil, err
+		return nil, dbError(ctx, "Create", err)
 	}
 
 	return &salida, nil
 }
 
 func (repo *PostgresLibrosRepo) Update(ctx context.Context, id int, upd models.LibroInput) (*models.Libro, error) {
+	return repo.update(ctx, id, 0, upd, models.RevisionReemplazar)
+}
+
+func (repo *PostgresLibrosRepo) UpdateIf(ctx context.Context, id, version int, upd models.LibroInput) (*models.Libro, error) {
+	return repo.update(ctx, id, version, upd, models.RevisionReemplazar)
+}
+
+// update, patch y delete con version 0 no miran la version (los libros arrancan en 1).
+// op es la operacion que queda en el historial (Revertir tambien pasa por aca).
+func (repo *PostgresLibrosRepo) update(ctx context.Context, id, version int, upd models.LibroInput, op string) (*models.Libro, error) {
 	var salida models.Libro
 
-	//DB.EXEC para INSERT/UPDATE/DELETE
-	err := repo.DB.QueryRow(ctx,
-		`UPDATE libros
-			SET titulo = $1, autor = $2, ano = $3 WHERE id = $4
-			RETURNING id, titulo, autor, ano`,
-		upd.Titulo,
-		upd.Autor,
-		upd.Ano,
-		id,
-	).Scan(
-		&salida.ID,
-		&salida.Titulo,
-		&salida.Autor,
-		&salida.Ano,
-	)
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		antes, err := libroParaEscribir(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
 
-	if err == pgx.ErrNoRows {
-		return nil, ErrNotFound
+		//DB.EXEC para INSERT/UPDATE/DELETE
+		err = tx.QueryRow(ctx,
+			`UPDATE libros
+				SET titulo = $1, autor = $2, ano = $3, isbn = NULLIF($4, ''), version = version + 1, updated_at = now()
+				WHERE id = $5
+				RETURNING `+libroCols,
+			upd.Titulo,
+			upd.Autor,
+			upd.Ano,
+			upd.ISBN,
+			id,
+		).Scan(libroDest(&salida)...)
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, op, antes, &salida)
+	})
+
+	if isISBNDuplicado(err) {
+		return nil, repo.isbnDuplicado(ctx, upd.ISBN)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, escrituraError(ctx, "Update", err)
 	}
 
 	return &salida, nil
@@ -145,6 +365,14 @@ func (repo *PostgresLibrosRepo) Update(ctx context.Context, id int, upd models.L
 }
 
 func (repo *PostgresLibrosRepo) Patch(ctx context.Context, id int, patch models.LibroPatch) (*models.Libro, error) {
+	return repo.patch(ctx, id, 0, patch)
+}
+
+func (repo *PostgresLibrosRepo) PatchIf(ctx context.Context, id, version int, patch models.LibroPatch) (*models.Libro, error) {
+	return repo.patch(ctx, id, version, patch)
+}
+
+func (repo *PostgresLibrosRepo) patch(ctx context.Context, id, version int, patch models.LibroPatch) (*models.Libro, error) {
 	var salida models.Libro
 
 	// armo la query dinamicamente
@@ -170,13 +398,23 @@ func (repo *PostgresLibrosRepo) Patch(ctx context.Context, id int, patch models.
 		argsPos++
 	}
 
-	if len(setClauses) == 0 { //si no recibi ningun valor
-		return repo.GetByID(ctx, id)
+	if patch.ISBN != nil {
+		setClauses = append(setClauses, fmt.Sprintf("isbn = NULLIF($%d, '')", argsPos)) // "" lo borra
+		args = append(args, *patch.ISBN)
+		argsPos++
+	}
+
+	if len(setClauses) == 0 { //si no recibi ningun valor no cambia nada, ni la version (ni queda revision)
+		l, err := repo.GetByID(ctx, id)
+		if err == nil && version != 0 && l.Version != version {
+			return nil, &VersionConflictError{Actual: *l}
+		}
+		return l, err
 	}
 
 	//aca formo la query
 	query := fmt.Sprintf(
-		"UPDATE libros SET %s WHERE id = $%d RETURNING id, titulo, autor, ano",
+		"UPDATE libros SET %s, version = version + 1, updated_at = now() WHERE id = $%d RETURNING "+libroCols,
 		strings.Join(setClauses, ", "),
 		argsPos,
 	)
@@ -184,32 +422,224 @@ func (repo *PostgresLibrosRepo) Patch(ctx context.Context, id int, patch models.
 	//cuando ya hice todos los chequeos agrego el id como ultimo arg
 	args = append(args, id)
 
-	err := repo.DB.QueryRow(ctx, query, args...). //args... expande el slice como parámetros individuales
-							Scan(&salida.ID,
-			&salida.Titulo,
-			&salida.Autor,
-			&salida.Ano)
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		antes, err := libroParaEscribir(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
 
-	if err == pgx.ErrNoRows {
-		return nil, ErrNotFound
+		err = tx.QueryRow(ctx, query, args...). //args... expande el slice como parámetros individuales
+							Scan(libroDest(&salida)...)
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, models.RevisionModificar, antes, &salida)
+	})
+
+	if isISBNDuplicado(err) {
+		return nil, repo.isbnDuplicado(ctx, *patch.ISBN)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, escrituraError(ctx, "Patch", err)
 	}
 
 	return &salida, nil
 }
 
 func (repo *PostgresLibrosRepo) Delete(ctx context.Context, id int) error {
-	result, err := repo.DB.Exec(ctx, "DELETE FROM libros WHERE id = $1", id)
+	return repo.delete(ctx, id, 0)
+}
+
+func (repo *PostgresLibrosRepo) DeleteIf(ctx context.Context, id, version int) error {
+	return repo.delete(ctx, id, version)
+}
+
+// delete no borra la fila, la manda a la papelera. Cuenta como un cambio: sube la version
+func (repo *PostgresLibrosRepo) delete(ctx context.Context, id, version int) error {
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		antes, err := libroParaEscribir(ctx, tx, id, version)
+		if err != nil {
+			return err
+		}
+
+		if err := libroLibre(ctx, tx, id); err != nil {
+			return err
+		}
+
+		var despues models.Libro
+		err = tx.QueryRow(ctx, `
+			UPDATE libros SET deleted_at = now(), version = version + 1, updated_at = now()
+			 WHERE id = $1
+			RETURNING `+libroCols, id).
+			Scan(libroDest(&despues)...)
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, models.RevisionBorrar, antes, &despues)
+	})
 
 	if err != nil {
+		return escrituraError(ctx, "Delete", err)
+	}
+
+	return nil
+}
+
+func (repo *PostgresLibrosRepo) Restaurar(ctx context.Context, id int) (*models.Libro, error) {
+	var antes, salida models.Libro
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, "SELECT "+libroCols+" FROM libros WHERE id = $1 FOR UPDATE", id).
+			Scan(libroDest(&antes)...)
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if antes.Borrado == nil {
+			return ErrNoBorrado
+		}
+
+		err = tx.QueryRow(ctx, `
+			UPDATE libros SET deleted_at = NULL, version = version + 1, updated_at = now()
+			 WHERE id = $1
+			RETURNING `+libroCols, id).
+			Scan(libroDest(&salida)...)
+		if err != nil {
+			return err
+		}
+
+		return registrarRevision(ctx, tx, models.RevisionRestaurar, &antes, &salida)
+	})
+
+	if isISBNDuplicado(err) {
+		// el indice unico solo mira los vivos: otro libro tomo el ISBN mientras este estaba borrado
+		return nil, repo.isbnDuplicado(ctx, antes.ISBN)
+	}
+
+	if err != nil {
+		return nil, escrituraError(ctx, "Restaurar", err)
+	}
+
+	return &salida, nil
+}
+
+func (repo *PostgresLibrosRepo) Purgar(ctx context.Context, antes time.Time) (int, error) {
+	// ejemplares, prestamos, reservas y libro_autores se van con el ON DELETE CASCADE. Las revisiones
+	// no tienen FK y quedan. Los que tienen un ejemplar prestado o apartado (de antes de que delete lo
+	// chequeara) no se tocan: el cascade se llevaria prestamos que todavia no se devolvieron
+	result, err := repo.DB.Exec(ctx, `
+		DELETE FROM libros
+		 WHERE deleted_at < $1 AND NOT `+libroPrestado("libros.id")+` AND NOT `+libroReservado("libros.id"), antes)
+	if err != nil {
+		return 0, dbError(ctx, "Purgar", err)
+	}
+
+	return int(result.RowsAffected()), nil
+}
+
+// libroPrestado y libroReservado son las condiciones "algun ejemplar del libro param esta prestado" y
+// "... esta apartado para una reserva": un libro asi no va a la papelera ni se purga
+func libroPrestado(param string) string {
+	return "EXISTS (SELECT 1 FROM prestamos p JOIN ejemplares e ON e.id = p.ejemplar_id WHERE e.libro_id = " + param + " AND p.devuelto IS NULL)"
+}
+
+func libroReservado(param string) string {
+	return "EXISTS (SELECT 1 FROM reservas r WHERE r.libro_id = " + param + " AND r.estado = 'disponible')"
+}
+
+// libroLibre devuelve ErrEjemplarPrestado o ErrEjemplarReservado si el libro tiene un ejemplar prestado
+// o apartado, como Delete de ejemplares. Bloquea los ejemplares: un Prestar que estaba esperando
+// termina antes y el chequeo ya lo ve.
+func libroLibre(ctx context.Context, tx pgx.Tx, id int) error {
+	if err := bloquearEjemplares(ctx, tx, id); err != nil {
 		return err
 	}
 
-	if result.RowsAffected() == 0 { //si 0 → 404
-		return ErrNotFound
+	var prestado, reservado bool
+	err := tx.QueryRow(ctx, "SELECT "+libroPrestado("$1")+", "+libroReservado("$1"), id).Scan(&prestado, &reservado)
+	switch {
+	case err != nil:
+		return err
+	case prestado:
+		return ErrEjemplarPrestado
+	case reservado:
+		return ErrEjemplarReservado
 	}
 	return nil
 }
+
+// libroParaEscribir bloquea el libro hasta el final de tx y lo devuelve como esta, para la revision.
+// ErrNotFound si no existe o esta en la papelera, *VersionConflictError si no esta en version (0 es
+// cualquiera).
+func libroParaEscribir(ctx context.Context, tx pgx.Tx, id, version int) (*models.Libro, error) {
+	var l models.Libro
+
+	err := tx.QueryRow(ctx, "SELECT "+libroCols+" FROM libros WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).
+		Scan(libroDest(&l)...)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && l.Version != version {
+		return nil, &VersionConflictError{Actual: l}
+	}
+
+	return &l, nil
+}
+
+// escrituraError deja pasar los errores de negocio y loguea el resto
+func escrituraError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrNoBorrado) ||
+		errors.Is(err, ErrEjemplarPrestado) || errors.Is(err, ErrEjemplarReservado) {
+		return err
+	}
+	return dbError(ctx, op, err)
+}
+
+// isISBNDuplicado: el error es del indice unico de isbn (y no de otra restriccion)
+func isISBNDuplicado(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "libros_isbn_key"
+}
+
+// isbnDuplicado arma el error con el id del libro que ya tiene el ISBN. Si lo borraron justo
+// despues del INSERT que fallo, el error va sin id.
+func (repo *PostgresLibrosRepo) isbnDuplicado(ctx context.Context, isbn string) error {
+	return isbnDuplicadoEn(ctx, repo.DB, isbn)
+}
+
+// isbnDuplicadoEn es isbnDuplicado dentro de una transaccion (Batch ve los libros que cargo ella misma)
+func isbnDuplicadoEn(ctx context.Context, q querier, isbn string) error {
+	dup := &ISBNDuplicadoError{ISBN: isbn}
+
+	err := q.QueryRow(ctx, "SELECT id FROM libros WHERE isbn = $1 AND deleted_at IS NULL", isbn).Scan(&dup.ID)
+	if err != nil && err != pgx.ErrNoRows {
+		return dbError(ctx, "isbnDuplicado", err)
+	}
+
+	return dup
+}
+
+// dbError loguea un error inesperado de la base con el request id del context y lo devuelve tal cual,
+// asi en los logs se puede seguir un 500 desde el access log hasta la query que fallo.
+func dbError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "libros", op, err)
+}
+
+func repoError(ctx context.Context, repo, op string, err error) error {
+	requestid.Logger(ctx).ErrorContext(ctx, "error en postgres",
+		slog.String("repo", repo),
+		slog.String("op", op),
+		slog.Any("err", err),
+	)
+	return err
+}
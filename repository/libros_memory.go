@@ -0,0 +1,804 @@
+package repository
+
+import (
+	"api-libros/actor"
+	"api-libros/models"
+	"api-libros/requestid"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MemoryLibrosRepo guarda los libros en un map protegido por un mutex.
+// Sirve para levantar la API y correr los tests sin Postgres, por eso
+// tiene que comportarse igual que PostgresLibrosRepo (mismos filtros, mismos errores).
+type MemoryLibrosRepo struct {
+	mu     sync.RWMutex
+	libros map[int]models.Libro
+	nextID int // como el SERIAL de postgres: nunca se reutiliza un id aunque se borre el libro
+
+	revisiones map[int][]models.Revision // por libro, de la mas vieja a la mas nueva; Purgar no las toca
+
+	// circulacion la ponen NewMemoryPrestamosRepo y NewMemoryReservasRepo: corre fn con sus locks
+	// tomados (antes que el nuestro, en el orden de MemoryPrestamosRepo) y ocupado dice si el libro
+	// tiene un ejemplar prestado o apartado. ocupado no puede llamar al repo, ya tiene nuestro lock.
+	circulacion func(fn func(ocupado func(libroID int) error) error) error
+
+	// autores la pone NewMemoryAutoresRepo: corre fn con su lock tomado (antes que el nuestro) y vincular
+	// es el trigger libro_autores_desde_texto, que rearma los autores del libro desde libros.autor.
+	autores func(fn func(vincular func(libroID int, autor string)) error) error
+}
+
+func NewMemoryLibrosRepo() *MemoryLibrosRepo {
+	return &MemoryLibrosRepo{
+		libros:     map[int]models.Libro{},
+		nextID:     1,
+		revisiones: map[int][]models.Revision{},
+	}
+}
+
+// libroMatcher es el equivalente de libroWhere: GetAll y Count filtran con la misma funcion.
+func libroMatcher(f models.LibroFilter) func(models.Libro) bool {
+	var autor *regexp.Regexp
+	if f.Autor != nil {
+		autor = ilikeContains(*f.Autor)
+	}
+
+	var q *busqueda
+	if f.Q != nil && !f.Fuzzy {
+		b := newBusqueda(*f.Q)
+		q = &b
+	}
+
+	return func(l models.Libro) bool {
+		switch {
+		case f.Papelera == models.SinBorrados && l.Borrado != nil:
+			return false
+		case f.Papelera == models.SoloBorrados && l.Borrado == nil:
+			return false
+		}
+		if q != nil && !q.match(l) {
+			return false
+		}
+		if f.Q != nil && f.Fuzzy && fuzzyScore(*f.Q, l) < f.FuzzyThreshold {
+			return false
+		}
+		if autor != nil && !autor.MatchString(l.Autor) {
+			return false
+		}
+		if f.From != nil && l.Ano < *f.From {
+			return false
+		}
+		if f.To != nil && l.Ano > *f.To {
+			return false
+		}
+		return true
+	}
+}
+
+func (repo *MemoryLibrosRepo) GetAll(ctx context.Context, f models.LibroFilter) ([]models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	match := libroMatcher(f)
+	sort := models.WithTiebreaker(f.Sort)
+	backward := f.Cursor != nil && f.Cursor.Backward
+	col := newCollator()
+
+	var after models.Libro
+	if f.Cursor != nil {
+		var err error
+		if after, err = f.Cursor.Libro(f.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Libro{}
+
+	for _, l := range repo.libros {
+		if !match(l) {
+			continue
+		}
+		if f.Cursor != nil && !backward && compareLibros(col, sort, l, after) <= 0 {
+			continue
+		}
+		if f.Cursor != nil && backward && compareLibros(col, sort, l, after) >= 0 {
+			continue
+		}
+		result = append(result, l)
+	}
+
+	// el map no tiene orden, ordeno igual que el ORDER BY de postgres para que el paginado sea estable.
+	// Hacia atras ordeno al reves para quedarme con los mas cercanos al cursor, igual que postgres
+	slices.SortFunc(result, func(a, b models.Libro) int {
+		if backward {
+			return compareLibros(col, sort, b, a)
+		}
+		return compareLibros(col, sort, a, b)
+	})
+
+	// con busqueda y sin sort explicito, primero los mas relevantes (el desempate sigue siendo por id)
+	var q busqueda
+	resaltar := f.Q != nil && !f.Fuzzy
+	if f.Q != nil {
+		q = newBusqueda(*f.Q)
+
+		rank := q.rank
+		if f.Fuzzy {
+			rank = func(l models.Libro) float64 { return fuzzyScore(*f.Q, l) }
+		}
+
+		if len(f.Sort) == 0 {
+			slices.SortStableFunc(result, func(a, b models.Libro) int {
+				return cmp.Compare(rank(b), rank(a))
+			})
+		}
+	}
+
+	result, err := paginar(result, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if backward {
+		slices.Reverse(result)
+	}
+
+	if resaltar {
+		for i, l := range result {
+			result[i].Resaltado = &models.Resaltado{Titulo: q.resaltar(l.Titulo), Autor: q.resaltar(l.Autor)}
+		}
+	}
+
+	return result, nil
+}
+
+func (repo *MemoryLibrosRepo) Count(ctx context.Context, f models.LibroFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	match := libroMatcher(f)
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	total := 0
+	for _, l := range repo.libros {
+		if match(l) {
+			total++
+		}
+	}
+
+	return total, nil
+}
+
+func (repo *MemoryLibrosRepo) Suggest(ctx context.Context, f models.SuggestFilter) ([]models.Sugerencia, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type candidato struct {
+		models.Sugerencia
+		sml float64
+	}
+
+	repo.mu.RLock()
+
+	// GROUP BY campo, texto: si dos libros tienen el mismo autor aparece una sola vez
+	vistos := map[models.Sugerencia]float64{}
+	for _, l := range repo.libros {
+		if l.Borrado != nil {
+			continue
+		}
+		for _, s := range []models.Sugerencia{{Texto: l.Titulo, Campo: "titulo"}, {Texto: l.Autor, Campo: "autor"}} {
+			if sml := wordSimilarity(f.Q, s.Texto); sml >= f.Threshold {
+				vistos[s] = max(vistos[s], sml)
+			}
+		}
+	}
+
+	repo.mu.RUnlock()
+
+	candidatos := make([]candidato, 0, len(vistos))
+	for s, sml := range vistos {
+		candidatos = append(candidatos, candidato{s, sml})
+	}
+
+	// ORDER BY max(sml) DESC, length(texto), texto COLLATE espanol, campo
+	col := newCollator()
+	slices.SortFunc(candidatos, func(a, b candidato) int {
+		return cmp.Or(
+			cmp.Compare(b.sml, a.sml),
+			cmp.Compare(utf8.RuneCountInString(a.Texto), utf8.RuneCountInString(b.Texto)),
+			compareText(col, a.Texto, b.Texto),
+			cmp.Compare(a.Campo, b.Campo),
+		)
+	})
+
+	result := []models.Sugerencia{}
+	for _, c := range candidatos {
+		if len(result) == f.Limit {
+			break
+		}
+		result = append(result, c.Sugerencia)
+	}
+
+	return result, nil
+}
+
+func (repo *MemoryLibrosRepo) GetByID(ctx context.Context, id int) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	l, ok := repo.libros[id]
+	if !ok || l.Borrado != nil {
+		return nil, ErrNotFound
+	}
+
+	return &l, nil //l ya es una copia del valor del map, devolver su direccion no expone el map
+}
+
+func (repo *MemoryLibrosRepo) GetByIDConBorrados(ctx context.Context, id int) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	l, ok := repo.libros[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &l, nil
+}
+
+func (repo *MemoryLibrosRepo) GetByISBN(ctx context.Context, isbn string) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, l := range repo.libros {
+		if isbn != "" && l.ISBN == isbn && l.Borrado == nil {
+			return &l, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// isbnUsado es el indice unico libros_isbn_key: error si otro libro vivo (distinto de id) ya tiene isbn.
+// Hay que llamarlo con el lock tomado.
+func (repo *MemoryLibrosRepo) isbnUsado(isbn string, id int) error {
+	if isbn == "" {
+		return nil // como NULL en postgres: los libros sin ISBN no chocan entre si
+	}
+
+	for _, l := range repo.libros {
+		if l.ISBN == isbn && l.ID != id && l.Borrado == nil {
+			return &ISBNDuplicadoError{ISBN: isbn, ID: l.ID}
+		}
+	}
+
+	return nil
+}
+
+func (repo *MemoryLibrosRepo) Create(ctx context.Context, in models.LibroInput) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var l *models.Libro
+	err := repo.conAutores(func(vincular func(int, string)) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		var err error
+		l, err = repo.crear(ctx, in, vincular)
+		return err
+	})
+
+	return l, err
+}
+
+// crear es Create con el lock tomado
+func (repo *MemoryLibrosRepo) crear(ctx context.Context, in models.LibroInput, vincular func(int, string)) (*models.Libro, error) {
+	if err := repo.isbnUsado(in.ISBN, 0); err != nil {
+		return nil, err
+	}
+
+	l := models.Libro{
+		ID:     repo.nextID,
+		Titulo: in.Titulo,
+		Autor:  in.Autor,
+		Ano:    in.Ano,
+		ISBN:   in.ISBN,
+
+		Version:    1,
+		Modificado: ahora(),
+	}
+	repo.nextID++
+
+	repo.libros[l.ID] = l
+	repo.registrar(ctx, models.RevisionCrear, nil, l)
+	vincular(l.ID, l.Autor)
+	return &l, nil
+}
+
+func (repo *MemoryLibrosRepo) Update(ctx context.Context, id int, upd models.LibroInput) (*models.Libro, error) {
+	return repo.update(ctx, id, 0, upd, models.RevisionReemplazar)
+}
+
+func (repo *MemoryLibrosRepo) UpdateIf(ctx context.Context, id, version int, upd models.LibroInput) (*models.Libro, error) {
+	return repo.update(ctx, id, version, upd, models.RevisionReemplazar)
+}
+
+// update, patch y delete con version 0 no miran la version, como en PostgresLibrosRepo
+func (repo *MemoryLibrosRepo) update(ctx context.Context, id, version int, upd models.LibroInput, op string) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var l *models.Libro
+	err := repo.conAutores(func(vincular func(int, string)) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		var err error
+		l, err = repo.reemplazar(ctx, id, version, upd, op, vincular)
+		return err
+	})
+
+	return l, err
+}
+
+// reemplazar es update con el lock tomado, para que Revertir lea la revision y escriba sin soltarlo
+func (repo *MemoryLibrosRepo) reemplazar(ctx context.Context, id, version int, upd models.LibroInput, op string, vincular func(int, string)) (*models.Libro, error) {
+
+	actual, err := repo.enVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.isbnUsado(upd.ISBN, id); err != nil {
+		return nil, err
+	}
+
+	l := models.Libro{
+		ID:     id,
+		Titulo: upd.Titulo,
+		Autor:  upd.Autor,
+		Ano:    upd.Ano,
+		ISBN:   upd.ISBN,
+
+		Version:    actual.Version + 1,
+		Modificado: ahora(),
+	}
+
+	repo.libros[id] = l
+	repo.registrar(ctx, op, &actual, l)
+	vincular(id, l.Autor)
+	return &l, nil
+}
+
+func (repo *MemoryLibrosRepo) Patch(ctx context.Context, id int, patch models.LibroPatch) (*models.Libro, error) {
+	return repo.patch(ctx, id, 0, patch)
+}
+
+func (repo *MemoryLibrosRepo) PatchIf(ctx context.Context, id, version int, patch models.LibroPatch) (*models.Libro, error) {
+	return repo.patch(ctx, id, version, patch)
+}
+
+func (repo *MemoryLibrosRepo) patch(ctx context.Context, id, version int, patch models.LibroPatch) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var l *models.Libro
+	err := repo.conAutores(func(vincular func(int, string)) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		var err error
+		l, err = repo.modificar(ctx, id, version, patch, vincular)
+		return err
+	})
+
+	return l, err
+}
+
+// mostrarAutores es el UPDATE de libros.autor de refreshAutorDisplay. Lo llama MemoryAutoresRepo con
+// su lock tomado y con el texto que ya sale de los links, asi que no hay nada que vincular.
+func (repo *MemoryLibrosRepo) mostrarAutores(ctx context.Context, id int, autor string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.modificar(ctx, id, 0, models.LibroPatch{Autor: &autor}, func(int, string) {})
+	return err
+}
+
+// modificar es patch con el lock tomado
+func (repo *MemoryLibrosRepo) modificar(ctx context.Context, id, version int, patch models.LibroPatch, vincular func(int, string)) (*models.Libro, error) {
+	l, err := repo.enVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch == (models.LibroPatch{}) {
+		return &l, nil // sin campos no cambia nada, ni la version (ni queda revision)
+	}
+	antes := l
+
+	if patch.Titulo != nil {
+		l.Titulo = *patch.Titulo
+	}
+	if patch.Autor != nil {
+		l.Autor = *patch.Autor
+	}
+	if patch.Ano != nil {
+		l.Ano = *patch.Ano
+	}
+	if patch.ISBN != nil {
+		if err := repo.isbnUsado(*patch.ISBN, id); err != nil {
+			return nil, err
+		}
+		l.ISBN = *patch.ISBN
+	}
+	l.Version++
+	l.Modificado = ahora()
+
+	repo.libros[id] = l
+	repo.registrar(ctx, models.RevisionModificar, &antes, l)
+	if patch.Autor != nil {
+		vincular(id, l.Autor)
+	}
+	return &l, nil
+}
+
+func (repo *MemoryLibrosRepo) Delete(ctx context.Context, id int) error {
+	return repo.delete(ctx, id, 0)
+}
+
+func (repo *MemoryLibrosRepo) DeleteIf(ctx context.Context, id, version int) error {
+	return repo.delete(ctx, id, version)
+}
+
+func (repo *MemoryLibrosRepo) delete(ctx context.Context, id, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return repo.conCirculacion(func(ocupado func(int) error) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		_, err := repo.borrar(ctx, id, version, ocupado)
+		return err
+	})
+}
+
+// conAutores corre fn con el lock de autores tomado; sin repo de autores no hay links que mantener.
+// fn toma nuestro lock.
+func (repo *MemoryLibrosRepo) conAutores(fn func(vincular func(libroID int, autor string)) error) error {
+	if repo.autores == nil {
+		return fn(func(int, string) {})
+	}
+	return repo.autores(fn)
+}
+
+// conCirculacion corre fn con los locks de circulacion tomados; sin prestamos ni reservas ningun
+// libro esta ocupado. fn toma nuestro lock.
+func (repo *MemoryLibrosRepo) conCirculacion(fn func(ocupado func(libroID int) error) error) error {
+	if repo.circulacion == nil {
+		return fn(func(int) error { return nil })
+	}
+	return repo.circulacion(fn)
+}
+
+// borrar es delete con el lock tomado. Devuelve el libro ya en la papelera, para Batch
+func (repo *MemoryLibrosRepo) borrar(ctx context.Context, id, version int, ocupado func(int) error) (*models.Libro, error) {
+	l, err := repo.enVersion(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// un libro con ejemplares prestados o apartados no va a la papelera, como no se borra un ejemplar asi
+	if err := ocupado(id); err != nil {
+		return nil, err
+	}
+
+	// a la papelera, como en postgres
+	antes := l
+	now := ahora()
+	l.Borrado = &now
+	l.Version++
+	l.Modificado = now
+
+	repo.libros[id] = l
+	repo.registrar(ctx, models.RevisionBorrar, &antes, l)
+	return &l, nil
+}
+
+func (repo *MemoryLibrosRepo) Restaurar(ctx context.Context, id int) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	l, ok := repo.libros[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if l.Borrado == nil {
+		return nil, ErrNoBorrado
+	}
+
+	if err := repo.isbnUsado(l.ISBN, id); err != nil {
+		return nil, err
+	}
+
+	antes := l
+	l.Borrado = nil
+	l.Version++
+	l.Modificado = ahora()
+
+	repo.libros[id] = l
+	repo.registrar(ctx, models.RevisionRestaurar, &antes, l)
+	return &l, nil
+}
+
+// Purgar borra los libros del map. Los ejemplares, autores, etc. que apuntan a ellos los descartan
+// los otros repos de memoria cuando ven que el libro ya no existe, como si fuera el ON DELETE CASCADE.
+func (repo *MemoryLibrosRepo) Purgar(ctx context.Context, antes time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	err := repo.conCirculacion(func(ocupado func(int) error) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		for id, l := range repo.libros {
+			// los de antes de que delete lo chequeara pueden tener prestamos: esos quedan
+			if l.Borrado != nil && l.Borrado.Before(antes) && ocupado(id) == nil {
+				delete(repo.libros, id)
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+func (repo *MemoryLibrosRepo) Batch(ctx context.Context, ops []models.LibroOp, atomic bool) ([]ResultadoBatch, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	resultados := make([]ResultadoBatch, len(ops))
+	err := repo.conAutores(func(vincular func(int, string)) error {
+		return repo.conCirculacion(func(ocupado func(int) error) error {
+			repo.mu.Lock()
+			defer repo.mu.Unlock()
+
+			// para deshacer un batch atomico alcanza con volver a los maps de antes: las revisiones se agregan
+			// al final de cada slice y los slices viejos no ven lo que se agrego. Los autores se vinculan al
+			// final, cuando ya no hay nada que deshacer.
+			libros, revisiones, nextID := maps.Clone(repo.libros), maps.Clone(repo.revisiones), repo.nextID
+
+			type vinculo struct {
+				libroID int
+				autor   string
+			}
+			var vinculos []vinculo
+			pendiente := func(libroID int, autor string) { vinculos = append(vinculos, vinculo{libroID, autor}) }
+
+			for i, op := range ops {
+				l, err := repo.aplicar(ctx, op, ocupado, pendiente)
+				if err != nil && atomic {
+					repo.libros, repo.revisiones, repo.nextID = libros, revisiones, nextID
+					return &BatchError{Indice: i, Err: err}
+				}
+				resultados[i] = ResultadoBatch{Libro: l, Err: err}
+			}
+
+			for _, v := range vinculos {
+				vincular(v.libroID, v.autor)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resultados, nil
+}
+
+// aplicar hace una operacion de Batch con el lock tomado
+func (repo *MemoryLibrosRepo) aplicar(ctx context.Context, op models.LibroOp, ocupado func(int) error, vincular func(int, string)) (*models.Libro, error) {
+	switch op.Op {
+	case models.RevisionCrear:
+		return repo.crear(ctx, op.Input(), vincular)
+	case models.RevisionReemplazar:
+		return repo.reemplazar(ctx, op.ID, op.Version, op.Input(), models.RevisionReemplazar, vincular)
+	case models.RevisionModificar:
+		return repo.modificar(ctx, op.ID, op.Version, *op.Libro, vincular)
+	default:
+		return repo.borrar(ctx, op.ID, op.Version, ocupado)
+	}
+}
+
+// registrar es el INSERT en libro_revisiones de postgres. Hay que llamarlo con el lock tomado.
+func (repo *MemoryLibrosRepo) registrar(ctx context.Context, op string, antes *models.Libro, despues models.Libro) {
+	repo.revisiones[despues.ID] = append(repo.revisiones[despues.ID], models.Revision{
+		LibroID:   despues.ID,
+		Rev:       despues.Version,
+		Operacion: op,
+		Antes:     antes,
+		Despues:   despues,
+		Actor:     actor.FromContext(ctx),
+		RequestID: requestid.FromContext(ctx),
+		Fecha:     despues.Modificado,
+	})
+}
+
+func (repo *MemoryLibrosRepo) Historial(ctx context.Context, id int, f models.RevisionFilter) ([]models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	revs := repo.revisiones[id]
+	if _, ok := repo.libros[id]; !ok && len(revs) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// de la mas nueva a la mas vieja, como ORDER BY rev DESC
+	result := slices.Clone(revs)
+	slices.Reverse(result)
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func (repo *MemoryLibrosRepo) EnFecha(ctx context.Context, id int, t time.Time) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	revs := repo.revisiones[id]
+	for i := len(revs) - 1; i >= 0; i-- {
+		if !revs[i].Fecha.After(t) {
+			l := revs[i].Despues
+			return &l, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (repo *MemoryLibrosRepo) Revertir(ctx context.Context, id, rev, version int) (*models.Libro, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var l *models.Libro
+	err := repo.conAutores(func(vincular func(int, string)) error {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		i := slices.IndexFunc(repo.revisiones[id], func(r models.Revision) bool { return r.Rev == rev })
+		if i < 0 {
+			if _, err := repo.enVersion(id, 0); err != nil {
+				return err
+			}
+			return ErrRevisionNotFound
+		}
+
+		var err error
+		l, err = repo.reemplazar(ctx, id, version, inputDe(repo.revisiones[id][i].Despues), models.RevisionRevertir, vincular)
+		return err
+	})
+
+	return l, err
+}
+
+// enVersion devuelve el libro si existe, no esta en la papelera y esta en version (cualquiera si
+// version es 0). Hay que llamarlo con el lock tomado.
+func (repo *MemoryLibrosRepo) enVersion(id, version int) (models.Libro, error) {
+	l, ok := repo.libros[id]
+	if !ok || l.Borrado != nil {
+		return l, ErrNotFound
+	}
+
+	if version != 0 && l.Version != version {
+		return l, &VersionConflictError{Actual: l}
+	}
+
+	return l, nil
+}
+
+// ahora es el now() de postgres: timestamptz guarda microsegundos
+func ahora() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// ilikeContains arma el equivalente a `ILIKE '%pattern%'`:
+// sin distinguir mayusculas, con % = cualquier cosa, _ = un caracter y \ para escapar.
+func ilikeContains(pattern string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString(`(?is)^.*`)
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			sb.WriteString(`.*`)
+		case r == '_':
+			sb.WriteString(`.`)
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	sb.WriteString(`.*$`)
+	return regexp.MustCompile(sb.String()) //no puede fallar: todo lo que no es comodin pasa por QuoteMeta
+}
+
+// paginar hace LIMIT/OFFSET en memoria (los repos en memoria lo usan despues de ordenar): offset fuera de
+// rango o limit 0 => vacio. Negativos son un error como en postgres, no un panic: a los repos se los
+// puede llamar sin pasar por Validate.
+func paginar[T any](result []T, limit, offset int) ([]T, error) {
+	if limit < 0 || offset < 0 {
+		return nil, fmt.Errorf("limit (%d) y offset (%d) no pueden ser negativos", limit, offset)
+	}
+
+	if offset >= len(result) {
+		return []T{}, nil
+	}
+	result = result[offset:]
+
+	if limit < len(result) {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
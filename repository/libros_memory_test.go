@@ -0,0 +1,175 @@
+package repository_test
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"api-libros/repository/repositorytest"
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestMemoryLibrosRepo_Conformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.LibrosRepository {
+		return repository.NewMemoryLibrosRepo()
+	})
+}
+
+func TestMemoryAutoresRepo_Conformance(t *testing.T) {
+	repositorytest.RunAutores(t, func(t *testing.T) (repository.LibrosRepository, repository.AutoresRepository) {
+		libros := repository.NewMemoryLibrosRepo()
+		return libros, repository.NewMemoryAutoresRepo(libros)
+	})
+}
+
+func TestMemoryEjemplaresRepo_Conformance(t *testing.T) {
+	repositorytest.RunEjemplares(t, func(t *testing.T) (repository.LibrosRepository, repository.EjemplaresRepository) {
+		libros := repository.NewMemoryLibrosRepo()
+		return libros, repository.NewMemoryEjemplaresRepo(libros)
+	})
+}
+
+func TestMemorySociosRepo_Conformance(t *testing.T) {
+	repositorytest.RunSocios(t, func(t *testing.T) repository.SociosRepository {
+		return repository.NewMemorySociosRepo()
+	})
+}
+
+func TestMemoryPrestamosRepo_Conformance(t *testing.T) {
+	repositorytest.RunPrestamos(t, newMemoryCirculacion)
+}
+
+func TestMemoryReservasRepo_Conformance(t *testing.T) {
+	repositorytest.RunReservas(t, newMemoryCirculacion)
+}
+
+func TestMemoryMultasRepo_Conformance(t *testing.T) {
+	repositorytest.RunMultas(t, newMemoryCirculacion)
+}
+
+func newMemoryCirculacion(t *testing.T) repositorytest.Circulacion {
+	libros := repository.NewMemoryLibrosRepo()
+	ejemplares := repository.NewMemoryEjemplaresRepo(libros)
+	socios := repository.NewMemorySociosRepo()
+	prestamos := repository.NewMemoryPrestamosRepo(ejemplares, socios)
+
+	return repositorytest.Circulacion{
+		Libros:     libros,
+		Ejemplares: ejemplares,
+		Socios:     socios,
+		Prestamos:  prestamos,
+		Reservas:   repository.NewMemoryReservasRepo(prestamos),
+		Multas:     repository.NewMemoryMultasRepo(prestamos),
+	}
+}
+
+func seedMemoryRepo(t *testing.T) *repository.MemoryLibrosRepo {
+	t.Helper()
+
+	repo := repository.NewMemoryLibrosRepo()
+
+	libros := []models.LibroInput{
+		{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965},
+		{Titulo: "1984", Autor: "George Orwell", Ano: 1949},
+		{Titulo: "Rebelión en la granja", Autor: "George Orwell", Ano: 1945},
+		{Titulo: "Crimen y castigo", Autor: "Fiódor Dostoievski", Ano: 1866},
+	}
+
+	for _, in := range libros {
+		if _, err := repo.Create(context.Background(), in); err != nil {
+			t.Fatalf("error cargando libros: %v", err)
+		}
+	}
+
+	return repo
+}
+
+func TestMemoryLibrosRepo_GetAll_Filtros(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  models.LibroFilter
+		wantIDs []int
+	}{
+		{"sin filtros", models.LibroFilter{Limit: 50}, []int{1, 2, 3, 4}},
+		{"autor sin distinguir mayusculas", models.LibroFilter{Autor: ptr("orwell"), Limit: 50}, []int{2, 3}},
+		{"autor con acento", models.LibroFilter{Autor: ptr("FIÓDOR"), Limit: 50}, []int{4}},
+		{"autor con comodin %", models.LibroFilter{Autor: ptr("george%well"), Limit: 50}, []int{2, 3}},
+		{"autor con comodin _", models.LibroFilter{Autor: ptr("Fr_nk"), Limit: 50}, []int{1}},
+		{"autor con comodin escapado", models.LibroFilter{Autor: ptr(`Fr\_nk`), Limit: 50}, nil},
+		{"from", models.LibroFilter{From: ptrInt(1949), Limit: 50}, []int{1, 2}},
+		{"to", models.LibroFilter{To: ptrInt(1949), Limit: 50}, []int{2, 3, 4}},
+		{"from y to", models.LibroFilter{From: ptrInt(1945), To: ptrInt(1949), Limit: 50}, []int{2, 3}},
+		{"limit", models.LibroFilter{Limit: 2}, []int{1, 2}},
+		{"limit 0", models.LibroFilter{Limit: 0}, nil},
+		{"offset", models.LibroFilter{Limit: 2, Offset: 3}, []int{4}},
+		{"offset fuera de rango", models.LibroFilter{Limit: 2, Offset: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedMemoryRepo(t)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if len(libros) != len(tt.wantIDs) {
+				t.Fatalf("esperaba %d libros, vinieron %d: %+v", len(tt.wantIDs), len(libros), libros)
+			}
+
+			for i, l := range libros {
+				if l.ID != tt.wantIDs[i] {
+					t.Fatalf("posicion %d: esperaba id %d, vino %d", i, tt.wantIDs[i], l.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryLibrosRepo_NoExponeElMap(t *testing.T) {
+	repo := seedMemoryRepo(t)
+	ctx := context.Background()
+
+	l, _ := repo.GetByID(ctx, 1)
+	l.Titulo = "modificado afuera"
+
+	again, _ := repo.GetByID(ctx, 1)
+	if again.Titulo != "Dune" {
+		t.Fatalf("el repo devolvio una referencia a su estado interno")
+	}
+}
+
+func TestMemoryLibrosRepo_Concurrencia(t *testing.T) {
+	repo := repository.NewMemoryLibrosRepo()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := repo.Create(ctx, models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000})
+			if err != nil {
+				t.Errorf("error inesperado: %v", err)
+				return
+			}
+			repo.Patch(ctx, l.ID, models.LibroPatch{Ano: ptrInt(2001)})
+			repo.GetAll(ctx, models.LibroFilter{Limit: 10})
+		}()
+	}
+	wg.Wait()
+
+	libros, _ := repo.GetAll(ctx, models.LibroFilter{Limit: 100})
+	if len(libros) != 50 {
+		t.Fatalf("esperaba 50 libros, hay %d", len(libros))
+	}
+
+	seen := map[int]bool{}
+	for _, l := range libros {
+		if seen[l.ID] {
+			t.Fatalf("id repetido: %d", l.ID)
+		}
+		seen[l.ID] = true
+	}
+}
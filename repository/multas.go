@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrSocioBloqueado   = errors.New("el socio debe multas por encima del maximo")
+	ErrSocioConMultas   = errors.New("el socio tiene movimientos de multas")
+	ErrMontoExcedeSaldo = errors.New("el monto es mayor que lo que debe el socio")
+)
+
+// MultasRepository es la cuenta de multas de los socios.
+type MultasRepository interface {
+	// GetBySocio devuelve los movimientos del socio en orden. ErrSocioNotFound si no existe.
+	GetBySocio(ctx context.Context, socioID int, filter models.MultaFilter) ([]models.Multa, error)
+	// Saldo suma los movimientos del socio (Bloqueado queda en false, lo decide quien llama).
+	Saldo(ctx context.Context, socioID int) (*models.SaldoMultas, error)
+
+	// Registrar guarda un pago o una condonacion. ErrMontoExcedeSaldo si el socio debe menos que el
+	// monto: dos pagos simultaneos no pueden dejar el saldo negativo.
+	Registrar(ctx context.Context, m models.NuevoMovimiento) (*models.Multa, error)
+	// Calcular pasa por los prestamos atrasados y deja el cargo de cada uno como dice la politica al dia
+	// de hoy. Los prestamos devueltos quedan con su cargo final y no se vuelven a mirar. Correrlo dos veces
+	// el mismo dia no cambia nada. Devuelve los cargos que se crearon o cambiaron.
+	Calcular(ctx context.Context, hoy models.Fecha, politica models.PoliticaMulta) ([]models.Multa, error)
+}
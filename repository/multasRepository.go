@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresMultasRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresMultasRepo(db *pgxpool.Pool) *PostgresMultasRepo {
+	return &PostgresMultasRepo{DB: db}
+}
+
+const multaCols = `m.id, m.socio_id, coalesce(m.prestamo_id, 0), m.tipo, m.monto, m.dias, m.fecha, m.final, m.nota`
+
+// saldoSocio suma la cuenta de un socio ($1): cargos, pagos, condonaciones
+const saldoSocio = `SELECT
+	coalesce(sum(monto) FILTER (WHERE tipo = 'cargo'), 0),
+	coalesce(sum(monto) FILTER (WHERE tipo = 'pago'), 0),
+	coalesce(sum(monto) FILTER (WHERE tipo = 'condonacion'), 0)
+	FROM multas WHERE socio_id = $1`
+
+func (repo *PostgresMultasRepo) GetBySocio(ctx context.Context, socioID int, f models.MultaFilter) ([]models.Multa, error) {
+	if err := socioExiste(ctx, repo.DB, socioID, false); err != nil {
+		return nil, multasError(ctx, "GetBySocio", err)
+	}
+
+	query := `SELECT ` + multaCols + ` FROM multas m WHERE m.socio_id = $1`
+	args := []any{socioID}
+	i := 2
+
+	if f.Tipo != nil {
+		query += fmt.Sprintf(" AND m.tipo = $%d", i)
+		args = append(args, *f.Tipo)
+		i++
+	}
+
+	query += fmt.Sprintf(" ORDER BY m.id LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := repo.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, multasError(ctx, "GetBySocio", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanMulta)
+	if err != nil {
+		return nil, multasError(ctx, "GetBySocio", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresMultasRepo) Saldo(ctx context.Context, socioID int) (*models.SaldoMultas, error) {
+	if err := socioExiste(ctx, repo.DB, socioID, false); err != nil {
+		return nil, multasError(ctx, "Saldo", err)
+	}
+
+	s, err := saldo(ctx, repo.DB, socioID)
+	if err != nil {
+		return nil, multasError(ctx, "Saldo", err)
+	}
+
+	return s, nil
+}
+
+func (repo *PostgresMultasRepo) Registrar(ctx context.Context, m models.NuevoMovimiento) (*models.Multa, error) {
+	var result models.Multa
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		// con el socio bloqueado los pagos del mismo socio van de a uno y cada uno ve el saldo del anterior
+		if err := socioExiste(ctx, tx, m.SocioID, true); err != nil {
+			return err
+		}
+
+		s, err := saldo(ctx, tx, m.SocioID)
+		if err != nil {
+			return err
+		}
+		if m.Monto > s.Saldo {
+			return ErrMontoExcedeSaldo
+		}
+
+		rows, _ := tx.Query(ctx,
+			`INSERT INTO multas AS m (socio_id, tipo, monto, fecha, nota) VALUES ($1, $2, $3, $4, $5) RETURNING `+multaCols,
+			m.SocioID, m.Tipo, m.Monto, m.Fecha, m.Nota)
+		result, err = pgx.CollectExactlyOneRow(rows, scanMulta)
+		return err
+	})
+
+	if err != nil {
+		return nil, multasError(ctx, "Registrar", err)
+	}
+
+	return &result, nil
+}
+
+func (repo *PostgresMultasRepo) Calcular(ctx context.Context, hoy models.Fecha, politica models.PoliticaMulta) ([]models.Multa, error) {
+	// solo los que tienen mas dias de atraso corridos que dias de gracia, y cuyo cargo no es final todavia.
+	// Los feriados se descuentan en Go: un prestamo puede pasar este filtro y no deber nada
+	rows, err := repo.DB.Query(ctx, `SELECT `+prestamoCols+prestamoFrom+`
+		WHERE coalesce(p.devuelto, $1) - p.vence > $2
+		  AND NOT EXISTS (SELECT 1 FROM multas m WHERE m.prestamo_id = p.id AND m.tipo = 'cargo' AND m.final)
+		ORDER BY p.id`, hoy, politica.DiasGracia)
+	if err != nil {
+		return nil, multasError(ctx, "Calcular", err)
+	}
+
+	prestamos, err := pgx.CollectRows(rows, scanPrestamo)
+	if err != nil {
+		return nil, multasError(ctx, "Calcular", err)
+	}
+
+	// cada cargo es un solo upsert, no hace falta una transaccion: si otro calculo corre a la vez
+	// los dos escriben lo mismo, y si el prestamo se devuelve en el medio el proximo calculo lo cierra
+	result := []models.Multa{}
+	for _, p := range prestamos {
+		c := politica.Calcular(p, hoy)
+		if c.Monto == 0 && !c.Final {
+			continue // sin nada que cobrar no hace falta un cargo en la cuenta (todavia)
+		}
+
+		rows, _ := repo.DB.Query(ctx, `
+			INSERT INTO multas AS m (socio_id, prestamo_id, tipo, monto, dias, fecha, final)
+			VALUES ($1, $2, 'cargo', $3, $4, $5, $6)
+			ON CONFLICT (prestamo_id) WHERE tipo = 'cargo' DO UPDATE
+			   SET monto = EXCLUDED.monto, dias = EXCLUDED.dias, fecha = EXCLUDED.fecha, final = EXCLUDED.final
+			 WHERE (m.monto, m.dias, m.final) IS DISTINCT FROM (EXCLUDED.monto, EXCLUDED.dias, EXCLUDED.final)
+			RETURNING `+multaCols,
+			p.SocioID, p.ID, c.Monto, c.Dias, hoy, c.Final)
+
+		m, err := pgx.CollectExactlyOneRow(rows, scanMulta)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // ya estaba igual
+		}
+		if isForeignKeyViolation(err) {
+			continue // se borro el prestamo mientras tanto
+		}
+		if err != nil {
+			return nil, multasError(ctx, "Calcular", err)
+		}
+
+		result = append(result, m)
+	}
+
+	return result, nil
+}
+
+// socioExiste devuelve ErrSocioNotFound si no existe. Con forUpdate bloquea al socio.
+func socioExiste(ctx context.Context, q querier, socioID int, forUpdate bool) error {
+	query := "SELECT id FROM socios WHERE id = $1"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, _ := q.Query(ctx, query, socioID)
+	_, err := pgx.CollectExactlyOneRow(rows, pgx.RowTo[int])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrSocioNotFound
+	}
+	return err
+}
+
+func saldo(ctx context.Context, q querier, socioID int) (*models.SaldoMultas, error) {
+	s := models.SaldoMultas{SocioID: socioID}
+
+	rows, _ := q.Query(ctx, saldoSocio, socioID)
+	_, err := pgx.CollectExactlyOneRow(rows, func(row pgx.CollectableRow) (struct{}, error) {
+		return struct{}{}, row.Scan(&s.Cargos, &s.Pagos, &s.Condonaciones)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Saldo = s.Cargos - s.Pagos - s.Condonaciones
+	return &s, nil
+}
+
+func scanMulta(row pgx.CollectableRow) (models.Multa, error) {
+	var m models.Multa
+	err := row.Scan(&m.ID, &m.SocioID, &m.PrestamoID, &m.Tipo, &m.Monto, &m.Dias, &m.Fecha, &m.Final, &m.Nota)
+	return m, err
+}
+
+func multasError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, ErrSocioNotFound) || errors.Is(err, ErrMontoExcedeSaldo) {
+		return err
+	}
+	return repoError(ctx, "multas", op, err)
+}
@@ -0,0 +1,261 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryMultasRepo es la version en memoria de PostgresMultasRepo. Se engancha al repo de prestamos
+// (Prestar consulta la deuda del socio) y al de socios (no se borra un socio con movimientos).
+// Su lock va ultimo en el orden de MemoryPrestamosRepo: socios, ejemplares, prestamos, reservas, multas.
+type MemoryMultasRepo struct {
+	mu     sync.RWMutex
+	multas map[int]models.Multa
+	nextID int
+
+	prestamos *MemoryPrestamosRepo
+}
+
+// NewMemoryMultasRepo, como NewMemoryPrestamosRepo, hay que llamarlo antes de usar los otros repos.
+func NewMemoryMultasRepo(prestamos *MemoryPrestamosRepo) *MemoryMultasRepo {
+	repo := &MemoryMultasRepo{
+		multas:    map[int]models.Multa{},
+		nextID:    1,
+		prestamos: prestamos,
+	}
+
+	prestamos.multas = repo
+	prestamos.socios.tieneMultas = repo.tieneMovimientos
+
+	return repo
+}
+
+func (repo *MemoryMultasRepo) GetBySocio(ctx context.Context, socioID int, f models.MultaFilter) ([]models.Multa, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	socios := repo.prestamos.socios
+	socios.mu.RLock()
+	defer socios.mu.RUnlock()
+
+	if _, ok := socios.socios[socioID]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	repo.prestamos.ejemplares.mu.RLock()
+	defer repo.prestamos.ejemplares.mu.RUnlock()
+
+	repo.prestamos.mu.RLock()
+	defer repo.prestamos.mu.RUnlock()
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Multa{}
+	for _, m := range repo.multas {
+		if m.SocioID != socioID || (f.Tipo != nil && m.Tipo != *f.Tipo) {
+			continue
+		}
+
+		m, err := repo.conPrestamo(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	slices.SortFunc(result, func(a, b models.Multa) int { return a.ID - b.ID })
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func (repo *MemoryMultasRepo) Saldo(ctx context.Context, socioID int) (*models.SaldoMultas, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	socios := repo.prestamos.socios
+	socios.mu.RLock()
+	defer socios.mu.RUnlock()
+
+	if _, ok := socios.socios[socioID]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	s := repo.saldo(socioID)
+	return &s, nil
+}
+
+func (repo *MemoryMultasRepo) Registrar(ctx context.Context, nm models.NuevoMovimiento) (*models.Multa, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	socios := repo.prestamos.socios
+	socios.mu.RLock()
+	defer socios.mu.RUnlock()
+
+	if _, ok := socios.socios[nm.SocioID]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if nm.Monto > repo.saldo(nm.SocioID).Saldo {
+		return nil, ErrMontoExcedeSaldo
+	}
+
+	m := models.Multa{
+		ID:      repo.nextID,
+		SocioID: nm.SocioID,
+		Tipo:    nm.Tipo,
+		Monto:   nm.Monto,
+		Fecha:   nm.Fecha,
+		Nota:    nm.Nota,
+	}
+	repo.nextID++
+
+	repo.multas[m.ID] = m
+	return &m, nil
+}
+
+func (repo *MemoryMultasRepo) Calcular(ctx context.Context, hoy models.Fecha, politica models.PoliticaMulta) ([]models.Multa, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.prestamos.ejemplares.mu.RLock()
+	defer repo.prestamos.ejemplares.mu.RUnlock()
+
+	repo.prestamos.mu.RLock()
+	defer repo.prestamos.mu.RUnlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	cargos := map[int]models.Multa{} // por prestamo
+	for _, m := range repo.multas {
+		if m.Tipo == models.MultaCargo {
+			cargos[m.PrestamoID] = m
+		}
+	}
+
+	result := []models.Multa{}
+	for _, p := range repo.prestamos.prestamos {
+		vivo, err := repo.prestamos.conLibro(ctx, &p)
+		if err != nil {
+			return nil, err
+		}
+		if !vivo {
+			continue
+		}
+
+		// el mismo filtro que postgres: dias corridos de atraso mas alla de la gracia, cargo no final
+		hasta := hoy
+		if !p.Activo() {
+			hasta = p.Devuelto
+		}
+		if int(hasta.Sub(p.Vence.Time).Hours()/24) <= politica.DiasGracia {
+			continue
+		}
+
+		m, existe := cargos[p.ID]
+		if existe && m.Final {
+			continue
+		}
+
+		c := politica.Calcular(p, hoy)
+		if c.Monto == 0 && !c.Final {
+			continue
+		}
+		if existe && m.Monto == c.Monto && m.Dias == c.Dias && m.Final == c.Final {
+			continue
+		}
+
+		if !existe {
+			m = models.Multa{ID: repo.nextID, SocioID: p.SocioID, PrestamoID: p.ID, Tipo: models.MultaCargo}
+			repo.nextID++
+		}
+		m.Monto, m.Dias, m.Fecha, m.Final = c.Monto, c.Dias, hoy, c.Final
+
+		repo.multas[m.ID] = m
+		result = append(result, m)
+	}
+
+	slices.SortFunc(result, func(a, b models.Multa) int { return a.PrestamoID - b.PrestamoID })
+	return result, nil
+}
+
+// saldo suma la cuenta del socio. Hay que tener nuestro lock.
+func (repo *MemoryMultasRepo) saldo(socioID int) models.SaldoMultas {
+	s := models.SaldoMultas{SocioID: socioID}
+
+	for _, m := range repo.multas {
+		if m.SocioID != socioID {
+			continue
+		}
+
+		switch m.Tipo {
+		case models.MultaCargo:
+			s.Cargos += m.Monto
+		case models.MultaPago:
+			s.Pagos += m.Monto
+		case models.MultaCondonacion:
+			s.Condonaciones += m.Monto
+		}
+	}
+
+	s.Saldo = s.Cargos - s.Pagos - s.Condonaciones
+	return s
+}
+
+// deuda es el hook de Prestar, que lo llama con los locks de socios, ejemplares y prestamos tomados
+func (repo *MemoryMultasRepo) deuda(socioID int) int {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.saldo(socioID).Saldo
+}
+
+// tieneMovimientos es el hook de socios (el ON DELETE RESTRICT). Lo llaman con el lock de socios tomado.
+func (repo *MemoryMultasRepo) tieneMovimientos(socioID int) bool {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, m := range repo.multas {
+		if m.SocioID == socioID {
+			return true
+		}
+	}
+	return false
+}
+
+// conPrestamo es el ON DELETE SET NULL: si el prestamo ya no existe, el cargo queda sin prestamo.
+// Hay que tener los locks de ejemplares y prestamos.
+func (repo *MemoryMultasRepo) conPrestamo(ctx context.Context, m models.Multa) (models.Multa, error) {
+	if m.PrestamoID == 0 {
+		return m, nil
+	}
+
+	p, ok := repo.prestamos.prestamos[m.PrestamoID]
+	if !ok {
+		m.PrestamoID = 0
+		return m, nil
+	}
+
+	vivo, err := repo.prestamos.conLibro(ctx, &p)
+	if err != nil {
+		return m, err
+	}
+	if !vivo {
+		m.PrestamoID = 0
+	}
+	return m, nil
+}
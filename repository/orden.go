@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"api-libros/models"
+	"cmp"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Los dos backends tienen que ordenar exactamente igual, si no un cursor generado con uno
+// saltea o repite libros en el otro. Por eso el orden de postgres (sortColumns + la collation
+// espanol de la migracion 0002) y el de memoria (compareLibros) estan juntos en este archivo.
+
+// sortColumns mapea cada campo ordenable a su expresion SQL. Lo que va en el ORDER BY sale
+// siempre de aca y nunca del query param, asi no hay forma de meter SQL por ?sort=.
+var sortColumns = map[string]string{
+	"titulo": "titulo COLLATE espanol",
+	"autor":  "autor COLLATE espanol",
+	"ano":    "ano",
+	"id":     "id",
+}
+
+// orderBy arma el ORDER BY. Hacia atras se invierte todo: se buscan los mas cercanos al
+// cursor y despues se dan vuelta.
+func orderBy(sort []models.SortField, backward bool) (string, error) {
+	parts := make([]string, 0, len(sort))
+
+	for _, s := range sort {
+		col, ok := sortColumns[s.Campo]
+		if !ok {
+			return "", fmt.Errorf("no se puede ordenar por %q", s.Campo)
+		}
+
+		if s.Desc != backward {
+			col += " DESC"
+		}
+		parts = append(parts, col)
+	}
+
+	return strings.Join(parts, ", "), nil
+}
+
+// keysetCondition arma la condicion "viene despues de after" para un orden de varias columnas.
+// No uso la comparacion de filas (a, b) > ($1, $2) porque solo sirve si todas van en la misma
+// direccion; con ?sort=-ano,titulo hace falta la forma expandida:
+//
+//	ano < $1 OR (ano = $1 AND titulo > $2) OR (ano = $1 AND titulo = $2 AND id > $3)
+//
+// next es el numero del primer placeholder libre.
+func keysetCondition(sort []models.SortField, after models.Libro, backward bool, next int) (string, []any) {
+	args := make([]any, 0, len(sort))
+	ors := make([]string, 0, len(sort))
+
+	for i, s := range sort {
+		args = append(args, models.SortValue(after, s.Campo))
+
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("%s = $%d", sortColumns[sort[j].Campo], next+j))
+		}
+
+		op := ">"
+		if s.Desc != backward {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s $%d", sortColumns[s.Campo], op, next+i))
+
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// newCollator devuelve un collator en español (CLDR, igual que el ICU de postgres).
+// collate.Collator no se puede usar desde varias goroutines, hay que crear uno por consulta.
+func newCollator() *collate.Collator {
+	return collate.New(language.Spanish)
+}
+
+// compareLibros es el equivalente en Go del ORDER BY: <0 si a va antes que b segun sort.
+func compareLibros(col *collate.Collator, sort []models.SortField, a, b models.Libro) int {
+	for _, s := range sort {
+		var c int
+
+		switch s.Campo {
+		case "titulo":
+			c = compareText(col, a.Titulo, b.Titulo)
+		case "autor":
+			c = compareText(col, a.Autor, b.Autor)
+		case "ano":
+			c = cmp.Compare(a.Ano, b.Ano)
+		default:
+			c = cmp.Compare(a.ID, b.ID)
+		}
+
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// compareText: las collations de ICU en postgres son deterministicas, si dos textos son
+// "iguales" para la collation desempatan por bytes. Hago lo mismo para no diferir en ese caso.
+func compareText(col *collate.Collator, a, b string) int {
+	if c := col.CompareString(a, b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
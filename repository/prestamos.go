@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrPrestamoNotFound = errors.New("prestamo not found")
+	ErrEjemplarPrestado = errors.New("el ejemplar ya esta prestado")
+	ErrPrestamoDevuelto = errors.New("el prestamo ya fue devuelto")
+	ErrPrestamoVencido  = errors.New("el prestamo esta vencido")
+	ErrSinRenovaciones  = errors.New("el prestamo ya uso todas sus renovaciones")
+)
+
+// PrestamosRepository maneja la circulacion. Cada operacion corre en una sola transaccion:
+// un ejemplar nunca queda prestado dos veces aunque lleguen dos pedidos al mismo tiempo.
+type PrestamosRepository interface {
+	GetAll(ctx context.Context, filter models.PrestamoFilter) ([]models.Prestamo, error)
+	GetByID(ctx context.Context, id int) (*models.Prestamo, error)
+
+	// Prestar devuelve ErrEjemplarNotFound o ErrSocioNotFound si no existen,
+	// ErrEjemplarPrestado si el ejemplar tiene un prestamo sin devolver y ErrEjemplarReservado si
+	// esta apartado para la reserva de otro socio. Si es la reserva del mismo socio, queda retirada.
+	// ErrSocioBloqueado si el socio debe mas de p.DeudaMaxima en multas.
+	Prestar(ctx context.Context, p models.NuevoPrestamo) (*models.Prestamo, error)
+	// Devolver cierra el prestamo con fecha d.Fecha y le aparta el ejemplar al primero de la cola de
+	// reservas del libro hasta d.RetirarHasta. ErrPrestamoDevuelto si ya estaba cerrado.
+	Devolver(ctx context.Context, id int, d models.Devolucion) (*models.Prestamo, error)
+	// Renovar corre el vencimiento a r.Hasta. Falla con ErrPrestamoDevuelto, ErrPrestamoVencido
+	// (vencio antes de r.Hoy) o ErrSinRenovaciones (ya se renovo r.MaxRenovaciones veces).
+	Renovar(ctx context.Context, id int, r models.Renovacion) (*models.Prestamo, error)
+}
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPrestamosRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresPrestamosRepo(db *pgxpool.Pool) *PostgresPrestamosRepo {
+	return &PostgresPrestamosRepo{DB: db}
+}
+
+// el libro_id sale del ejemplar, no se guarda en prestamos
+const (
+	prestamoCols = `p.id, p.ejemplar_id, e.libro_id, p.socio_id, p.desde, p.vence, p.devuelto, p.renovaciones`
+	prestamoFrom = ` FROM prestamos p JOIN ejemplares e ON e.id = p.ejemplar_id`
+)
+
+func (repo *PostgresPrestamosRepo) GetAll(ctx context.Context, f models.PrestamoFilter) ([]models.Prestamo, error) {
+	query := `SELECT ` + prestamoCols + prestamoFrom + ` WHERE 1=1`
+	args := []any{}
+	i := 1
+
+	if f.SocioID != nil {
+		query += fmt.Sprintf(" AND p.socio_id = $%d", i)
+		args = append(args, *f.SocioID)
+		i++
+	}
+
+	if f.EjemplarID != nil {
+		query += fmt.Sprintf(" AND p.ejemplar_id = $%d", i)
+		args = append(args, *f.EjemplarID)
+		i++
+	}
+
+	if f.LibroID != nil {
+		query += fmt.Sprintf(" AND e.libro_id = $%d", i)
+		args = append(args, *f.LibroID)
+		i++
+	}
+
+	if f.Activos || f.VencidosAl != nil {
+		query += " AND p.devuelto IS NULL"
+	}
+
+	order := "p.id"
+	if f.VencidosAl != nil {
+		query += fmt.Sprintf(" AND p.vence < $%d", i)
+		args = append(args, *f.VencidosAl)
+		i++
+		order = "p.vence, p.id" // los mas atrasados primero
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", order, i, i+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := repo.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, prestamosError(ctx, "GetAll", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanPrestamo)
+	if err != nil {
+		return nil, prestamosError(ctx, "GetAll", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresPrestamosRepo) GetByID(ctx context.Context, id int) (*models.Prestamo, error) {
+	p, err := getPrestamo(ctx, repo.DB, id, false)
+	if errors.Is(err, ErrPrestamoNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, prestamosError(ctx, "GetByID", err)
+	}
+
+	return p, nil
+}
+
+func (repo *PostgresPrestamosRepo) Prestar(ctx context.Context, np models.NuevoPrestamo) (*models.Prestamo, error) {
+	var result *models.Prestamo
+	var reservado bool
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		// primero el libro, como las reservas y el borrado: mientras tanto nadie aparta sus ejemplares
+		var libroID int
+		err := tx.QueryRow(ctx, "SELECT libro_id FROM ejemplares WHERE id = $1", np.EjemplarID).Scan(&libroID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEjemplarNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := bloquearLibro(ctx, tx, libroID); err != nil {
+			return err
+		}
+
+		// bloqueo el ejemplar: dos pedidos por el mismo ejemplar se atienden de a uno y el segundo
+		// ya ve el prestamo del primero. Los de un libro en la papelera no se prestan
+		err = tx.QueryRow(ctx,
+			"SELECT libro_id FROM ejemplares WHERE id = $1 AND "+libroVivo("ejemplares.libro_id")+" FOR UPDATE",
+			np.EjemplarID).Scan(&libroID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrEjemplarNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var prestado bool
+		err = tx.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM prestamos WHERE ejemplar_id = $1 AND devuelto IS NULL)",
+			np.EjemplarID).Scan(&prestado)
+		if err != nil {
+			return err
+		}
+		if prestado {
+			return ErrEjemplarPrestado
+		}
+
+		s, err := saldo(ctx, tx, np.SocioID)
+		if err != nil {
+			return err
+		}
+		if s.Saldo > np.DeudaMaxima {
+			return ErrSocioBloqueado
+		}
+
+		// un ejemplar libre con gente en la cola es del primero de la cola, no del que vino al mostrador
+		if _, err := atenderLibro(ctx, tx, libroID, nil, np.RetirarHasta); err != nil {
+			return err
+		}
+
+		// apartado para una reserva: solo se lo puede llevar ese socio, y la reserva queda retirada
+		var reservaID, socioID int
+		err = tx.QueryRow(ctx,
+			"SELECT id, socio_id FROM reservas WHERE ejemplar_id = $1 AND estado = 'disponible' FOR UPDATE",
+			np.EjemplarID).Scan(&reservaID, &socioID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+		case err != nil:
+			return err
+		case socioID != np.SocioID:
+			// sin prestamo, pero lo que aparto atenderLibro queda
+			reservado = true
+			return nil
+		default:
+			if _, err := tx.Exec(ctx, "UPDATE reservas SET estado = 'retirada' WHERE id = $1", reservaID); err != nil {
+				return err
+			}
+		}
+
+		var id int
+		err = tx.QueryRow(ctx,
+			"INSERT INTO prestamos (ejemplar_id, socio_id, desde, vence) VALUES ($1, $2, $3, $4) RETURNING id",
+			np.EjemplarID, np.SocioID, np.Desde, np.Vence).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		result, err = getPrestamo(ctx, tx, id, false)
+		return err
+	})
+
+	if err == nil && reservado {
+		return nil, ErrEjemplarReservado
+	}
+
+	switch {
+	case errors.Is(err, ErrEjemplarNotFound), errors.Is(err, ErrEjemplarPrestado), errors.Is(err, ErrEjemplarReservado),
+		errors.Is(err, ErrSocioBloqueado):
+		return nil, err
+	case isForeignKeyViolation(err):
+		return nil, ErrSocioNotFound // la unica FK que queda, el ejemplar ya estaba bloqueado
+	case isUniqueViolation(err):
+		return nil, ErrEjemplarPrestado // prestamos_activo_key
+	case err != nil:
+		return nil, prestamosError(ctx, "Prestar", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresPrestamosRepo) Devolver(ctx context.Context, id int, d models.Devolucion) (*models.Prestamo, error) {
+	var result *models.Prestamo
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		p, err := getPrestamo(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !p.Activo() {
+			return ErrPrestamoDevuelto
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE prestamos SET devuelto = $1 WHERE id = $2", d.Fecha, id); err != nil {
+			return err
+		}
+
+		// en la misma transaccion el ejemplar pasa al primero de la cola, si hay alguien esperando
+		if err := bloquearLibro(ctx, tx, p.LibroID); err != nil {
+			return err
+		}
+		if _, err := atenderLibro(ctx, tx, p.LibroID, nil, d.RetirarHasta); err != nil {
+			return err
+		}
+
+		p.Devuelto = d.Fecha
+		result = p
+		return nil
+	})
+
+	if errors.Is(err, ErrPrestamoNotFound) || errors.Is(err, ErrPrestamoDevuelto) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, prestamosError(ctx, "Devolver", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresPrestamosRepo) Renovar(ctx context.Context, id int, r models.Renovacion) (*models.Prestamo, error) {
+	var result *models.Prestamo
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		p, err := getPrestamo(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if err := puedeRenovar(*p, r); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE prestamos SET vence = $1, renovaciones = renovaciones + 1 WHERE id = $2",
+			r.Hasta, id)
+		if err != nil {
+			return err
+		}
+
+		p.Vence = r.Hasta
+		p.Renovaciones++
+		result = p
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, ErrPrestamoNotFound), errors.Is(err, ErrPrestamoDevuelto),
+		errors.Is(err, ErrPrestamoVencido), errors.Is(err, ErrSinRenovaciones):
+		return nil, err
+	case err != nil:
+		return nil, prestamosError(ctx, "Renovar", err)
+	}
+
+	return result, nil
+}
+
+// puedeRenovar son las reglas de Renovar, compartidas con la version en memoria
+func puedeRenovar(p models.Prestamo, r models.Renovacion) error {
+	switch {
+	case !p.Activo():
+		return ErrPrestamoDevuelto
+	case p.Vencido(r.Hoy):
+		return ErrPrestamoVencido
+	case p.Renovaciones >= r.MaxRenovaciones:
+		return ErrSinRenovaciones
+	}
+	return nil
+}
+
+// getPrestamo lee un prestamo. Con forUpdate bloquea la fila hasta el final de la transaccion.
+func getPrestamo(ctx context.Context, q querier, id int, forUpdate bool) (*models.Prestamo, error) {
+	query := `SELECT ` + prestamoCols + prestamoFrom + ` WHERE p.id = $1`
+	if forUpdate {
+		query += " FOR UPDATE OF p"
+	}
+
+	rows, _ := q.Query(ctx, query, id)
+	p, err := pgx.CollectExactlyOneRow(rows, scanPrestamo)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPrestamoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func scanPrestamo(row pgx.CollectableRow) (models.Prestamo, error) {
+	var p models.Prestamo
+	err := row.Scan(&p.ID, &p.EjemplarID, &p.LibroID, &p.SocioID, &p.Desde, &p.Vence, &p.Devuelto, &p.Renovaciones)
+	return p, err
+}
+
+func prestamosError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "prestamos", op, err)
+}
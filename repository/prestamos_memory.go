@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"api-libros/models"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// MemoryPrestamosRepo es la version en memoria de PostgresPrestamosRepo. Se engancha a los repos de
+// ejemplares y socios para que no se pueda borrar un ejemplar prestado ni un socio con prestamos.
+//
+// Para no trabarse los locks se toman siempre en el mismo orden: socios, ejemplares, prestamos, reservas, multas.
+// Prestar toma los tres primeros, asi nadie borra el ejemplar o el socio a mitad del prestamo.
+type MemoryPrestamosRepo struct {
+	mu        sync.RWMutex
+	prestamos map[int]models.Prestamo
+	nextID    int
+
+	ejemplares *MemoryEjemplaresRepo
+	socios     *MemorySociosRepo
+	reservas   *MemoryReservasRepo // lo pone NewMemoryReservasRepo, puede no haber
+	multas     *MemoryMultasRepo   // lo pone NewMemoryMultasRepo, puede no haber
+}
+
+// NewMemoryPrestamosRepo hay que llamarlo antes de empezar a usar ejemplares y socios: les
+// deja los hooks que consultan los prestamos.
+func NewMemoryPrestamosRepo(ejemplares *MemoryEjemplaresRepo, socios *MemorySociosRepo) *MemoryPrestamosRepo {
+	repo := &MemoryPrestamosRepo{
+		prestamos:  map[int]models.Prestamo{},
+		nextID:     1,
+		ejemplares: ejemplares,
+		socios:     socios,
+	}
+
+	ejemplares.prestado = repo.prestado
+	socios.tienePrestamos = repo.tienePrestamos
+	if libros, ok := ejemplares.libros.(*MemoryLibrosRepo); ok {
+		libros.circulacion = repo.circulacion
+	}
+
+	return repo
+}
+
+// circulacion es el hook de libros: toma los locks de ejemplares y prestamos y le pasa a fn si el libro
+// tiene un ejemplar prestado (ErrEjemplarPrestado)
+func (repo *MemoryPrestamosRepo) circulacion(fn func(ocupado func(libroID int) error) error) error {
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return fn(func(libroID int) error {
+		for _, p := range repo.prestamos {
+			if p.Activo() && repo.ejemplares.ejemplares[p.EjemplarID].LibroID == libroID {
+				return ErrEjemplarPrestado
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *MemoryPrestamosRepo) GetAll(ctx context.Context, f models.PrestamoFilter) ([]models.Prestamo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Prestamo{}
+	for _, p := range repo.prestamos {
+		vivo, err := repo.conLibro(ctx, &p)
+		if err != nil {
+			return nil, err
+		}
+		if vivo && prestamoMatch(f, p) {
+			result = append(result, p)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Prestamo) int {
+		if f.VencidosAl != nil {
+			return cmp.Or(a.Vence.Compare(b.Vence.Time), a.ID-b.ID)
+		}
+		return a.ID - b.ID
+	})
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func prestamoMatch(f models.PrestamoFilter, p models.Prestamo) bool {
+	if f.SocioID != nil && p.SocioID != *f.SocioID {
+		return false
+	}
+	if f.EjemplarID != nil && p.EjemplarID != *f.EjemplarID {
+		return false
+	}
+	if f.LibroID != nil && p.LibroID != *f.LibroID {
+		return false
+	}
+	if (f.Activos || f.VencidosAl != nil) && !p.Activo() {
+		return false
+	}
+	if f.VencidosAl != nil && !p.Vencido(*f.VencidosAl) {
+		return false
+	}
+	return true
+}
+
+func (repo *MemoryPrestamosRepo) GetByID(ctx context.Context, id int) (*models.Prestamo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.get(ctx, id)
+}
+
+func (repo *MemoryPrestamosRepo) Prestar(ctx context.Context, np models.NuevoPrestamo) (*models.Prestamo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.socios.mu.RLock()
+	defer repo.socios.mu.RUnlock()
+
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	e, ok, err := repo.ejemplares.vivo(ctx, np.EjemplarID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrEjemplarNotFound
+	}
+
+	// los ejemplares de un libro en la papelera no se prestan
+	_, err = repo.ejemplares.libros.GetByID(ctx, e.LibroID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrEjemplarNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := repo.socios.socios[np.SocioID]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	if repo.activo(np.EjemplarID) {
+		return nil, ErrEjemplarPrestado
+	}
+
+	if repo.multas != nil && repo.multas.deuda(np.SocioID) > np.DeudaMaxima {
+		return nil, ErrSocioBloqueado
+	}
+
+	if repo.reservas != nil {
+		if err := repo.reservas.retirar(ctx, e.LibroID, np.EjemplarID, np.SocioID, np.RetirarHasta); err != nil {
+			return nil, err
+		}
+	}
+
+	p := models.Prestamo{
+		ID:         repo.nextID,
+		EjemplarID: np.EjemplarID,
+		LibroID:    e.LibroID,
+		SocioID:    np.SocioID,
+		Desde:      np.Desde,
+		Vence:      np.Vence,
+	}
+	repo.nextID++
+
+	repo.prestamos[p.ID] = p
+	return &p, nil
+}
+
+func (repo *MemoryPrestamosRepo) Devolver(ctx context.Context, id int, d models.Devolucion) (*models.Prestamo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	p, err := repo.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Activo() {
+		return nil, ErrPrestamoDevuelto
+	}
+
+	p.Devuelto = d.Fecha
+	repo.prestamos[id] = *p
+
+	// con los locks todavia tomados, asi nadie se lleva el ejemplar antes que el primero de la cola
+	if repo.reservas != nil {
+		if _, err := repo.reservas.atender(ctx, p.LibroID, nil, d.RetirarHasta, repo.activo); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+func (repo *MemoryPrestamosRepo) Renovar(ctx context.Context, id int, r models.Renovacion) (*models.Prestamo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	p, err := repo.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := puedeRenovar(*p, r); err != nil {
+		return nil, err
+	}
+
+	p.Vence = r.Hasta
+	p.Renovaciones++
+
+	repo.prestamos[id] = *p
+	return p, nil
+}
+
+// get busca un prestamo cuyo ejemplar siga existiendo. Hay que tener los locks de ejemplares y prestamos.
+func (repo *MemoryPrestamosRepo) get(ctx context.Context, id int) (*models.Prestamo, error) {
+	p, ok := repo.prestamos[id]
+	if !ok {
+		return nil, ErrPrestamoNotFound
+	}
+
+	vivo, err := repo.conLibro(ctx, &p)
+	if err != nil {
+		return nil, err
+	}
+	if !vivo {
+		return nil, ErrPrestamoNotFound
+	}
+
+	return &p, nil
+}
+
+// conLibro dice si el ejemplar del prestamo sigue existiendo: si se purgo el libro, en postgres el
+// prestamo se fue con el ON DELETE CASCADE. Hay que tener el lock de ejemplares.
+func (repo *MemoryPrestamosRepo) conLibro(ctx context.Context, p *models.Prestamo) (bool, error) {
+	e, ok, err := repo.ejemplares.vivo(ctx, p.EjemplarID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	p.LibroID = e.LibroID
+	return true, nil
+}
+
+// prestado es el hook de ejemplares: el ejemplar tiene un prestamo sin devolver. Lo llaman con el
+// lock de ejemplares tomado, que en el orden de locks va antes que el nuestro.
+func (repo *MemoryPrestamosRepo) prestado(ejemplarID int) bool {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.activo(ejemplarID)
+}
+
+// activo es prestado con repo.mu ya tomado
+func (repo *MemoryPrestamosRepo) activo(ejemplarID int) bool {
+	for _, p := range repo.prestamos {
+		if p.EjemplarID == ejemplarID && p.Activo() {
+			return true
+		}
+	}
+	return false
+}
+
+// tienePrestamos es el hook de socios: cuenta tambien los devueltos, como la FK de postgres.
+// Lo llaman con el lock de socios tomado.
+func (repo *MemoryPrestamosRepo) tienePrestamos(ctx context.Context, socioID int) (bool, error) {
+	repo.ejemplares.mu.RLock()
+	defer repo.ejemplares.mu.RUnlock()
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	for _, p := range repo.prestamos {
+		if p.SocioID != socioID {
+			continue
+		}
+
+		vivo, err := repo.conLibro(ctx, &p)
+		if err != nil || vivo {
+			return vivo, err
+		}
+	}
+
+	return false, nil
+}
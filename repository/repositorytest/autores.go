@@ -0,0 +1,433 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// AutoresFactory devuelve los dos repos VACIOS y conectados entre si: el de autores tiene que
+// ver los libros del de libros (y actualizar su campo autor).
+type AutoresFactory func(t *testing.T) (repository.LibrosRepository, repository.AutoresRepository)
+
+// RunAutores es la especificacion de repository.AutoresRepository.
+func RunAutores(t *testing.T, newRepos AutoresFactory) {
+	t.Run("CRUD", func(t *testing.T) { testAutoresCRUD(t, newRepos) })
+	t.Run("Duplicado", func(t *testing.T) { testAutoresDuplicado(t, newRepos) })
+	t.Run("GetAll", func(t *testing.T) { testAutoresGetAll(t, newRepos) })
+	t.Run("SetLibroAutores", func(t *testing.T) { testSetLibroAutores(t, newRepos) })
+	t.Run("SetLibroAutores/Errores", func(t *testing.T) { testSetLibroAutoresErrores(t, newRepos) })
+	t.Run("GetLibros", func(t *testing.T) { testAutorGetLibros(t, newRepos) })
+	t.Run("Renombrar", func(t *testing.T) { testAutorRenombrar(t, newRepos) })
+	t.Run("DeleteConLibros", func(t *testing.T) { testAutorDeleteConLibros(t, newRepos) })
+	t.Run("DesdeTexto", func(t *testing.T) { testAutoresDesdeTexto(t, newRepos) })
+}
+
+func testAutoresCRUD(t *testing.T, newRepos AutoresFactory) {
+	_, repo := newRepos(t)
+	ctx := context.Background()
+
+	creado, err := repo.Create(ctx, models.AutorInput{Nombre: "Ursula K. Le Guin"})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if creado.ID == 0 || creado.Nombre != "Ursula K. Le Guin" {
+		t.Fatalf("autor creado incorrecto: %+v", creado)
+	}
+
+	leido, err := repo.GetByID(ctx, creado.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *leido != *creado {
+		t.Fatalf("GetByID devolvio %+v, esperaba %+v", leido, creado)
+	}
+
+	editado, err := repo.Update(ctx, creado.ID, models.AutorInput{Nombre: "Ursula Le Guin"})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if editado.Nombre != "Ursula Le Guin" {
+		t.Fatalf("Update no cambio el nombre: %+v", editado)
+	}
+
+	if err := repo.Delete(ctx, creado.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, creado.ID); !errors.Is(err, repository.ErrAutorNotFound) {
+		t.Fatalf("esperaba ErrAutorNotFound despues de borrar, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, creado.ID, models.AutorInput{Nombre: "x"}); !errors.Is(err, repository.ErrAutorNotFound) {
+		t.Fatalf("Update: esperaba ErrAutorNotFound, vino %v", err)
+	}
+	if err := repo.Delete(ctx, creado.ID); !errors.Is(err, repository.ErrAutorNotFound) {
+		t.Fatalf("Delete: esperaba ErrAutorNotFound, vino %v", err)
+	}
+}
+
+func testAutoresDuplicado(t *testing.T, newRepos AutoresFactory) {
+	_, repo := newRepos(t)
+	ctx := context.Background()
+
+	a, err := repo.Create(ctx, models.AutorInput{Nombre: "Fiódor Dostoievski"})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	b, err := repo.Create(ctx, models.AutorInput{Nombre: "León Tolstói"})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// mismo nombre con otras mayusculas y sin tildes
+	if _, err := repo.Create(ctx, models.AutorInput{Nombre: "fiodor dostoievski"}); !errors.Is(err, repository.ErrAutorDuplicado) {
+		t.Fatalf("Create: esperaba ErrAutorDuplicado, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, b.ID, models.AutorInput{Nombre: "FIODOR DOSTOIEVSKI"}); !errors.Is(err, repository.ErrAutorDuplicado) {
+		t.Fatalf("Update: esperaba ErrAutorDuplicado, vino %v", err)
+	}
+
+	// cambiarse el nombre a si mismo no es un duplicado
+	if _, err := repo.Update(ctx, a.ID, models.AutorInput{Nombre: "Fiodor Dostoievski"}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+func testAutoresGetAll(t *testing.T, newRepos AutoresFactory) {
+	_, repo := newRepos(t)
+	ids := loadAutores(t, repo, "George Orwell", "Aldous Huxley", "Ray Bradbury", "George R. R. Martin")
+
+	tests := []struct {
+		name   string
+		filter models.AutorFilter
+		want   []int
+	}{
+		{"todos", models.AutorFilter{Limit: 10}, []int{0, 1, 2, 3}},
+		{"por nombre", models.AutorFilter{Nombre: ptr("GEORGE"), Limit: 10}, []int{0, 3}},
+		{"limit y offset", models.AutorFilter{Limit: 2, Offset: 1}, []int{1, 2}},
+		{"offset fuera de rango", models.AutorFilter{Limit: 2, Offset: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			autores, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			assertAutores(t, autores, pick(ids, tt.want))
+		})
+	}
+}
+
+func testSetLibroAutores(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	autores := loadAutores(t, repo, "Terry Pratchett", "Neil Gaiman")
+	libro, err := libros.Create(ctx, models.LibroInput{Titulo: "Buenos presagios", Autor: "Pratchett y Gaiman", Ano: 1990})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// desde el texto: por "y" no se corta, queda un solo autor
+	got, err := repo.GetByLibro(ctx, libro.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(got) != 1 || got[0].Nombre != "Pratchett y Gaiman" {
+		t.Fatalf("esperaba un autor \"Pratchett y Gaiman\", vinieron %+v", got)
+	}
+
+	// el orden es el que se manda, no el de los ids
+	set, err := repo.SetLibroAutores(ctx, libro.ID, []int{autores[1], autores[0]})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutores(t, set, []int{autores[1], autores[0]})
+
+	got, err = repo.GetByLibro(ctx, libro.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutores(t, got, []int{autores[1], autores[0]})
+
+	assertDisplay(t, libros, libro.ID, "Neil Gaiman, Terry Pratchett")
+
+	// reemplaza la lista completa
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, []int{autores[0]}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	got, err = repo.GetByLibro(ctx, libro.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutores(t, got, []int{autores[0]})
+	assertDisplay(t, libros, libro.ID, "Terry Pratchett")
+}
+
+func testSetLibroAutoresErrores(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	autores := loadAutores(t, repo, "Isaac Asimov")
+	libro, err := libros.Create(ctx, models.LibroInput{Titulo: "Fundación", Autor: "Isaac Asimov", Ano: 1951})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, autores); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.SetLibroAutores(ctx, 999999, autores); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("libro inexistente: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.GetByLibro(ctx, 999999); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByLibro de libro inexistente: esperaba ErrNotFound, vino %v", err)
+	}
+
+	// un autor inexistente no deja la lista a medias
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, []int{autores[0], 999999}); !errors.Is(err, repository.ErrAutorNotFound) {
+		t.Fatalf("autor inexistente: esperaba ErrAutorNotFound, vino %v", err)
+	}
+	got, err := repo.GetByLibro(ctx, libro.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutores(t, got, autores)
+	assertDisplay(t, libros, libro.ID, "Isaac Asimov")
+}
+
+func testAutorGetLibros(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	// los libros de seed ya traen sus autores desde el texto
+	ids := load(t, libros)
+	orwell := autorPorNombre(t, repo, "George Orwell")
+
+	got, err := repo.GetLibros(ctx, orwell)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, got, pick(ids, []int{1, 2}))
+
+	// un libro borrado deja de aparecer
+	if err := libros.Delete(ctx, ids[1]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	got, err = repo.GetLibros(ctx, orwell)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, got, pick(ids, []int{2}))
+
+	if _, err := repo.GetLibros(ctx, 999999); !errors.Is(err, repository.ErrAutorNotFound) {
+		t.Fatalf("autor inexistente: esperaba ErrAutorNotFound, vino %v", err)
+	}
+}
+
+// testAutorRenombrar: corregir el nombre de un autor corrige el texto de todos sus libros
+func testAutorRenombrar(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	autores := loadAutores(t, repo, "Robert Martin", "Micah Martin")
+	libro, err := libros.Create(ctx, models.LibroInput{Titulo: "Agile Principles", Autor: "x", Ano: 2006})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, autores); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.Update(ctx, autores[0], models.AutorInput{Nombre: "Robert C. Martin"}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	assertDisplay(t, libros, libro.ID, "Robert C. Martin, Micah Martin")
+
+	// el libro cambio dos veces (al cargarle los autores y al renombrar uno) y las dos quedan en el historial
+	revs, err := libros.Historial(ctx, libro.ID, models.RevisionFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(revs) != 3 || revs[0].Operacion != models.RevisionModificar || revs[0].Antes.Autor != "Robert Martin, Micah Martin" {
+		t.Fatalf("esperaba la revision del renombre sobre la de SetLibroAutores, vino %+v", revs)
+	}
+}
+
+func testAutorDeleteConLibros(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	autor := loadAutores(t, repo, "Frank Herbert")[0]
+	libro, err := libros.Create(ctx, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, []int{autor}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if err := repo.Delete(ctx, autor); !errors.Is(err, repository.ErrAutorConLibros) {
+		t.Fatalf("esperaba ErrAutorConLibros, vino %v", err)
+	}
+
+	// con el libro en la papelera todavia no: se podria restaurar
+	if err := libros.Delete(ctx, libro.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := repo.Delete(ctx, autor); !errors.Is(err, repository.ErrAutorConLibros) {
+		t.Fatalf("con el libro en la papelera: esperaba ErrAutorConLibros, vino %v", err)
+	}
+
+	// purgado el libro ya se puede borrar
+	if _, err := libros.Purgar(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := repo.Delete(ctx, autor); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+// testAutoresDesdeTexto: cada escritura de libros.autor (alta, PUT, PATCH, batch, revertir) rearma los
+// autores del libro, asi que renombrar un autor despues no pisa lo que se edito a mano
+func testAutoresDesdeTexto(t *testing.T, newRepos AutoresFactory) {
+	libros, repo := newRepos(t)
+	ctx := context.Background()
+
+	gaiman := loadAutores(t, repo, "Neil Gaiman")[0]
+
+	libro, err := libros.Create(ctx, models.LibroInput{Titulo: "Buenos presagios", Autor: "Terry Pratchett, neil gaiman", Ano: 1990})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	pratchett := autorPorNombre(t, repo, "Terry Pratchett")
+	assertAutoresDe(t, repo, libro.ID, []int{pratchett, gaiman})
+
+	// PATCH: el texto editado a mano manda
+	gaimanSolo := "Neil Gaiman"
+	if _, err := libros.Patch(ctx, libro.ID, models.LibroPatch{Autor: &gaimanSolo}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{gaiman})
+
+	// renombrar ya no vuelve a poner a Pratchett
+	if _, err := repo.Update(ctx, gaiman, models.AutorInput{Nombre: "Neil R. Gaiman"}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertDisplay(t, libros, libro.ID, "Neil R. Gaiman")
+
+	// PUT y batch
+	if _, err := libros.Update(ctx, libro.ID, models.LibroInput{Titulo: "Buenos presagios", Autor: "Neil R. Gaiman & Terry Pratchett", Ano: 1990}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{gaiman, pratchett})
+
+	pratchettSolo := "Terry Pratchett"
+	if _, err := libros.Batch(ctx, []models.LibroOp{{Op: models.RevisionModificar, ID: libro.ID, Libro: &models.LibroPatch{Autor: &pratchettSolo}}}, true); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{pratchett})
+
+	// un batch atomico que falla no cambia los autores
+	fallido := []models.LibroOp{
+		{Op: models.RevisionModificar, ID: libro.ID, Libro: &models.LibroPatch{Autor: &gaimanSolo}},
+		{Op: models.RevisionBorrar, ID: 999999},
+	}
+	if _, err := libros.Batch(ctx, fallido, true); err == nil {
+		t.Fatalf("esperaba un error del batch")
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{pratchett})
+
+	// revertir a la del PUT (alta, PATCH, renombre, PUT) vuelve a los dos
+	if _, err := libros.Revertir(ctx, libro.ID, 4, 0); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{gaiman, pratchett})
+
+	// un nombre con coma cargado con SetLibroAutores no se parte cuando se vuelve a escribir el mismo texto
+	tolkien, err := repo.Create(ctx, models.AutorInput{Nombre: "Tolkien, J. R. R."})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.SetLibroAutores(ctx, libro.ID, []int{tolkien.ID}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	mismo := "Tolkien, J. R. R."
+	if _, err := libros.Patch(ctx, libro.ID, models.LibroPatch{Autor: &mismo}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutoresDe(t, repo, libro.ID, []int{tolkien.ID})
+}
+
+func loadAutores(t *testing.T, repo repository.AutoresRepository, nombres ...string) []int {
+	t.Helper()
+
+	ids := make([]int, 0, len(nombres))
+	for _, n := range nombres {
+		a, err := repo.Create(context.Background(), models.AutorInput{Nombre: n})
+		if err != nil {
+			t.Fatalf("error cargando autores: %v", err)
+		}
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+func assertAutores(t *testing.T, autores []models.Autor, want []int) {
+	t.Helper()
+
+	if autores == nil {
+		t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(autores) != len(want) {
+		t.Fatalf("esperaba autores %v, vinieron %+v", want, autores)
+	}
+
+	for i, a := range autores {
+		if a.ID != want[i] {
+			t.Fatalf("posicion %d: esperaba autor %d, vino %d (esperados %v)", i, want[i], a.ID, want)
+		}
+	}
+}
+
+func assertDisplay(t *testing.T, libros repository.LibrosRepository, id int, want string) {
+	t.Helper()
+
+	l, err := libros.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if l.Autor != want {
+		t.Fatalf("libros.autor esperado %q, vino %q", want, l.Autor)
+	}
+}
+
+// autorPorNombre es el id del unico autor con ese nombre
+func autorPorNombre(t *testing.T, repo repository.AutoresRepository, nombre string) int {
+	t.Helper()
+
+	autores, err := repo.GetAll(context.Background(), models.AutorFilter{Nombre: &nombre, Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(autores) != 1 {
+		t.Fatalf("esperaba un autor %q, vinieron %+v", nombre, autores)
+	}
+	return autores[0].ID
+}
+
+func assertAutoresDe(t *testing.T, repo repository.AutoresRepository, libroID int, want []int) {
+	t.Helper()
+
+	got, err := repo.GetByLibro(context.Background(), libroID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertAutores(t, got, want)
+}
@@ -0,0 +1,276 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// EjemplaresFactory devuelve los dos repos VACIOS, con el de ejemplares apoyado en el de libros.
+type EjemplaresFactory func(t *testing.T) (repository.LibrosRepository, repository.EjemplaresRepository)
+
+// RunEjemplares es la especificacion de repository.EjemplaresRepository.
+func RunEjemplares(t *testing.T, newRepos EjemplaresFactory) {
+	t.Run("CRUD", func(t *testing.T) { testEjemplaresCRUD(t, newRepos) })
+	t.Run("CodigoDuplicado", func(t *testing.T) { testEjemplaresCodigoDuplicado(t, newRepos) })
+	t.Run("OtroLibro", func(t *testing.T) { testEjemplaresOtroLibro(t, newRepos) })
+	t.Run("LibroInexistente", func(t *testing.T) { testEjemplaresLibroInexistente(t, newRepos) })
+	t.Run("Disponibilidad", func(t *testing.T) { testEjemplaresDisponibilidad(t, newRepos) })
+	t.Run("BorrarLibro", func(t *testing.T) { testEjemplaresBorrarLibro(t, newRepos) })
+}
+
+func ejemplarInput(codigo string) models.EjemplarInput {
+	return models.EjemplarInput{
+		CodigoBarras:     codigo,
+		Ubicacion:        "A-1",
+		Estado:           models.EstadoBueno,
+		FechaAdquisicion: models.NewFecha(2020, time.March, 15),
+	}
+}
+
+func testEjemplaresCRUD(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	in := ejemplarInput("BC-0001")
+	creado, err := repo.Create(ctx, ids[0], in, hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	want := models.Ejemplar{ID: creado.ID, LibroID: ids[0], CodigoBarras: in.CodigoBarras, Ubicacion: in.Ubicacion, Estado: in.Estado, FechaAdquisicion: in.FechaAdquisicion}
+	if creado.ID == 0 || *creado != want {
+		t.Fatalf("Create devolvio %+v, esperaba %+v", creado, want)
+	}
+
+	leido, err := repo.GetByID(ctx, ids[0], creado.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *leido != *creado {
+		t.Fatalf("GetByID devolvio %+v, esperaba %+v", leido, creado)
+	}
+
+	otro, err := repo.Create(ctx, ids[0], ejemplarInput("BC-0002"), hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	lista, err := repo.GetByLibro(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertEjemplares(t, lista, []int{creado.ID, otro.ID})
+
+	// un libro sin ejemplares devuelve [] y no nil
+	vacia, err := repo.GetByLibro(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertEjemplares(t, vacia, []int{})
+
+	upd := models.EjemplarInput{CodigoBarras: "BC-0001", Ubicacion: "Z-9", Estado: models.EstadoMalo, FechaAdquisicion: models.NewFecha(2019, time.January, 2)}
+	editado, err := repo.Update(ctx, ids[0], creado.ID, upd)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if editado.Ubicacion != "Z-9" || editado.Estado != models.EstadoMalo || editado.FechaAdquisicion != upd.FechaAdquisicion {
+		t.Fatalf("Update devolvio %+v, esperaba los datos de %+v", editado, upd)
+	}
+
+	if err := repo.Delete(ctx, ids[0], creado.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, ids[0], creado.ID); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("GetByID despues de Delete: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+	if err := repo.Delete(ctx, ids[0], creado.ID); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("Delete dos veces: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+}
+
+func testEjemplaresCodigoDuplicado(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	a, err := repo.Create(ctx, ids[0], ejemplarInput("BC-0001"), hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	b, err := repo.Create(ctx, ids[1], ejemplarInput("BC-0002"), hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// el codigo es unico en toda la biblioteca, no por libro
+	if _, err := repo.Create(ctx, ids[1], ejemplarInput("BC-0001"), hoy.AddDays(3)); !errors.Is(err, repository.ErrCodigoBarrasDuplicado) {
+		t.Fatalf("Create: esperaba ErrCodigoBarrasDuplicado, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, ids[1], b.ID, ejemplarInput("BC-0001")); !errors.Is(err, repository.ErrCodigoBarrasDuplicado) {
+		t.Fatalf("Update: esperaba ErrCodigoBarrasDuplicado, vino %v", err)
+	}
+
+	// guardar con su propio codigo no es un duplicado
+	if _, err := repo.Update(ctx, ids[0], a.ID, ejemplarInput("BC-0001")); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+func testEjemplaresOtroLibro(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	e, err := repo.Create(ctx, ids[0], ejemplarInput("BC-0001"), hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// el ejemplar existe pero es de otro libro
+	if _, err := repo.GetByID(ctx, ids[1], e.ID); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("GetByID: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, ids[1], e.ID, ejemplarInput("BC-0001")); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("Update: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+	if err := repo.Delete(ctx, ids[1], e.ID); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("Delete: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, ids[0], e.ID); err != nil {
+		t.Fatalf("el ejemplar no tenia que tocarse: %v", err)
+	}
+}
+
+func testEjemplaresLibroInexistente(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	missing := ids[len(ids)-1] + 1000
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetByLibro", func() error {
+			_, err := repo.GetByLibro(ctx, missing)
+			return err
+		}},
+		{"GetByID", func() error {
+			_, err := repo.GetByID(ctx, missing, 1)
+			return err
+		}},
+		{"Create", func() error {
+			_, err := repo.Create(ctx, missing, ejemplarInput("BC-0001"), hoy.AddDays(3))
+			return err
+		}},
+		{"Update", func() error {
+			_, err := repo.Update(ctx, missing, 1, ejemplarInput("BC-0001"))
+			return err
+		}},
+		{"Delete", func() error {
+			return repo.Delete(ctx, missing, 1)
+		}},
+		{"Disponibilidad", func() error {
+			_, err := repo.Disponibilidad(ctx, missing)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, repository.ErrNotFound) {
+				t.Fatalf("esperaba ErrNotFound, vino %v", err)
+			}
+		})
+	}
+}
+
+func testEjemplaresDisponibilidad(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	for _, codigo := range []string{"BC-0001", "BC-0002", "BC-0003"} {
+		if _, err := repo.Create(ctx, ids[0], ejemplarInput(codigo), hoy.AddDays(3)); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+	}
+
+	got, err := repo.Disponibilidad(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if want := (models.Disponibilidad{Total: 3, Disponibles: 3}); got != want {
+		t.Fatalf("Disponibilidad devolvio %+v, esperaba %+v", got, want)
+	}
+
+	got, err = repo.Disponibilidad(ctx, ids[1])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if got != (models.Disponibilidad{}) {
+		t.Fatalf("un libro sin ejemplares tiene que dar todo 0, vino %+v", got)
+	}
+}
+
+// testEjemplaresBorrarLibro: con el libro en la papelera sus ejemplares no se ven pero siguen
+// ocupando el codigo; al purgarlo se van con el libro y el codigo queda libre
+func testEjemplaresBorrarLibro(t *testing.T, newRepos EjemplaresFactory) {
+	libros, repo := newRepos(t)
+	ids := load(t, libros)
+	ctx := context.Background()
+
+	creado, err := repo.Create(ctx, ids[0], ejemplarInput("BC-0001"), hoy.AddDays(3))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if err := libros.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.GetByLibro(ctx, ids[0]); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByLibro con el libro en la papelera: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.GetByID(ctx, ids[0], creado.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByID con el libro en la papelera: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.Create(ctx, ids[0], ejemplarInput("BC-0002"), hoy.AddDays(3)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Create con el libro en la papelera: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.Create(ctx, ids[1], ejemplarInput("BC-0001"), hoy.AddDays(3)); !errors.Is(err, repository.ErrCodigoBarrasDuplicado) {
+		t.Fatalf("el codigo sigue ocupado mientras el libro esta en la papelera: esperaba ErrCodigoBarrasDuplicado, vino %v", err)
+	}
+
+	if _, err := libros.Purgar(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.Create(ctx, ids[1], ejemplarInput("BC-0001"), hoy.AddDays(3)); err != nil {
+		t.Fatalf("el codigo de un libro borrado tiene que quedar libre: %v", err)
+	}
+}
+
+func assertEjemplares(t *testing.T, ejemplares []models.Ejemplar, want []int) {
+	t.Helper()
+
+	if ejemplares == nil {
+		t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(ejemplares) != len(want) {
+		t.Fatalf("esperaba ejemplares %v, vinieron %+v", want, ejemplares)
+	}
+
+	for i, e := range ejemplares {
+		if e.ID != want[i] {
+			t.Fatalf("posicion %d: esperaba ejemplar %d, vino %d (esperados %v)", i, want[i], e.ID, want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// RunMultas es la especificacion de repository.MultasRepository y de como las multas cortan los prestamos.
+func RunMultas(t *testing.T, newRepos CirculacionFactory) {
+	t.Run("Calcular", func(t *testing.T) { testMultasCalcular(t, newRepos) })
+	t.Run("Tope", func(t *testing.T) { testMultasTope(t, newRepos) })
+	t.Run("Movimientos", func(t *testing.T) { testMultasMovimientos(t, newRepos) })
+	t.Run("PagosConcurrentes", func(t *testing.T) { testMultasPagosConcurrentes(t, newRepos) })
+	t.Run("Bloqueo", func(t *testing.T) { testMultasBloqueo(t, newRepos) })
+	t.Run("Borrar", func(t *testing.T) { testMultasBorrar(t, newRepos) })
+}
+
+// politicaMulta: $1 por dia, 2 dias de gracia, tope de $10 y un feriado 17 dias despues de hoy
+// (3 despues del vencimiento de un prestamo de hoy)
+var politicaMulta = models.PoliticaMulta{PorDia: 100, DiasGracia: 2, Tope: 1000, Feriados: []models.Fecha{hoy.AddDays(17)}}
+
+func (c circulacion) calcular(t *testing.T, dia models.Fecha) []models.Multa {
+	t.Helper()
+
+	cambiadas, err := c.Multas.Calcular(context.Background(), dia, politicaMulta)
+	if err != nil {
+		t.Fatalf("error calculando multas: %v", err)
+	}
+	return cambiadas
+}
+
+// conDeuda deja al primer socio debiendo $3 por un prestamo que devolvio 20 dias despues de hoy
+func conDeuda(t *testing.T, newRepos CirculacionFactory) (circulacion, *models.Prestamo) {
+	t.Helper()
+
+	c := loadCirculacion(t, newRepos)
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	if _, err := c.Prestamos.Devolver(context.Background(), p.ID, devolucion(hoy.AddDays(20))); err != nil {
+		t.Fatalf("error devolviendo: %v", err)
+	}
+	if got := c.calcular(t, hoy.AddDays(20)); len(got) != 1 || got[0].Monto != 300 {
+		t.Fatalf("esperaba un cargo de 300, vino %+v", got)
+	}
+
+	return c, p
+}
+
+func testMultasCalcular(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+	c.prestar(t, c.ejemplares[2], c.socios[1], hoy.AddDays(10)) // vence despues, no debe nada todavia
+
+	tests := []struct {
+		name string
+		dia  models.Fecha
+		want []models.Multa // los cargos que cambiaron ese dia
+	}{
+		{"el dia que vence", hoy.AddDays(14), []models.Multa{}},
+		{"dentro de la gracia", hoy.AddDays(16), []models.Multa{}},
+		// 15, 16, 17 (feriado) y 18: 3 dias de atraso, 1 cobrado
+		{"primer dia cobrado", hoy.AddDays(18), []models.Multa{{SocioID: c.socios[0], PrestamoID: p.ID, Tipo: models.MultaCargo, Monto: 100, Dias: 1, Fecha: hoy.AddDays(18)}}},
+		{"el mismo dia otra vez", hoy.AddDays(18), []models.Multa{}},
+		{"sigue sumando", hoy.AddDays(20), []models.Multa{{SocioID: c.socios[0], PrestamoID: p.ID, Tipo: models.MultaCargo, Monto: 300, Dias: 3, Fecha: hoy.AddDays(20)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertCargos(t, c.calcular(t, tt.dia), tt.want)
+		})
+	}
+
+	// devuelto el 21: el cargo queda final con 4 dias cobrados
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy.AddDays(21))); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertCargos(t, c.calcular(t, hoy.AddDays(23)), []models.Multa{
+		{SocioID: c.socios[0], PrestamoID: p.ID, Tipo: models.MultaCargo, Monto: 400, Dias: 4, Fecha: hoy.AddDays(23), Final: true},
+	})
+
+	// mucho despues solo cambia el otro prestamo (vencio el 24), el final ya no se toca
+	got := c.calcular(t, hoy.AddDays(40))
+	if len(got) != 1 || got[0].PrestamoID == p.ID {
+		t.Fatalf("esperaba solo el cargo del otro prestamo, vino %+v", got)
+	}
+
+	got, err := c.Multas.GetBySocio(ctx, c.socios[0], models.MultaFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(got) != 1 || got[0].Monto != 400 || !got[0].Final {
+		t.Fatalf("esperaba un solo cargo final de 400, vino %+v", got)
+	}
+}
+
+func testMultasTope(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	assertCargos(t, c.calcular(t, hoy.AddDays(100)), []models.Multa{
+		{SocioID: c.socios[0], PrestamoID: p.ID, Tipo: models.MultaCargo, Monto: 1000, Dias: 83, Fecha: hoy.AddDays(100)},
+	})
+
+	// pasado el tope los dias siguen contando, el monto no
+	got := c.calcular(t, hoy.AddDays(101))
+	if len(got) != 1 || got[0].Monto != 1000 || got[0].Dias != 84 {
+		t.Fatalf("esperaba el cargo en el tope con 84 dias, vino %+v", got)
+	}
+}
+
+func testMultasMovimientos(t *testing.T, newRepos CirculacionFactory) {
+	c, _ := conDeuda(t, newRepos)
+	ctx := context.Background()
+
+	pago := models.NuevoMovimiento{SocioID: c.socios[0], Tipo: models.MultaPago, Monto: 100, Fecha: hoy.AddDays(21)}
+	m, err := c.Multas.Registrar(ctx, pago)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	want := models.Multa{ID: m.ID, SocioID: c.socios[0], Tipo: models.MultaPago, Monto: 100, Fecha: hoy.AddDays(21)}
+	if *m != want {
+		t.Fatalf("Registrar devolvio %+v, esperaba %+v", m, want)
+	}
+
+	tests := []struct {
+		name    string
+		nm      models.NuevoMovimiento
+		wantErr error
+	}{
+		{"pago mayor que la deuda", models.NuevoMovimiento{SocioID: c.socios[0], Tipo: models.MultaPago, Monto: 201, Fecha: hoy}, repository.ErrMontoExcedeSaldo},
+		{"socio sin deuda", models.NuevoMovimiento{SocioID: c.socios[1], Tipo: models.MultaPago, Monto: 1, Fecha: hoy}, repository.ErrMontoExcedeSaldo},
+		{"socio inexistente", models.NuevoMovimiento{SocioID: c.socios[2] + 1000, Tipo: models.MultaPago, Monto: 1, Fecha: hoy}, repository.ErrSocioNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Multas.Registrar(ctx, tt.nm); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("esperaba %v, vino %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	condonacion := models.NuevoMovimiento{SocioID: c.socios[0], Tipo: models.MultaCondonacion, Monto: 200, Fecha: hoy.AddDays(22), Nota: "primera vez"}
+	if _, err := c.Multas.Registrar(ctx, condonacion); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	s, err := c.Multas.Saldo(ctx, c.socios[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if want := (models.SaldoMultas{SocioID: c.socios[0], Cargos: 300, Pagos: 100, Condonaciones: 200}); *s != want {
+		t.Fatalf("Saldo devolvio %+v, esperaba %+v", s, want)
+	}
+
+	if _, err := c.Multas.Saldo(ctx, c.socios[2]+1000); !errors.Is(err, repository.ErrSocioNotFound) {
+		t.Fatalf("Saldo de socio inexistente: esperaba ErrSocioNotFound, vino %v", err)
+	}
+
+	pagos := models.MultaPago
+	got, err := c.Multas.GetBySocio(ctx, c.socios[0], models.MultaFilter{Tipo: &pagos, Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != m.ID {
+		t.Fatalf("esperaba solo el pago %d, vino %+v", m.ID, got)
+	}
+
+	got, err = c.Multas.GetBySocio(ctx, c.socios[0], models.MultaFilter{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(got) != 2 || got[0].Tipo != models.MultaPago || got[1].Tipo != models.MultaCondonacion {
+		t.Fatalf("esperaba el pago y la condonacion, vino %+v", got)
+	}
+
+	if _, err := c.Multas.GetBySocio(ctx, c.socios[2]+1000, models.MultaFilter{Limit: 50}); !errors.Is(err, repository.ErrSocioNotFound) {
+		t.Fatalf("GetBySocio de socio inexistente: esperaba ErrSocioNotFound, vino %v", err)
+	}
+}
+
+// testMultasPagosConcurrentes: con una deuda de $3, de diez pagos de $1 simultaneos pasan exactamente tres
+func testMultasPagosConcurrentes(t *testing.T, newRepos CirculacionFactory) {
+	c, _ := conDeuda(t, newRepos)
+	ctx := context.Background()
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Multas.Registrar(ctx, models.NuevoMovimiento{SocioID: c.socios[0], Tipo: models.MultaPago, Monto: 100, Fecha: hoy})
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	ok := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, repository.ErrMontoExcedeSaldo):
+			t.Fatalf("esperaba ErrMontoExcedeSaldo, vino %v", err)
+		}
+	}
+	if ok != 3 {
+		t.Fatalf("esperaba exactamente 3 pagos, pasaron %d", ok)
+	}
+
+	s, err := c.Multas.Saldo(ctx, c.socios[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if s.Saldo != 0 {
+		t.Fatalf("esperaba saldo 0, vino %+v", s)
+	}
+}
+
+func testMultasBloqueo(t *testing.T, newRepos CirculacionFactory) {
+	c, _ := conDeuda(t, newRepos)
+	ctx := context.Background()
+
+	np := nuevoPrestamo(c.ejemplares[1], c.socios[0], hoy.AddDays(21))
+
+	np.DeudaMaxima = 299
+	if _, err := c.Prestamos.Prestar(ctx, np); !errors.Is(err, repository.ErrSocioBloqueado) {
+		t.Fatalf("debiendo mas que el maximo: esperaba ErrSocioBloqueado, vino %v", err)
+	}
+
+	// otro socio sin deuda si puede
+	otro := nuevoPrestamo(c.ejemplares[2], c.socios[1], hoy.AddDays(21))
+	if _, err := c.Prestamos.Prestar(ctx, otro); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// debiendo justo el maximo todavia puede
+	np.DeudaMaxima = 300
+	if _, err := c.Prestamos.Prestar(ctx, np); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+// testMultasBorrar: la cuenta sobrevive al prestamo (se purgo el libro) y no deja borrar al socio
+func testMultasBorrar(t *testing.T, newRepos CirculacionFactory) {
+	c, _ := conDeuda(t, newRepos)
+	ctx := context.Background()
+
+	purgar(t, c.Libros, c.libros[0])
+
+	got, err := c.Multas.GetBySocio(ctx, c.socios[0], models.MultaFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(got) != 1 || got[0].Monto != 300 || got[0].PrestamoID != 0 {
+		t.Fatalf("esperaba el cargo de 300 sin prestamo, vino %+v", got)
+	}
+
+	if err := c.Socios.Delete(ctx, c.socios[0]); !errors.Is(err, repository.ErrSocioConMultas) {
+		t.Fatalf("esperaba ErrSocioConMultas, vino %v", err)
+	}
+}
+
+// assertCargos compara sin mirar los ids
+func assertCargos(t *testing.T, got, want []models.Multa) {
+	t.Helper()
+
+	if got == nil {
+		t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("esperaba cargos %+v, vinieron %+v", want, got)
+	}
+	for i := range got {
+		m := got[i]
+		m.ID = 0
+		if m != want[i] {
+			t.Fatalf("esperaba cargos %+v, vinieron %+v", want, got)
+		}
+	}
+}
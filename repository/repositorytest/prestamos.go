@@ -0,0 +1,461 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Circulacion son los repos que intervienen en un prestamo, todos VACIOS y conectados entre si.
+type Circulacion struct {
+	Libros     repository.LibrosRepository
+	Ejemplares repository.EjemplaresRepository
+	Socios     repository.SociosRepository
+	Prestamos  repository.PrestamosRepository
+	Reservas   repository.ReservasRepository
+	Multas     repository.MultasRepository
+}
+
+type CirculacionFactory func(t *testing.T) Circulacion
+
+// RunPrestamos es la especificacion de repository.PrestamosRepository y de como los prestamos
+// afectan a ejemplares y socios.
+func RunPrestamos(t *testing.T, newRepos CirculacionFactory) {
+	t.Run("Prestar", func(t *testing.T) { testPrestamosPrestar(t, newRepos) })
+	t.Run("Prestar/Errores", func(t *testing.T) { testPrestamosPrestarErrores(t, newRepos) })
+	t.Run("Concurrencia", func(t *testing.T) { testPrestamosConcurrencia(t, newRepos) })
+	t.Run("Devolver", func(t *testing.T) { testPrestamosDevolver(t, newRepos) })
+	t.Run("Renovar", func(t *testing.T) { testPrestamosRenovar(t, newRepos) })
+	t.Run("GetAll", func(t *testing.T) { testPrestamosGetAll(t, newRepos) })
+	t.Run("Disponibilidad", func(t *testing.T) { testPrestamosDisponibilidad(t, newRepos) })
+	t.Run("BorrarEjemplarPrestado", func(t *testing.T) { testPrestamosBorrarEjemplar(t, newRepos) })
+	t.Run("BorrarSocio", func(t *testing.T) { testPrestamosBorrarSocio(t, newRepos) })
+	t.Run("BorrarLibro", func(t *testing.T) { testPrestamosBorrarLibro(t, newRepos) })
+}
+
+var hoy = models.NewFecha(2024, time.March, 1)
+
+// circulacion es lo que dejan cargado los tests: libros de seed, dos ejemplares del primer libro,
+// uno del segundo y los socios de loadSocios
+type circulacion struct {
+	Circulacion
+	libros     []int
+	ejemplares []int
+	socios     []int
+}
+
+func loadCirculacion(t *testing.T, newRepos CirculacionFactory) circulacion {
+	t.Helper()
+
+	c := circulacion{Circulacion: newRepos(t)}
+	c.libros = load(t, c.Libros)
+	c.socios = loadSocios(t, c.Socios)
+
+	for i, libro := range []int{c.libros[0], c.libros[0], c.libros[1]} {
+		e, err := c.Ejemplares.Create(context.Background(), libro, ejemplarInput(string(rune('A'+i))+"-0001"), hoy.AddDays(3))
+		if err != nil {
+			t.Fatalf("error cargando ejemplares: %v", err)
+		}
+		c.ejemplares = append(c.ejemplares, e.ID)
+	}
+
+	return c
+}
+
+func nuevoPrestamo(ejemplar, socio int, desde models.Fecha) models.NuevoPrestamo {
+	return models.NuevoPrestamo{EjemplarID: ejemplar, SocioID: socio, Desde: desde, Vence: desde.AddDays(14), RetirarHasta: desde.AddDays(3)}
+}
+
+// devolucion devuelve en la fecha f; si el ejemplar queda apartado para una reserva, se retira hasta 3 dias despues
+func devolucion(f models.Fecha) models.Devolucion {
+	return models.Devolucion{Fecha: f, RetirarHasta: f.AddDays(3)}
+}
+
+func (c circulacion) prestar(t *testing.T, ejemplar, socio int, desde models.Fecha) *models.Prestamo {
+	t.Helper()
+
+	p, err := c.Prestamos.Prestar(context.Background(), nuevoPrestamo(ejemplar, socio, desde))
+	if err != nil {
+		t.Fatalf("error prestando: %v", err)
+	}
+	return p
+}
+
+func testPrestamosPrestar(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	np := nuevoPrestamo(c.ejemplares[0], c.socios[0], hoy)
+	p, err := c.Prestamos.Prestar(ctx, np)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	want := models.Prestamo{ID: p.ID, EjemplarID: np.EjemplarID, LibroID: c.libros[0], SocioID: np.SocioID, Desde: np.Desde, Vence: np.Vence}
+	if p.ID == 0 || *p != want {
+		t.Fatalf("Prestar devolvio %+v, esperaba %+v", p, want)
+	}
+	if !p.Activo() {
+		t.Fatalf("un prestamo nuevo tiene que estar activo: %+v", p)
+	}
+
+	leido, err := c.Prestamos.GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *leido != *p {
+		t.Fatalf("GetByID devolvio %+v, esperaba %+v", leido, p)
+	}
+
+	if _, err := c.Prestamos.GetByID(ctx, p.ID+1000); !errors.Is(err, repository.ErrPrestamoNotFound) {
+		t.Fatalf("GetByID inexistente: esperaba ErrPrestamoNotFound, vino %v", err)
+	}
+}
+
+func testPrestamosPrestarErrores(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	tests := []struct {
+		name    string
+		np      models.NuevoPrestamo
+		wantErr error
+	}{
+		{"ya prestado", nuevoPrestamo(c.ejemplares[0], c.socios[1], hoy), repository.ErrEjemplarPrestado},
+		{"ya prestado al mismo socio", nuevoPrestamo(c.ejemplares[0], c.socios[0], hoy), repository.ErrEjemplarPrestado},
+		{"ejemplar inexistente", nuevoPrestamo(c.ejemplares[2]+1000, c.socios[0], hoy), repository.ErrEjemplarNotFound},
+		{"socio inexistente", nuevoPrestamo(c.ejemplares[1], c.socios[2]+1000, hoy), repository.ErrSocioNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Prestamos.Prestar(ctx, tt.np); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("esperaba %v, vino %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// otro ejemplar del mismo libro si se puede prestar
+	c.prestar(t, c.ejemplares[1], c.socios[1], hoy)
+}
+
+// testPrestamosConcurrencia: muchos pedidos a la vez por el mismo ejemplar, exactamente uno lo consigue
+func testPrestamosConcurrencia(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	const n = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Prestamos.Prestar(ctx, nuevoPrestamo(c.ejemplares[0], c.socios[i%len(c.socios)], hoy))
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	ok := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, repository.ErrEjemplarPrestado):
+			t.Fatalf("esperaba ErrEjemplarPrestado, vino %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("esperaba exactamente 1 prestamo, salieron %d", ok)
+	}
+
+	activos, err := c.Prestamos.GetAll(ctx, models.PrestamoFilter{EjemplarID: &c.ejemplares[0], Activos: true, Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(activos) != 1 {
+		t.Fatalf("esperaba 1 prestamo activo, hay %+v", activos)
+	}
+}
+
+func testPrestamosDevolver(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	devuelto, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy.AddDays(3)))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if devuelto.Activo() || devuelto.Devuelto != hoy.AddDays(3) {
+		t.Fatalf("Devolver devolvio %+v, esperaba devuelto el %v", devuelto, hoy.AddDays(3))
+	}
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy.AddDays(4))); !errors.Is(err, repository.ErrPrestamoDevuelto) {
+		t.Fatalf("Devolver dos veces: esperaba ErrPrestamoDevuelto, vino %v", err)
+	}
+	if _, err := c.Prestamos.Devolver(ctx, p.ID+1000, devolucion(hoy)); !errors.Is(err, repository.ErrPrestamoNotFound) {
+		t.Fatalf("Devolver inexistente: esperaba ErrPrestamoNotFound, vino %v", err)
+	}
+
+	// devuelto, el ejemplar se puede volver a prestar
+	c.prestar(t, c.ejemplares[0], c.socios[1], hoy.AddDays(3))
+}
+
+func testPrestamosRenovar(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+	renovacion := func(dia int) models.Renovacion {
+		d := hoy.AddDays(dia)
+		return models.Renovacion{Hoy: d, Hasta: d.AddDays(14), MaxRenovaciones: 2}
+	}
+
+	r, err := c.Prestamos.Renovar(ctx, p.ID, renovacion(10))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if r.Vence != hoy.AddDays(24) || r.Renovaciones != 1 {
+		t.Fatalf("Renovar devolvio %+v, esperaba vence %v y 1 renovacion", r, hoy.AddDays(24))
+	}
+
+	// el dia del vencimiento todavia se puede renovar
+	if r, err = c.Prestamos.Renovar(ctx, p.ID, renovacion(24)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if r.Renovaciones != 2 {
+		t.Fatalf("esperaba 2 renovaciones, vino %+v", r)
+	}
+
+	if _, err := c.Prestamos.Renovar(ctx, p.ID, renovacion(30)); !errors.Is(err, repository.ErrSinRenovaciones) {
+		t.Fatalf("tercera renovacion: esperaba ErrSinRenovaciones, vino %v", err)
+	}
+
+	// lo que falla no cambia nada
+	leido, err := c.Prestamos.GetByID(ctx, p.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *leido != *r {
+		t.Fatalf("una renovacion rechazada cambio el prestamo: %+v, esperaba %+v", leido, r)
+	}
+
+	vencido := c.prestar(t, c.ejemplares[1], c.socios[1], hoy)
+	if _, err := c.Prestamos.Renovar(ctx, vencido.ID, renovacion(15)); !errors.Is(err, repository.ErrPrestamoVencido) {
+		t.Fatalf("renovar vencido: esperaba ErrPrestamoVencido, vino %v", err)
+	}
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy.AddDays(30))); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := c.Prestamos.Renovar(ctx, p.ID, renovacion(30)); !errors.Is(err, repository.ErrPrestamoDevuelto) {
+		t.Fatalf("renovar devuelto: esperaba ErrPrestamoDevuelto, vino %v", err)
+	}
+
+	if _, err := c.Prestamos.Renovar(ctx, p.ID+1000, renovacion(0)); !errors.Is(err, repository.ErrPrestamoNotFound) {
+		t.Fatalf("renovar inexistente: esperaba ErrPrestamoNotFound, vino %v", err)
+	}
+}
+
+func testPrestamosGetAll(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	// p1 vence el dia 14, p2 el 4 (se presto antes) y p3 ya se devolvio
+	p1 := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+	p2 := c.prestar(t, c.ejemplares[2], c.socios[1], hoy.AddDays(-10))
+	p3 := c.prestar(t, c.ejemplares[1], c.socios[0], hoy.AddDays(-20))
+	if _, err := c.Prestamos.Devolver(ctx, p3.ID, devolucion(hoy.AddDays(-19))); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	dia := func(n int) *models.Fecha {
+		f := hoy.AddDays(n)
+		return &f
+	}
+
+	tests := []struct {
+		name   string
+		filter models.PrestamoFilter
+		want   []int
+	}{
+		{"todos", models.PrestamoFilter{Limit: 50}, []int{p1.ID, p2.ID, p3.ID}},
+		{"por socio", models.PrestamoFilter{SocioID: &c.socios[0], Limit: 50}, []int{p1.ID, p3.ID}},
+		{"por ejemplar", models.PrestamoFilter{EjemplarID: &c.ejemplares[2], Limit: 50}, []int{p2.ID}},
+		{"por libro", models.PrestamoFilter{LibroID: &c.libros[0], Limit: 50}, []int{p1.ID, p3.ID}},
+		{"activos", models.PrestamoFilter{Activos: true, Limit: 50}, []int{p1.ID, p2.ID}},
+		{"activos de un socio", models.PrestamoFilter{SocioID: &c.socios[0], Activos: true, Limit: 50}, []int{p1.ID}},
+		{"nada vencido", models.PrestamoFilter{VencidosAl: dia(4), Limit: 50}, []int{}},
+		{"vencido uno", models.PrestamoFilter{VencidosAl: dia(5), Limit: 50}, []int{p2.ID}},
+		// ordenados por vencimiento, el devuelto no cuenta aunque haya vencido
+		{"vencidos los dos", models.PrestamoFilter{VencidosAl: dia(30), Limit: 50}, []int{p2.ID, p1.ID}},
+		{"limit", models.PrestamoFilter{Limit: 1}, []int{p1.ID}},
+		{"offset", models.PrestamoFilter{Limit: 50, Offset: 2}, []int{p3.ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Prestamos.GetAll(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			assertPrestamos(t, got, tt.want)
+		})
+	}
+}
+
+func testPrestamosDisponibilidad(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	got, err := c.Ejemplares.Disponibilidad(ctx, c.libros[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if want := (models.Disponibilidad{Total: 2, Disponibles: 1, Prestados: 1}); got != want {
+		t.Fatalf("Disponibilidad devolvio %+v, esperaba %+v", got, want)
+	}
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	got, err = c.Ejemplares.Disponibilidad(ctx, c.libros[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if want := (models.Disponibilidad{Total: 2, Disponibles: 2}); got != want {
+		t.Fatalf("Disponibilidad despues de devolver: %+v, esperaba %+v", got, want)
+	}
+}
+
+func testPrestamosBorrarEjemplar(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	if err := c.Ejemplares.Delete(ctx, c.libros[0], c.ejemplares[0]); !errors.Is(err, repository.ErrEjemplarPrestado) {
+		t.Fatalf("esperaba ErrEjemplarPrestado, vino %v", err)
+	}
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// devuelto se puede borrar, y el historial de ese ejemplar se va con el
+	if err := c.Ejemplares.Delete(ctx, c.libros[0], c.ejemplares[0]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := c.Prestamos.GetByID(ctx, p.ID); !errors.Is(err, repository.ErrPrestamoNotFound) {
+		t.Fatalf("esperaba ErrPrestamoNotFound, vino %v", err)
+	}
+}
+
+func testPrestamosBorrarSocio(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// aunque lo haya devuelto: borrarlo dejaria el historial sin socio
+	if err := c.Socios.Delete(ctx, c.socios[0]); !errors.Is(err, repository.ErrSocioConPrestamos) {
+		t.Fatalf("esperaba ErrSocioConPrestamos, vino %v", err)
+	}
+
+	if err := c.Socios.Delete(ctx, c.socios[1]); err != nil {
+		t.Fatalf("un socio sin prestamos se puede borrar: %v", err)
+	}
+}
+
+// testPrestamosBorrarLibro: con el libro en la papelera los prestamos siguen pero no se prestan sus
+// ejemplares; al purgarlo los prestamos se van con el libro (y sus ejemplares)
+// testPrestamosBorrarLibro: un libro con un ejemplar prestado no va a la papelera (ni suelto ni en un
+// batch), asi la purga no se lleva prestamos sin devolver. Devuelto si, y el historial se va al purgarlo
+func testPrestamosBorrarLibro(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	p := c.prestar(t, c.ejemplares[0], c.socios[0], hoy)
+
+	if err := c.Libros.Delete(ctx, c.libros[0]); !errors.Is(err, repository.ErrEjemplarPrestado) {
+		t.Fatalf("esperaba ErrEjemplarPrestado, vino %v", err)
+	}
+	res, err := c.Libros.Batch(ctx, []models.LibroOp{{Op: models.RevisionBorrar, ID: c.libros[0]}}, false)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if !errors.Is(res[0].Err, repository.ErrEjemplarPrestado) {
+		t.Fatalf("borrar en un batch: esperaba ErrEjemplarPrestado, vino %v", res[0].Err)
+	}
+	if _, err := c.Libros.GetByID(ctx, c.libros[0]); err != nil {
+		t.Fatalf("el libro no tenia que ir a la papelera: %v", err)
+	}
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := c.Libros.Delete(ctx, c.libros[0]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := c.Prestamos.GetByID(ctx, p.ID); err != nil {
+		t.Fatalf("con el libro en la papelera el prestamo sigue: %v", err)
+	}
+	if _, err := c.Prestamos.Prestar(ctx, nuevoPrestamo(c.ejemplares[1], c.socios[1], hoy)); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("ejemplar de un libro en la papelera: esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+	if err := c.Socios.Delete(ctx, c.socios[0]); !errors.Is(err, repository.ErrSocioConPrestamos) {
+		t.Fatalf("esperaba ErrSocioConPrestamos, vino %v", err)
+	}
+
+	if _, err := c.Libros.Purgar(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := c.Prestamos.GetByID(ctx, p.ID); !errors.Is(err, repository.ErrPrestamoNotFound) {
+		t.Fatalf("esperaba ErrPrestamoNotFound, vino %v", err)
+	}
+	if _, err := c.Prestamos.Prestar(ctx, nuevoPrestamo(c.ejemplares[0], c.socios[0], hoy)); !errors.Is(err, repository.ErrEjemplarNotFound) {
+		t.Fatalf("esperaba ErrEjemplarNotFound, vino %v", err)
+	}
+	if err := c.Socios.Delete(ctx, c.socios[0]); err != nil {
+		t.Fatalf("sin prestamos el socio se puede borrar: %v", err)
+	}
+}
+
+func assertPrestamos(t *testing.T, prestamos []models.Prestamo, want []int) {
+	t.Helper()
+
+	if prestamos == nil {
+		t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(prestamos) != len(want) {
+		t.Fatalf("esperaba prestamos %v, vinieron %+v", want, prestamos)
+	}
+
+	for i, p := range prestamos {
+		if p.ID != want[i] {
+			t.Fatalf("posicion %d: esperaba prestamo %d, vino %d (esperados %v)", i, want[i], p.ID, want)
+		}
+	}
+}
@@ -0,0 +1,1695 @@
+// Package repositorytest tiene la especificacion compartida que tiene que cumplir
+// cualquier implementacion de repository.LibrosRepository.
+//
+// Cada backend la corre desde sus tests:
+//
+//	repositorytest.Run(t, func(t *testing.T) repository.LibrosRepository {
+//		return repository.NewMemoryLibrosRepo()
+//	})
+package repositorytest
+
+import (
+	"api-libros/actor"
+	"api-libros/models"
+	"api-libros/repository"
+	"api-libros/requestid"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Factory tiene que devolver un repo VACIO cada vez que se la llama.
+// Cada subtest la llama una vez, asi los casos no se pisan entre si.
+type Factory func(t *testing.T) repository.LibrosRepository
+
+// libros que se cargan en los casos que necesitan datos. Con un repo vacio quedan con ids crecientes en este orden.
+var seed = []models.LibroInput{
+	{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965},
+	{Titulo: "1984", Autor: "George Orwell", Ano: 1949},
+	{Titulo: "Rebelión en la granja", Autor: "George Orwell", Ano: 1945},
+	{Titulo: "Crimen y castigo", Autor: "Fiódor Dostoievski", Ano: 1866},
+	{Titulo: "Fahrenheit 451", Autor: "Ray Bradbury", Ano: 1953},
+}
+
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("Create", func(t *testing.T) { testCreate(t, newRepo) })
+	t.Run("GetByID", func(t *testing.T) { testGetByID(t, newRepo) })
+	t.Run("GetAll/Filtros", func(t *testing.T) { testGetAllFiltros(t, newRepo) })
+	t.Run("GetAll/Paginado", func(t *testing.T) { testGetAllPaginado(t, newRepo) })
+	t.Run("GetAll/Negativos", func(t *testing.T) { testGetAllNegativos(t, newRepo) })
+	t.Run("GetAll/Orden", func(t *testing.T) { testGetAllOrden(t, newRepo) })
+	t.Run("GetAll/OrdenEspanol", func(t *testing.T) { testGetAllOrdenEspanol(t, newRepo) })
+	t.Run("GetAll/CursorOrdenado", func(t *testing.T) { testGetAllCursorOrdenado(t, newRepo) })
+	t.Run("GetAll/Cursor", func(t *testing.T) { testGetAllCursor(t, newRepo) })
+	t.Run("GetAll/CursorConCambios", func(t *testing.T) { testGetAllCursorConCambios(t, newRepo) })
+	t.Run("GetAll/Busqueda", func(t *testing.T) { testGetAllBusqueda(t, newRepo) })
+	t.Run("GetAll/BusquedaRanking", func(t *testing.T) { testGetAllBusquedaRanking(t, newRepo) })
+	t.Run("GetAll/BusquedaResaltado", func(t *testing.T) { testGetAllBusquedaResaltado(t, newRepo) })
+	t.Run("GetAll/Fuzzy", func(t *testing.T) { testGetAllFuzzy(t, newRepo) })
+	t.Run("Suggest", func(t *testing.T) { testSuggest(t, newRepo) })
+	t.Run("Count", func(t *testing.T) { testCount(t, newRepo) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo) })
+	t.Run("Patch", func(t *testing.T) { testPatch(t, newRepo) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newRepo) })
+	t.Run("ISBN", func(t *testing.T) { testISBN(t, newRepo) })
+	t.Run("Version", func(t *testing.T) { testVersion(t, newRepo) })
+	t.Run("Papelera", func(t *testing.T) { testPapelera(t, newRepo) })
+	t.Run("Purgar", func(t *testing.T) { testPurgar(t, newRepo) })
+	t.Run("Historial", func(t *testing.T) { testHistorial(t, newRepo) })
+	t.Run("Historial/Negativos", func(t *testing.T) { testHistorialNegativos(t, newRepo) })
+	t.Run("EnFecha", func(t *testing.T) { testEnFecha(t, newRepo) })
+	t.Run("Revertir", func(t *testing.T) { testRevertir(t, newRepo) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, newRepo) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newRepo) })
+}
+
+// ---------- CASOS ----------
+
+func testCreate(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	prev := 0
+	for _, in := range seed {
+		l, err := repo.Create(ctx, in)
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+
+		if l.ID <= prev {
+			t.Fatalf("los ids tienen que ser positivos y crecientes: vino %d despues de %d", l.ID, prev)
+		}
+		prev = l.ID
+
+		if l.Titulo != in.Titulo || l.Autor != in.Autor || l.Ano != in.Ano {
+			t.Fatalf("Create devolvio %+v, esperaba los datos de %+v", l, in)
+		}
+	}
+
+	// los ids no se reutilizan aunque se borre el ultimo
+	if err := repo.Delete(ctx, prev); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	l, err := repo.Create(ctx, seed[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if l.ID <= prev {
+		t.Fatalf("se reutilizo un id: vino %d, el ultimo asignado fue %d", l.ID, prev)
+	}
+}
+
+func testGetByID(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+
+	l, err := repo.GetByID(context.Background(), ids[1])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	want := seed[1]
+	if l.ID != ids[1] || l.Titulo != want.Titulo || l.Autor != want.Autor || l.Ano != want.Ano {
+		t.Fatalf("GetByID devolvio %+v, esperaba %+v", l, want)
+	}
+}
+
+func testGetAllFiltros(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   []int // posiciones dentro de seed
+	}{
+		{"sin filtros", models.LibroFilter{Limit: 50}, []int{0, 1, 2, 3, 4}},
+		{"autor parcial", models.LibroFilter{Autor: ptr("orwell"), Limit: 50}, []int{1, 2}},
+		{"autor sin distinguir mayusculas", models.LibroFilter{Autor: ptr("FRANK"), Limit: 50}, []int{0}},
+		{"autor con acento", models.LibroFilter{Autor: ptr("fiódor"), Limit: 50}, []int{3}},
+		{"autor sin resultados", models.LibroFilter{Autor: ptr("tolkien"), Limit: 50}, nil},
+		{"from inclusivo", models.LibroFilter{From: ptr(1953), Limit: 50}, []int{0, 4}},
+		{"to inclusivo", models.LibroFilter{To: ptr(1949), Limit: 50}, []int{1, 2, 3}},
+		{"from y to", models.LibroFilter{From: ptr(1945), To: ptr(1953), Limit: 50}, []int{1, 2, 4}},
+		{"autor y rango", models.LibroFilter{Autor: ptr("george"), From: ptr(1946), Limit: 50}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+		})
+	}
+}
+
+func testGetAllPaginado(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   []int
+	}{
+		{"limit menor al total", models.LibroFilter{Limit: 2}, []int{0, 1}},
+		{"limit igual al total", models.LibroFilter{Limit: 5}, []int{0, 1, 2, 3, 4}},
+		{"limit mayor al total", models.LibroFilter{Limit: 100}, []int{0, 1, 2, 3, 4}},
+		{"limit 0", models.LibroFilter{Limit: 0}, nil},
+		{"offset", models.LibroFilter{Limit: 2, Offset: 2}, []int{2, 3}},
+		{"ultima pagina incompleta", models.LibroFilter{Limit: 2, Offset: 4}, []int{4}},
+		{"offset igual al total", models.LibroFilter{Limit: 2, Offset: 5}, nil},
+		{"offset mayor al total", models.LibroFilter{Limit: 2, Offset: 50}, nil},
+		{"paginado con filtro", models.LibroFilter{To: ptr(1953), Limit: 2, Offset: 1}, []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+		})
+	}
+}
+
+// testGetAllNegativos: limit u offset negativos (sin pasar por Validate) son un error, no un panic
+func testGetAllNegativos(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+	}{
+		{"limit negativo", models.LibroFilter{Limit: -1}},
+		{"offset negativo", models.LibroFilter{Limit: 10, Offset: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			load(t, repo)
+
+			if _, err := repo.GetAll(context.Background(), tt.filter); err == nil {
+				t.Fatal("esperaba un error")
+			}
+		})
+	}
+}
+
+func testGetAllOrden(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   []int
+	}{
+		{"sin sort es por id", models.LibroFilter{Limit: 10}, []int{0, 1, 2, 3, 4}},
+		{"ano", models.LibroFilter{Limit: 10, Sort: sortBy("ano")}, []int{3, 2, 1, 4, 0}},
+		{"-ano", models.LibroFilter{Limit: 10, Sort: sortBy("-ano")}, []int{0, 4, 1, 2, 3}},
+		{"titulo", models.LibroFilter{Limit: 10, Sort: sortBy("titulo")}, []int{1, 3, 0, 4, 2}},
+		{"autor,-titulo", models.LibroFilter{Limit: 10, Sort: sortBy("autor", "-titulo")}, []int{3, 0, 2, 1, 4}},
+		{"empate se desarma por id", models.LibroFilter{Limit: 10, Sort: sortBy("-autor")}, []int{4, 1, 2, 0, 3}},
+		{"con limit y offset", models.LibroFilter{Limit: 2, Offset: 1, Sort: sortBy("ano")}, []int{2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+		})
+	}
+}
+
+// testGetAllOrdenEspanol: los titulos se ordenan como en un diccionario, no por bytes.
+// Por bytes "Árbol" y "Ñandú" quedan despues de "Zorro"; en español la ñ va despues de la n.
+func testGetAllOrdenEspanol(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	titulos := []string{"Zorro", "Ñandú", "Más", "árbol", "oso", "nube", "Mas", "Arco"}
+	want := []string{"árbol", "Arco", "Mas", "Más", "nube", "Ñandú", "oso", "Zorro"}
+
+	for _, titulo := range titulos {
+		if _, err := repo.Create(ctx, models.LibroInput{Titulo: titulo, Autor: "Anónimo", Ano: 2000}); err != nil {
+			t.Fatalf("error cargando libros: %v", err)
+		}
+	}
+
+	libros, err := repo.GetAll(ctx, models.LibroFilter{Limit: 10, Sort: sortBy("titulo")})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	got := make([]string, 0, len(libros))
+	for _, l := range libros {
+		got = append(got, l.Titulo)
+	}
+
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("orden esperado %v, vino %v", want, got)
+	}
+}
+
+// testGetAllCursorOrdenado: recorrer con cursor en un orden con columnas en distintas
+// direcciones y empates da lo mismo que pedir todo junto, hacia adelante y hacia atras.
+func testGetAllCursorOrdenado(t *testing.T, newRepo Factory) {
+	for _, sort := range [][]models.SortField{sortBy("-autor"), sortBy("autor", "-ano"), sortBy("-ano", "titulo")} {
+		t.Run(models.SortString(sort), func(t *testing.T) {
+			repo := newRepo(t)
+			load(t, repo)
+			ctx := context.Background()
+
+			todos, err := repo.GetAll(ctx, models.LibroFilter{Limit: 10, Sort: sort})
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			want := make([]int, 0, len(todos))
+			for _, l := range todos {
+				want = append(want, l.ID)
+			}
+
+			// hacia adelante de a 2
+			var adelante []models.Libro
+			f := models.LibroFilter{Limit: 2, Sort: sort}
+			for page := 0; page < 10; page++ {
+				libros, err := repo.GetAll(ctx, f)
+				if err != nil {
+					t.Fatalf("error inesperado: %v", err)
+				}
+				if len(libros) == 0 {
+					break
+				}
+				adelante = append(adelante, libros...)
+				c := models.NewCursor(libros[len(libros)-1], sort, false)
+				f.Cursor = &c
+			}
+			assertIDs(t, adelante, want)
+
+			// hacia atras de a 2 desde el ultimo
+			var atras []models.Libro
+			c := models.NewCursor(todos[len(todos)-1], sort, true)
+			f = models.LibroFilter{Limit: 2, Sort: sort, Cursor: &c}
+			for page := 0; page < 10; page++ {
+				libros, err := repo.GetAll(ctx, f)
+				if err != nil {
+					t.Fatalf("error inesperado: %v", err)
+				}
+				if len(libros) == 0 {
+					break
+				}
+				atras = append(libros, atras...)
+				c := models.NewCursor(libros[0], sort, true)
+				f.Cursor = &c
+			}
+			assertIDs(t, atras, want[:len(want)-1])
+		})
+	}
+}
+
+func testGetAllCursor(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter func(ids []int) models.LibroFilter
+		want   []int
+	}{
+		{
+			name: "hacia adelante",
+			filter: func(ids []int) models.LibroFilter {
+				return models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: ids[1]}}
+			},
+			want: []int{2, 3},
+		},
+		{
+			name: "hacia adelante al final",
+			filter: func(ids []int) models.LibroFilter {
+				return models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: ids[4]}}
+			},
+			want: nil,
+		},
+		{
+			name: "hacia atras devuelve orden ascendente",
+			filter: func(ids []int) models.LibroFilter {
+				return models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: ids[4], Backward: true}}
+			},
+			want: []int{2, 3},
+		},
+		{
+			name: "hacia atras cerca del principio",
+			filter: func(ids []int) models.LibroFilter {
+				return models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: ids[1], Backward: true}}
+			},
+			want: []int{0},
+		},
+		{
+			name: "con filtro",
+			filter: func(ids []int) models.LibroFilter {
+				return models.LibroFilter{Autor: ptr("orwell"), Limit: 5, Cursor: &models.Cursor{ID: ids[1]}}
+			},
+			want: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter(ids))
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+		})
+	}
+
+	t.Run("recorrido completo", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := load(t, repo)
+		ctx := context.Background()
+
+		var seen []int
+		f := models.LibroFilter{Limit: 2}
+
+		for page := 0; page < 10; page++ {
+			libros, err := repo.GetAll(ctx, f)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if len(libros) == 0 {
+				break
+			}
+			for _, l := range libros {
+				seen = append(seen, l.ID)
+			}
+			f.Cursor = &models.Cursor{ID: libros[len(libros)-1].ID}
+		}
+
+		if len(seen) != len(ids) {
+			t.Fatalf("el recorrido con cursor devolvio %v, esperaba %v", seen, ids)
+		}
+		for i := range ids {
+			if seen[i] != ids[i] {
+				t.Fatalf("el recorrido con cursor devolvio %v, esperaba %v", seen, ids)
+			}
+		}
+	})
+}
+
+// testGetAllCursorConCambios: insertar o borrar libros entre pagina y pagina no hace
+// que se repitan ni se salteen libros (que es justamente lo que pasa con OFFSET).
+func testGetAllCursorConCambios(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	page1, err := repo.GetAll(ctx, models.LibroFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, page1, pick(ids, []int{0, 1}))
+
+	// entre pagina y pagina alguien borra un libro ya visto y el ultimo de la pagina, y agrega uno nuevo
+	if err := repo.Delete(ctx, ids[0]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := repo.Delete(ctx, ids[1]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	nuevo, err := repo.Create(ctx, models.LibroInput{Titulo: "Nuevo", Autor: "Alguien", Ano: 2020})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	page2, err := repo.GetAll(ctx, models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: page1[1].ID}})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, page2, pick(ids, []int{2, 3}))
+
+	page3, err := repo.GetAll(ctx, models.LibroFilter{Limit: 2, Cursor: &models.Cursor{ID: page2[1].ID}})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, page3, []int{ids[4], nuevo.ID})
+}
+
+// testGetAllBusqueda: ?q= encuentra por titulo y autor, sin importar tildes ni mayusculas,
+// y todas las palabras tienen que estar (salvo stopwords como "la").
+func testGetAllBusqueda(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   []int
+	}{
+		{"titulo y autor", models.LibroFilter{Q: ptr("dostoievski crimen")}, []int{3}},
+		{"mayusculas", models.LibroFilter{Q: ptr("CASTIGO")}, []int{3}},
+		{"sin tildes", models.LibroFilter{Q: ptr("rebelion")}, []int{2}},
+		{"tilde en el autor", models.LibroFilter{Q: ptr("fiodor")}, []int{3}},
+		{"mismo puntaje por id", models.LibroFilter{Q: ptr("orwell")}, []int{1, 2}},
+		{"stopword", models.LibroFilter{Q: ptr("la granja")}, []int{2}},
+		{"todas las palabras", models.LibroFilter{Q: ptr("orwell granja")}, []int{2}},
+		{"numeros", models.LibroFilter{Q: ptr("451")}, []int{4}},
+		{"sin resultados", models.LibroFilter{Q: ptr("tolkien")}, nil},
+		{"con otros filtros", models.LibroFilter{Q: ptr("orwell"), From: ptr(1946)}, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			tt.filter.Limit = 10
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+		})
+	}
+}
+
+// testGetAllBusquedaRanking: sin sort, una coincidencia en el titulo va antes que una en el autor.
+func testGetAllBusquedaRanking(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	herbert, err := repo.Create(ctx, models.LibroInput{Titulo: "Herbert", Autor: "Anónimo", Ano: 2000})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	libros, err := repo.GetAll(ctx, models.LibroFilter{Q: ptr("herbert"), Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, libros, []int{herbert.ID, ids[0]})
+
+	// con sort explicito manda el sort
+	libros, err = repo.GetAll(ctx, models.LibroFilter{Q: ptr("herbert"), Limit: 10, Sort: sortBy("id")})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, libros, []int{ids[0], herbert.ID})
+}
+
+func testGetAllBusquedaResaltado(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	load(t, repo)
+	ctx := context.Background()
+
+	libros, err := repo.GetAll(ctx, models.LibroFilter{Q: ptr("rebelion orwell"), Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(libros) != 1 || libros[0].Resaltado == nil {
+		t.Fatalf("esperaba un libro con resaltado, vino %+v", libros)
+	}
+
+	want := models.Resaltado{Titulo: "<mark>Rebelión</mark> en la granja", Autor: "George <mark>Orwell</mark>"}
+	if *libros[0].Resaltado != want {
+		t.Fatalf("resaltado esperado %+v, vino %+v", want, *libros[0].Resaltado)
+	}
+
+	// el frontend muestra el resaltado como HTML: lo que no es <mark> tiene que venir escapado
+	if _, err := repo.Create(ctx, models.LibroInput{Titulo: `Tom & Jerry <img src=x onerror="alert(1)">`, Autor: "O'Brien", Ano: 1940}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	libros, err = repo.GetAll(ctx, models.LibroFilter{Q: ptr("jerry"), Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(libros) != 1 || libros[0].Resaltado == nil {
+		t.Fatalf("esperaba un libro con resaltado, vino %+v", libros)
+	}
+
+	want = models.Resaltado{
+		Titulo: "Tom &amp; <mark>Jerry</mark> &lt;img src=x onerror=&#34;alert(1)&#34;&gt;",
+		Autor:  "O&#39;Brien",
+	}
+	if *libros[0].Resaltado != want {
+		t.Fatalf("resaltado esperado %+v, vino %+v", want, *libros[0].Resaltado)
+	}
+
+	// sin busqueda no hay resaltado
+	libros, err = repo.GetAll(ctx, models.LibroFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	for _, l := range libros {
+		if l.Resaltado != nil {
+			t.Fatalf("sin q no tiene que venir resaltado: %+v", l)
+		}
+	}
+}
+
+// testGetAllFuzzy: con Fuzzy la busqueda tolera errores de tipeo
+func testGetAllFuzzy(t *testing.T, newRepo Factory) {
+	fuzzy := func(q string) models.LibroFilter {
+		return models.LibroFilter{Q: ptr(q), Fuzzy: true, FuzzyThreshold: 0.3, Limit: 10}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   []int
+	}{
+		{"autor mal escrito", fuzzy("dostoyevski"), []int{3}},
+		{"titulo mal escrito", fuzzy("fahrenhet"), []int{4}},
+		{"empate por id", fuzzy("orwel"), []int{1, 2}},
+		{"sin resultados", fuzzy("zzzz"), nil},
+		{"con otros filtros", func() models.LibroFilter { f := fuzzy("orwel"); f.To = ptr(1946); return f }(), []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+
+			libros, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			assertIDs(t, libros, pick(ids, tt.want))
+
+			for _, l := range libros {
+				if l.Resaltado != nil {
+					t.Fatalf("en fuzzy no hay resaltado: %+v", l)
+				}
+			}
+		})
+	}
+}
+
+func testSuggest(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.SuggestFilter
+		want   []models.Sugerencia
+	}{
+		{"prefijo de autor", models.SuggestFilter{Q: "dost", Limit: 10}, []models.Sugerencia{{Texto: "Fiódor Dostoievski", Campo: "autor"}}},
+		{"mal escrito", models.SuggestFilter{Q: "fahrenhet", Limit: 10}, []models.Sugerencia{{Texto: "Fahrenheit 451", Campo: "titulo"}}},
+		{"autor repetido una vez", models.SuggestFilter{Q: "orwell", Limit: 10}, []models.Sugerencia{{Texto: "George Orwell", Campo: "autor"}}},
+		{
+			"empate por largo",
+			models.SuggestFilter{Q: "dune", Limit: 10},
+			[]models.Sugerencia{{Texto: "Dune", Campo: "titulo"}, {Texto: "Dune Mesías", Campo: "titulo"}},
+		},
+		{"limit", models.SuggestFilter{Q: "dune", Limit: 1}, []models.Sugerencia{{Texto: "Dune", Campo: "titulo"}}},
+		{"sin resultados", models.SuggestFilter{Q: "zzzz", Limit: 10}, []models.Sugerencia{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			load(t, repo)
+			ctx := context.Background()
+
+			if _, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune Mesías", Autor: "Frank Herbert", Ano: 1969}); err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			tt.filter.Threshold = 0.3
+			got, err := repo.Suggest(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if got == nil {
+				t.Fatal("Suggest devolvio nil, esperaba un slice (aunque sea vacio)")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("esperaba %v, vino %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("esperaba %v, vino %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+// testCount: Count cuenta lo mismo que devolveria GetAll sin limite, y no le afectan limit, offset ni cursor.
+func testCount(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.LibroFilter
+		want   int
+	}{
+		{"sin filtros", models.LibroFilter{}, 5},
+		{"por autor", models.LibroFilter{Autor: ptr("orwell")}, 2},
+		{"por rango", models.LibroFilter{From: ptr(1945), To: ptr(1960)}, 3},
+		{"sin resultados", models.LibroFilter{Autor: ptr("borges")}, 0},
+		{"con busqueda", models.LibroFilter{Q: ptr("orwell")}, 2},
+		{"con busqueda fuzzy", models.LibroFilter{Q: ptr("orwel"), Fuzzy: true, FuzzyThreshold: 0.3}, 2},
+		{"ignora limit y offset", models.LibroFilter{Limit: 1, Offset: 3}, 5},
+		{"ignora cursor", models.LibroFilter{Autor: ptr("orwell"), Cursor: &models.Cursor{ID: 1 << 30}}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			load(t, repo)
+			ctx := context.Background()
+
+			total, err := repo.Count(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if total != tt.want {
+				t.Fatalf("Count esperado %d, vino %d", tt.want, total)
+			}
+
+			todos := tt.filter
+			todos.Limit, todos.Offset, todos.Cursor = 100, 0, nil
+			libros, err := repo.GetAll(ctx, todos)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if len(libros) != total {
+				t.Fatalf("Count dice %d pero GetAll devolvio %d libros", total, len(libros))
+			}
+		})
+	}
+}
+
+func testUpdate(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	in := models.LibroInput{Titulo: "Dune Messiah", Autor: "Frank Herbert", Ano: 1969}
+
+	l, err := repo.Update(ctx, ids[0], in)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if l.ID != ids[0] || l.Titulo != in.Titulo || l.Autor != in.Autor || l.Ano != in.Ano {
+		t.Fatalf("Update devolvio %+v, esperaba %+v", l, in)
+	}
+
+	got, err := repo.GetByID(ctx, ids[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *got != *l {
+		t.Fatalf("GetByID despues de Update devolvio %+v, esperaba %+v", got, l)
+	}
+
+	// el resto no se toca
+	other, _ := repo.GetByID(ctx, ids[1])
+	if other.Titulo != seed[1].Titulo {
+		t.Fatalf("Update modifico otro libro: %+v", other)
+	}
+}
+
+func testPatch(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name  string
+		patch models.LibroPatch
+		want  models.Libro // sin id, se completa con el del libro parcheado (que arranca en la version 1)
+	}{
+		{
+			name:  "sin campos no cambia nada",
+			patch: models.LibroPatch{},
+			want:  models.Libro{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965, Version: 1},
+		},
+		{
+			name:  "solo titulo",
+			patch: models.LibroPatch{Titulo: ptr("Dune (edicion aniversario)")},
+			want:  models.Libro{Titulo: "Dune (edicion aniversario)", Autor: "Frank Herbert", Ano: 1965, Version: 2},
+		},
+		{
+			name:  "solo autor",
+			patch: models.LibroPatch{Autor: ptr("F. Herbert")},
+			want:  models.Libro{Titulo: "Dune", Autor: "F. Herbert", Ano: 1965, Version: 2},
+		},
+		{
+			name:  "solo ano",
+			patch: models.LibroPatch{Ano: ptr(1966)},
+			want:  models.Libro{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1966, Version: 2},
+		},
+		{
+			name:  "todos los campos",
+			patch: models.LibroPatch{Titulo: ptr("X"), Autor: ptr("Y"), Ano: ptr(2000)},
+			want:  models.Libro{Titulo: "X", Autor: "Y", Ano: 2000, Version: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ids := load(t, repo)
+			ctx := context.Background()
+
+			tt.want.ID = ids[0]
+
+			orig, err := repo.GetByID(ctx, ids[0])
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			l, err := repo.Patch(ctx, ids[0], tt.patch)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			// Modificado lo pone el repo: sin campos no se toca, con campos no puede ir para atras
+			if tt.patch == (models.LibroPatch{}) && !l.Modificado.Equal(orig.Modificado) || l.Modificado.Before(orig.Modificado) {
+				t.Fatalf("Patch dejo Modificado en %v, antes era %v", l.Modificado, orig.Modificado)
+			}
+			tt.want.Modificado = l.Modificado
+
+			if *l != tt.want {
+				t.Fatalf("Patch devolvio %+v, esperaba %+v", l, tt.want)
+			}
+
+			got, err := repo.GetByID(ctx, ids[0])
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if *got != tt.want {
+				t.Fatalf("GetByID despues de Patch devolvio %+v, esperaba %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testDelete(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	if err := repo.Delete(ctx, ids[2]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.GetByID(ctx, ids[2]); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByID despues de Delete: esperaba ErrNotFound, vino %v", err)
+	}
+
+	libros, err := repo.GetAll(ctx, models.LibroFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, libros, pick(ids, []int{0, 1, 3, 4}))
+
+	if err := repo.Delete(ctx, ids[2]); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Delete dos veces: esperaba ErrNotFound, vino %v", err)
+	}
+}
+
+// testISBN: los repos reciben el ISBN ya normalizado (eso lo hace LibroInput.Validate),
+// solo tienen que guardarlo, buscarlo y no dejar que dos libros compartan uno.
+func testISBN(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo) // los del seed no tienen ISBN y no chocan entre si
+	ctx := context.Background()
+
+	const isbn = "9780441013593"
+
+	dune, err := repo.Patch(ctx, ids[0], models.LibroPatch{ISBN: ptr(isbn)})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if dune.ISBN != isbn {
+		t.Fatalf("Patch no guardo el isbn: %+v", dune)
+	}
+
+	got, err := repo.GetByISBN(ctx, isbn)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *got != *dune {
+		t.Fatalf("GetByISBN devolvio %+v, esperaba %+v", got, dune)
+	}
+
+	if _, err := repo.GetByISBN(ctx, "9780306406157"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByISBN inexistente: esperaba ErrNotFound, vino %v", err)
+	}
+
+	duplicados := []struct {
+		name string
+		call func() error
+	}{
+		{"Create", func() error {
+			_, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune (otra edicion)", Autor: "Frank Herbert", Ano: 1990, ISBN: isbn})
+			return err
+		}},
+		{"Update", func() error {
+			_, err := repo.Update(ctx, ids[1], models.LibroInput{Titulo: "1984", Autor: "George Orwell", Ano: 1949, ISBN: isbn})
+			return err
+		}},
+		{"Patch", func() error {
+			_, err := repo.Patch(ctx, ids[2], models.LibroPatch{ISBN: ptr(isbn)})
+			return err
+		}},
+	}
+
+	for _, tt := range duplicados {
+		t.Run("duplicado en "+tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var dup *repository.ISBNDuplicadoError
+			if !errors.As(err, &dup) || !errors.Is(err, repository.ErrISBNDuplicado) {
+				t.Fatalf("esperaba ISBNDuplicadoError, vino %v", err)
+			}
+			if dup.ID != ids[0] {
+				t.Fatalf("el error tiene que decir que el isbn es del libro %d, dice %d", ids[0], dup.ID)
+			}
+		})
+	}
+
+	// volver a mandar su propio ISBN no es un duplicado
+	if _, err := repo.Update(ctx, ids[0], models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965, ISBN: isbn}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// "" se lo saca, y ahi otro libro lo puede usar
+	if l, err := repo.Patch(ctx, ids[0], models.LibroPatch{ISBN: ptr("")}); err != nil || l.ISBN != "" {
+		t.Fatalf("Patch con isbn vacio: libro %+v, error %v", l, err)
+	}
+	if _, err := repo.Patch(ctx, ids[2], models.LibroPatch{ISBN: ptr(isbn)}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+// testVersion: cada cambio sube la version (y actualiza Modificado), y UpdateIf, PatchIf y DeleteIf con una version vieja no
+// tocan nada y devuelven el libro como esta
+func testVersion(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+	id := ids[0]
+
+	l, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if l.Version != 1 || l.Modificado.IsZero() {
+		t.Fatalf("un libro nuevo tiene que estar en la version 1 y con Modificado, vino %+v", l)
+	}
+
+	// el libro pasa por las versiones 2 a 5
+	pasos := []struct {
+		name string
+		call func(version int) (*models.Libro, error)
+	}{
+		{"Update", func(v int) (*models.Libro, error) {
+			return repo.Update(ctx, id, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1966})
+		}},
+		{"UpdateIf", func(v int) (*models.Libro, error) {
+			return repo.UpdateIf(ctx, id, v, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1967})
+		}},
+		{"Patch", func(v int) (*models.Libro, error) {
+			return repo.Patch(ctx, id, models.LibroPatch{Ano: ptr(1968)})
+		}},
+		{"PatchIf", func(v int) (*models.Libro, error) {
+			return repo.PatchIf(ctx, id, v, models.LibroPatch{Ano: ptr(1969)})
+		}},
+	}
+
+	prev := l.Modificado
+	for i, p := range pasos {
+		got, err := p.call(i + 1)
+		if err != nil {
+			t.Fatalf("%s: error inesperado: %v", p.name, err)
+		}
+		if got.Version != i+2 {
+			t.Fatalf("%s: esperaba la version %d, vino %+v", p.name, i+2, got)
+		}
+		if got.Modificado.Before(prev) {
+			t.Fatalf("%s: Modificado fue para atras: %v, antes %v", p.name, got.Modificado, prev)
+		}
+		prev = got.Modificado
+	}
+
+	actual, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	conflictos := []struct {
+		name string
+		call func() error
+	}{
+		{"UpdateIf", func() error {
+			_, err := repo.UpdateIf(ctx, id, 4, models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000})
+			return err
+		}},
+		{"PatchIf", func() error {
+			_, err := repo.PatchIf(ctx, id, 4, models.LibroPatch{Titulo: ptr("X")})
+			return err
+		}},
+		{"PatchIf sin campos", func() error {
+			_, err := repo.PatchIf(ctx, id, 4, models.LibroPatch{})
+			return err
+		}},
+		{"DeleteIf", func() error {
+			return repo.DeleteIf(ctx, id, 4)
+		}},
+	}
+
+	for _, tt := range conflictos {
+		t.Run("conflicto en "+tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var conflict *repository.VersionConflictError
+			if !errors.As(err, &conflict) || !errors.Is(err, repository.ErrVersionConflict) {
+				t.Fatalf("esperaba VersionConflictError, vino %v", err)
+			}
+			if conflict.Actual != *actual {
+				t.Fatalf("el error tiene que traer el libro actual %+v, trae %+v", actual, conflict.Actual)
+			}
+		})
+	}
+
+	got, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *got != *actual {
+		t.Fatalf("un conflicto modifico el libro: %+v, esperaba %+v", got, actual)
+	}
+
+	// un patch sin campos no cambia la version
+	if got, err := repo.PatchIf(ctx, id, 5, models.LibroPatch{}); err != nil || got.Version != 5 {
+		t.Fatalf("PatchIf sin campos: libro %+v, error %v", got, err)
+	}
+
+	if err := repo.DeleteIf(ctx, id, 5); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := repo.DeleteIf(ctx, id, 5); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("DeleteIf de un libro borrado: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.UpdateIf(ctx, id, 5, models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateIf de un libro borrado: esperaba ErrNotFound, vino %v", err)
+	}
+}
+
+func testNotFound(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	missing := ids[len(ids)-1] + 1000
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"GetByID", func() error {
+			_, err := repo.GetByID(ctx, missing)
+			return err
+		}},
+		{"Update", func() error {
+			_, err := repo.Update(ctx, missing, models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000})
+			return err
+		}},
+		{"Patch", func() error {
+			_, err := repo.Patch(ctx, missing, models.LibroPatch{Titulo: ptr("X")})
+			return err
+		}},
+		{"Patch sin campos", func() error {
+			_, err := repo.Patch(ctx, missing, models.LibroPatch{})
+			return err
+		}},
+		{"Delete", func() error {
+			return repo.Delete(ctx, missing)
+		}},
+		{"GetByIDConBorrados", func() error {
+			_, err := repo.GetByIDConBorrados(ctx, missing)
+			return err
+		}},
+		{"Restaurar", func() error {
+			_, err := repo.Restaurar(ctx, missing)
+			return err
+		}},
+		{"Historial", func() error {
+			_, err := repo.Historial(ctx, missing, models.RevisionFilter{Limit: 50})
+			return err
+		}},
+		{"EnFecha", func() error {
+			_, err := repo.EnFecha(ctx, missing, time.Now().Add(time.Hour))
+			return err
+		}},
+		{"Revertir", func() error {
+			_, err := repo.Revertir(ctx, missing, 1, 0)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, repository.ErrNotFound) {
+				t.Fatalf("esperaba ErrNotFound, vino %v", err)
+			}
+		})
+	}
+
+	// que un NotFound no haya modificado nada
+	libros, err := repo.GetAll(ctx, models.LibroFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, libros, ids)
+}
+
+// testPapelera: un libro borrado no existe para nadie salvo para los que piden los borrados, y se
+// puede restaurar tal como estaba
+func testPapelera(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	antes, err := repo.GetByID(ctx, ids[2])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if err := repo.Delete(ctx, ids[2]); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	borrado, err := repo.GetByIDConBorrados(ctx, ids[2])
+	if err != nil {
+		t.Fatalf("GetByIDConBorrados: error inesperado: %v", err)
+	}
+	if borrado.Borrado == nil || borrado.Version != antes.Version+1 || borrado.Titulo != antes.Titulo {
+		t.Fatalf("esperaba el libro con Borrado y la version %d, vino %+v", antes.Version+1, borrado)
+	}
+
+	tests := []struct {
+		papelera  models.Papelera
+		want      []int
+		wantTotal int
+	}{
+		{models.SinBorrados, pick(ids, []int{0, 1, 3, 4}), 4},
+		{models.ConBorrados, ids, 5},
+		{models.SoloBorrados, pick(ids, []int{2}), 1},
+	}
+	for _, tt := range tests {
+		f := models.LibroFilter{Limit: 50, Papelera: tt.papelera}
+
+		got, err := repo.GetAll(ctx, f)
+		if err != nil {
+			t.Fatalf("papelera %d: error inesperado: %v", tt.papelera, err)
+		}
+		assertIDs(t, got, tt.want)
+
+		total, err := repo.Count(ctx, f)
+		if err != nil {
+			t.Fatalf("papelera %d: error inesperado: %v", tt.papelera, err)
+		}
+		if total != tt.wantTotal {
+			t.Fatalf("papelera %d: Count esperaba %d, vino %d", tt.papelera, tt.wantTotal, total)
+		}
+	}
+
+	sugerencias, err := repo.Suggest(ctx, models.SuggestFilter{Q: "granja", Threshold: 0.3, Limit: 10})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(sugerencias) != 0 {
+		t.Fatalf("Suggest no tiene que sugerir libros borrados: %+v", sugerencias)
+	}
+
+	noExiste := []struct {
+		name string
+		call func() error
+	}{
+		{"GetByID", func() error {
+			_, err := repo.GetByID(ctx, ids[2])
+			return err
+		}},
+		{"Update", func() error {
+			_, err := repo.Update(ctx, ids[2], models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000})
+			return err
+		}},
+		{"Patch", func() error {
+			_, err := repo.Patch(ctx, ids[2], models.LibroPatch{Titulo: ptr("X")})
+			return err
+		}},
+		{"PatchIf", func() error {
+			_, err := repo.PatchIf(ctx, ids[2], borrado.Version, models.LibroPatch{})
+			return err
+		}},
+		{"Delete", func() error {
+			return repo.Delete(ctx, ids[2])
+		}},
+	}
+	for _, tt := range noExiste {
+		if err := tt.call(); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("%s de un libro borrado: esperaba ErrNotFound, vino %v", tt.name, err)
+		}
+	}
+
+	restaurado, err := repo.Restaurar(ctx, ids[2])
+	if err != nil {
+		t.Fatalf("Restaurar: error inesperado: %v", err)
+	}
+	if restaurado.Borrado != nil || restaurado.Version != antes.Version+2 || restaurado.Titulo != antes.Titulo {
+		t.Fatalf("esperaba el libro vivo en la version %d, vino %+v", antes.Version+2, restaurado)
+	}
+	if _, err := repo.GetByID(ctx, ids[2]); err != nil {
+		t.Fatalf("GetByID despues de Restaurar: error inesperado: %v", err)
+	}
+
+	if _, err := repo.Restaurar(ctx, ids[2]); !errors.Is(err, repository.ErrNoBorrado) {
+		t.Fatalf("Restaurar un libro vivo: esperaba ErrNoBorrado, vino %v", err)
+	}
+
+	// el ISBN de un libro borrado se puede volver a usar, y entonces ese ya no se puede restaurar
+	isbn := "9780441013593"
+	viejo, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965, ISBN: isbn})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if err := repo.Delete(ctx, viejo.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.GetByISBN(ctx, isbn); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByISBN de un libro borrado: esperaba ErrNotFound, vino %v", err)
+	}
+
+	nuevo, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune (reedicion)", Autor: "Frank Herbert", Ano: 2005, ISBN: isbn})
+	if err != nil {
+		t.Fatalf("el ISBN de un libro borrado tiene que quedar libre: %v", err)
+	}
+
+	var dup *repository.ISBNDuplicadoError
+	if _, err := repo.Restaurar(ctx, viejo.ID); !errors.As(err, &dup) || dup.ID != nuevo.ID {
+		t.Fatalf("esperaba un ISBNDuplicadoError con el libro %d, vino %v", nuevo.ID, err)
+	}
+}
+
+// testPurgar: Purgar borra de verdad solo los que estan en la papelera desde antes del corte
+func testPurgar(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ids := load(t, repo)
+	ctx := context.Background()
+
+	for _, id := range pick(ids, []int{0, 3}) {
+		if err := repo.Delete(ctx, id); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+	}
+
+	n, err := repo.Purgar(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("borrados hace menos de una hora: esperaba 0 purgados, vinieron %d", n)
+	}
+
+	n, err = repo.Purgar(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("esperaba 2 purgados, vinieron %d", n)
+	}
+
+	if _, err := repo.GetByIDConBorrados(ctx, ids[0]); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByIDConBorrados de un libro purgado: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := repo.Restaurar(ctx, ids[0]); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Restaurar un libro purgado: esperaba ErrNotFound, vino %v", err)
+	}
+
+	got, err := repo.GetAll(ctx, models.LibroFilter{Limit: 50, Papelera: models.ConBorrados})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertIDs(t, got, pick(ids, []int{1, 2, 4}))
+}
+
+// testHistorial: cada cambio deja una revision con el antes, el despues, quien y en que request,
+// y el historial sigue ahi despues de purgar el libro
+func testHistorial(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := actor.NewContext(requestid.NewContext(context.Background(), "req-1"), "ana@biblioteca.org")
+
+	l, err := repo.Create(ctx, seed[0])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	escrituras := []func() error{
+		func() error {
+			_, err := repo.Patch(ctx, l.ID, models.LibroPatch{Titulo: ptr("Dune (ed. 1965)")})
+			return err
+		},
+		func() error {
+			_, err := repo.Patch(ctx, l.ID, models.LibroPatch{}) // no cambia nada, no deja revision
+			return err
+		},
+		func() error {
+			_, err := repo.Update(ctx, l.ID, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1966})
+			return err
+		},
+		func() error { return repo.Delete(ctx, l.ID) },
+		func() error {
+			_, err := repo.Restaurar(ctx, l.ID)
+			return err
+		},
+	}
+	for i, escribir := range escrituras {
+		if err := escribir(); err != nil {
+			t.Fatalf("escritura %d: error inesperado: %v", i, err)
+		}
+	}
+
+	// un cambio que falla no deja nada
+	if _, err := repo.UpdateIf(ctx, l.ID, 1, models.LibroInput{Titulo: "X", Autor: "Y", Ano: 2000}); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("esperaba ErrVersionConflict, vino %v", err)
+	}
+
+	revs, err := repo.Historial(ctx, l.ID, models.RevisionFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	want := []struct {
+		rev int
+		op  string
+	}{
+		{5, models.RevisionRestaurar},
+		{4, models.RevisionBorrar},
+		{3, models.RevisionReemplazar},
+		{2, models.RevisionModificar},
+		{1, models.RevisionCrear},
+	}
+	if len(revs) != len(want) {
+		t.Fatalf("esperaba %d revisiones, vinieron %+v", len(want), revs)
+	}
+	for i, w := range want {
+		r := revs[i]
+		if r.LibroID != l.ID || r.Rev != w.rev || r.Operacion != w.op {
+			t.Fatalf("revision %d: esperaba rev %d %s, vino %+v", i, w.rev, w.op, r)
+		}
+		if r.Actor != "ana@biblioteca.org" || r.RequestID != "req-1" {
+			t.Fatalf("revision %d: esperaba el actor y el request id del context, vino %q y %q", i, r.Actor, r.RequestID)
+		}
+		if r.Despues.Version != w.rev || r.Fecha.IsZero() || !r.Fecha.Equal(r.Despues.Modificado) {
+			t.Fatalf("revision %d: el despues tiene que estar en la version %d y modificado en la fecha, vino %+v", i, w.rev, r)
+		}
+		if (r.Antes == nil) != (w.op == models.RevisionCrear) {
+			t.Fatalf("revision %d: solo el alta va sin antes, vino %+v", i, r.Antes)
+		}
+	}
+
+	if modif := revs[3]; modif.Antes.Titulo != "Dune" || modif.Despues.Titulo != "Dune (ed. 1965)" {
+		t.Fatalf("el PATCH tiene que guardar el titulo de antes y el de despues, vino %+v -> %+v", modif.Antes, modif.Despues)
+	}
+	if revs[1].Despues.Borrado == nil || revs[0].Despues.Borrado != nil {
+		t.Fatalf("el borrado tiene que quedar con Borrado y el restaurado sin, vino %+v y %+v", revs[1].Despues, revs[0].Despues)
+	}
+
+	pagina, err := repo.Historial(ctx, l.ID, models.RevisionFilter{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(pagina) != 2 || pagina[0].Rev != 4 || pagina[1].Rev != 3 {
+		t.Fatalf("limit 2 offset 1: esperaba las revisiones 4 y 3, vino %+v", pagina)
+	}
+
+	purgar(t, repo, l.ID)
+
+	revs, err = repo.Historial(ctx, l.ID, models.RevisionFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("Historial de un libro purgado: error inesperado: %v", err)
+	}
+	if len(revs) != 6 || revs[0].Operacion != models.RevisionBorrar {
+		t.Fatalf("despues de purgar esperaba las 6 revisiones, la ultima el borrado, vino %+v", revs)
+	}
+}
+
+// testHistorialNegativos: como en GetAll, limit u offset negativos son un error y no un panic
+func testHistorialNegativos(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name   string
+		filter models.RevisionFilter
+	}{
+		{"limit negativo", models.RevisionFilter{Limit: -1}},
+		{"offset negativo", models.RevisionFilter{Limit: 10, Offset: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(t)
+			ctx := context.Background()
+
+			l, err := repo.Create(ctx, seed[0])
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if _, err := repo.Historial(ctx, l.ID, tt.filter); err == nil {
+				t.Fatal("esperaba un error")
+			}
+		})
+	}
+}
+
+// testEnFecha: el libro se puede ver como estaba en cualquier momento desde que se creo
+func testEnFecha(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	// entre escritura y escritura pasa un poco de tiempo, asi las fechas no empatan
+	escribir := func(f func() error) {
+		t.Helper()
+		if err := f(); err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	var l *models.Libro
+	escribir(func() (err error) {
+		l, err = repo.Create(ctx, seed[0])
+		return err
+	})
+	escribir(func() error {
+		_, err := repo.Patch(ctx, l.ID, models.LibroPatch{Ano: ptr(1966)})
+		return err
+	})
+	escribir(func() error { return repo.Delete(ctx, l.ID) })
+
+	revs, err := repo.Historial(ctx, l.ID, models.RevisionFilter{Limit: 50})
+	if err != nil || len(revs) != 3 {
+		t.Fatalf("esperaba 3 revisiones, vino %+v (%v)", revs, err)
+	}
+	creado, modificado, borrado := revs[2].Fecha, revs[1].Fecha, revs[0].Fecha
+
+	tests := []struct {
+		name        string
+		t           time.Time
+		wantVersion int
+		wantAno     int
+		wantBorrado bool
+	}{
+		{"justo al crearse", creado, 1, 1965, false},
+		{"antes de modificarse", modificado.Add(-time.Microsecond), 1, 1965, false},
+		{"al modificarse", modificado, 2, 1966, false},
+		{"en la papelera", borrado.Add(time.Hour), 3, 1966, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.EnFecha(ctx, l.ID, tt.t)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if got.Version != tt.wantVersion || got.Ano != tt.wantAno || (got.Borrado != nil) != tt.wantBorrado {
+				t.Fatalf("esperaba la version %d con ano %d (borrado %v), vino %+v", tt.wantVersion, tt.wantAno, tt.wantBorrado, got)
+			}
+		})
+	}
+
+	if _, err := repo.EnFecha(ctx, l.ID, creado.Add(-time.Microsecond)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("antes de crearse: esperaba ErrNotFound, vino %v", err)
+	}
+}
+
+// testRevertir: revertir vuelve a los datos de una revision vieja como un cambio mas
+func testRevertir(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	const isbn = "9780441013593"
+
+	l, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune", Autor: "Frank Herbert", Ano: 1965, ISBN: isbn})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.Patch(ctx, l.ID, models.LibroPatch{Titulo: ptr("Dune Mesías")}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.Patch(ctx, l.ID, models.LibroPatch{Ano: ptr(1969)}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if _, err := repo.Revertir(ctx, l.ID, 1, 2); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Fatalf("Revertir con version vieja: esperaba ErrVersionConflict, vino %v", err)
+	}
+	if _, err := repo.Revertir(ctx, l.ID, 99, 0); !errors.Is(err, repository.ErrRevisionNotFound) {
+		t.Fatalf("Revertir a una revision que no existe: esperaba ErrRevisionNotFound, vino %v", err)
+	}
+
+	got, err := repo.Revertir(ctx, l.ID, 1, 3)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if got.Titulo != "Dune" || got.Ano != 1965 || got.ISBN != isbn || got.Version != 4 {
+		t.Fatalf("esperaba Dune 1965 en la version 4, vino %+v", got)
+	}
+
+	revs, err := repo.Historial(ctx, l.ID, models.RevisionFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(revs) != 1 || revs[0].Operacion != models.RevisionRevertir || revs[0].Antes.Titulo != "Dune Mesías" {
+		t.Fatalf("esperaba la revision de revertir con el titulo de antes, vino %+v", revs)
+	}
+
+	// si el ISBN de esa revision ahora lo tiene otro libro, no se puede volver
+	if _, err := repo.Patch(ctx, l.ID, models.LibroPatch{ISBN: ptr("")}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	otro, err := repo.Create(ctx, models.LibroInput{Titulo: "Dune (reedicion)", Autor: "Frank Herbert", Ano: 2005, ISBN: isbn})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	var dup *repository.ISBNDuplicadoError
+	if _, err := repo.Revertir(ctx, l.ID, 1, 0); !errors.As(err, &dup) || dup.ID != otro.ID {
+		t.Fatalf("esperaba un ISBNDuplicadoError con el libro %d, vino %v", otro.ID, err)
+	}
+
+	// un libro en la papelera no se revierte: primero hay que restaurarlo
+	if err := repo.Delete(ctx, l.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.Revertir(ctx, l.ID, 1, 0); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Revertir un libro borrado: esperaba ErrNotFound, vino %v", err)
+	}
+}
+
+func testBatch(t *testing.T, newRepo Factory) {
+	const isbn, otroISBN = "9780441013593", "9780306406157"
+
+	aleph := func(isbn string) *models.LibroPatch {
+		return &models.LibroPatch{Titulo: ptr("El Aleph"), Autor: ptr("Jorge Luis Borges"), Ano: ptr(1949), ISBN: ptr(isbn)}
+	}
+
+	t.Run("sin atomic cada una por su cuenta", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := load(t, repo)
+		ctx := context.Background()
+
+		res, err := repo.Batch(ctx, []models.LibroOp{
+			{Op: models.RevisionCrear, Libro: aleph(isbn)},
+			{Op: models.RevisionCrear, Libro: aleph(isbn)}, // el ISBN lo acaba de tomar la anterior
+			{Op: models.RevisionReemplazar, ID: ids[0], Version: 1, Libro: &models.LibroPatch{Titulo: ptr("Dune"), Autor: ptr("Frank Herbert"), Ano: ptr(1966)}},
+			{Op: models.RevisionModificar, ID: ids[1], Version: 99, Libro: &models.LibroPatch{Ano: ptr(1950)}},
+			{Op: models.RevisionBorrar, ID: ids[2]},
+			{Op: models.RevisionModificar, ID: 999999, Libro: &models.LibroPatch{Ano: ptr(1950)}},
+			{Op: models.RevisionModificar, ID: ids[3], Libro: &models.LibroPatch{}},
+		}, false)
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if len(res) != 7 {
+			t.Fatalf("esperaba 7 resultados, vinieron %d", len(res))
+		}
+
+		if res[0].Err != nil || res[0].Libro.ISBN != isbn || res[0].Libro.Version != 1 {
+			t.Fatalf("crear: esperaba el libro nuevo, vino %+v", res[0])
+		}
+		var dup *repository.ISBNDuplicadoError
+		if !errors.As(res[1].Err, &dup) || dup.ID != res[0].Libro.ID {
+			t.Fatalf("crear con ISBN usado: esperaba un ISBNDuplicadoError con el libro %d, vino %+v", res[0].Libro.ID, res[1])
+		}
+		if res[2].Err != nil || res[2].Libro.Ano != 1966 || res[2].Libro.Version != 2 {
+			t.Fatalf("reemplazar: esperaba 1966 en la version 2, vino %+v", res[2])
+		}
+		if !errors.Is(res[3].Err, repository.ErrVersionConflict) {
+			t.Fatalf("modificar con otra version: esperaba ErrVersionConflict, vino %+v", res[3])
+		}
+		if res[4].Err != nil || res[4].Libro.Borrado == nil {
+			t.Fatalf("borrar: esperaba el libro en la papelera, vino %+v", res[4])
+		}
+		if !errors.Is(res[5].Err, repository.ErrNotFound) {
+			t.Fatalf("modificar uno que no existe: esperaba ErrNotFound, vino %+v", res[5])
+		}
+		if res[6].Err != nil || res[6].Libro.Version != 1 {
+			t.Fatalf("modificar sin campos: esperaba el libro sin cambios, vino %+v", res[6])
+		}
+
+		if _, err := repo.GetByID(ctx, ids[2]); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("el borrado tendria que estar en la papelera, vino %v", err)
+		}
+		if l, err := repo.GetByID(ctx, ids[1]); err != nil || l.Ano != 1949 {
+			t.Fatalf("el del conflicto no tendria que cambiar, vino %+v %v", l, err)
+		}
+
+		// cada cambio deja su revision, como si hubiera venido por su endpoint
+		revs, err := repo.Historial(ctx, ids[0], models.RevisionFilter{Limit: 10})
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if len(revs) != 2 || revs[0].Operacion != models.RevisionReemplazar || revs[0].Antes.Ano != 1965 {
+			t.Fatalf("esperaba la revision de reemplazar con el ano de antes, vino %+v", revs)
+		}
+		revs, err = repo.Historial(ctx, res[0].Libro.ID, models.RevisionFilter{Limit: 10})
+		if err != nil || len(revs) != 1 || revs[0].Operacion != models.RevisionCrear {
+			t.Fatalf("esperaba la revision de crear, vino %+v %v", revs, err)
+		}
+	})
+
+	t.Run("atomic no deja nada si una falla", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := load(t, repo)
+		ctx := context.Background()
+
+		_, err := repo.Batch(ctx, []models.LibroOp{
+			{Op: models.RevisionCrear, Libro: aleph(otroISBN)},
+			{Op: models.RevisionBorrar, ID: ids[4]},
+			{Op: models.RevisionModificar, ID: ids[0], Libro: &models.LibroPatch{Ano: ptr(1966)}},
+			{Op: models.RevisionCrear, Libro: aleph(otroISBN)},
+		}, true)
+
+		var batchErr *repository.BatchError
+		var dup *repository.ISBNDuplicadoError
+		if !errors.As(err, &batchErr) || batchErr.Indice != 3 || !errors.As(err, &dup) {
+			t.Fatalf("esperaba un BatchError con un ISBN duplicado en la operacion 3, vino %v", err)
+		}
+
+		total, err := repo.Count(ctx, models.LibroFilter{})
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		if total != len(seed) {
+			t.Fatalf("esperaba los %d libros de antes, hay %d", len(seed), total)
+		}
+		if l, err := repo.GetByID(ctx, ids[0]); err != nil || l.Ano != 1965 || l.Version != 1 {
+			t.Fatalf("el modificado tendria que quedar como estaba, vino %+v %v", l, err)
+		}
+		if _, err := repo.GetByID(ctx, ids[4]); err != nil {
+			t.Fatalf("el borrado tendria que seguir, vino %v", err)
+		}
+		if revs, err := repo.Historial(ctx, ids[0], models.RevisionFilter{Limit: 10}); err != nil || len(revs) != 1 {
+			t.Fatalf("no tendria que quedar ninguna revision del batch, vino %+v %v", revs, err)
+		}
+
+		// el mismo batch sin la que fallaba entra entero, y los ids siguen en orden
+		res, err := repo.Batch(ctx, []models.LibroOp{
+			{Op: models.RevisionCrear, Libro: aleph(otroISBN)},
+			{Op: models.RevisionBorrar, ID: ids[4]},
+			{Op: models.RevisionModificar, ID: ids[0], Version: 1, Libro: &models.LibroPatch{Ano: ptr(1966)}},
+		}, true)
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		for i, r := range res {
+			if r.Err != nil {
+				t.Fatalf("operacion %d: error inesperado: %v", i, r.Err)
+			}
+		}
+		if res[0].Libro.ID <= ids[4] || res[2].Libro.Ano != 1966 {
+			t.Fatalf("resultados inesperados: %+v", res)
+		}
+	})
+}
+
+// ---------- HELPERS ----------
+
+// purgar borra el libro de verdad (papelera y purga), como hacia Delete antes de la papelera
+func purgar(t *testing.T, repo repository.LibrosRepository, id int) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := repo.Delete(ctx, id); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.Purgar(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+// load carga seed y devuelve los ids asignados, en el mismo orden
+func load(t *testing.T, repo repository.LibrosRepository) []int {
+	t.Helper()
+
+	ids := make([]int, 0, len(seed))
+	for _, in := range seed {
+		l, err := repo.Create(context.Background(), in)
+		if err != nil {
+			t.Fatalf("error cargando libros: %v", err)
+		}
+		ids = append(ids, l.ID)
+	}
+	return ids
+}
+
+func pick(ids []int, pos []int) []int {
+	out := make([]int, 0, len(pos))
+	for _, p := range pos {
+		out = append(out, ids[p])
+	}
+	return out
+}
+
+func assertIDs(t *testing.T, libros []models.Libro, want []int) {
+	t.Helper()
+
+	// un resultado vacio tiene que ser [] y no nil, asi el handler responde [] en vez de null
+	if libros == nil {
+		t.Fatalf("GetAll devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(libros) != len(want) {
+		t.Fatalf("esperaba ids %v, vinieron %d libros: %+v", want, len(libros), libros)
+	}
+
+	for i, l := range libros {
+		if l.ID != want[i] {
+			t.Fatalf("posicion %d: esperaba id %d, vino %d (esperados %v)", i, want[i], l.ID, want)
+		}
+	}
+}
+
+// sortBy arma un orden a partir de "campo" o "-campo"
+func sortBy(campos ...string) []models.SortField {
+	sort := make([]models.SortField, 0, len(campos))
+	for _, c := range campos {
+		campo, desc := strings.CutPrefix(c, "-")
+		sort = append(sort, models.SortField{Campo: campo, Desc: desc})
+	}
+	return sort
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
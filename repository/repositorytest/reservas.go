@@ -0,0 +1,420 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// RunReservas es la especificacion de repository.ReservasRepository y de como las reservas se
+// cruzan con los prestamos (devolver aparta, prestar retira) y los ejemplares.
+func RunReservas(t *testing.T, newRepos CirculacionFactory) {
+	t.Run("Reservar", func(t *testing.T) { testReservasReservar(t, newRepos) })
+	t.Run("Reservar/Errores", func(t *testing.T) { testReservasReservarErrores(t, newRepos) })
+	t.Run("DevolverAparta", func(t *testing.T) { testReservasDevolverAparta(t, newRepos) })
+	t.Run("Retirar", func(t *testing.T) { testReservasRetirar(t, newRepos) })
+	t.Run("Cancelar", func(t *testing.T) { testReservasCancelar(t, newRepos) })
+	t.Run("Atender", func(t *testing.T) { testReservasAtender(t, newRepos) })
+	t.Run("Concurrencia", func(t *testing.T) { testReservasConcurrencia(t, newRepos) })
+	t.Run("GetAll", func(t *testing.T) { testReservasGetAll(t, newRepos) })
+	t.Run("Borrar", func(t *testing.T) { testReservasBorrar(t, newRepos) })
+}
+
+// loadCola deja el libro con un solo ejemplar (el segundo libro) prestado al primer socio y
+// a los otros dos en la cola, en orden
+func loadCola(t *testing.T, newRepos CirculacionFactory) (circulacion, *models.Prestamo, []models.Reserva) {
+	t.Helper()
+
+	c := loadCirculacion(t, newRepos)
+	p := c.prestar(t, c.ejemplares[2], c.socios[0], hoy)
+
+	cola := []models.Reserva{
+		*c.reservar(t, c.libros[1], c.socios[1]),
+		*c.reservar(t, c.libros[1], c.socios[2]),
+	}
+
+	return c, p, cola
+}
+
+func nuevaReserva(libro, socio int) models.NuevaReserva {
+	return models.NuevaReserva{LibroID: libro, SocioID: socio, Creada: hoy, RetirarHasta: hoy.AddDays(3)}
+}
+
+func (c circulacion) reservar(t *testing.T, libro, socio int) *models.Reserva {
+	t.Helper()
+
+	r, err := c.Reservas.Reservar(context.Background(), nuevaReserva(libro, socio))
+	if err != nil {
+		t.Fatalf("error reservando: %v", err)
+	}
+	return r
+}
+
+func (c circulacion) reserva(t *testing.T, id int) models.Reserva {
+	t.Helper()
+
+	r, err := c.Reservas.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("error leyendo la reserva %d: %v", id, err)
+	}
+	return *r
+}
+
+func testReservasReservar(t *testing.T, newRepos CirculacionFactory) {
+	c, _, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	want := []models.Reserva{
+		{ID: cola[0].ID, LibroID: c.libros[1], SocioID: c.socios[1], Estado: models.ReservaEsperando, Creada: hoy, Posicion: 1},
+		{ID: cola[1].ID, LibroID: c.libros[1], SocioID: c.socios[2], Estado: models.ReservaEsperando, Creada: hoy, Posicion: 2},
+	}
+	for i, r := range cola {
+		if r.ID == 0 || r != want[i] {
+			t.Fatalf("Reservar devolvio %+v, esperaba %+v", r, want[i])
+		}
+		if leida := c.reserva(t, r.ID); leida != r {
+			t.Fatalf("GetByID devolvio %+v, esperaba %+v", leida, r)
+		}
+	}
+
+	got, err := c.Reservas.GetByLibro(ctx, c.libros[1])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertReservas(t, got, []int{cola[0].ID, cola[1].ID})
+
+	if _, err := c.Reservas.GetByLibro(ctx, c.libros[1]+1000); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByLibro de un libro inexistente: esperaba ErrNotFound, vino %v", err)
+	}
+	if _, err := c.Reservas.GetByID(ctx, cola[1].ID+1000); !errors.Is(err, repository.ErrReservaNotFound) {
+		t.Fatalf("GetByID inexistente: esperaba ErrReservaNotFound, vino %v", err)
+	}
+}
+
+func testReservasReservarErrores(t *testing.T, newRepos CirculacionFactory) {
+	c, _, _ := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		nr      models.NuevaReserva
+		wantErr error
+	}{
+		{"ya esta en la cola", nuevaReserva(c.libros[1], c.socios[1]), repository.ErrReservaDuplicada},
+		{"hay ejemplares libres", nuevaReserva(c.libros[0], c.socios[1]), repository.ErrHayDisponibles},
+		{"libro inexistente", nuevaReserva(c.libros[1]+1000, c.socios[1]), repository.ErrNotFound},
+		{"socio inexistente", nuevaReserva(c.libros[1], c.socios[2]+1000), repository.ErrSocioNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Reservas.Reservar(ctx, tt.nr); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("esperaba %v, vino %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// el que tiene el libro prestado tambien puede hacer cola (para cuando lo devuelva y se lo lleve otro)
+	c.reservar(t, c.libros[1], c.socios[0])
+}
+
+// testReservasDevolverAparta: al devolver, el ejemplar queda apartado para el primero de la cola
+func testReservasDevolverAparta(t *testing.T, newRepos CirculacionFactory) {
+	c, p, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy.AddDays(2))); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	primera := c.reserva(t, cola[0].ID)
+	want := models.Reserva{
+		ID: cola[0].ID, LibroID: c.libros[1], SocioID: c.socios[1], Estado: models.ReservaDisponible, Creada: hoy,
+		EjemplarID: c.ejemplares[2], RetirarHasta: hoy.AddDays(5),
+	}
+	if primera != want {
+		t.Fatalf("la primera de la cola quedo %+v, esperaba %+v", primera, want)
+	}
+	if segunda := c.reserva(t, cola[1].ID); segunda.Estado != models.ReservaEsperando || segunda.Posicion != 1 {
+		t.Fatalf("la segunda tendria que ser la proxima de la cola: %+v", segunda)
+	}
+
+	got, err := c.Ejemplares.Disponibilidad(ctx, c.libros[1])
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if want := (models.Disponibilidad{Total: 1, Apartados: 1, EnEspera: 1}); got != want {
+		t.Fatalf("Disponibilidad devolvio %+v, esperaba %+v", got, want)
+	}
+
+	// apartado no se puede borrar ni hay lugar para reservar sin hacer cola
+	if err := c.Ejemplares.Delete(ctx, c.libros[1], c.ejemplares[2]); !errors.Is(err, repository.ErrEjemplarReservado) {
+		t.Fatalf("Delete de un ejemplar apartado: esperaba ErrEjemplarReservado, vino %v", err)
+	}
+	if err := c.Libros.Delete(ctx, c.libros[1]); !errors.Is(err, repository.ErrEjemplarReservado) {
+		t.Fatalf("Delete del libro con un ejemplar apartado: esperaba ErrEjemplarReservado, vino %v", err)
+	}
+	c.reservar(t, c.libros[1], c.socios[0])
+}
+
+// testReservasRetirar: el ejemplar apartado solo se lo lleva el socio de la reserva
+func testReservasRetirar(t *testing.T, newRepos CirculacionFactory) {
+	c, p, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	for _, socio := range []int{c.socios[0], c.socios[2]} {
+		if _, err := c.Prestamos.Prestar(ctx, nuevoPrestamo(c.ejemplares[2], socio, hoy)); !errors.Is(err, repository.ErrEjemplarReservado) {
+			t.Fatalf("Prestar a otro socio: esperaba ErrEjemplarReservado, vino %v", err)
+		}
+	}
+
+	c.prestar(t, c.ejemplares[2], c.socios[1], hoy.AddDays(1))
+
+	if r := c.reserva(t, cola[0].ID); r.Estado != models.ReservaRetirada {
+		t.Fatalf("despues de llevarselo la reserva tendria que estar retirada: %+v", r)
+	}
+	if r := c.reserva(t, cola[1].ID); r.Estado != models.ReservaEsperando || r.Posicion != 1 {
+		t.Fatalf("la segunda sigue esperando, ahora primera: %+v", r)
+	}
+
+	// los ejemplares sin reserva se siguen prestando normalmente
+	c.prestar(t, c.ejemplares[0], c.socios[2], hoy)
+}
+
+func testReservasCancelar(t *testing.T, newRepos CirculacionFactory) {
+	c, p, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	cancelada, err := c.Reservas.Cancelar(ctx, cola[0].ID, hoy.AddDays(4))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if cancelada.Estado != models.ReservaCancelada {
+		t.Fatalf("Cancelar devolvio %+v, esperaba cancelada", cancelada)
+	}
+
+	// el ejemplar que tenia apartado pasa al siguiente
+	siguiente := c.reserva(t, cola[1].ID)
+	if siguiente.Estado != models.ReservaDisponible || siguiente.EjemplarID != c.ejemplares[2] || siguiente.RetirarHasta != hoy.AddDays(4) {
+		t.Fatalf("el ejemplar tendria que haber pasado a la siguiente: %+v", siguiente)
+	}
+
+	if _, err := c.Reservas.Cancelar(ctx, cola[0].ID, hoy); !errors.Is(err, repository.ErrReservaCerrada) {
+		t.Fatalf("Cancelar dos veces: esperaba ErrReservaCerrada, vino %v", err)
+	}
+	if _, err := c.Reservas.Cancelar(ctx, cola[1].ID+1000, hoy); !errors.Is(err, repository.ErrReservaNotFound) {
+		t.Fatalf("Cancelar inexistente: esperaba ErrReservaNotFound, vino %v", err)
+	}
+
+	// la ultima se cancela y el ejemplar queda libre
+	if _, err := c.Reservas.Cancelar(ctx, cola[1].ID, hoy); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	c.prestar(t, c.ejemplares[2], c.socios[0], hoy)
+}
+
+// testReservasAtender: las que no se retiran a tiempo vencen y los ejemplares nuevos se reparten
+func testReservasAtender(t *testing.T, newRepos CirculacionFactory) {
+	c, p, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	// el ultimo dia para retirarlo todavia no vence
+	cambiadas, err := c.Reservas.Atender(ctx, hoy.AddDays(3), hoy.AddDays(6))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertReservas(t, cambiadas, []int{})
+
+	cambiadas, err = c.Reservas.Atender(ctx, hoy.AddDays(4), hoy.AddDays(7))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertReservas(t, cambiadas, []int{cola[0].ID, cola[1].ID})
+
+	if r := c.reserva(t, cola[0].ID); r.Estado != models.ReservaVencida {
+		t.Fatalf("la primera tendria que estar vencida: %+v", r)
+	}
+	if r := c.reserva(t, cola[1].ID); r.Estado != models.ReservaDisponible || r.EjemplarID != c.ejemplares[2] || r.RetirarHasta != hoy.AddDays(7) {
+		t.Fatalf("el ejemplar tendria que haber pasado a la segunda: %+v", r)
+	}
+
+	// un ejemplar nuevo se aparta enseguida para el primero de la cola, sin esperar a la proxima pasada
+	tercera := c.reservar(t, c.libros[1], c.socios[0])
+	e, err := c.Ejemplares.Create(ctx, c.libros[1], ejemplarInput("B-0002"), hoy.AddDays(8))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	if r := c.reserva(t, tercera.ID); r.Estado != models.ReservaDisponible || r.EjemplarID != e.ID || r.RetirarHasta != hoy.AddDays(8) {
+		t.Fatalf("el ejemplar nuevo tendria que estar apartado para la tercera: %+v", r)
+	}
+
+	cambiadas, err = c.Reservas.Atender(ctx, hoy.AddDays(5), hoy.AddDays(8))
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertReservas(t, cambiadas, []int{})
+
+	// nadie se lo lleva antes que la cola
+	if _, err := c.Prestamos.Prestar(ctx, nuevoPrestamo(e.ID, c.socios[2], hoy.AddDays(5))); !errors.Is(err, repository.ErrEjemplarReservado) {
+		t.Fatalf("se esperaba ErrEjemplarReservado, se obtuvo %v", err)
+	}
+	c.prestar(t, e.ID, c.socios[0], hoy.AddDays(5))
+
+	if r := c.reserva(t, tercera.ID); r.Estado != models.ReservaRetirada {
+		t.Fatalf("la tercera tendria que estar retirada: %+v", r)
+	}
+}
+
+// testReservasConcurrencia: devoluciones y pasadas de Atender a la vez nunca le dan el mismo
+// ejemplar a dos reservas ni dos ejemplares a la misma
+func testReservasConcurrencia(t *testing.T, newRepos CirculacionFactory) {
+	c := loadCirculacion(t, newRepos)
+	ctx := context.Background()
+
+	prestamos := []*models.Prestamo{
+		c.prestar(t, c.ejemplares[0], c.socios[0], hoy),
+		c.prestar(t, c.ejemplares[1], c.socios[0], hoy),
+	}
+	cola := []int{
+		c.reservar(t, c.libros[0], c.socios[1]).ID,
+		c.reservar(t, c.libros[0], c.socios[2]).ID,
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(prestamos))
+
+	for _, p := range prestamos {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy))
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.Reservas.Atender(ctx, hoy, hoy.AddDays(3))
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+	}
+
+	apartados := map[int]bool{}
+	for _, id := range cola {
+		r := c.reserva(t, id)
+		if r.Estado != models.ReservaDisponible || apartados[r.EjemplarID] {
+			t.Fatalf("cada reserva tendria que tener su propio ejemplar: %+v (ya apartados %v)", r, apartados)
+		}
+		apartados[r.EjemplarID] = true
+	}
+}
+
+func testReservasGetAll(t *testing.T, newRepos CirculacionFactory) {
+	c, _, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	otra := c.reservar(t, c.libros[1], c.socios[0])
+	if _, err := c.Reservas.Cancelar(ctx, cola[1].ID, hoy); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter models.ReservaFilter
+		want   []int
+	}{
+		{"todas", models.ReservaFilter{Limit: 50}, []int{cola[0].ID, cola[1].ID, otra.ID}},
+		{"activas", models.ReservaFilter{Activas: true, Limit: 50}, []int{cola[0].ID, otra.ID}},
+		{"por socio", models.ReservaFilter{SocioID: &c.socios[2], Limit: 50}, []int{cola[1].ID}},
+		{"por libro", models.ReservaFilter{LibroID: &c.libros[0], Limit: 50}, []int{}},
+		{"limit y offset", models.ReservaFilter{Limit: 1, Offset: 1}, []int{cola[1].ID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.Reservas.GetAll(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			assertReservas(t, got, tt.want)
+		})
+	}
+
+	// con la segunda cancelada, la otra paso a estar segunda en la cola
+	if r := c.reserva(t, otra.ID); r.Posicion != 2 {
+		t.Fatalf("esperaba posicion 2, vino %+v", r)
+	}
+}
+
+// testReservasBorrar: las reservas se van con el socio y con el libro (cuando se purga)
+func testReservasBorrar(t *testing.T, newRepos CirculacionFactory) {
+	c, p, cola := loadCola(t, newRepos)
+	ctx := context.Background()
+
+	if err := c.Socios.Delete(ctx, c.socios[2]); err != nil {
+		t.Fatalf("un socio que solo tiene reservas se puede borrar: %v", err)
+	}
+	if _, err := c.Reservas.GetByID(ctx, cola[1].ID); !errors.Is(err, repository.ErrReservaNotFound) {
+		t.Fatalf("esperaba ErrReservaNotFound, vino %v", err)
+	}
+
+	// el libro no va a la papelera con el ejemplar prestado ni apartado: primero se devuelve y se
+	// cancela la reserva que se lo quedo
+	if _, err := c.Prestamos.Devolver(ctx, p.ID, devolucion(hoy)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := c.Reservas.Cancelar(ctx, cola[0].ID, hoy.AddDays(3)); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+
+	purgar(t, c.Libros, c.libros[1])
+	if _, err := c.Reservas.GetByID(ctx, cola[0].ID); !errors.Is(err, repository.ErrReservaNotFound) {
+		t.Fatalf("esperaba ErrReservaNotFound, vino %v", err)
+	}
+
+	got, err := c.Reservas.GetAll(ctx, models.ReservaFilter{Limit: 50})
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	assertReservas(t, got, []int{})
+}
+
+func assertReservas(t *testing.T, reservas []models.Reserva, want []int) {
+	t.Helper()
+
+	if reservas == nil {
+		t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+	}
+
+	if len(reservas) != len(want) {
+		t.Fatalf("esperaba reservas %v, vinieron %+v", want, reservas)
+	}
+	for i, r := range reservas {
+		if r.ID != want[i] {
+			t.Fatalf("esperaba reservas %v, vinieron %+v", want, reservas)
+		}
+	}
+}
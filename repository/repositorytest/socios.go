@@ -0,0 +1,142 @@
+package repositorytest
+
+import (
+	"api-libros/models"
+	"api-libros/repository"
+	"context"
+	"errors"
+	"testing"
+)
+
+// SociosFactory devuelve un repo de socios VACIO.
+type SociosFactory func(t *testing.T) repository.SociosRepository
+
+// RunSocios es la especificacion de repository.SociosRepository. Lo que tiene que ver con
+// prestamos (no borrar un socio con prestamos) esta en RunPrestamos.
+func RunSocios(t *testing.T, newRepo SociosFactory) {
+	t.Run("CRUD", func(t *testing.T) { testSociosCRUD(t, newRepo) })
+	t.Run("EmailDuplicado", func(t *testing.T) { testSociosEmailDuplicado(t, newRepo) })
+	t.Run("GetAll", func(t *testing.T) { testSociosGetAll(t, newRepo) })
+}
+
+func loadSocios(t *testing.T, repo repository.SociosRepository) []int {
+	t.Helper()
+
+	socios := []models.SocioInput{
+		{Nombre: "Ana Pérez", Email: "ana@example.com"},
+		{Nombre: "Bruno Díaz", Email: "bruno@example.com"},
+		{Nombre: "Carla Anaya", Email: "carla@example.com"},
+	}
+
+	ids := make([]int, 0, len(socios))
+	for _, in := range socios {
+		s, err := repo.Create(context.Background(), in)
+		if err != nil {
+			t.Fatalf("error cargando socios: %v", err)
+		}
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func testSociosCRUD(t *testing.T, newRepo SociosFactory) {
+	repo := newRepo(t)
+	ctx := context.Background()
+
+	in := models.SocioInput{Nombre: "Ana Pérez", Email: "ana@example.com"}
+	creado, err := repo.Create(ctx, in)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if creado.ID == 0 || creado.Nombre != in.Nombre || creado.Email != in.Email {
+		t.Fatalf("Create devolvio %+v, esperaba los datos de %+v", creado, in)
+	}
+
+	leido, err := repo.GetByID(ctx, creado.ID)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if *leido != *creado {
+		t.Fatalf("GetByID devolvio %+v, esperaba %+v", leido, creado)
+	}
+
+	upd := models.SocioInput{Nombre: "Ana María Pérez", Email: "ana.maria@example.com"}
+	editado, err := repo.Update(ctx, creado.ID, upd)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if editado.Nombre != upd.Nombre || editado.Email != upd.Email {
+		t.Fatalf("Update devolvio %+v, esperaba los datos de %+v", editado, upd)
+	}
+
+	if err := repo.Delete(ctx, creado.ID); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, creado.ID); !errors.Is(err, repository.ErrSocioNotFound) {
+		t.Fatalf("GetByID despues de Delete: esperaba ErrSocioNotFound, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, creado.ID, upd); !errors.Is(err, repository.ErrSocioNotFound) {
+		t.Fatalf("Update despues de Delete: esperaba ErrSocioNotFound, vino %v", err)
+	}
+	if err := repo.Delete(ctx, creado.ID); !errors.Is(err, repository.ErrSocioNotFound) {
+		t.Fatalf("Delete dos veces: esperaba ErrSocioNotFound, vino %v", err)
+	}
+}
+
+func testSociosEmailDuplicado(t *testing.T, newRepo SociosFactory) {
+	repo := newRepo(t)
+	ids := loadSocios(t, repo)
+	ctx := context.Background()
+
+	// el email no distingue mayusculas
+	if _, err := repo.Create(ctx, models.SocioInput{Nombre: "Otra Ana", Email: "ANA@example.com"}); !errors.Is(err, repository.ErrSocioDuplicado) {
+		t.Fatalf("Create: esperaba ErrSocioDuplicado, vino %v", err)
+	}
+	if _, err := repo.Update(ctx, ids[1], models.SocioInput{Nombre: "Bruno Díaz", Email: "Ana@Example.com"}); !errors.Is(err, repository.ErrSocioDuplicado) {
+		t.Fatalf("Update: esperaba ErrSocioDuplicado, vino %v", err)
+	}
+
+	// guardar con su propio email (aunque cambie mayusculas) no es un duplicado
+	if _, err := repo.Update(ctx, ids[0], models.SocioInput{Nombre: "Ana Pérez", Email: "Ana@example.com"}); err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+}
+
+func testSociosGetAll(t *testing.T, newRepo SociosFactory) {
+	repo := newRepo(t)
+	ids := loadSocios(t, repo)
+
+	tests := []struct {
+		name   string
+		filter models.SocioFilter
+		want   []int
+	}{
+		{"todos", models.SocioFilter{Limit: 50}, ids},
+		{"nombre parcial sin mayusculas", models.SocioFilter{Nombre: ptr("ANA"), Limit: 50}, pick(ids, []int{0, 2})},
+		{"limit", models.SocioFilter{Limit: 2}, pick(ids, []int{0, 1})},
+		{"offset", models.SocioFilter{Limit: 50, Offset: 2}, pick(ids, []int{2})},
+		{"offset fuera de rango", models.SocioFilter{Limit: 50, Offset: 10}, []int{}},
+		{"sin coincidencias", models.SocioFilter{Nombre: ptr("zzz"), Limit: 50}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAll(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+
+			if got == nil {
+				t.Fatalf("se devolvio nil, esperaba un slice (aunque sea vacio)")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("esperaba socios %v, vinieron %+v", tt.want, got)
+			}
+			for i, s := range got {
+				if s.ID != tt.want[i] {
+					t.Fatalf("posicion %d: esperaba socio %d, vino %d (esperados %v)", i, tt.want[i], s.ID, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrReservaNotFound   = errors.New("reserva not found")
+	ErrReservaDuplicada  = errors.New("el socio ya esta en la cola de ese libro")
+	ErrReservaCerrada    = errors.New("la reserva ya no esta en la cola")
+	ErrHayDisponibles    = errors.New("el libro tiene ejemplares disponibles")
+	ErrEjemplarReservado = errors.New("el ejemplar esta apartado para otra reserva")
+)
+
+// ReservasRepository maneja la cola de reservas de cada libro. Como los prestamos, cada operacion
+// corre en una transaccion: dos devoluciones simultaneas nunca le apartan un ejemplar a la misma
+// reserva ni el mismo ejemplar a dos reservas.
+//
+// Devolver un prestamo (PrestamosRepository.Devolver) ya le aparta el ejemplar al primero de la cola,
+// y Prestar solo deja llevarse un ejemplar apartado al socio de la reserva, que queda retirada.
+type ReservasRepository interface {
+	GetAll(ctx context.Context, filter models.ReservaFilter) ([]models.Reserva, error)
+	// GetByLibro devuelve la cola del libro (las reservas activas) en orden. ErrNotFound si no existe el libro.
+	GetByLibro(ctx context.Context, libroID int) ([]models.Reserva, error)
+	GetByID(ctx context.Context, id int) (*models.Reserva, error)
+
+	// Reservar pone al socio al final de la cola. Devuelve ErrNotFound si no existe el libro,
+	// ErrSocioNotFound, ErrReservaDuplicada si ya esta en la cola y ErrHayDisponibles si hay un
+	// ejemplar que no esta prestado ni apartado (que lo pida prestado directamente).
+	Reservar(ctx context.Context, r models.NuevaReserva) (*models.Reserva, error)
+	// Cancelar saca la reserva de la cola (ErrReservaCerrada si ya no estaba). Si tenia un ejemplar
+	// apartado, pasa al siguiente de la cola con plazo hasta retirarHasta.
+	Cancelar(ctx context.Context, id int, retirarHasta models.Fecha) (*models.Reserva, error)
+	// Atender vence las reservas disponibles que no se retiraron antes de hoy y reparte los ejemplares
+	// libres entre las que esperan (los liberados por las vencidas y, por ejemplo, los recien comprados).
+	// Devuelve las reservas que cambiaron de estado.
+	Atender(ctx context.Context, hoy, retirarHasta models.Fecha) ([]models.Reserva, error)
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresReservasRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresReservasRepo(db *pgxpool.Pool) *PostgresReservasRepo {
+	return &PostgresReservasRepo{DB: db}
+}
+
+// la posicion se calcula: es cuantas reservas del libro esperan desde antes (o desde el mismo id)
+const reservaCols = `r.id, r.libro_id, r.socio_id, r.estado, r.creada,
+	CASE WHEN r.estado = 'esperando'
+	     THEN (SELECT count(*) FROM reservas c WHERE c.libro_id = r.libro_id AND c.estado = 'esperando' AND c.id <= r.id)
+	     ELSE 0 END,
+	coalesce(r.ejemplar_id, 0), r.retirar_hasta`
+
+// ejemplarLibre es la condicion de un ejemplar (alias e) que no esta prestado ni apartado para una reserva
+const ejemplarLibre = `NOT EXISTS (SELECT 1 FROM prestamos lp WHERE lp.ejemplar_id = e.id AND lp.devuelto IS NULL)
+	AND NOT EXISTS (SELECT 1 FROM reservas lr WHERE lr.ejemplar_id = e.id AND lr.estado = 'disponible')`
+
+func (repo *PostgresReservasRepo) GetAll(ctx context.Context, f models.ReservaFilter) ([]models.Reserva, error) {
+	query := `SELECT ` + reservaCols + ` FROM reservas r WHERE 1=1`
+	args := []any{}
+	i := 1
+
+	if f.SocioID != nil {
+		query += fmt.Sprintf(" AND r.socio_id = $%d", i)
+		args = append(args, *f.SocioID)
+		i++
+	}
+
+	if f.LibroID != nil {
+		query += fmt.Sprintf(" AND r.libro_id = $%d", i)
+		args = append(args, *f.LibroID)
+		i++
+	}
+
+	if f.Activas {
+		query += " AND r.estado IN ('esperando', 'disponible')"
+	}
+
+	query += fmt.Sprintf(" ORDER BY r.id LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := repo.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, reservasError(ctx, "GetAll", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanReserva)
+	if err != nil {
+		return nil, reservasError(ctx, "GetAll", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresReservasRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Reserva, error) {
+	var existe bool
+	if err := repo.DB.QueryRow(ctx, "SELECT "+libroVivo("$1"), libroID).Scan(&existe); err != nil {
+		return nil, reservasError(ctx, "GetByLibro", err)
+	}
+	if !existe {
+		return nil, ErrNotFound
+	}
+
+	// primero las que ya tienen ejemplar, despues la cola en orden de llegada
+	rows, err := repo.DB.Query(ctx, `SELECT `+reservaCols+` FROM reservas r
+		WHERE r.libro_id = $1 AND r.estado IN ('esperando', 'disponible')
+		ORDER BY r.estado = 'esperando', r.id`, libroID)
+	if err != nil {
+		return nil, reservasError(ctx, "GetByLibro", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanReserva)
+	if err != nil {
+		return nil, reservasError(ctx, "GetByLibro", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresReservasRepo) GetByID(ctx context.Context, id int) (*models.Reserva, error) {
+	r, err := getReserva(ctx, repo.DB, id, false)
+	if errors.Is(err, ErrReservaNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, reservasError(ctx, "GetByID", err)
+	}
+
+	return r, nil
+}
+
+func (repo *PostgresReservasRepo) Reservar(ctx context.Context, nr models.NuevaReserva) (*models.Reserva, error) {
+	var result *models.Reserva
+
+	err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		if err := bloquearLibro(ctx, tx, nr.LibroID); err != nil {
+			return err
+		}
+
+		// los libros de la papelera no toman reservas nuevas, las que ya tenian siguen hasta la purga
+		var vivo bool
+		if err := tx.QueryRow(ctx, "SELECT "+libroVivo("$1"), nr.LibroID).Scan(&vivo); err != nil {
+			return err
+		}
+		if !vivo {
+			return ErrNotFound
+		}
+
+		// si habia ejemplares libres primero van para los que ya esperaban
+		if _, err := atenderLibro(ctx, tx, nr.LibroID, nil, nr.RetirarHasta); err != nil {
+			return err
+		}
+
+		var libres bool
+		err := tx.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM ejemplares e WHERE e.libro_id = $1 AND "+ejemplarLibre+")",
+			nr.LibroID).Scan(&libres)
+		if err != nil {
+			return err
+		}
+		if libres {
+			return ErrHayDisponibles
+		}
+
+		var id int
+		err = tx.QueryRow(ctx,
+			"INSERT INTO reservas (libro_id, socio_id, creada) VALUES ($1, $2, $3) RETURNING id",
+			nr.LibroID, nr.SocioID, nr.Creada).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		result, err = getReserva(ctx, tx, id, false)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrHayDisponibles):
+		return nil, err
+	case isForeignKeyViolation(err):
+		return nil, ErrSocioNotFound // el libro ya estaba bloqueado, la que falla es la del socio
+	case isUniqueViolation(err):
+		return nil, ErrReservaDuplicada
+	case err != nil:
+		return nil, reservasError(ctx, "Reservar", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresReservasRepo) Cancelar(ctx context.Context, id int, retirarHasta models.Fecha) (*models.Reserva, error) {
+	// el libro se lee sin lock: hay que bloquearlo antes que la reserva para respetar el orden
+	// libro, ejemplares, reservas que usan todas las operaciones
+	var libroID int
+	err := repo.DB.QueryRow(ctx, "SELECT libro_id FROM reservas WHERE id = $1", id).Scan(&libroID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReservaNotFound
+	}
+	if err != nil {
+		return nil, reservasError(ctx, "Cancelar", err)
+	}
+
+	var result *models.Reserva
+
+	err = pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		if err := bloquearLibro(ctx, tx, libroID); err != nil {
+			return err
+		}
+		if err := bloquearEjemplares(ctx, tx, libroID); err != nil {
+			return err
+		}
+
+		r, err := getReserva(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if !r.Activa() {
+			return ErrReservaCerrada
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE reservas SET estado = 'cancelada' WHERE id = $1", id); err != nil {
+			return err
+		}
+
+		// si tenia un ejemplar apartado, ahora esta libre y le toca al siguiente
+		if _, err := atenderLibro(ctx, tx, libroID, nil, retirarHasta); err != nil {
+			return err
+		}
+
+		result, err = getReserva(ctx, tx, id, false)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, ErrReservaNotFound // se borro el libro, y con el la reserva
+	case errors.Is(err, ErrReservaNotFound), errors.Is(err, ErrReservaCerrada):
+		return nil, err
+	case err != nil:
+		return nil, reservasError(ctx, "Cancelar", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresReservasRepo) Atender(ctx context.Context, hoy, retirarHasta models.Fecha) ([]models.Reserva, error) {
+	rows, err := repo.DB.Query(ctx, `
+		SELECT DISTINCT libro_id FROM reservas
+		 WHERE estado = 'esperando' OR (estado = 'disponible' AND retirar_hasta < $1)
+		 ORDER BY libro_id`, hoy)
+	if err != nil {
+		return nil, reservasError(ctx, "Atender", err)
+	}
+
+	libros, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, reservasError(ctx, "Atender", err)
+	}
+
+	// una transaccion por libro: un libro con problemas no frena a los demas y los locks duran poco
+	cambiadas := []int{}
+	for _, libroID := range libros {
+		err := pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+			if err := bloquearLibro(ctx, tx, libroID); err != nil {
+				return err
+			}
+
+			ids, err := atenderLibro(ctx, tx, libroID, &hoy, retirarHasta)
+			cambiadas = append(cambiadas, ids...)
+			return err
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue // el libro se borro mientras tanto
+		}
+		if err != nil {
+			return nil, reservasError(ctx, "Atender", err)
+		}
+	}
+
+	rows, err = repo.DB.Query(ctx, `SELECT `+reservaCols+` FROM reservas r WHERE r.id = ANY($1) ORDER BY r.id`, cambiadas)
+	if err != nil {
+		return nil, reservasError(ctx, "Atender", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanReserva)
+	if err != nil {
+		return nil, reservasError(ctx, "Atender", err)
+	}
+
+	return result, nil
+}
+
+// bloquearLibro serializa las operaciones sobre la cola de un libro. ErrNotFound si no existe.
+// NO KEY UPDATE no choca con los FK de ejemplares o reservas que apuntan al libro.
+func bloquearLibro(ctx context.Context, tx pgx.Tx, libroID int) error {
+	var id int
+	err := tx.QueryRow(ctx, "SELECT id FROM libros WHERE id = $1 FOR NO KEY UPDATE", libroID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// bloquearEjemplares toma los ejemplares del libro, que Prestar bloquea de a uno. Despues de esto la
+// proxima sentencia (en READ COMMITTED cada una ve los datos confirmados hasta ese momento) ya ve
+// los prestamos que se hicieron mientras esperabamos.
+func bloquearEjemplares(ctx context.Context, tx pgx.Tx, libroID int) error {
+	_, err := tx.Exec(ctx, "SELECT id FROM ejemplares WHERE libro_id = $1 ORDER BY id FOR UPDATE", libroID)
+	return err
+}
+
+// atenderLibro vence las reservas disponibles que no se retiraron antes de hoy (si hoy no es nil) y le
+// aparta cada ejemplar libre del libro a una reserva que espera, en orden de llegada. Hay que tener
+// el libro bloqueado. Devuelve los ids de las reservas que cambiaron.
+func atenderLibro(ctx context.Context, tx pgx.Tx, libroID int, hoy *models.Fecha, retirarHasta models.Fecha) ([]int, error) {
+	if err := bloquearEjemplares(ctx, tx, libroID); err != nil {
+		return nil, err
+	}
+
+	cambiadas := []int{}
+
+	if hoy != nil {
+		rows, _ := tx.Query(ctx, `
+			UPDATE reservas SET estado = 'vencida'
+			 WHERE libro_id = $1 AND estado = 'disponible' AND retirar_hasta < $2
+			RETURNING id`, libroID, *hoy)
+		vencidas, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return nil, err
+		}
+		cambiadas = append(cambiadas, vencidas...)
+	}
+
+	// el n-esimo ejemplar libre para la n-esima reserva de la cola
+	rows, _ := tx.Query(ctx, `
+		WITH libres AS (
+			SELECT e.id, row_number() OVER (ORDER BY e.id) AS n
+			  FROM ejemplares e
+			 WHERE e.libro_id = $1 AND `+ejemplarLibre+`
+		), cola AS (
+			SELECT id, row_number() OVER (ORDER BY id) AS n
+			  FROM reservas
+			 WHERE libro_id = $1 AND estado = 'esperando'
+		)
+		UPDATE reservas r
+		   SET estado = 'disponible', ejemplar_id = libres.id, retirar_hasta = $2
+		  FROM cola JOIN libres USING (n)
+		 WHERE r.id = cola.id
+		RETURNING r.id`, libroID, retirarHasta)
+	asignadas, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(cambiadas, asignadas...), nil
+}
+
+// getReserva lee una reserva. Con forUpdate bloquea la fila hasta el final de la transaccion.
+func getReserva(ctx context.Context, q querier, id int, forUpdate bool) (*models.Reserva, error) {
+	query := `SELECT ` + reservaCols + ` FROM reservas r WHERE r.id = $1`
+	if forUpdate {
+		query += " FOR UPDATE OF r"
+	}
+
+	rows, _ := q.Query(ctx, query, id)
+	r, err := pgx.CollectExactlyOneRow(rows, scanReserva)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReservaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func scanReserva(row pgx.CollectableRow) (models.Reserva, error) {
+	var r models.Reserva
+	err := row.Scan(&r.ID, &r.LibroID, &r.SocioID, &r.Estado, &r.Creada, &r.Posicion, &r.EjemplarID, &r.RetirarHasta)
+	return r, err
+}
+
+func reservasError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "reservas", op, err)
+}
@@ -0,0 +1,498 @@
+package repository
+
+import (
+	"api-libros/models"
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// MemoryReservasRepo es la version en memoria de PostgresReservasRepo. Se engancha al repo de prestamos
+// (devolver le aparta el ejemplar al primero de la cola, prestar retira la reserva), al de ejemplares
+// y al de socios. Los locks van en el orden de MemoryPrestamosRepo: socios, ejemplares, prestamos, reservas.
+type MemoryReservasRepo struct {
+	mu       sync.RWMutex
+	reservas map[int]models.Reserva
+	nextID   int
+
+	prestamos *MemoryPrestamosRepo
+}
+
+// NewMemoryReservasRepo, como NewMemoryPrestamosRepo, hay que llamarlo antes de usar los otros repos.
+func NewMemoryReservasRepo(prestamos *MemoryPrestamosRepo) *MemoryReservasRepo {
+	repo := &MemoryReservasRepo{
+		reservas:  map[int]models.Reserva{},
+		nextID:    1,
+		prestamos: prestamos,
+	}
+
+	prestamos.reservas = repo
+	prestamos.ejemplares.apartado = repo.apartado
+	prestamos.ejemplares.enEspera = repo.enEspera
+	prestamos.ejemplares.atender = repo.atenderNuevo
+	prestamos.socios.borrarReservas = repo.borrarDeSocio
+	if libros, ok := prestamos.ejemplares.libros.(*MemoryLibrosRepo); ok {
+		libros.circulacion = repo.circulacion
+	}
+
+	return repo
+}
+
+// circulacion reemplaza el hook de libros de prestamos: a los ejemplares prestados les suma los
+// apartados (ErrEjemplarReservado), con nuestro lock tomado despues de los de prestamos
+func (repo *MemoryReservasRepo) circulacion(fn func(ocupado func(libroID int) error) error) error {
+	return repo.prestamos.circulacion(func(prestado func(int) error) error {
+		repo.mu.RLock()
+		defer repo.mu.RUnlock()
+
+		return fn(func(libroID int) error {
+			if err := prestado(libroID); err != nil {
+				return err
+			}
+			for _, r := range repo.reservas {
+				if r.LibroID == libroID && r.Estado == models.ReservaDisponible {
+					return ErrEjemplarReservado
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// lock toma los locks de ejemplares y prestamos para leer y el nuestro para leer o escribir.
+// Devuelve la funcion que los suelta.
+func (repo *MemoryReservasRepo) lock(escribir bool) func() {
+	repo.prestamos.ejemplares.mu.RLock()
+	repo.prestamos.mu.RLock()
+
+	if escribir {
+		repo.mu.Lock()
+	} else {
+		repo.mu.RLock()
+	}
+
+	return func() {
+		if escribir {
+			repo.mu.Unlock()
+		} else {
+			repo.mu.RUnlock()
+		}
+		repo.prestamos.mu.RUnlock()
+		repo.prestamos.ejemplares.mu.RUnlock()
+	}
+}
+
+func (repo *MemoryReservasRepo) GetAll(ctx context.Context, f models.ReservaFilter) ([]models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.lock(false)()
+
+	result, err := repo.filtrar(ctx, func(r models.Reserva) bool {
+		if f.SocioID != nil && r.SocioID != *f.SocioID {
+			return false
+		}
+		if f.LibroID != nil && r.LibroID != *f.LibroID {
+			return false
+		}
+		return !f.Activas || r.Activa()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func (repo *MemoryReservasRepo) GetByLibro(ctx context.Context, libroID int) ([]models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.prestamos.ejemplares.libros.GetByID(ctx, libroID); err != nil {
+		return nil, err
+	}
+
+	defer repo.lock(false)()
+
+	result, err := repo.filtrar(ctx, func(r models.Reserva) bool {
+		return r.LibroID == libroID && r.Activa()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// primero las que ya tienen ejemplar, despues la cola en orden de llegada
+	slices.SortStableFunc(result, func(a, b models.Reserva) int {
+		return cmp.Compare(enCola(a), enCola(b))
+	})
+
+	return result, nil
+}
+
+func (repo *MemoryReservasRepo) GetByID(ctx context.Context, id int) (*models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.lock(false)()
+
+	return repo.get(ctx, id)
+}
+
+func (repo *MemoryReservasRepo) Reservar(ctx context.Context, nr models.NuevaReserva) (*models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := repo.prestamos.ejemplares.libros.GetByID(ctx, nr.LibroID); err != nil {
+		return nil, err
+	}
+
+	socios := repo.prestamos.socios
+	socios.mu.RLock()
+	defer socios.mu.RUnlock()
+
+	defer repo.lock(true)()
+
+	// si habia ejemplares libres primero van para los que ya esperaban
+	if _, err := repo.atender(ctx, nr.LibroID, nil, nr.RetirarHasta, repo.prestamos.activo); err != nil {
+		return nil, err
+	}
+
+	libres, err := repo.libres(ctx, nr.LibroID, repo.prestamos.activo)
+	if err != nil {
+		return nil, err
+	}
+	if len(libres) > 0 {
+		return nil, ErrHayDisponibles
+	}
+
+	if _, ok := socios.socios[nr.SocioID]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	for _, r := range repo.reservas {
+		if r.LibroID != nr.LibroID || r.SocioID != nr.SocioID || !r.Activa() {
+			continue
+		}
+		viva, err := repo.viva(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if viva {
+			return nil, ErrReservaDuplicada
+		}
+	}
+
+	r := models.Reserva{
+		ID:      repo.nextID,
+		LibroID: nr.LibroID,
+		SocioID: nr.SocioID,
+		Estado:  models.ReservaEsperando,
+		Creada:  nr.Creada,
+	}
+	repo.nextID++
+	repo.reservas[r.ID] = r
+
+	return repo.get(ctx, r.ID)
+}
+
+func (repo *MemoryReservasRepo) Cancelar(ctx context.Context, id int, retirarHasta models.Fecha) (*models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.lock(true)()
+
+	r, err := repo.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Activa() {
+		return nil, ErrReservaCerrada
+	}
+
+	r.Estado = models.ReservaCancelada
+	repo.reservas[id] = *r
+
+	// si tenia un ejemplar apartado, ahora esta libre y le toca al siguiente
+	if _, err := repo.atender(ctx, r.LibroID, nil, retirarHasta, repo.prestamos.activo); err != nil {
+		return nil, err
+	}
+
+	return repo.get(ctx, id)
+}
+
+func (repo *MemoryReservasRepo) Atender(ctx context.Context, hoy, retirarHasta models.Fecha) ([]models.Reserva, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	defer repo.lock(true)()
+
+	libros := []int{}
+	for _, r := range repo.reservas {
+		if r.Activa() && !slices.Contains(libros, r.LibroID) {
+			libros = append(libros, r.LibroID)
+		}
+	}
+	slices.Sort(libros)
+
+	cambiadas := []int{}
+	for _, libroID := range libros {
+		ids, err := repo.atender(ctx, libroID, &hoy, retirarHasta, repo.prestamos.activo)
+		if err != nil {
+			return nil, err
+		}
+		cambiadas = append(cambiadas, ids...)
+	}
+
+	return repo.filtrar(ctx, func(r models.Reserva) bool {
+		return slices.Contains(cambiadas, r.ID)
+	})
+}
+
+// atender es atenderLibro de postgres: vence las disponibles que no se retiraron antes de hoy (si hoy
+// no es nil) y le aparta cada ejemplar libre del libro a una reserva que espera, en orden de llegada.
+// Hay que tener nuestro lock para escribir y los de ejemplares y prestamos; prestado es la version
+// de MemoryPrestamosRepo.activo que se puede llamar con esos locks.
+func (repo *MemoryReservasRepo) atender(ctx context.Context, libroID int, hoy *models.Fecha, retirarHasta models.Fecha, prestado func(int) bool) ([]int, error) {
+	_, err := repo.prestamos.ejemplares.libros.GetByIDConBorrados(ctx, libroID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil // se purgo el libro y con el su cola
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cambiadas := []int{}
+
+	if hoy != nil {
+		for id, r := range repo.reservas {
+			if r.LibroID == libroID && r.Estado == models.ReservaDisponible && r.RetirarHasta.Before(hoy.Time) {
+				r.Estado = models.ReservaVencida
+				repo.reservas[id] = r
+				cambiadas = append(cambiadas, id)
+			}
+		}
+	}
+
+	libres, err := repo.libres(ctx, libroID, prestado)
+	if err != nil {
+		return nil, err
+	}
+
+	cola, err := repo.filtrar(ctx, func(r models.Reserva) bool {
+		return r.LibroID == libroID && r.Estado == models.ReservaEsperando
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range min(len(libres), len(cola)) {
+		r := repo.reservas[cola[i].ID]
+		r.Estado = models.ReservaDisponible
+		r.EjemplarID = libres[i]
+		r.RetirarHasta = retirarHasta
+		repo.reservas[r.ID] = r
+		cambiadas = append(cambiadas, r.ID)
+	}
+
+	return cambiadas, nil
+}
+
+// libres son los ejemplares del libro que no estan prestados ni apartados, por id. Mismos locks que atender.
+func (repo *MemoryReservasRepo) libres(ctx context.Context, libroID int, prestado func(int) bool) ([]int, error) {
+	libres := []int{}
+
+	for _, e := range repo.prestamos.ejemplares.ejemplares {
+		if e.LibroID != libroID || prestado(e.ID) {
+			continue
+		}
+
+		apartado, err := repo.apartadoLocked(ctx, e.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !apartado {
+			libres = append(libres, e.ID)
+		}
+	}
+
+	slices.Sort(libres)
+	return libres, nil
+}
+
+// atenderNuevo es el hook de ejemplares para Create: lo llaman con el lock de ejemplares tomado y le
+// aparta el ejemplar nuevo al primero de la cola, como atenderLibro en postgres.
+func (repo *MemoryReservasRepo) atenderNuevo(ctx context.Context, libroID int, retirarHasta models.Fecha) error {
+	repo.prestamos.mu.RLock()
+	defer repo.prestamos.mu.RUnlock()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, err := repo.atender(ctx, libroID, nil, retirarHasta, repo.prestamos.activo)
+	return err
+}
+
+// retirar lo llama Prestar con sus locks tomados. Primero los ejemplares libres del libro pasan a la
+// cola; si el ejemplar quedo apartado solo se lo puede llevar el socio de la reserva, que queda retirada.
+func (repo *MemoryReservasRepo) retirar(ctx context.Context, libroID, ejemplarID, socioID int, retirarHasta models.Fecha) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, err := repo.atender(ctx, libroID, nil, retirarHasta, repo.prestamos.activo); err != nil {
+		return err
+	}
+
+	for id, r := range repo.reservas {
+		if r.EjemplarID != ejemplarID || r.Estado != models.ReservaDisponible {
+			continue
+		}
+
+		viva, err := repo.viva(ctx, r)
+		if err != nil {
+			return err
+		}
+		if !viva {
+			continue
+		}
+		if r.SocioID != socioID {
+			return ErrEjemplarReservado
+		}
+
+		r.Estado = models.ReservaRetirada
+		repo.reservas[id] = r
+	}
+
+	return nil
+}
+
+// apartado es el hook de ejemplares: lo llaman con el lock de ejemplares tomado.
+func (repo *MemoryReservasRepo) apartado(ctx context.Context, ejemplarID int) (bool, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	return repo.apartadoLocked(ctx, ejemplarID)
+}
+
+func (repo *MemoryReservasRepo) apartadoLocked(ctx context.Context, ejemplarID int) (bool, error) {
+	for _, r := range repo.reservas {
+		if r.EjemplarID == ejemplarID && r.Estado == models.ReservaDisponible {
+			return repo.viva(ctx, r)
+		}
+	}
+	return false, nil
+}
+
+// enEspera es el otro hook de ejemplares, para la disponibilidad: cuantos esperan en la cola del libro.
+func (repo *MemoryReservasRepo) enEspera(ctx context.Context, libroID int) (int, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	cola, err := repo.filtrar(ctx, func(r models.Reserva) bool {
+		return r.LibroID == libroID && r.Estado == models.ReservaEsperando
+	})
+	return len(cola), err
+}
+
+// borrarDeSocio es el hook de socios, el ON DELETE CASCADE. Lo llaman con el lock de socios tomado.
+func (repo *MemoryReservasRepo) borrarDeSocio(socioID int) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for id, r := range repo.reservas {
+		if r.SocioID == socioID {
+			delete(repo.reservas, id)
+		}
+	}
+}
+
+func enCola(r models.Reserva) int {
+	if r.Estado == models.ReservaEsperando {
+		return 1
+	}
+	return 0
+}
+
+// get busca una reserva que siga existiendo. Hay que tener el lock de ejemplares y el nuestro.
+func (repo *MemoryReservasRepo) get(ctx context.Context, id int) (*models.Reserva, error) {
+	r, ok := repo.reservas[id]
+	if !ok {
+		return nil, ErrReservaNotFound
+	}
+
+	viva, err := repo.viva(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	if !viva {
+		return nil, ErrReservaNotFound
+	}
+
+	r.Posicion = repo.posicion(r)
+	return &r, nil
+}
+
+// filtrar devuelve las reservas vivas que cumplen match, por id y con la posicion calculada. Mismos locks que get.
+func (repo *MemoryReservasRepo) filtrar(ctx context.Context, match func(models.Reserva) bool) ([]models.Reserva, error) {
+	result := []models.Reserva{}
+
+	for _, r := range repo.reservas {
+		if !match(r) {
+			continue
+		}
+
+		viva, err := repo.viva(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if viva {
+			r.Posicion = repo.posicion(r)
+			result = append(result, r)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Reserva) int { return a.ID - b.ID })
+	return result, nil
+}
+
+// posicion es el lugar en la cola: cuantas del mismo libro esperan desde antes, contandose a si misma.
+// Las reservas de un libro borrado no importan: ya no se ven.
+func (repo *MemoryReservasRepo) posicion(r models.Reserva) int {
+	if r.Estado != models.ReservaEsperando {
+		return 0
+	}
+
+	n := 0
+	for _, o := range repo.reservas {
+		if o.LibroID == r.LibroID && o.Estado == models.ReservaEsperando && o.ID <= r.ID {
+			n++
+		}
+	}
+	return n
+}
+
+// viva: en postgres la reserva se habria ido en cascada con su libro (al purgarlo) o su ejemplar. Hay
+// que tener el lock de ejemplares.
+func (repo *MemoryReservasRepo) viva(ctx context.Context, r models.Reserva) (bool, error) {
+	_, err := repo.prestamos.ejemplares.libros.GetByIDConBorrados(ctx, r.LibroID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if r.EjemplarID == 0 {
+		return true, nil
+	}
+
+	_, ok := repo.prestamos.ejemplares.ejemplares[r.EjemplarID]
+	return ok, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"api-libros/actor"
+	"api-libros/models"
+	"api-libros/requestid"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const revisionCols = `libro_id, rev, operacion, antes, despues, actor, request_id, fecha`
+
+// registrarRevision guarda el cambio de antes a despues en tx, con el actor y el request id del context.
+// La fecha es el updated_at que dejo el cambio.
+func registrarRevision(ctx context.Context, tx pgx.Tx, op string, antes, despues *models.Libro) error {
+	_, err := tx.Exec(ctx, insertRevision, revisionArgs(ctx, op, antes, despues)...)
+	return err
+}
+
+const insertRevision = "INSERT INTO libro_revisiones (" + revisionCols + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+
+// revisionArgs son los argumentos de insertRevision. Los libros van como *models.Libro y pgx los pasa a
+// JSON con encoding/json: el historial guarda lo mismo que devuelve la API.
+func revisionArgs(ctx context.Context, op string, antes, despues *models.Libro) []any {
+	return []any{despues.ID, despues.Version, op, antes, despues,
+		actor.FromContext(ctx), requestid.FromContext(ctx), despues.Modificado}
+}
+
+// scanRevision lee una fila de revisionCols. El JSON de los libros no tiene version ni fecha de
+// modificacion; se completan con rev y fecha para que despues sirva como el libro de ese momento.
+func scanRevision(row pgx.CollectableRow) (models.Revision, error) {
+	var r models.Revision
+
+	err := row.Scan(&r.LibroID, &r.Rev, &r.Operacion, &r.Antes, &r.Despues, &r.Actor, &r.RequestID, &r.Fecha)
+	if err != nil {
+		return r, err
+	}
+
+	r.Despues.Version = r.Rev
+	r.Despues.Modificado = r.Fecha
+	if r.Antes != nil {
+		r.Antes.Version = r.Rev - 1
+	}
+
+	return r, nil
+}
+
+func (repo *PostgresLibrosRepo) Historial(ctx context.Context, id int, f models.RevisionFilter) ([]models.Revision, error) {
+	rows, err := repo.DB.Query(ctx,
+		"SELECT "+revisionCols+" FROM libro_revisiones WHERE libro_id = $1 ORDER BY rev DESC LIMIT $2 OFFSET $3",
+		id, f.Limit, f.Offset)
+	if err != nil {
+		return nil, dbError(ctx, "Historial", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanRevision)
+	if err != nil {
+		return nil, dbError(ctx, "Historial", err)
+	}
+
+	if len(result) == 0 {
+		// sin revisiones puede ser un libro de antes del historial, o uno que no existe
+		var existe bool
+		err := repo.DB.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM libros WHERE id = $1)
+			    OR EXISTS (SELECT 1 FROM libro_revisiones WHERE libro_id = $1)`, id).
+			Scan(&existe)
+		if err != nil {
+			return nil, dbError(ctx, "Historial", err)
+		}
+		if !existe {
+			return nil, ErrNotFound
+		}
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresLibrosRepo) EnFecha(ctx context.Context, id int, t time.Time) (*models.Libro, error) {
+	rows, err := repo.DB.Query(ctx,
+		"SELECT "+revisionCols+" FROM libro_revisiones WHERE libro_id = $1 AND fecha <= $2 ORDER BY rev DESC LIMIT 1",
+		id, t)
+	if err != nil {
+		return nil, dbError(ctx, "EnFecha", err)
+	}
+
+	r, err := pgx.CollectExactlyOneRow(rows, scanRevision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, dbError(ctx, "EnFecha", err)
+	}
+
+	return &r.Despues, nil
+}
+
+func (repo *PostgresLibrosRepo) Revertir(ctx context.Context, id, rev, version int) (*models.Libro, error) {
+	rows, err := repo.DB.Query(ctx,
+		"SELECT "+revisionCols+" FROM libro_revisiones WHERE libro_id = $1 AND rev = $2", id, rev)
+	if err != nil {
+		return nil, dbError(ctx, "Revertir", err)
+	}
+
+	r, err := pgx.CollectExactlyOneRow(rows, scanRevision)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := repo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, dbError(ctx, "Revertir", err)
+	}
+
+	// las revisiones no cambian, no hace falta leerla en la misma transaccion que el update
+	return repo.update(ctx, id, version, inputDe(r.Despues), models.RevisionRevertir)
+}
+
+// inputDe son los datos editables de l, para volver a escribirlos
+func inputDe(l models.Libro) models.LibroInput {
+	return models.LibroInput{Titulo: l.Titulo, Autor: l.Autor, Ano: l.Ano, ISBN: l.ISBN}
+}
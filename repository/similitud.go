@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Busqueda fuzzy (?fuzzy=true) y /libros/suggest con trigramas de pg_trgm (migracion 0004).
+// Como en busqueda.go, aca estan el SQL y la misma cuenta hecha en Go para el repo en memoria.
+
+// trgmWhere: la busqueda se parece a alguna parte del titulo o del autor. <% usa los indices
+// GIN de la migracion, por eso la expresion tiene que ser exactamente f_unaccent(columna).
+func trgmWhere(n int) string {
+	return fmt.Sprintf("(f_unaccent($%d) <%% f_unaccent(titulo) OR f_unaccent($%d) <%% f_unaccent(autor))", n, n)
+}
+
+// trgmScore es el puntaje para ordenar: la mejor similitud entre titulo y autor
+func trgmScore(n int) string {
+	return fmt.Sprintf("GREATEST(word_similarity(f_unaccent($%d), f_unaccent(titulo)), word_similarity(f_unaccent($%d), f_unaccent(autor)))", n, n)
+}
+
+// querier es lo que tienen en comun *pgxpool.Pool y pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// conUmbral corre fn con pg_trgm.word_similarity_threshold = threshold. El operador <% no recibe
+// el umbral como parametro, lee ese setting; con set_config(..., true) vale solo para esta transaccion.
+func (repo *PostgresLibrosRepo) conUmbral(ctx context.Context, threshold float64, fn func(q querier) error) error {
+	return pgx.BeginFunc(ctx, repo.DB, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)",
+			strconv.FormatFloat(threshold, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// conFiltro corre fn en una transaccion con el umbral si f es una busqueda fuzzy, y directo en el pool si no.
+func (repo *PostgresLibrosRepo) conFiltro(ctx context.Context, f models.LibroFilter, fn func(q querier) error) error {
+	if f.Fuzzy && f.Q != nil {
+		return repo.conUmbral(ctx, f.FuzzyThreshold, fn)
+	}
+	return fn(repo.DB)
+}
+
+// trigramas arma los trigramas como pg_trgm: cada palabra (sin tildes y en minuscula) se rellena
+// con dos espacios adelante y uno atras, "dos" => "  d", " do", "dos", "os ". Respeta el orden del texto.
+func trigramas(s string) []string {
+	var out []string
+
+	for _, w := range palabrasNormalizadas(s) {
+		r := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			out = append(out, string(r[i:i+3]))
+		}
+	}
+
+	return out
+}
+
+// wordSimilarity imita word_similarity(a, b) de pg_trgm: la mayor similitud entre los trigramas de a
+// y algun tramo continuo de los trigramas de b. word_similarity('word', 'two words') = 0.8
+func wordSimilarity(a, b string) float64 {
+	ta := map[string]bool{}
+	for _, t := range trigramas(a) {
+		ta[t] = true
+	}
+	if len(ta) == 0 {
+		return 0
+	}
+
+	tb := trigramas(b)
+	var best float32
+
+	for i := range tb {
+		extent := map[string]bool{}
+		common := 0
+
+		for j := i; j < len(tb); j++ {
+			if extent[tb[j]] {
+				continue
+			}
+			extent[tb[j]] = true
+			if ta[tb[j]] {
+				common++
+			}
+
+			// postgres hace la cuenta en float4, la hago igual para que los empates sean los mismos
+			sml := float32(common) / float32(len(ta)+len(extent)-common)
+			best = max(best, sml)
+		}
+	}
+
+	return float64(best)
+}
+
+// fuzzyScore es trgmScore en Go
+func fuzzyScore(q string, l models.Libro) float64 {
+	return max(wordSimilarity(q, l.Titulo), wordSimilarity(q, l.Autor))
+}
@@ -0,0 +1,28 @@
+package repository
+
+import "testing"
+
+func TestWordSimilarity_TableDriven(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{"word", "two words", 0.79, 0.81}, // el ejemplo de la documentacion de pg_trgm
+		{"word", "word", 1, 1},
+		{"dostoyevski", "Fiódor Dostoievski", 0.5, 0.7},
+		{"saint exupery", "Antoine de Saint-Exupéry", 0.9, 1},
+		{"dos", "Fiódor Dostoievski", 0.7, 0.8},
+		{"tolkien", "Fiódor Dostoievski", 0, 0.1},
+		{"", "Dune", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			got := wordSimilarity(tt.a, tt.b)
+			if got < tt.min || got > tt.max {
+				t.Fatalf("wordSimilarity(%q, %q) = %v, esperaba entre %v y %v", tt.a, tt.b, got, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+)
+
+var (
+	ErrSocioNotFound     = errors.New("socio not found")
+	ErrSocioDuplicado    = errors.New("ya existe un socio con ese email")
+	ErrSocioConPrestamos = errors.New("el socio tiene prestamos")
+)
+
+type SociosRepository interface {
+	GetAll(ctx context.Context, filter models.SocioFilter) ([]models.Socio, error)
+	GetByID(ctx context.Context, id int) (*models.Socio, error)
+	// Create y Update devuelven ErrSocioDuplicado si el email ya lo usa otro socio (sin distinguir mayusculas)
+	Create(ctx context.Context, in models.SocioInput) (*models.Socio, error)
+	Update(ctx context.Context, id int, upd models.SocioInput) (*models.Socio, error)
+	// Delete devuelve ErrSocioConPrestamos si tuvo algun prestamo, aunque ya lo haya devuelto, y
+	// ErrSocioConMultas si tiene movimientos de multas (de prestamos que ya no existen)
+	Delete(ctx context.Context, id int) error
+}
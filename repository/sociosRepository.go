@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresSociosRepo struct {
+	DB *pgxpool.Pool
+}
+
+func NewPostgresSociosRepo(db *pgxpool.Pool) *PostgresSociosRepo {
+	return &PostgresSociosRepo{DB: db}
+}
+
+func (repo *PostgresSociosRepo) GetAll(ctx context.Context, f models.SocioFilter) ([]models.Socio, error) {
+	query := `SELECT id, nombre, email FROM socios WHERE 1=1`
+	args := []any{}
+	i := 1
+
+	if f.Nombre != nil {
+		query += fmt.Sprintf(" AND nombre ILIKE $%d", i)
+		args = append(args, "%"+*f.Nombre+"%")
+		i++
+	}
+
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", i, i+1)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := repo.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, sociosError(ctx, "GetAll", err)
+	}
+
+	result, err := pgx.CollectRows(rows, scanSocio)
+	if err != nil {
+		return nil, sociosError(ctx, "GetAll", err)
+	}
+
+	return result, nil
+}
+
+func (repo *PostgresSociosRepo) GetByID(ctx context.Context, id int) (*models.Socio, error) {
+	rows, _ := repo.DB.Query(ctx, "SELECT id, nombre, email FROM socios WHERE id = $1", id)
+
+	s, err := pgx.CollectExactlyOneRow(rows, scanSocio)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSocioNotFound
+	}
+	if err != nil {
+		return nil, sociosError(ctx, "GetByID", err)
+	}
+
+	return &s, nil
+}
+
+func (repo *PostgresSociosRepo) Create(ctx context.Context, in models.SocioInput) (*models.Socio, error) {
+	rows, _ := repo.DB.Query(ctx,
+		"INSERT INTO socios (nombre, email) VALUES ($1, $2) RETURNING id, nombre, email",
+		in.Nombre, in.Email)
+
+	s, err := pgx.CollectExactlyOneRow(rows, scanSocio)
+	if isUniqueViolation(err) {
+		return nil, ErrSocioDuplicado
+	}
+	if err != nil {
+		return nil, sociosError(ctx, "Create", err)
+	}
+
+	return &s, nil
+}
+
+func (repo *PostgresSociosRepo) Update(ctx context.Context, id int, upd models.SocioInput) (*models.Socio, error) {
+	rows, _ := repo.DB.Query(ctx,
+		"UPDATE socios SET nombre = $1, email = $2 WHERE id = $3 RETURNING id, nombre, email",
+		upd.Nombre, upd.Email, id)
+
+	s, err := pgx.CollectExactlyOneRow(rows, scanSocio)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSocioNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, ErrSocioDuplicado
+	}
+	if err != nil {
+		return nil, sociosError(ctx, "Update", err)
+	}
+
+	return &s, nil
+}
+
+func (repo *PostgresSociosRepo) Delete(ctx context.Context, id int) error {
+	result, err := repo.DB.Exec(ctx, "DELETE FROM socios WHERE id = $1", id)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		if pgErr.ConstraintName == "multas_socio_id_fkey" {
+			return ErrSocioConMultas
+		}
+		return ErrSocioConPrestamos // ON DELETE RESTRICT de prestamos
+	}
+	if err != nil {
+		return sociosError(ctx, "Delete", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrSocioNotFound
+	}
+	return nil
+}
+
+func scanSocio(row pgx.CollectableRow) (models.Socio, error) {
+	var s models.Socio
+	err := row.Scan(&s.ID, &s.Nombre, &s.Email)
+	return s, err
+}
+
+func sociosError(ctx context.Context, op string, err error) error {
+	return repoError(ctx, "socios", op, err)
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"api-libros/models"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemorySociosRepo es la version en memoria de PostgresSociosRepo.
+type MemorySociosRepo struct {
+	mu     sync.RWMutex
+	socios map[int]models.Socio
+	nextID int
+
+	// tienePrestamos lo pone NewMemoryPrestamosRepo, es el ON DELETE RESTRICT de prestamos.
+	// borrarReservas lo pone NewMemoryReservasRepo, es el ON DELETE CASCADE de reservas.
+	// tieneMultas lo pone NewMemoryMultasRepo, es el ON DELETE RESTRICT de multas.
+	// Se llaman con repo.mu tomado
+	tienePrestamos func(ctx context.Context, socioID int) (bool, error)
+	borrarReservas func(socioID int)
+	tieneMultas    func(socioID int) bool
+}
+
+func NewMemorySociosRepo() *MemorySociosRepo {
+	return &MemorySociosRepo{
+		socios: map[int]models.Socio{},
+		nextID: 1,
+	}
+}
+
+func (repo *MemorySociosRepo) GetAll(ctx context.Context, f models.SocioFilter) ([]models.Socio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var nombre *regexp.Regexp
+	if f.Nombre != nil {
+		nombre = ilikeContains(*f.Nombre)
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	result := []models.Socio{}
+	for _, s := range repo.socios {
+		if nombre == nil || nombre.MatchString(s.Nombre) {
+			result = append(result, s)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b models.Socio) int { return a.ID - b.ID })
+
+	return paginar(result, f.Limit, f.Offset)
+}
+
+func (repo *MemorySociosRepo) GetByID(ctx context.Context, id int) (*models.Socio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+
+	s, ok := repo.socios[id]
+	if !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	return &s, nil
+}
+
+func (repo *MemorySociosRepo) Create(ctx context.Context, in models.SocioInput) (*models.Socio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.emailUsado(in.Email, 0) {
+		return nil, ErrSocioDuplicado
+	}
+
+	s := models.Socio{ID: repo.nextID, Nombre: in.Nombre, Email: in.Email}
+	repo.nextID++
+
+	repo.socios[s.ID] = s
+	return &s, nil
+}
+
+func (repo *MemorySociosRepo) Update(ctx context.Context, id int, upd models.SocioInput) (*models.Socio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.socios[id]; !ok {
+		return nil, ErrSocioNotFound
+	}
+
+	if repo.emailUsado(upd.Email, id) {
+		return nil, ErrSocioDuplicado
+	}
+
+	s := models.Socio{ID: id, Nombre: upd.Nombre, Email: upd.Email}
+	repo.socios[id] = s
+	return &s, nil
+}
+
+func (repo *MemorySociosRepo) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if _, ok := repo.socios[id]; !ok {
+		return ErrSocioNotFound
+	}
+
+	if repo.tienePrestamos != nil {
+		tiene, err := repo.tienePrestamos(ctx, id)
+		if err != nil {
+			return err
+		}
+		if tiene {
+			return ErrSocioConPrestamos
+		}
+	}
+
+	if repo.tieneMultas != nil && repo.tieneMultas(id) {
+		return ErrSocioConMultas
+	}
+
+	if repo.borrarReservas != nil {
+		repo.borrarReservas(id)
+	}
+
+	delete(repo.socios, id)
+	return nil
+}
+
+// emailUsado es el indice unico sobre lower(email). Hay que tener el lock tomado.
+func (repo *MemorySociosRepo) emailUsado(email string, id int) bool {
+	for _, s := range repo.socios {
+		if strings.EqualFold(s.Email, email) && s.ID != id {
+			return true
+		}
+	}
+	return false
+}
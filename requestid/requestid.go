@@ -0,0 +1,29 @@
+// Package requestid guarda el id del request en el context, para que cualquier capa
+// (handlers, repositorios) pueda loguear con el mismo id que el access log.
+package requestid
+
+import (
+	"context"
+	"log/slog"
+)
+
+// tipo propio para la key, asi ningun otro paquete puede pisarla por accidente
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext devuelve el id del request o "" si no hay.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Logger devuelve el logger por defecto con el request_id ya agregado (si hay).
+func Logger(ctx context.Context) *slog.Logger {
+	if id := FromContext(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}